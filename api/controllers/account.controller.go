@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// AccountController handles account deletion and data export
+type AccountController struct {
+	logger  *core.Logger
+	service services.IAccountService
+}
+
+// NewAccountController creates new account controller
+func NewAccountController(logger *core.Logger, service services.IAccountService) *AccountController {
+	return &AccountController{
+		logger:  logger,
+		service: service,
+	}
+}
+
+// DeleteAccount schedules the erasure of the signed in user's account
+func (c *AccountController) DeleteAccount(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	eraseAt, err := c.service.ScheduleDeletion(userID)
+	if err != nil {
+		c.logger.Errorf("fail to schedule account deletion, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, models.HTTPResponse{
+		Message: "success",
+		Data: map[string]interface{}{
+			"erase_at": eraseAt.Unix(),
+		},
+	})
+}
+
+// RestoreAccount cancels a scheduled deletion
+func (c *AccountController) RestoreAccount(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = c.service.CancelDeletion(userID); err != nil {
+		if errors.Is(err, services.ErrAccountDeletionNotScheduled) {
+			ctx.JSON(http.StatusConflict, models.HTTPResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		c.logger.Errorf("fail to cancel account deletion, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// ExportAccount downloads everything we hold about the signed in user, as a zip archive
+// or, with ?format=json, as plain json
+func (c *AccountController) ExportAccount(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	export, err := c.service.Export(userID)
+	if err != nil {
+		c.logger.Errorf("fail to export account, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if ctx.Query("format") == "json" {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Message: "success",
+			Data:    export,
+		})
+		return
+	}
+
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="winglets-export-%s.zip"`, userID))
+	ctx.Status(http.StatusOK)
+
+	archive := zip.NewWriter(ctx.Writer)
+	file, err := archive.Create("account.json")
+	if err == nil {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(export)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		// the headers are gone already, all we can do is log
+		c.logger.Errorf("fail to write account export, user [%v], error [%v]", userID, err)
+	}
+}
@@ -1,8 +1,6 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"encoding/base32"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -12,19 +10,27 @@ import (
 	"github.com/hodukihugi/winglets-api/services"
 	"github.com/hodukihugi/winglets-api/utils"
 	"gorm.io/gorm"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
+// dummyPasswordHash is compared against when the email is unknown
+var dummyPasswordHash, _ = utils.HashPassword("winglets-dummy-password")
+
 // AuthController struct
 type AuthController struct {
-	logger      *core.Logger
-	service     services.IAuthService
-	userService services.IUserService
-	validator   *core.Validator
-	env         *core.Env
+	logger          *core.Logger
+	service         services.IAuthService
+	userService     services.IUserService
+	throttleService services.ILoginThrottleService
+	mailService     services.IMailService
+	oidcService     services.IOIDCService
+	mfaService      services.IMFAService
+	validator       *core.Validator
+	env             *core.Env
 }
 
 // NewAuthController creates new controller
@@ -32,15 +38,23 @@ func NewAuthController(
 	logger *core.Logger,
 	service services.IAuthService,
 	userService services.IUserService,
+	throttleService services.ILoginThrottleService,
+	mailService services.IMailService,
+	oidcService services.IOIDCService,
+	mfaService services.IMFAService,
 	validator *core.Validator,
 	env *core.Env,
 ) *AuthController {
 	return &AuthController{
-		logger:      logger,
-		service:     service,
-		userService: userService,
-		validator:   validator,
-		env:         env,
+		logger:          logger,
+		service:         service,
+		userService:     userService,
+		throttleService: throttleService,
+		mailService:     mailService,
+		oidcService:     oidcService,
+		mfaService:      mfaService,
+		validator:       validator,
+		env:             env,
 	}
 }
 
@@ -66,54 +80,420 @@ func (c *AuthController) SignIn(ctx *gin.Context) {
 		return
 	}
 
+	ip := ctx.ClientIP()
+	retryAfter, err := c.throttleService.Check(payload.Email, ip)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		c.logger.Errorf("fail to check login throttle, email [%v], error [%v]", payload.Email, err)
+		return
+	}
+	if retryAfter > 0 {
+		c.respondTooManyAttempts(ctx, retryAfter)
+		return
+	}
+
 	user, err := c.userService.First(models.OneUserFilter{Email: payload.Email})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		c.logger.Errorf("fail to sign in, email [%v], error [%v]", payload.Email, err)
+		return
+	}
+
+	// unknown emails still pay for a bcrypt comparison so both cases take the same time
+	hashedPassword := dummyPasswordHash
+	if user != nil && err == nil {
+		hashedPassword = user.Password
+	}
+
+	if utils.VerifyPassword(hashedPassword, payload.Password) != nil || err != nil {
+		retryAfter, err = c.throttleService.RecordFailure(payload.Email, ip)
+		if err != nil {
+			c.logger.Errorf("fail to record login failure, email [%v], error [%v]", payload.Email, err)
+		}
+		if retryAfter > 0 {
+			c.respondTooManyAttempts(ctx, retryAfter)
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, models.HTTPResponse{
+			Message: "invalid email or password",
+		})
+		return
+	}
+
+	if err = c.throttleService.RecordSuccess(payload.Email); err != nil {
+		c.logger.Errorf("fail to reset login failures, email [%v], error [%v]", payload.Email, err)
+	}
+
+	// only reachable with the right password, so it does not leak which emails exist
+	if user.VerificationStatus == 0 {
+		ctx.JSON(http.StatusForbidden, models.HTTPResponse{
+			Message: "email is not verified",
+		})
+		return
+	}
+
+	c.completeSignIn(ctx, user)
+}
+
+// OIDCSignIn signs in with an OpenID Connect id token of the provider in the path
+func (c *AuthController) OIDCSignIn(ctx *gin.Context) {
+	var payload models.OIDCSignInRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	user, err := c.oidcService.SignIn(ctx.Param("provider"), payload.IDToken, payload.Nonce)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.JSON(http.StatusOK, models.HTTPResponse{
-				Message: "user not found",
+		c.respondOIDCError(ctx, err)
+		return
+	}
+
+	c.completeSignIn(ctx, user)
+}
+
+// LinkIdentity links the provider account of the id token to the signed in user
+func (c *AuthController) LinkIdentity(ctx *gin.Context) {
+	var payload models.OIDCSignInRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = c.oidcService.Link(userID, ctx.Param("provider"), payload.IDToken, payload.Nonce); err != nil {
+		c.respondOIDCError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// UnlinkIdentity removes the provider from the signed in user
+func (c *AuthController) UnlinkIdentity(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = c.oidcService.Unlink(userID, ctx.Param("provider")); err != nil {
+		c.respondOIDCError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// ListIdentities lists the providers linked to the signed in user
+func (c *AuthController) ListIdentities(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	identities, err := c.oidcService.ListIdentities(userID)
+	if err != nil {
+		c.logger.Errorf("fail to list identities, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	result := make([]models.SerializableIdentity, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, *identity.Serialize())
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"identities": result},
+	})
+}
+
+// VerifyMFA exchanges an mfa pending token and a second factor for a session
+func (c *AuthController) VerifyMFA(ctx *gin.Context) {
+	var payload models.MFAVerifyRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	claim, err := c.service.AuthorizeMFAPending(payload.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, models.HTTPResponse{
+			Message: "invalid mfa token",
+		})
+		return
+	}
+
+	// second factor guesses count towards the same lockout as password guesses
+	ip := ctx.ClientIP()
+	retryAfter, err := c.throttleService.Check(claim.UserEmail, ip)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		c.logger.Errorf("fail to check login throttle, email [%v], error [%v]", claim.UserEmail, err)
+		return
+	}
+	if retryAfter > 0 {
+		c.respondTooManyAttempts(ctx, retryAfter)
+		return
+	}
+
+	if err = c.mfaService.Verify(claim.UserID, payload.Code); err != nil {
+		if !errors.Is(err, services.ErrMFACodeInvalid) && !errors.Is(err, services.ErrMFANotEnabled) {
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
 			})
+			c.logger.Errorf("fail to verify mfa, user [%v], error [%v]", claim.UserID, err)
 			return
 		}
+		retryAfter, err = c.throttleService.RecordFailure(claim.UserEmail, ip)
+		if err != nil {
+			c.logger.Errorf("fail to record login failure, email [%v], error [%v]", claim.UserEmail, err)
+		}
+		if retryAfter > 0 {
+			c.respondTooManyAttempts(ctx, retryAfter)
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, models.HTTPResponse{
+			Message: services.ErrMFACodeInvalid.Error(),
+		})
+		return
+	}
+
+	if err = c.throttleService.RecordSuccess(claim.UserEmail); err != nil {
+		c.logger.Errorf("fail to reset login failures, email [%v], error [%v]", claim.UserEmail, err)
+	}
+
+	user, err := c.userService.First(models.OneUserFilter{ID: claim.UserID})
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
 			Message: "server error",
 		})
-		c.logger.Errorf("fail to sign in, payload [%v], error [%v]", payload, err)
+		c.logger.Errorf("fail to get user [%v], error [%v]", claim.UserID, err)
 		return
 	}
 
-	if user.VerificationStatus == 0 {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
-			Message: "email is not verified",
+	c.respondTokens(ctx, user)
+}
+
+// BeginMFAEnrolment creates a TOTP secret for the signed in user, the otpauth uri is meant
+// to be shown as a QR code
+func (c *AuthController) BeginMFAEnrolment(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
 		return
 	}
 
-	if err = utils.VerifyPassword(user.Password, payload.Password); err != nil {
-		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-			Message: "wrong password",
+	user, err := c.userService.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
+		c.logger.Errorf("fail to get user [%v], error [%v]", userID, err)
 		return
 	}
 
-	accessToken, refreshToken, accessExpired, refreshExpired, err := c.service.GenerateJWTTokens(*user)
+	secret, uri, err := c.mfaService.BeginEnrolment(user.ID, user.Email)
 	if err != nil {
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			ctx.JSON(http.StatusConflict, models.HTTPResponse{
+				Message: err.Error(),
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
 			Message: "server error",
 		})
-		c.logger.Errorf("fail to gen jwt tokens, payload [%v], error [%v]", payload, err)
+		c.logger.Errorf("fail to begin mfa enrolment, user [%v], error [%v]", userID, err)
 		return
 	}
 
-	utils.AttachCookiesToResponse(c.env, accessToken, refreshToken, ctx)
 	ctx.JSON(http.StatusCreated, models.HTTPResponse{
 		Message: "success",
 		Data: map[string]interface{}{
-			"access_token":    accessToken,
-			"refresh_token":   refreshToken,
-			"access_expired":  accessExpired,
-			"refresh_expired": refreshExpired,
-		}})
-	return
+			"secret":      secret,
+			"otpauth_uri": uri,
+		},
+	})
+}
+
+// ConfirmMFAEnrolment enables two-factor authentication with a code from the authenticator
+// app and returns the recovery codes
+func (c *AuthController) ConfirmMFAEnrolment(ctx *gin.Context) {
+	var payload models.MFAEnrolmentConfirmRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	recoveryCodes, err := c.mfaService.ConfirmEnrolment(userID, payload.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrMFACodeInvalid):
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{Message: err.Error()})
+		return
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+		return
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		ctx.JSON(http.StatusConflict, models.HTTPResponse{Message: err.Error()})
+		return
+	default:
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		c.logger.Errorf("fail to confirm mfa enrolment, user [%v], error [%v]", userID, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data: map[string]interface{}{
+			"recovery_codes": recoveryCodes,
+		},
+	})
+}
+
+// ResetMFA turns off two-factor authentication of the user in the path, admin only
+func (c *AuthController) ResetMFA(ctx *gin.Context) {
+	if err := c.mfaService.Reset(ctx.Param("id")); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		c.logger.Errorf("fail to reset mfa, user [%v], error [%v]", ctx.Param("id"), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// UnlockAccount lifts a sign in lockout, admin only
+func (c *AuthController) UnlockAccount(ctx *gin.Context) {
+	var payload models.UnlockAccountRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	if err := c.throttleService.Unlock(payload.Email, payload.IP); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		c.logger.Errorf("fail to unlock, payload [%v], error [%v]", payload, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
 }
 
 // Refresh renew session
@@ -129,8 +509,9 @@ func (c *AuthController) Refresh(ctx *gin.Context) {
 
 	var jwtClaim = claim.(*models.JWTClaim)
 	accessToken, accessExpired, err := c.service.Refresh(models.User{
-		Email: jwtClaim.UserEmail,
-		ID:    jwtClaim.UserID,
+		Email:          jwtClaim.UserEmail,
+		ID:             jwtClaim.UserID,
+		SessionVersion: jwtClaim.SessionVersion,
 	})
 
 	if err != nil {
@@ -186,19 +567,9 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		return
 	}
 
-	//send verification token to user's email
-	var wg sync.WaitGroup
-	ch := make(chan error, 1)
-	email := []string{result.Email}
-	wg.Add(1)
-	go utils.SendVerificationEmailAsync(&wg, ch, c.env, result.VerificationCode, email)
-	wg.Wait()
-	if err = <-ch; err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
-			Message: "server error",
-		})
-		c.logger.Debug(err)
-		return
+	// the mail goes through the background queue, an outage must not fail the registration
+	if err = c.mailService.SendVerificationEmail(result.ID); err != nil {
+		c.logger.Errorf("fail to queue verification email [%v], error [%v]", result.Email, err)
 	}
 
 	ctx.JSON(http.StatusOK, models.HTTPResponse{
@@ -221,6 +592,7 @@ func (c *AuthController) VerifyEmail(ctx *gin.Context) {
 	verificationRequest := models.VerifyEmailRequest{
 		Email:            email,
 		VerificationCode: verifyToken,
+		Password:         verifyEmailRequest.Password,
 	}
 
 	if errs := c.validator.Validate.Struct(&verificationRequest); errs != nil {
@@ -235,47 +607,30 @@ func (c *AuthController) VerifyEmail(ctx *gin.Context) {
 		return
 	}
 
-	filterUser := models.OneUserFilter{
-		Email: email,
-	}
-
-	result, err := c.userService.First(filterUser)
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
-			Message: "server error",
-		})
-		return
-	}
-
-	if result.VerificationStatus == 1 {
-		ctx.JSON(http.StatusConflict, models.HTTPResponse{
-			Message: "email has already been verified",
-		})
-		return
-	}
-
-	expireTime := result.VerificationTime.Add(c.env.EmailVerificationExpiresIn)
-
-	isValid := (result.VerificationCode == verifyToken) &&
-		(time.Now().UTC().Before(expireTime))
-
-	if !isValid {
-		ctx.JSON(http.StatusRequestTimeout, models.HTTPResponse{
-			Message: "email verification timeout",
-		})
-		return
-	}
-
-	userUpdateRequest := models.UserUpdateRequest{
-		VerificationStatus: 1,
-	}
-
-	if err = c.userService.UpdateById(result.ID, userUpdateRequest); err != nil {
-		c.logger.Debug(err)
-		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
-			Message: "server error",
-		})
+	if err := c.service.VerifyEmail(email, verifyToken, verificationRequest.Password); err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			ctx.JSON(http.StatusConflict, models.HTTPResponse{
+				Message: "email has already been verified",
+			})
+		case errors.Is(err, services.ErrVerificationCodeExpired):
+			ctx.JSON(http.StatusRequestTimeout, models.HTTPResponse{
+				Message: "email verification timeout",
+			})
+		case errors.Is(err, services.ErrVerificationAttemptsExceeded):
+			ctx.JSON(http.StatusTooManyRequests, models.HTTPResponse{
+				Message: "too many attempts, request a new verification code",
+			})
+		case errors.Is(err, services.ErrVerificationCodeInvalid):
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Message: "invalid verification code",
+			})
+		default:
+			c.logger.Errorf("fail to verify email [%v], error [%v]", email, err)
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
+			})
+		}
 		return
 	}
 
@@ -306,67 +661,123 @@ func (c *AuthController) SendVerificationEmail(ctx *gin.Context) {
 		return
 	}
 
-	result, err := c.userService.First(models.OneUserFilter{
-		Email: request.Email,
-	})
-
+	user, retryAfter, err := c.service.ResendVerificationCode(request.Email)
 	if err != nil {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
-			Message: "user not exist",
-		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusOK, models.HTTPResponse{
+				Message: "user not exist",
+			})
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			ctx.JSON(http.StatusConflict, models.HTTPResponse{
+				Message: "user has already been verified",
+			})
+		case errors.Is(err, services.ErrVerificationResendTooSoon):
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			ctx.JSON(http.StatusTooManyRequests, models.HTTPResponse{
+				Message: "verification email was sent recently, try again later",
+			})
+		default:
+			c.logger.Errorf("fail to issue verification code [%v], error [%v]", request.Email, err)
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
+			})
+		}
 		return
 	}
 
-	if result.VerificationStatus == 1 {
-		ctx.JSON(http.StatusConflict, models.HTTPResponse{
-			Message: "user has already been verified",
+	if err = c.mailService.SendVerificationEmail(user.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
+		c.logger.Errorf("fail to queue verification email [%v], error [%v]", request.Email, err)
 		return
 	}
 
-	//generate verification token
-	randomBytes := make([]byte, 10)
-	_, err = rand.Read(randomBytes)
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+	return
+}
+
+// ----------------- private -----------------
+
+func (c *AuthController) respondTooManyAttempts(ctx *gin.Context, retryAfter time.Duration) {
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	ctx.JSON(http.StatusTooManyRequests, models.HTTPResponse{
+		Message: "too many login attempts, try again later",
+	})
+}
+
+func (c *AuthController) respondOIDCError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOIDCProviderUnsupported):
+		ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, services.ErrOIDCTokenInvalid), errors.Is(err, services.ErrOIDCEmailMissing):
+		ctx.JSON(http.StatusUnauthorized, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, services.ErrOIDCEmailTaken),
+		errors.Is(err, services.ErrIdentityAlreadyLinked),
+		errors.Is(err, services.ErrLastSignInMethod):
+		ctx.JSON(http.StatusConflict, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, services.ErrIdentityNotLinked):
+		ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+	default:
+		c.logger.Errorf("oidc request failed: [%v]", err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{Message: "server error"})
+	}
+}
+
+// completeSignIn hands out the session, or an mfa pending token when the user has a second factor
+func (c *AuthController) completeSignIn(ctx *gin.Context, user *models.User) {
+	enabled, err := c.mfaService.IsEnabled(user.ID)
 	if err != nil {
-		c.logger.Debug(err)
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
 			Message: "server error",
 		})
+		c.logger.Errorf("fail to check mfa, user [%v], error [%v]", user.ID, err)
 		return
 	}
 
-	verificationCode := base32.StdEncoding.EncodeToString(randomBytes)[:10]
-
-	err = c.userService.UpdateById(result.ID, models.UserUpdateRequest{
-		VerificationCode: verificationCode,
-		VerificationTime: time.Now().UTC(),
-	})
+	if !enabled {
+		c.respondTokens(ctx, user)
+		return
+	}
 
+	mfaToken, mfaExpired, err := c.service.GenerateMFAPendingToken(*user)
 	if err != nil {
-		c.logger.Debug(err)
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
 			Message: "server error",
 		})
+		c.logger.Errorf("fail to gen mfa token, user [%v], error [%v]", user.ID, err)
 		return
 	}
 
-	//send verification token to user's email
-	var wg sync.WaitGroup
-	ch := make(chan error, 1)
-	email := []string{request.Email}
-	wg.Add(1)
-	go utils.SendVerificationEmailAsync(&wg, ch, c.env, verificationCode, email)
-	wg.Wait()
-	if err = <-ch; err != nil {
+	ctx.JSON(http.StatusAccepted, models.HTTPResponse{
+		Message: "mfa required",
+		Data: map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"mfa_expired":  mfaExpired,
+		}})
+}
+
+func (c *AuthController) respondTokens(ctx *gin.Context, user *models.User) {
+	accessToken, refreshToken, accessExpired, refreshExpired, err := c.service.GenerateJWTTokens(*user)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
 			Message: "server error",
 		})
-		c.logger.Debug(err)
+		c.logger.Errorf("fail to gen jwt tokens, user [%v], error [%v]", user.ID, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, models.HTTPResponse{
+	utils.AttachCookiesToResponse(c.env, accessToken, refreshToken, ctx)
+	ctx.JSON(http.StatusCreated, models.HTTPResponse{
 		Message: "success",
-	})
-	return
+		Data: map[string]interface{}{
+			"access_token":    accessToken,
+			"refresh_token":   refreshToken,
+			"access_expired":  accessExpired,
+			"refresh_expired": refreshExpired,
+		}})
 }
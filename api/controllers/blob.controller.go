@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+// BlobController serves the files of the local blob store
+type BlobController struct {
+	logger    *core.Logger
+	clock     core.Clock
+	blobStore core.BlobStore
+}
+
+// NewBlobController creates new blob controller
+func NewBlobController(
+	logger *core.Logger,
+	clock core.Clock,
+	blobStore core.BlobStore,
+) *BlobController {
+	return &BlobController{
+		logger:    logger,
+		clock:     clock,
+		blobStore: blobStore,
+	}
+}
+
+// Serves reports whether files are served by the api, only the local store needs it
+func (c *BlobController) Serves() bool {
+	_, ok := c.blobStore.(*core.LocalBlobStore)
+	return ok
+}
+
+// ServeBlob sends the file once the signature of the url checks out
+func (c *BlobController) ServeBlob(ctx *gin.Context) {
+	store, ok := c.blobStore.(*core.LocalBlobStore)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: "not found"})
+		return
+	}
+
+	file, err := store.Open(
+		strings.TrimPrefix(ctx.Param("path"), "/"),
+		ctx.Query("expires"),
+		ctx.Query("signature"),
+		c.clock.Now(),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrBlobSignatureInvalid):
+			ctx.JSON(http.StatusForbidden, models.HTTPResponse{Message: err.Error()})
+		default:
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: "not found"})
+		}
+		return
+	}
+
+	ctx.File(file)
+}
@@ -8,4 +8,11 @@ var Module = fx.Options(
 	fx.Provide(NewAuthController),
 	fx.Provide(NewProfileController),
 	fx.Provide(NewRecommendController),
+	fx.Provide(NewPhoneController),
+	fx.Provide(NewAccountController),
+	fx.Provide(NewBlobController),
+	fx.Provide(NewVerificationController),
+	fx.Provide(NewOnboardingController),
+	fx.Provide(NewExperimentController),
+	fx.Provide(NewNotificationController),
 )
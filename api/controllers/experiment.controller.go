@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+)
+
+// ExperimentController lets admins look at the experiments and who is in which variant
+type ExperimentController struct {
+	logger  *core.Logger
+	service services.IExperimentService
+}
+
+// NewExperimentController creates new experiment controller
+func NewExperimentController(logger *core.Logger, service services.IExperimentService) *ExperimentController {
+	return &ExperimentController{
+		logger:  logger,
+		service: service,
+	}
+}
+
+// ListExperiments returns the loaded experiments, admin only
+func (c *ExperimentController) ListExperiments(ctx *gin.Context) {
+	experiments := c.service.Experiments()
+	if experiments == nil {
+		experiments = []core.Experiment{}
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"experiments": experiments},
+	})
+}
+
+// GetUserAssignments returns the variants of the user in the path, admin only
+func (c *ExperimentController) GetUserAssignments(ctx *gin.Context) {
+	assignments := c.service.Assign(ctx.Param("id"))
+	if assignments == nil {
+		assignments = core.ExperimentAssignments{}
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"assignments": assignments},
+	})
+}
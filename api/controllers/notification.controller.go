@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// NotificationController handles the push devices and notification preferences of the user
+type NotificationController struct {
+	logger    *core.Logger
+	service   services.INotificationService
+	validator *core.Validator
+}
+
+// NewNotificationController creates new notification controller
+func NewNotificationController(
+	logger *core.Logger,
+	service services.INotificationService,
+	validator *core.Validator,
+) *NotificationController {
+	return &NotificationController{
+		logger:    logger,
+		service:   service,
+		validator: validator,
+	}
+}
+
+// RegisterDevice saves the push token of the device the user is signed in on
+func (c *NotificationController) RegisterDevice(ctx *gin.Context) {
+	var request models.DeviceRequest
+	if !c.bind(ctx, &request) {
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = c.service.RegisterDevice(userID, request); err != nil {
+		c.logger.Errorf("fail to register device, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// UnregisterDevice forgets a push token, on sign out
+func (c *NotificationController) UnregisterDevice(ctx *gin.Context) {
+	var request models.DeviceDeleteRequest
+	if !c.bind(ctx, &request) {
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = c.service.UnregisterDevice(userID, request.Token); err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		c.logger.Errorf("fail to unregister device, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// GetPreferences returns the notification preferences of the signed in user
+func (c *NotificationController) GetPreferences(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	preference, err := c.service.GetPreferences(userID)
+	if err != nil {
+		c.logger.Errorf("fail to load notification preferences, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    preference.Serialize(),
+	})
+}
+
+// UpdatePreferences changes which pushes the signed in user gets and their quiet hours
+func (c *NotificationController) UpdatePreferences(ctx *gin.Context) {
+	var request models.NotificationPreferenceRequest
+	if !c.bind(ctx, &request) {
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	preference, err := c.service.UpdatePreferences(userID, request)
+	if err != nil {
+		var fieldErr *services.FieldError
+		if errors.As(err, &fieldErr) {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Message:       fieldErr.Message,
+				InvalidFields: []string{fieldErr.Field},
+			})
+			return
+		}
+		c.logger.Errorf("fail to save notification preferences, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    preference.Serialize(),
+	})
+}
+
+// ----------------- private -----------------
+
+// bind parses and validates the request body, answering 400 itself when it fails
+func (c *NotificationController) bind(ctx *gin.Context, request interface{}) bool {
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	if errs := c.validator.Validate.Struct(request); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// OnboardingController data type
+type OnboardingController struct {
+	service services.IOnboardingService
+	logger  *core.Logger
+}
+
+// NewOnboardingController creates new onboarding controller
+func NewOnboardingController(service services.IOnboardingService, logger *core.Logger) *OnboardingController {
+	return &OnboardingController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetOnboarding tells which onboarding steps the signed in user has done and how complete
+// their profile is
+func (c *OnboardingController) GetOnboarding(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	state, err := c.service.State(userID)
+	if err != nil {
+		c.logger.Errorf("fail to load onboarding state, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    state,
+	})
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// PhoneController handles phone number verification
+type PhoneController struct {
+	logger    *core.Logger
+	service   services.IPhoneService
+	validator *core.Validator
+}
+
+// NewPhoneController creates new phone controller
+func NewPhoneController(
+	logger *core.Logger,
+	service services.IPhoneService,
+	validator *core.Validator,
+) *PhoneController {
+	return &PhoneController{
+		logger:    logger,
+		service:   service,
+		validator: validator,
+	}
+}
+
+// SendCode texts a verification code to the number in the body
+func (c *PhoneController) SendCode(ctx *gin.Context) {
+	var payload models.PhoneCodeRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	retryAfter, err := c.service.SendCode(userID, payload.PhoneNumber)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrPhoneCodeResendTooSoon),
+		errors.Is(err, services.ErrPhoneCodeSendLimitExceeded),
+		errors.Is(err, services.ErrPhoneCodeUserLimitExceeded):
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, models.HTTPResponse{Message: err.Error()})
+		return
+	case errors.Is(err, services.ErrPhoneAlreadyVerified), errors.Is(err, services.ErrPhoneTaken):
+		ctx.JSON(http.StatusConflict, models.HTTPResponse{Message: err.Error()})
+		return
+	default:
+		c.logger.Errorf("fail to send phone code, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// VerifyCode checks the code and marks the number as verified
+func (c *PhoneController) VerifyCode(ctx *gin.Context) {
+	var payload models.PhoneVerifyRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	err = c.service.VerifyCode(userID, payload.PhoneNumber, payload.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrPhoneCodeInvalid), errors.Is(err, services.ErrPhoneCodeExpired):
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{Message: err.Error()})
+		return
+	case errors.Is(err, services.ErrPhoneCodeAttemptsExceeded):
+		ctx.JSON(http.StatusTooManyRequests, models.HTTPResponse{Message: err.Error()})
+		return
+	case errors.Is(err, services.ErrPhoneAlreadyVerified), errors.Is(err, services.ErrPhoneTaken):
+		ctx.JSON(http.StatusConflict, models.HTTPResponse{Message: err.Error()})
+		return
+	default:
+		c.logger.Errorf("fail to verify phone, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
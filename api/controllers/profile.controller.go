@@ -2,37 +2,49 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/hodukihugi/winglets-api/core"
 	"github.com/hodukihugi/winglets-api/models"
 	"github.com/hodukihugi/winglets-api/services"
 	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"reflect"
 	"strconv"
-	"strings"
-	"sync"
-	"time"
+)
+
+const (
+	maxPhotoFileSize     = 10 << 20
+	maxPhotoUploadMemory = 32 << 20
 )
 
 type ProfileController struct {
 	service          services.IProfileService
 	recommendService services.IRecommendService
-	ik               *core.ImageKit
+	photoService     services.IPhotoService
+	urlSigner        *core.BlobURLSigner
+	validator        *core.Validator
 	logger           *core.Logger
 }
 
 func NewProfileController(
 	service services.IProfileService,
 	recommendService services.IRecommendService,
-	ik *core.ImageKit,
+	photoService services.IPhotoService,
+	urlSigner *core.BlobURLSigner,
+	validator *core.Validator,
 	logger *core.Logger,
 ) *ProfileController {
 	return &ProfileController{
 		service:          service,
 		recommendService: recommendService,
-		ik:               ik,
+		photoService:     photoService,
+		urlSigner:        urlSigner,
+		validator:        validator,
 		logger:           logger,
 	}
 }
@@ -63,6 +75,14 @@ func (c *ProfileController) CreateProfile(ctx *gin.Context) {
 	}
 
 	if err = c.service.CreateProfile(userID, request); err != nil {
+		var fieldErr *services.FieldError
+		if errors.As(err, &fieldErr) {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Message:       fieldErr.Message,
+				InvalidFields: []string{fieldErr.Field},
+			})
+			return
+		}
 		ctx.JSON(http.StatusForbidden, models.HTTPResponse{
 			Message: err.Error(),
 		})
@@ -74,19 +94,53 @@ func (c *ProfileController) CreateProfile(ctx *gin.Context) {
 	}
 }
 
+// GetProfileById shows a profile the signed in user is allowed to see, hidden profiles
+// answer 404 like missing ones
 func (c *ProfileController) GetProfileById(ctx *gin.Context) {
-	data, err := c.service.GetProfileById(ctx.Param("id"))
+	userID, err := utils.GetUserID(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
-			Message: err.Error(),
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	data, err := c.service.GetVisibleProfile(userID, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrProfileNotVisible) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		c.logger.Errorf("fail to load profile, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
 		return
 	} else {
 		ctx.JSON(http.StatusOK, models.HTTPResponse{
 			Message: "success",
-			Data:    data,
+			Data:    data.Serialize(c.urlSigner.Sign),
+		})
+	}
+}
+
+// GetTaxonomy lists the interests, languages and prompt questions profiles pick from
+func (c *ProfileController) GetTaxonomy(ctx *gin.Context) {
+	taxonomy, err := c.service.GetTaxonomy()
+	if err != nil {
+		c.logger.Errorf("fail to load taxonomy, error [%v]", err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
+		return
 	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    taxonomy,
+	})
 }
 
 func (c *ProfileController) GetMyProfile(ctx *gin.Context) {
@@ -120,7 +174,7 @@ func (c *ProfileController) GetMyProfile(ctx *gin.Context) {
 		}
 	}
 
-	serializeProfile := result.Serialize()
+	serializeProfile := result.SerializeForOwner(c.urlSigner.Sign)
 	serializeProfile.Answered = answered
 
 	ctx.JSON(http.StatusOK, models.HTTPResponse{
@@ -147,6 +201,14 @@ func (c *ProfileController) UpdateProfile(ctx *gin.Context) {
 	}
 
 	if err := c.service.UpdateProfileById(userID, request); err != nil {
+		var fieldErr *services.FieldError
+		if errors.As(err, &fieldErr) {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Message:       fieldErr.Message,
+				InvalidFields: []string{fieldErr.Field},
+			})
+			return
+		}
 		ctx.JSON(http.StatusConflict, models.HTTPResponse{
 			Message: err.Error(),
 		})
@@ -158,9 +220,27 @@ func (c *ProfileController) UpdateProfile(ctx *gin.Context) {
 	}
 }
 
-func (c *ProfileController) UploadImage(ctx *gin.Context) {
-	context, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+// PatchProfile changes only the fields sent in the body
+func (c *ProfileController) PatchProfile(ctx *gin.Context) {
+	var request models.ProfilePatchRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&request); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
 
 	userID, err := utils.GetUserID(ctx)
 	if err != nil {
@@ -170,112 +250,506 @@ func (c *ProfileController) UploadImage(ctx *gin.Context) {
 		return
 	}
 
-	var wg sync.WaitGroup
-	ch := make(chan models.ImageUploadResult, 5)
+	if err = c.service.PatchProfileById(userID, request); err != nil {
+		var fieldErr *services.FieldError
+		switch {
+		case errors.As(err, &fieldErr):
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Message:       fieldErr.Message,
+				InvalidFields: []string{fieldErr.Field},
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: "profile not found",
+			})
+		default:
+			c.logger.Errorf("fail to patch profile, user [%v], error [%v]", userID, err)
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
+			})
+		}
+		return
+	}
 
-	// Parse the multipart form
-	err = ctx.Request.ParseMultipartForm(10 << 20) // Max file size of 10MB
+	profile, err := c.service.GetProfileById(userID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-			Message: "bad request",
+		c.logger.Errorf("fail to load profile, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
+		return
 	}
 
-	// Get the files from the form
-	form, _ := ctx.MultipartForm()
-	files := form.File
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    profile.SerializeForOwner(c.urlSigner.Sign),
+	})
+}
 
-	var invalidFields []string
+// GetPreferences returns who the signed in user wants to be recommended
+func (c *ProfileController) GetPreferences(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
 
-	for key, images := range files {
-		if !strings.HasPrefix(key, "image_") {
-			continue
+	preference, err := c.service.GetPreferences(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: "preferences not set",
+			})
+			return
 		}
+		c.logger.Errorf("fail to load preferences, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
 
-		if len(images) <= 0 {
-			invalidFields = append(invalidFields, fmt.Sprintf("Empty image: %s", key))
-			break
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    preference.Serialize(),
+	})
+}
+
+// SavePreferences sets who the signed in user wants to be recommended
+func (c *ProfileController) SavePreferences(ctx *gin.Context) {
+	var request models.UserPreferenceRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&request); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
 		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
 
-		slotId, err := strconv.Atoi(strings.Trim(key, "image_"))
-		if err != nil {
-			invalidFields = append(invalidFields, fmt.Sprintf("Can't convert image slot id: %s", key))
-			c.logger.Debug(err)
-			break
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	preference, err := c.service.SavePreferences(userID, request)
+	if err != nil {
+		c.logger.Errorf("fail to save preferences, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    preference.Serialize(),
+	})
+}
+
+// UpdateVisibility changes who sees the profile of the signed in user and how much of it
+func (c *ProfileController) UpdateVisibility(ctx *gin.Context) {
+	var request models.ProfileVisibilityRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	visibility, err := c.service.UpdateVisibility(userID, request)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: "profile not found",
+			})
+			return
 		}
+		c.logger.Errorf("fail to update visibility, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
 
-		// Open the uploaded file
-		fileHeader := images[0]
-		src, err := fileHeader.Open()
-		defer src.Close()
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    visibility,
+	})
+}
 
-		if err != nil {
-			invalidFields = append(invalidFields, fmt.Sprintf("Can't open image: %s", key))
-			c.logger.Debug(err)
-			break
+// ListTravelCities lists the cities travel mode can be set to
+func (c *ProfileController) ListTravelCities(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"cities": utils.Cities()},
+	})
+}
+
+// GetTravel returns the travel location of the signed in user, null when not travelling
+func (c *ProfileController) GetTravel(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	travel, err := c.service.GetTravel(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: "profile not found",
+			})
+			return
 		}
+		c.logger.Errorf("fail to load travel, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"travel": travel},
+	})
+}
+
+// SetTravel makes the signed in user discover from another city for a few days
+func (c *ProfileController) SetTravel(ctx *gin.Context) {
+	var request models.TravelRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: err.Error(),
+		})
+		return
+	}
 
-		// Upload the file to ImageKit
-		wg.Add(1)
-		imageName := fmt.Sprintf("user_profile_image_%d", slotId)
-		go utils.UploadProfileImageAsync(&wg, context, ch, c.ik.ImageKit, src, slotId, imageName, userID)
+	if errs := c.validator.Validate.Struct(&request); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
 	}
 
-	wg.Wait()
-	close(ch)
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
 
-	var request models.ProfileUpdateRequest
-	for imageUploadResult := range ch {
-		v := reflect.ValueOf(&request)
-		f_id := v.Elem().FieldByName(fmt.Sprintf("ImageId%d", imageUploadResult.SlotId))
-		f_url := v.Elem().FieldByName(fmt.Sprintf("ImageUrl%d", imageUploadResult.SlotId))
-		if f_id.IsValid() {
-			f_id.Set(reflect.ValueOf(imageUploadResult.FileId))
+	travel, err := c.service.SetTravel(userID, request)
+	if err != nil {
+		var fieldErr *services.FieldError
+		switch {
+		case errors.As(err, &fieldErr):
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Message:       fieldErr.Message,
+				InvalidFields: []string{fieldErr.Field},
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: "profile not found",
+			})
+		default:
+			c.logger.Errorf("fail to set travel, user [%v], error [%v]", userID, err)
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
+			})
 		}
+		return
+	}
 
-		if f_url.IsValid() {
-			f_url.Set(reflect.ValueOf(imageUploadResult.FileUrl))
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"travel": travel},
+	})
+}
+
+// ClearTravel brings the signed in user back to their own location
+func (c *ProfileController) ClearTravel(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = c.service.ClearTravel(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{
+				Message: "profile not found",
+			})
+			return
 		}
+		c.logger.Errorf("fail to clear travel, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
 	}
 
-	if err = c.service.UpdateProfileById(userID, request); err != nil {
-		ctx.JSON(http.StatusConflict, models.HTTPResponse{
-			Message: err.Error(),
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// ListPhotos returns the gallery of the signed in user
+func (c *ProfileController) ListPhotos(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
 		return
-	} else {
+	}
+
+	photos, err := c.photoService.List(userID)
+	if err != nil {
+		c.logger.Errorf("fail to list photos, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"photos": models.SerializeProfilePhotos(photos, c.urlSigner.Sign)},
+	})
+}
+
+// UploadPhotos appends the files of the "photos" form field to the gallery and answers
+// with one result per file: 201 when all were uploaded, 207 when only some were. More files
+// than the gallery has room for are refused with 422 before any is read
+func (c *ProfileController) UploadPhotos(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	if err = ctx.Request.ParseMultipartForm(maxPhotoUploadMemory); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse multipart form",
+		})
+		return
+	}
+
+	files := ctx.Request.MultipartForm.File["photos"]
+	if len(files) == 0 {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "no photo uploaded",
+			InvalidFields: []string{"photos"},
+		})
+		return
+	}
+
+	free, err := c.photoService.FreeSlots(userID)
+	if err != nil {
+		c.logger.Errorf("fail to count photos, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+	if len(files) > free {
+		ctx.JSON(http.StatusUnprocessableEntity, models.HTTPResponse{
+			Message:       fmt.Sprintf("%d photos sent, the gallery has room for %d more", len(files), free),
+			InvalidFields: []string{"photos"},
+		})
+		return
+	}
+
+	// files that can't be read are answered here, the others by the service
+	results := make([]models.ProfilePhotoUploadResult, len(files))
+	var uploads []models.ProfilePhotoUpload
+	var pending []int
+	for i, fileHeader := range files {
+		results[i].Filename = fileHeader.Filename
+		if fileHeader.Size > maxPhotoFileSize {
+			results[i].Status = models.ProfilePhotoUploadRejected
+			results[i].Reason = fmt.Sprintf("file is larger than %d MB", maxPhotoFileSize>>20)
+			continue
+		}
 
-		if len(invalidFields) > 0 {
-			ctx.JSON(http.StatusOK, models.HTTPResponse{
-				Message:       "Upload profile image fail",
-				InvalidFields: invalidFields,
+		content, err := readMultipartFile(fileHeader)
+		if err != nil {
+			c.logger.Debug(err)
+			results[i].Status, results[i].Reason = models.ProfilePhotoUploadFailed, "can't read file"
+			continue
+		}
+		uploads = append(uploads, models.ProfilePhotoUpload{
+			Filename: fileHeader.Filename,
+			Content:  content,
+		})
+		pending = append(pending, i)
+	}
+
+	if len(uploads) > 0 {
+		uploaded, err := c.photoService.Upload(ctx.Request.Context(), userID, uploads)
+		if errors.Is(err, services.ErrPhotoLimitReached) {
+			ctx.JSON(http.StatusUnprocessableEntity, models.HTTPResponse{
+				Message:       err.Error(),
+				InvalidFields: []string{"photos"},
 			})
-		} else {
-			ctx.JSON(http.StatusOK, models.HTTPResponse{
-				Message: "success",
+			return
+		}
+		if err != nil {
+			c.logger.Errorf("fail to upload photos, user [%v], error [%v]", userID, err)
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
 			})
+			return
+		}
+		for j, i := range pending {
+			results[i] = uploaded[j]
+		}
+	}
+
+	var succeeded, rejected int
+	for _, result := range results {
+		switch result.Status {
+		case models.ProfilePhotoUploadUploaded:
+			succeeded++
+		case models.ProfilePhotoUploadRejected:
+			rejected++
 		}
 	}
+
+	status, message := http.StatusCreated, "success"
+	switch {
+	case succeeded == len(results):
+	case succeeded > 0:
+		status, message = http.StatusMultiStatus, "some photos were not uploaded"
+	case rejected == len(results):
+		status, message = http.StatusUnprocessableEntity, "no photo uploaded"
+	default:
+		status, message = http.StatusBadGateway, "no photo uploaded"
+	}
+
+	ctx.JSON(status, models.HTTPResponse{
+		Message: message,
+		Data:    map[string]interface{}{"results": results},
+	})
 }
 
-func (c *ProfileController) RemoveImage(ctx *gin.Context) {
-	var deleteRequest models.ProfileImageDeleteRequest
-	if err := ctx.ShouldBindJSON(&deleteRequest); err != nil {
+// ReplacePhoto swaps the image of the photo in the path for the "photo" form file
+func (c *ProfileController) ReplacePhoto(ctx *gin.Context) {
+	userID, photoID, ok := c.photoParams(ctx)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("photo")
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-			Message: err.Error(),
+			Message:       "no photo uploaded",
+			InvalidFields: []string{"photo"},
+		})
+		return
+	}
+	if fileHeader.Size > maxPhotoFileSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, models.HTTPResponse{
+			Message: fmt.Sprintf("%s is larger than %d MB", fileHeader.Filename, maxPhotoFileSize>>20),
 		})
 		return
 	}
 
-	if len(deleteRequest.Slots) <= 0 {
+	content, err := readMultipartFile(fileHeader)
+	if err != nil {
+		c.logger.Debug(err)
 		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-			Message: "image slot list empty",
+			Message: fmt.Sprintf("can't read %s", fileHeader.Filename),
 		})
 		return
 	}
 
-	context, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	photo, err := c.photoService.Replace(ctx.Request.Context(), userID, photoID, models.ProfilePhotoUpload{
+		Filename: fileHeader.Filename,
+		Content:  content,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPhotoNotFound):
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+		case errors.Is(err, utils.ErrImageUnsupported):
+			ctx.JSON(http.StatusUnsupportedMediaType, models.HTTPResponse{Message: err.Error()})
+		case errors.Is(err, utils.ErrImageDimensions):
+			ctx.JSON(http.StatusUnprocessableEntity, models.HTTPResponse{Message: err.Error()})
+		case errors.Is(err, context.DeadlineExceeded):
+			ctx.JSON(http.StatusGatewayTimeout, models.HTTPResponse{Message: "upload timed out"})
+		default:
+			c.logger.Errorf("fail to replace photo, user [%v], error [%v]", userID, err)
+			ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
+			})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"photo": photo.Serialize(c.urlSigner.Sign)},
+	})
+}
+
+// ReorderPhotos puts the gallery in the order of the ids in the body
+func (c *ProfileController) ReorderPhotos(ctx *gin.Context) {
+	var request models.ProfilePhotoReorderRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&request); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
 
 	userID, err := utils.GetUserID(ctx)
 	if err != nil {
@@ -285,54 +759,150 @@ func (c *ProfileController) RemoveImage(ctx *gin.Context) {
 		return
 	}
 
-	profile, err := c.service.GetProfileById(userID)
+	photos, err := c.photoService.Reorder(userID, request.PhotoIDs)
 	if err != nil {
+		if errors.Is(err, services.ErrPhotoOrderInvalid) {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{Message: err.Error()})
+			return
+		}
+		c.logger.Errorf("fail to reorder photos, user [%v], error [%v]", userID, err)
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
-			Message: err.Error(),
+			Message: "server error",
 		})
+		return
 	}
 
-	var invalidFields []string
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"photos": models.SerializeProfilePhotos(photos, c.urlSigner.Sign)},
+	})
+}
 
-	for _, slotId := range deleteRequest.Slots {
-		v := reflect.ValueOf(profile)
-		f := v.Elem().FieldByName(fmt.Sprintf("ImageId%d", slotId))
-		if !f.IsValid() || f.String() == "" {
-			invalidFields = append(invalidFields, fmt.Sprintf("Image slot %d is empty", slotId))
-			c.logger.Debugf("Invalid ImageId%d: %s", slotId, f.String())
-		} else {
-			c.logger.Debugf("Valid ImageId%d: %s", slotId, f.String())
+// SetPrimaryPhoto makes the photo in the path the primary one
+func (c *ProfileController) SetPrimaryPhoto(ctx *gin.Context) {
+	userID, photoID, ok := c.photoParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.photoService.SetPrimary(userID, photoID); err != nil {
+		if errors.Is(err, services.ErrPhotoNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+			return
 		}
+		c.logger.Errorf("fail to set primary photo, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
 	}
 
-	if len(invalidFields) > 0 {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
-			Message:       "Remove profile image fail",
-			InvalidFields: invalidFields,
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// DeletePhoto removes the photo in the path from the gallery
+func (c *ProfileController) DeletePhoto(ctx *gin.Context) {
+	userID, photoID, ok := c.photoParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.photoService.Delete(userID, photoID); err != nil {
+		if errors.Is(err, services.ErrPhotoNotFound) {
+			ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+			return
+		}
+		c.logger.Errorf("fail to delete photo, user [%v], error [%v]", userID, err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
 		})
 		return
 	}
 
-	var wg sync.WaitGroup
-	for _, slotId := range deleteRequest.Slots {
-		wg.Add(1)
-		v := reflect.ValueOf(profile)
-		f := v.Elem().FieldByName(fmt.Sprintf("ImageId%d", slotId))
-		go utils.RemoveProfileImageAsync(&wg, context, c.ik.ImageKit, f.String())
-		c.logger.Debugf("Run remove profile image!")
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// ListPhotoReviewQueue lists the photos waiting for an admin, admin only
+func (c *ProfileController) ListPhotoReviewQueue(ctx *gin.Context) {
+	photos, err := c.photoService.ReviewQueue()
+	if err != nil {
+		c.logger.Errorf("fail to list photo review queue, error [%v]", err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
 	}
-	wg.Wait()
 
-	if err = c.service.UpdateProfileImageById(userID, deleteRequest.Slots); err != nil {
-		ctx.JSON(http.StatusConflict, models.HTTPResponse{
-			Message: err.Error(),
+	result := make([]models.SerializablePhotoReview, 0, len(photos))
+	for _, photo := range photos {
+		result = append(result, models.SerializablePhotoReview{
+			UserID:                   photo.UserID,
+			SerializableProfilePhoto: *photo.Serialize(c.urlSigner.Sign),
 		})
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"photos": result},
+	})
+}
+
+// ApprovePhoto shows the photo in the path to other users, admin only
+func (c *ProfileController) ApprovePhoto(ctx *gin.Context) {
+	reviewerID, photoID, ok := c.photoParams(ctx)
+	if !ok {
 		return
-	} else {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
-			Message: "success",
+	}
+
+	if err := c.photoService.Approve(photoID, reviewerID); err != nil {
+		c.respondReviewError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// RejectPhoto turns down the photo in the path with a reason shown to its owner, admin only
+func (c *ProfileController) RejectPhoto(ctx *gin.Context) {
+	reviewerID, photoID, ok := c.photoParams(ctx)
+	if !ok {
+		return
+	}
+
+	var request models.ProfilePhotoRejectRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&request); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
 		})
+		return
+	}
+
+	if err := c.photoService.Reject(photoID, reviewerID, request.Reason); err != nil {
+		c.respondReviewError(ctx, err)
+		return
 	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
 }
 
 func (c *ProfileController) DeleteProfile(ctx *gin.Context) {
@@ -356,3 +926,44 @@ func (c *ProfileController) DeleteProfile(ctx *gin.Context) {
 		})
 	}
 }
+
+func (c *ProfileController) photoParams(ctx *gin.Context) (string, uint, bool) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return "", 0, false
+	}
+
+	photoID, err := strconv.ParseUint(ctx.Param("photo_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid photo id",
+			InvalidFields: []string{"photo_id"},
+		})
+		return "", 0, false
+	}
+
+	return userID, uint(photoID), true
+}
+
+func (c *ProfileController) respondReviewError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrPhotoNotPending) {
+		ctx.JSON(http.StatusConflict, models.HTTPResponse{Message: err.Error()})
+		return
+	}
+	c.logger.Errorf("fail to review photo, path [%v], error [%v]", ctx.FullPath(), err)
+	ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+		Message: "server error",
+	})
+}
+
+func readMultipartFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
@@ -12,15 +12,17 @@ import (
 
 // RecommendController data type
 type RecommendController struct {
-	service services.IRecommendService
-	logger  *core.Logger
+	service   services.IRecommendService
+	urlSigner *core.BlobURLSigner
+	logger    *core.Logger
 }
 
 // NewRecommendController creates new match controller
-func NewRecommendController(recommendService services.IRecommendService, logger *core.Logger) *RecommendController {
+func NewRecommendController(recommendService services.IRecommendService, urlSigner *core.BlobURLSigner, logger *core.Logger) *RecommendController {
 	return &RecommendController{
-		service: recommendService,
-		logger:  logger,
+		service:   recommendService,
+		urlSigner: urlSigner,
+		logger:    logger,
 	}
 }
 
@@ -145,7 +147,7 @@ func (c *RecommendController) GetRecommendations(ctx *gin.Context) {
 		return
 	}
 
-	profiles, err := c.service.GetRecommendationByUserId(userID, minAgeInt, maxAgeInt, minDistanceFloat, maxDistanceFloat)
+	profiles, err := c.service.GetRecommendationByUserId(ctx.Request.Context(), userID, minAgeInt, maxAgeInt, minDistanceFloat, maxDistanceFloat, request.VerifiedOnly)
 	if err != nil {
 		c.logger.Error(err)
 		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
@@ -178,7 +180,7 @@ func (c *RecommendController) Smash(ctx *gin.Context) {
 		})
 	}
 
-	message, profile, err := c.service.SmashById(userID, request.UserId)
+	message, profile, err := c.service.SmashById(ctx.Request.Context(), userID, request.UserId)
 	if err != nil {
 		c.logger.Error(err)
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
@@ -203,7 +205,7 @@ func (c *RecommendController) Smash(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, models.HTTPResponse{
 			Message: "success, match finish",
 			Data: map[string]*models.SerializableProfile{
-				"profile": profile.Serialize(),
+				"profile": profile.Serialize(c.urlSigner.Sign),
 			},
 		})
 	}
@@ -226,7 +228,7 @@ func (c *RecommendController) Pass(ctx *gin.Context) {
 		})
 	}
 
-	if err := c.service.PassById(userID, request.UserId); err != nil {
+	if err := c.service.PassById(ctx.Request.Context(), userID, request.UserId); err != nil {
 		c.logger.Error(err)
 		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
 			Message: "server error",
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// VerificationController handles selfie verification and its review queue
+type VerificationController struct {
+	logger    *core.Logger
+	service   services.IVerificationService
+	urlSigner *core.BlobURLSigner
+	validator *core.Validator
+}
+
+// NewVerificationController creates new verification controller
+func NewVerificationController(
+	logger *core.Logger,
+	service services.IVerificationService,
+	urlSigner *core.BlobURLSigner,
+	validator *core.Validator,
+) *VerificationController {
+	return &VerificationController{
+		logger:    logger,
+		service:   service,
+		urlSigner: urlSigner,
+		validator: validator,
+	}
+}
+
+// GetVerification returns the state of the user's latest verification
+func (c *VerificationController) GetVerification(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	verification, err := c.service.Latest(userID)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"verification": verification.Serialize(c.urlSigner.Sign)},
+	})
+}
+
+// RequestChallenge gives the pose the selfie has to show
+func (c *VerificationController) RequestChallenge(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	verification, err := c.service.RequestChallenge(userID)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"verification": verification.Serialize(c.urlSigner.Sign)},
+	})
+}
+
+// SubmitSelfie answers the challenge with the "selfie" form file
+func (c *VerificationController) SubmitSelfie(ctx *gin.Context) {
+	userID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("selfie")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "no selfie uploaded",
+			InvalidFields: []string{"selfie"},
+		})
+		return
+	}
+	if fileHeader.Size > maxPhotoFileSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, models.HTTPResponse{
+			Message: fmt.Sprintf("%s is larger than %d MB", fileHeader.Filename, maxPhotoFileSize>>20),
+		})
+		return
+	}
+
+	content, err := readMultipartFile(fileHeader)
+	if err != nil {
+		c.logger.Debug(err)
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: fmt.Sprintf("can't read %s", fileHeader.Filename),
+		})
+		return
+	}
+
+	verification, err := c.service.SubmitSelfie(ctx.Request.Context(), userID, models.ProfilePhotoUpload{
+		Filename: fileHeader.Filename,
+		Content:  content,
+	})
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"verification": verification.Serialize(c.urlSigner.Sign)},
+	})
+}
+
+// ListReviewQueue lists the selfies waiting for an admin, admin only
+func (c *VerificationController) ListReviewQueue(ctx *gin.Context) {
+	verifications, err := c.service.ReviewQueue()
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	result := make([]models.SerializableProfileVerification, 0, len(verifications))
+	for _, verification := range verifications {
+		result = append(result, *verification.Serialize(c.urlSigner.Sign))
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+		Data:    map[string]interface{}{"verifications": result},
+	})
+}
+
+// ApproveVerification marks the profile of the verification in the path as verified, admin only
+func (c *VerificationController) ApproveVerification(ctx *gin.Context) {
+	reviewerID, id, ok := c.reviewParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Approve(id, reviewerID); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+// RejectVerification turns down the verification in the path with a reason, admin only
+func (c *VerificationController) RejectVerification(ctx *gin.Context) {
+	reviewerID, id, ok := c.reviewParams(ctx)
+	if !ok {
+		return
+	}
+
+	var payload models.ProfileVerificationRejectRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message: "fail to parse request body",
+		})
+		return
+	}
+
+	if errs := c.validator.Validate.Struct(&payload); errs != nil {
+		var invalidFields []string
+		for _, err := range errs.(validator.ValidationErrors) {
+			invalidFields = append(invalidFields, utils.PascalToSnake(err.Field()))
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid request body",
+			InvalidFields: invalidFields,
+		})
+		return
+	}
+
+	if err := c.service.Reject(id, reviewerID, payload.Reason); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.HTTPResponse{
+		Message: "success",
+	})
+}
+
+func (c *VerificationController) reviewParams(ctx *gin.Context) (string, uint, bool) {
+	reviewerID, err := utils.GetUserID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+		return "", 0, false
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Message:       "invalid verification id",
+			InvalidFields: []string{"id"},
+		})
+		return "", 0, false
+	}
+
+	return reviewerID, uint(id), true
+}
+
+func (c *VerificationController) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrVerificationNotFound),
+		errors.Is(err, services.ErrVerificationProfileMissing):
+		ctx.JSON(http.StatusNotFound, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, services.ErrVerificationAlreadyVerified),
+		errors.Is(err, services.ErrVerificationInReview),
+		errors.Is(err, services.ErrVerificationNotPending),
+		errors.Is(err, services.ErrVerificationChallengeNotFound):
+		ctx.JSON(http.StatusConflict, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, services.ErrVerificationChallengeExpired):
+		ctx.JSON(http.StatusGone, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, utils.ErrImageUnsupported):
+		ctx.JSON(http.StatusUnsupportedMediaType, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, utils.ErrImageDimensions):
+		ctx.JSON(http.StatusUnprocessableEntity, models.HTTPResponse{Message: err.Error()})
+	case errors.Is(err, context.DeadlineExceeded):
+		ctx.JSON(http.StatusGatewayTimeout, models.HTTPResponse{Message: "upload timed out"})
+	default:
+		c.logger.Errorf("fail to handle verification, path [%v], error [%v]", ctx.FullPath(), err)
+		ctx.JSON(http.StatusInternalServerError, models.HTTPResponse{
+			Message: "server error",
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// AdminMiddleware middleware restricting routes to admin users,
+// it must run after JWTMiddleware.Handler
+type AdminMiddleware struct {
+	userService services.IUserService
+	logger      *core.Logger
+}
+
+// NewAdminMiddleware creates new admin middleware
+func NewAdminMiddleware(userService services.IUserService, logger *core.Logger) *AdminMiddleware {
+	return &AdminMiddleware{
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+// Setup sets up admin middleware
+func (m *AdminMiddleware) Setup() {}
+
+// Handler handles middleware functionality
+func (m *AdminMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserID(c)
+		if err != nil || userID == "" {
+			c.JSON(http.StatusUnauthorized, models.HTTPResponse{
+				Message: "you are not authorized",
+			})
+			c.Abort()
+			return
+		}
+
+		user, err := m.userService.First(models.OneUserFilter{ID: userID})
+		if err != nil {
+			m.logger.Errorf("fail to load admin user [%v]: [%v]", userID, err)
+			c.JSON(http.StatusForbidden, models.HTTPResponse{
+				Message: "forbidden",
+			})
+			c.Abort()
+			return
+		}
+
+		if user.Role != models.UserRoleAdmin {
+			c.JSON(http.StatusForbidden, models.HTTPResponse{
+				Message: "forbidden",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/constants"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// ExperimentMiddleware middleware assigning the user to the running experiments, it must run
+// after JWTMiddleware.Handler. Services read the assignments from the request context with
+// core.ExperimentAssignmentsFrom.
+type ExperimentMiddleware struct {
+	experimentService services.IExperimentService
+	logger            *core.Logger
+}
+
+// NewExperimentMiddleware creates new experiment middleware
+func NewExperimentMiddleware(experimentService services.IExperimentService, logger *core.Logger) *ExperimentMiddleware {
+	return &ExperimentMiddleware{
+		experimentService: experimentService,
+		logger:            logger,
+	}
+}
+
+// Setup sets up experiment middleware
+func (m *ExperimentMiddleware) Setup() {}
+
+// Handler handles middleware functionality, anonymous requests are in no experiment
+func (m *ExperimentMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserID(c)
+		if err != nil || userID == "" {
+			c.Next()
+			return
+		}
+
+		assignments := m.experimentService.Assign(userID)
+		c.Set(constants.CtxKey_Experiments, assignments)
+		c.Request = c.Request.WithContext(core.WithExperimentAssignments(c.Request.Context(), assignments))
+		c.Next()
+	}
+}
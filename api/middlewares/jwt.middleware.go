@@ -16,9 +16,10 @@ import (
 
 // JWTMiddleware middleware for jwt authentication
 type JWTMiddleware struct {
-	env     *core.Env
-	service services.IAuthService
-	logger  *core.Logger
+	env             *core.Env
+	service         services.IAuthService
+	activityService services.IActivityService
+	logger          *core.Logger
 }
 
 // NewJWTMiddleware creates new jwt auth middleware
@@ -26,18 +27,20 @@ func NewJWTMiddleware(
 	env *core.Env,
 	logger *core.Logger,
 	service services.IAuthService,
+	activityService services.IActivityService,
 ) *JWTMiddleware {
 	return &JWTMiddleware{
-		env:     env,
-		service: service,
-		logger:  logger,
+		env:             env,
+		service:         service,
+		activityService: activityService,
+		logger:          logger,
 	}
 }
 
 // Setup sets up jwt auth middleware
 func (m *JWTMiddleware) Setup() {}
 
-// Handler handles middleware functionality
+// Handler handles middleware functionality, it also records the user as active
 func (m *JWTMiddleware) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.Request.Header.Get("Authorization")
@@ -47,6 +50,7 @@ func (m *JWTMiddleware) Handler() gin.HandlerFunc {
 			claim, err := m.service.Authorize(authToken)
 			if err == nil {
 				c.Set(constants.CtxKey_JWTClaim, claim)
+				m.activityService.Touch(claim.UserID)
 				c.Next()
 				return
 			}
@@ -101,8 +105,9 @@ func (m *JWTMiddleware) AuthorizationWithCookie() gin.HandlerFunc {
 			}
 
 			accessToken, _, err := m.service.Refresh(models.User{
-				Email: payload.UserEmail,
-				ID:    payload.UserID,
+				Email:          payload.UserEmail,
+				ID:             payload.UserID,
+				SessionVersion: payload.SessionVersion,
 			})
 
 			utils.AttachCookiesToResponse(m.env, accessToken, refreshToken, c)
@@ -8,6 +8,9 @@ import (
 var Module = fx.Options(
 	fx.Provide(NewCorsMiddleware),
 	fx.Provide(NewJWTMiddleware),
+	fx.Provide(NewAdminMiddleware),
+	fx.Provide(NewOnboardingMiddleware),
+	fx.Provide(NewExperimentMiddleware),
 	fx.Provide(NewMiddlewares),
 )
 
@@ -24,10 +27,16 @@ type Middlewares []IMiddleware
 func NewMiddlewares(
 	corsMiddleware *CorsMiddleware,
 	jwtMiddleware *JWTMiddleware,
+	adminMiddleware *AdminMiddleware,
+	onboardingMiddleware *OnboardingMiddleware,
+	experimentMiddleware *ExperimentMiddleware,
 ) Middlewares {
 	return Middlewares{
 		corsMiddleware,
 		jwtMiddleware,
+		adminMiddleware,
+		onboardingMiddleware,
+		experimentMiddleware,
 	}
 }
 
@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+// OnboardingMiddleware middleware restricting routes to users who finished the required
+// onboarding steps, it must run after JWTMiddleware.Handler
+type OnboardingMiddleware struct {
+	onboardingService services.IOnboardingService
+	logger            *core.Logger
+}
+
+// NewOnboardingMiddleware creates new onboarding middleware
+func NewOnboardingMiddleware(onboardingService services.IOnboardingService, logger *core.Logger) *OnboardingMiddleware {
+	return &OnboardingMiddleware{
+		onboardingService: onboardingService,
+		logger:            logger,
+	}
+}
+
+// Setup sets up onboarding middleware
+func (m *OnboardingMiddleware) Setup() {}
+
+// Handler handles middleware functionality, the state is returned so the client knows
+// which step is missing
+func (m *OnboardingMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.GetUserID(c)
+		if err != nil || userID == "" {
+			c.JSON(http.StatusUnauthorized, models.HTTPResponse{
+				Message: "you are not authorized",
+			})
+			c.Abort()
+			return
+		}
+
+		state, err := m.onboardingService.State(userID)
+		if err != nil {
+			m.logger.Errorf("fail to load onboarding state [%v]: [%v]", userID, err)
+			c.JSON(http.StatusInternalServerError, models.HTTPResponse{
+				Message: "server error",
+			})
+			c.Abort()
+			return
+		}
+
+		if !state.Complete {
+			c.JSON(http.StatusForbidden, models.HTTPResponse{
+				Message: services.ErrOnboardingIncomplete.Error(),
+				Data:    state,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/api/middlewares"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// AccountRouter struct
+type AccountRouter struct {
+	handler           *core.RequestHandler
+	accountController *controllers.AccountController
+	authMiddleware    *middlewares.JWTMiddleware
+}
+
+// Setup account routes
+func (r *AccountRouter) Setup() {
+	api := r.handler.Gin.Group("/api/account").Use(r.authMiddleware.Handler())
+	{
+		api.DELETE("", r.accountController.DeleteAccount)
+		api.POST("/restore", r.accountController.RestoreAccount)
+		api.GET("/export", r.accountController.ExportAccount)
+	}
+}
+
+// NewAccountRouter creates new account router
+func NewAccountRouter(
+	handler *core.RequestHandler,
+	accountController *controllers.AccountController,
+	authMiddleware *middlewares.JWTMiddleware,
+) *AccountRouter {
+	return &AccountRouter{
+		handler:           handler,
+		accountController: accountController,
+		authMiddleware:    authMiddleware,
+	}
+}
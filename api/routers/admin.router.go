@@ -0,0 +1,56 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/api/middlewares"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// AdminRouter struct
+type AdminRouter struct {
+	handler                *core.RequestHandler
+	authController         *controllers.AuthController
+	verificationController *controllers.VerificationController
+	profileController      *controllers.ProfileController
+	experimentController   *controllers.ExperimentController
+	authMiddleware         *middlewares.JWTMiddleware
+	adminMiddleware        *middlewares.AdminMiddleware
+}
+
+// Setup admin routes
+func (r *AdminRouter) Setup() {
+	api := r.handler.Gin.Group("/api/admin").Use(r.authMiddleware.Handler(), r.adminMiddleware.Handler())
+	{
+		api.POST("/auth/unlock", r.authController.UnlockAccount)
+		api.DELETE("/users/:id/mfa", r.authController.ResetMFA)
+		api.GET("/verifications", r.verificationController.ListReviewQueue)
+		api.POST("/verifications/:id/approve", r.verificationController.ApproveVerification)
+		api.POST("/verifications/:id/reject", r.verificationController.RejectVerification)
+		api.GET("/photos", r.profileController.ListPhotoReviewQueue)
+		api.POST("/photos/:photo_id/approve", r.profileController.ApprovePhoto)
+		api.POST("/photos/:photo_id/reject", r.profileController.RejectPhoto)
+		api.GET("/experiments", r.experimentController.ListExperiments)
+		api.GET("/experiments/users/:id", r.experimentController.GetUserAssignments)
+	}
+}
+
+// NewAdminRouter creates new admin router
+func NewAdminRouter(
+	handler *core.RequestHandler,
+	authController *controllers.AuthController,
+	verificationController *controllers.VerificationController,
+	profileController *controllers.ProfileController,
+	experimentController *controllers.ExperimentController,
+	authMiddleware *middlewares.JWTMiddleware,
+	adminMiddleware *middlewares.AdminMiddleware,
+) *AdminRouter {
+	return &AdminRouter{
+		handler:                handler,
+		authController:         authController,
+		verificationController: verificationController,
+		profileController:      profileController,
+		experimentController:   experimentController,
+		authMiddleware:         authMiddleware,
+		adminMiddleware:        adminMiddleware,
+	}
+}
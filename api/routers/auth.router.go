@@ -25,6 +25,13 @@ func (s *AuthRouter) Setup() {
 		auth.POST("/verify-email", s.authController.VerifyEmail)
 		auth.POST("/send-verification-email", s.authController.SendVerificationEmail)
 		auth.POST("/refresh", s.authMiddleware.Handler(), s.authController.Refresh)
+		auth.POST("/oidc/:provider", s.authController.OIDCSignIn)
+		auth.POST("/mfa/verify", s.authController.VerifyMFA)
+		auth.POST("/mfa/totp", s.authMiddleware.Handler(), s.authController.BeginMFAEnrolment)
+		auth.POST("/mfa/totp/confirm", s.authMiddleware.Handler(), s.authController.ConfirmMFAEnrolment)
+		auth.GET("/identities", s.authMiddleware.Handler(), s.authController.ListIdentities)
+		auth.POST("/identities/:provider", s.authMiddleware.Handler(), s.authController.LinkIdentity)
+		auth.DELETE("/identities/:provider", s.authMiddleware.Handler(), s.authController.UnlinkIdentity)
 	}
 }
 
@@ -0,0 +1,31 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// BlobRouter struct
+type BlobRouter struct {
+	handler        *core.RequestHandler
+	blobController *controllers.BlobController
+}
+
+// Setup blob routes, only when files are kept by the local store
+func (r *BlobRouter) Setup() {
+	if !r.blobController.Serves() {
+		return
+	}
+	r.handler.Gin.GET(core.LocalBlobRoute+"/*path", r.blobController.ServeBlob)
+}
+
+// NewBlobRouter creates new blob router
+func NewBlobRouter(
+	handler *core.RequestHandler,
+	blobController *controllers.BlobController,
+) *BlobRouter {
+	return &BlobRouter{
+		handler:        handler,
+		blobController: blobController,
+	}
+}
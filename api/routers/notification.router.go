@@ -0,0 +1,38 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/api/middlewares"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// NotificationRouter struct
+type NotificationRouter struct {
+	handler                *core.RequestHandler
+	notificationController *controllers.NotificationController
+	authMiddleware         *middlewares.JWTMiddleware
+}
+
+// Setup notification routes
+func (r *NotificationRouter) Setup() {
+	api := r.handler.Gin.Group("/api").Use(r.authMiddleware.Handler())
+	{
+		api.POST("/devices", r.notificationController.RegisterDevice)
+		api.DELETE("/devices", r.notificationController.UnregisterDevice)
+		api.GET("/notifications/preferences", r.notificationController.GetPreferences)
+		api.PUT("/notifications/preferences", r.notificationController.UpdatePreferences)
+	}
+}
+
+// NewNotificationRouter creates new notification router
+func NewNotificationRouter(
+	handler *core.RequestHandler,
+	notificationController *controllers.NotificationController,
+	authMiddleware *middlewares.JWTMiddleware,
+) *NotificationRouter {
+	return &NotificationRouter{
+		handler:                handler,
+		notificationController: notificationController,
+		authMiddleware:         authMiddleware,
+	}
+}
@@ -0,0 +1,35 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/api/middlewares"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// OnboardingRouter struct
+type OnboardingRouter struct {
+	handler              *core.RequestHandler
+	onboardingController *controllers.OnboardingController
+	authMiddleware       *middlewares.JWTMiddleware
+}
+
+// Setup onboarding routes
+func (r *OnboardingRouter) Setup() {
+	api := r.handler.Gin.Group("/api").Use(r.authMiddleware.Handler())
+	{
+		api.GET("/onboarding", r.onboardingController.GetOnboarding)
+	}
+}
+
+// NewOnboardingRouter creates new onboarding router
+func NewOnboardingRouter(
+	handler *core.RequestHandler,
+	onboardingController *controllers.OnboardingController,
+	authMiddleware *middlewares.JWTMiddleware,
+) *OnboardingRouter {
+	return &OnboardingRouter{
+		handler:              handler,
+		onboardingController: onboardingController,
+		authMiddleware:       authMiddleware,
+	}
+}
@@ -0,0 +1,36 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/api/middlewares"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// PhoneRouter struct
+type PhoneRouter struct {
+	handler         *core.RequestHandler
+	phoneController *controllers.PhoneController
+	authMiddleware  *middlewares.JWTMiddleware
+}
+
+// Setup phone routes
+func (r *PhoneRouter) Setup() {
+	api := r.handler.Gin.Group("/api/phone").Use(r.authMiddleware.Handler())
+	{
+		api.POST("/send-code", r.phoneController.SendCode)
+		api.POST("/verify", r.phoneController.VerifyCode)
+	}
+}
+
+// NewPhoneRouter creates new phone router
+func NewPhoneRouter(
+	handler *core.RequestHandler,
+	phoneController *controllers.PhoneController,
+	authMiddleware *middlewares.JWTMiddleware,
+) *PhoneRouter {
+	return &PhoneRouter{
+		handler:         handler,
+		phoneController: phoneController,
+		authMiddleware:  authMiddleware,
+	}
+}
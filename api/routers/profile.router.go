@@ -16,12 +16,25 @@ type ProfileRouter struct {
 func (r *ProfileRouter) Setup() {
 	api := r.handler.Gin.Group("/api").Use(r.authMiddleware.Handler())
 	{
+		api.GET("/profile/taxonomy", r.profileController.GetTaxonomy)
+		api.GET("/profile/preferences", r.profileController.GetPreferences)
+		api.PUT("/profile/preferences", r.profileController.SavePreferences)
+		api.PUT("/profile/visibility", r.profileController.UpdateVisibility)
+		api.GET("/profile/travel", r.profileController.GetTravel)
+		api.GET("/profile/travel/cities", r.profileController.ListTravelCities)
+		api.PUT("/profile/travel", r.profileController.SetTravel)
+		api.DELETE("/profile/travel", r.profileController.ClearTravel)
 		api.GET("/profile/:id", r.profileController.GetProfileById)
 		api.GET("/profile", r.profileController.GetMyProfile)
 		api.POST("/profile", r.profileController.CreateProfile)
-		api.POST("/profile/upload-image", r.profileController.UploadImage)
-		api.DELETE("/profile/remove-image", r.profileController.RemoveImage)
+		api.GET("/profile/photos", r.profileController.ListPhotos)
+		api.POST("/profile/photos", r.profileController.UploadPhotos)
+		api.PUT("/profile/photos/order", r.profileController.ReorderPhotos)
+		api.PUT("/profile/photos/:photo_id", r.profileController.ReplacePhoto)
+		api.PUT("/profile/photos/:photo_id/primary", r.profileController.SetPrimaryPhoto)
+		api.DELETE("/profile/photos/:photo_id", r.profileController.DeletePhoto)
 		api.PUT("/profile", r.profileController.UpdateProfile)
+		api.PATCH("/profile", r.profileController.PatchProfile)
 		api.DELETE("/profile", r.profileController.DeleteProfile)
 	}
 }
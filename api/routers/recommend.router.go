@@ -8,9 +8,11 @@ import (
 
 // RecommendRouter struct
 type RecommendRouter struct {
-	handler             *core.RequestHandler
-	recommendController *controllers.RecommendController
-	authMiddleware      *middlewares.JWTMiddleware
+	handler              *core.RequestHandler
+	recommendController  *controllers.RecommendController
+	authMiddleware       *middlewares.JWTMiddleware
+	onboardingMiddleware *middlewares.OnboardingMiddleware
+	experimentMiddleware *middlewares.ExperimentMiddleware
 }
 
 func (r *RecommendRouter) Setup() {
@@ -20,9 +22,17 @@ func (r *RecommendRouter) Setup() {
 		api.GET("/get-matches", r.recommendController.GetUserMatches)
 		api.GET("/get-answers", r.recommendController.GetUserAnswers)
 		api.GET("/get-questions", r.recommendController.GetQuestions)
-		api.GET("/get-recommendations", r.recommendController.GetRecommendations)
-		api.POST("/smash", r.recommendController.Smash)
-		api.POST("/pass", r.recommendController.Pass)
+	}
+
+	onboarded := r.handler.Gin.Group("/api").Use(
+		r.authMiddleware.Handler(),
+		r.onboardingMiddleware.Handler(),
+		r.experimentMiddleware.Handler(),
+	)
+	{
+		onboarded.GET("/get-recommendations", r.recommendController.GetRecommendations)
+		onboarded.POST("/smash", r.recommendController.Smash)
+		onboarded.POST("/pass", r.recommendController.Pass)
 	}
 }
 
@@ -30,10 +40,14 @@ func NewRecommendRouter(
 	handler *core.RequestHandler,
 	recommendController *controllers.RecommendController,
 	authMiddleware *middlewares.JWTMiddleware,
+	onboardingMiddleware *middlewares.OnboardingMiddleware,
+	experimentMiddleware *middlewares.ExperimentMiddleware,
 ) *RecommendRouter {
 	return &RecommendRouter{
-		handler:             handler,
-		recommendController: recommendController,
-		authMiddleware:      authMiddleware,
+		handler:              handler,
+		recommendController:  recommendController,
+		authMiddleware:       authMiddleware,
+		onboardingMiddleware: onboardingMiddleware,
+		experimentMiddleware: experimentMiddleware,
 	}
 }
@@ -10,6 +10,13 @@ var Module = fx.Options(
 	fx.Provide(NewAuthRouter),
 	fx.Provide(NewProfileRouter),
 	fx.Provide(NewRecommendRouter),
+	fx.Provide(NewAdminRouter),
+	fx.Provide(NewPhoneRouter),
+	fx.Provide(NewAccountRouter),
+	fx.Provide(NewBlobRouter),
+	fx.Provide(NewVerificationRouter),
+	fx.Provide(NewOnboardingRouter),
+	fx.Provide(NewNotificationRouter),
 	fx.Provide(NewRouters),
 )
 
@@ -27,12 +34,26 @@ func NewRouters(
 	authRouter *AuthRouter,
 	profileRouter *ProfileRouter,
 	recommendRouter *RecommendRouter,
+	adminRouter *AdminRouter,
+	phoneRouter *PhoneRouter,
+	accountRouter *AccountRouter,
+	blobRouter *BlobRouter,
+	verificationRouter *VerificationRouter,
+	onboardingRouter *OnboardingRouter,
+	notificationRouter *NotificationRouter,
 ) Routers {
 	return Routers{
 		userRouter,
 		authRouter,
 		profileRouter,
 		recommendRouter,
+		adminRouter,
+		phoneRouter,
+		accountRouter,
+		blobRouter,
+		verificationRouter,
+		onboardingRouter,
+		notificationRouter,
 	}
 }
 
@@ -0,0 +1,37 @@
+package routers
+
+import (
+	"github.com/hodukihugi/winglets-api/api/controllers"
+	"github.com/hodukihugi/winglets-api/api/middlewares"
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+// VerificationRouter struct
+type VerificationRouter struct {
+	handler                *core.RequestHandler
+	verificationController *controllers.VerificationController
+	authMiddleware         *middlewares.JWTMiddleware
+}
+
+// Setup verification routes
+func (r *VerificationRouter) Setup() {
+	api := r.handler.Gin.Group("/api/profile/verification").Use(r.authMiddleware.Handler())
+	{
+		api.GET("", r.verificationController.GetVerification)
+		api.POST("/challenge", r.verificationController.RequestChallenge)
+		api.POST("/selfie", r.verificationController.SubmitSelfie)
+	}
+}
+
+// NewVerificationRouter creates new verification router
+func NewVerificationRouter(
+	handler *core.RequestHandler,
+	verificationController *controllers.VerificationController,
+	authMiddleware *middlewares.JWTMiddleware,
+) *VerificationRouter {
+	return &VerificationRouter{
+		handler:                handler,
+		verificationController: verificationController,
+		authMiddleware:         authMiddleware,
+	}
+}
@@ -10,7 +10,12 @@ import (
 )
 
 var cmds = map[string]core.Command{
-	"app:serve": NewServeCommand(),
+	"app:serve":        NewServeCommand(),
+	"jobs:list":        NewJobsListCommand(),
+	"jobs:retry":       NewJobsRetryCommand(),
+	"profiles:geocode": NewProfilesGeocodeCommand(),
+	"reco:evaluate":    NewRecoEvaluateCommand(),
+	"reco:compare":     NewRecoCompareCommand(),
 }
 
 // GetSubCommands gives a list of sub commands
@@ -41,6 +46,9 @@ func WrapSubCommand(name string, cmd core.Command, opt fx.Option) *cobra.Command
 			if err != nil {
 				logger.Fatal(err)
 			}
+			if _, ok := cmd.(core.DaemonCommand); ok {
+				<-app.Done()
+			}
 		},
 	}
 	cmd.Setup(wrappedCmd)
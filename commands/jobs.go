@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/spf13/cobra"
+)
+
+// JobsListCommand prints the jobs of the outbox
+type JobsListCommand struct {
+	status string
+	limit  int
+}
+
+func (c *JobsListCommand) Short() string {
+	return "list background jobs"
+}
+
+func (c *JobsListCommand) Setup(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&c.status, "status", "s", "", "only list jobs in this status (pending, running, done, dead)")
+	cmd.Flags().IntVarP(&c.limit, "limit", "n", 50, "maximum number of jobs to list")
+}
+
+func (c *JobsListCommand) Run() core.CommandRunner {
+	return func(jobService services.IJobService, logger *core.Logger) {
+		jobs, err := jobService.List(models.JobFilter{Status: c.status, Limit: c.limit})
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTYPE\tSTATUS\tATTEMPTS\tRUN AT\tLAST ERROR")
+		for _, job := range jobs {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d/%d\t%s\t%s\n",
+				job.ID, job.Type, job.Status, job.Attempts, job.MaxAttempts,
+				job.RunAt.Format("2006-01-02 15:04:05"), job.LastError)
+		}
+		_ = w.Flush()
+	}
+}
+
+func NewJobsListCommand() *JobsListCommand {
+	return &JobsListCommand{}
+}
+
+// JobsRetryCommand puts dead jobs back in the queue
+type JobsRetryCommand struct {
+	ids     []uint
+	allDead bool
+}
+
+func (c *JobsRetryCommand) Short() string {
+	return "retry dead background jobs"
+}
+
+func (c *JobsRetryCommand) Setup(cmd *cobra.Command) {
+	cmd.Flags().UintSliceVar(&c.ids, "id", nil, "ids of the dead jobs to retry")
+	cmd.Flags().BoolVar(&c.allDead, "all-dead", false, "retry every dead job")
+}
+
+func (c *JobsRetryCommand) Run() core.CommandRunner {
+	return func(jobService services.IJobService, logger *core.Logger) {
+		ids := c.ids
+		if c.allDead {
+			jobs, err := jobService.List(models.JobFilter{Status: models.JobStatusDead})
+			if err != nil {
+				logger.Fatal(err)
+			}
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+			}
+		}
+
+		if len(ids) == 0 {
+			logger.Info("No job to retry, pass --id or --all-dead")
+			return
+		}
+
+		for _, id := range ids {
+			if err := jobService.Retry(id); err != nil {
+				if errors.Is(err, services.ErrJobNotRetryable) {
+					logger.Warnf("job %d is not dead, skipping it", id)
+					continue
+				}
+				logger.Errorf("fail to retry job %d: [%v]", id, err)
+				continue
+			}
+			logger.Infof("job %d queued again", id)
+		}
+	}
+}
+
+func NewJobsRetryCommand() *JobsRetryCommand {
+	return &JobsRetryCommand{}
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/spf13/cobra"
+)
+
+// ProfilesGeocodeCommand fills the city-level location of profiles saved before it existed
+type ProfilesGeocodeCommand struct{}
+
+func (c *ProfilesGeocodeCommand) Short() string {
+	return "fill the location of profiles from their coordinates"
+}
+
+func (c *ProfilesGeocodeCommand) Setup(cmd *cobra.Command) {}
+
+func (c *ProfilesGeocodeCommand) Run() core.CommandRunner {
+	return func(profileService services.IProfileService, logger *core.Logger) {
+		located, err := profileService.GeocodeMissingLocations()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("%d profiles located", located)
+	}
+}
+
+func NewProfilesGeocodeCommand() *ProfilesGeocodeCommand {
+	return &ProfilesGeocodeCommand{}
+}
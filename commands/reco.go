@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/services"
+	"github.com/spf13/cobra"
+)
+
+// RecoEvaluateCommand measures offline how a scorer and ranker would have ordered feeds
+type RecoEvaluateCommand struct {
+	options models.RecoEvaluationOptions
+	out     string
+}
+
+func (c *RecoEvaluateCommand) Short() string {
+	return "evaluate recommendations against mutual matches"
+}
+
+func (c *RecoEvaluateCommand) Setup(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&c.options.Source, "source", models.RecoEvaluationSourceHistorical, "data to replay (historical, synthetic)")
+	cmd.Flags().IntVar(&c.options.Users, "users", 200, "size of the synthetic population")
+	cmd.Flags().Int64Var(&c.options.Seed, "seed", 1, "seed of the synthetic population, the random scorer and the ranker")
+	cmd.Flags().StringVar(&c.options.Scorer, "scorer", models.RecoEvaluationScorerAnswers, "scorer (answers, random)")
+	cmd.Flags().StringVar(&c.options.Ranker, "ranker", "weighted", "ranker (weighted, match)")
+	cmd.Flags().IntVarP(&c.options.K, "k", "k", 10, "size of the top of the feed")
+	cmd.Flags().StringVarP(&c.out, "out", "o", "", "report file, .csv writes CSV, anything else JSON (default stdout)")
+}
+
+func (c *RecoEvaluateCommand) Run() core.CommandRunner {
+	return func(service services.IRecoEvaluationService, logger *core.Logger) {
+		report, err := service.Evaluate(c.options)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if err = writeRecoReport(c.out, report, func(out *os.File) error {
+			return writeRecoReportCSV(out, report)
+		}); err != nil {
+			logger.Fatal(err)
+		}
+	}
+}
+
+func NewRecoEvaluateCommand() *RecoEvaluateCommand {
+	return &RecoEvaluateCommand{}
+}
+
+// RecoCompareCommand compares the recorded outcomes of the variants of an experiment
+type RecoCompareCommand struct {
+	experiment string
+	out        string
+}
+
+func (c *RecoCompareCommand) Short() string {
+	return "compare the variants of a recommendation experiment"
+}
+
+func (c *RecoCompareCommand) Setup(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&c.experiment, "experiment", "e", "", "name of the experiment")
+	cmd.Flags().StringVarP(&c.out, "out", "o", "", "report file, .csv writes CSV, anything else JSON (default stdout)")
+	_ = cmd.MarkFlagRequired("experiment")
+}
+
+func (c *RecoCompareCommand) Run() core.CommandRunner {
+	return func(service services.IRecoEvaluationService, logger *core.Logger) {
+		report, err := service.CompareExperiment(c.experiment)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if err = writeRecoReport(c.out, report, func(out *os.File) error {
+			return writeExperimentReportCSV(out, report)
+		}); err != nil {
+			logger.Fatal(err)
+		}
+	}
+}
+
+func NewRecoCompareCommand() *RecoCompareCommand {
+	return &RecoCompareCommand{}
+}
+
+// writeRecoReport writes report to path, or stdout when it is empty, as CSV with writeCSV when
+// path ends in .csv and as JSON otherwise
+func writeRecoReport(path string, report interface{}, writeCSV func(out *os.File) error) error {
+	out := os.Stdout
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return writeCSV(out)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeExperimentReportCSV writes one row per variant
+func writeExperimentReportCSV(out *os.File, report *models.ExperimentReport) error {
+	rows := [][]string{
+		{"experiment", "variant", "users", "exposures", "likes", "passes", "matches", "like_rate", "match_rate"},
+	}
+	for _, variant := range report.Variants {
+		rows = append(rows, []string{
+			report.Experiment,
+			variant.Variant,
+			strconv.Itoa(variant.Users),
+			strconv.Itoa(variant.Exposures),
+			strconv.Itoa(variant.Likes),
+			strconv.Itoa(variant.Passes),
+			strconv.Itoa(variant.Matches),
+			strconv.FormatFloat(variant.LikeRate, 'f', 6, 64),
+			strconv.FormatFloat(variant.MatchRate, 'f', 6, 64),
+		})
+	}
+
+	writer := csv.NewWriter(out)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// writeRecoReportCSV writes one metric,value row per figure, buckets included
+func writeRecoReportCSV(out *os.File, report *models.RecoEvaluationReport) error {
+	float := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 6, 64)
+	}
+	distribution := report.ScoreDistribution
+	rows := [][]string{
+		{"metric", "value"},
+		{"source", report.Source},
+		{"scorer", report.Scorer},
+		{"ranker", report.Ranker},
+		{"seed", strconv.FormatInt(report.Seed, 10)},
+		{"k", strconv.Itoa(report.K)},
+		{"users", strconv.Itoa(report.Users)},
+		{"viewers", strconv.Itoa(report.Viewers)},
+		{"precision_at_k", float(report.PrecisionAtK)},
+		{"recall_at_k", float(report.RecallAtK)},
+		{"coverage", float(report.Coverage)},
+		{"scores", strconv.Itoa(distribution.Scored)},
+		{"scores_unscorable", strconv.Itoa(distribution.Unscorable)},
+		{"score_mean", float(distribution.Mean)},
+		{"score_p50", float(distribution.P50)},
+		{"score_p90", float(distribution.P90)},
+	}
+	for _, bucket := range distribution.Buckets {
+		rows = append(rows, []string{
+			fmt.Sprintf("score_bucket_%.1f_%.1f", bucket.From, bucket.To),
+			strconv.Itoa(bucket.Count),
+		})
+	}
+	rows = append(rows, []string{"generated_at", report.GeneratedAt.Format("2006-01-02T15:04:05Z07:00")})
+
+	writer := csv.NewWriter(out)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
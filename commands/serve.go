@@ -1,10 +1,16 @@
 package commands
 
 import (
+	"context"
+	"errors"
+	"net/http"
+
 	"github.com/hodukihugi/winglets-api/api/middlewares"
 	"github.com/hodukihugi/winglets-api/api/routers"
 	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/services"
 	"github.com/spf13/cobra"
+	"go.uber.org/fx"
 )
 
 // ServeCommand test command
@@ -16,24 +22,55 @@ func (s *ServeCommand) Short() string {
 
 func (s *ServeCommand) Setup(cmd *cobra.Command) {}
 
+// Daemon keeps the command running until the process is asked to stop
+func (s *ServeCommand) Daemon() {}
+
 func (s *ServeCommand) Run() core.CommandRunner {
 	return func(
+		lifecycle fx.Lifecycle,
 		middleware middlewares.Middlewares,
 		env *core.Env,
 		router *core.RequestHandler,
 		route routers.Routers,
 		logger *core.Logger,
 		database *core.Database,
+		jobService services.IJobService,
+		activityService services.IActivityService,
 	) {
 		middleware.Setup()
 		route.Setup()
 
-		logger.Info("Running server")
-		if env.ServerPort == "" {
-			_ = router.Gin.Run()
-		} else {
-			_ = router.Gin.Run(":" + env.ServerPort)
+		// hooks stop in reverse order: the server stops taking requests before the workers stop
+		lifecycle.Append(fx.Hook{
+			OnStart: jobService.Start,
+			OnStop:  jobService.Stop,
+		})
+		lifecycle.Append(fx.Hook{
+			OnStart: activityService.Start,
+			OnStop:  activityService.Stop,
+		})
+
+		addr := ":8080"
+		if env.ServerPort != "" {
+			addr = ":" + env.ServerPort
 		}
+		server := &http.Server{Addr: addr, Handler: router.Gin}
+
+		lifecycle.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				logger.Info("Running server")
+				go func() {
+					if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						logger.Fatal(err)
+					}
+				}()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				logger.Info("Stopping server")
+				return server.Shutdown(ctx)
+			},
+		})
 	}
 }
 
@@ -3,4 +3,5 @@ package constants
 const (
 	CtxKey_JWTClaim    = "jwt_claim"
 	CtxKey_RequestBody = "request_body" // this is to pass the request body to other handler after already parsed it
+	CtxKey_Experiments = "experiments"  // the experiment variants the user is in
 )
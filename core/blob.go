@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+const defaultBlobURLTTL = time.Hour
+
+// Blob is a stored file. ID is what Delete takes, Path is what SignedURL takes.
+// Only the path is kept, urls are signed when the blob is shown.
+type Blob struct {
+	ID   string
+	Path string
+}
+
+// BlobStore keeps uploaded files, pick the implementation with the BLOB_STORE env
+type BlobStore interface {
+	// Upload stores content as folder/name
+	Upload(ctx context.Context, folder, name string, content []byte) (*Blob, error)
+	// Delete removes the blob, deleting a missing blob is not an error
+	Delete(ctx context.Context, id string) error
+	// SignedURL returns a url to the blob at path, valid for ttl
+	SignedURL(path string, ttl time.Duration) (string, error)
+}
+
+// NewBlobStore creates the store configured by the env: imagekit (default) or local.
+// The local store signs with BLOB_SIGNING_KEY, which must not be the JWT secret.
+func NewBlobStore(env *Env, logger *Logger, clock Clock, ik *ImageKit) BlobStore {
+	switch env.BlobStore {
+	case "local":
+		if env.BlobSigningKey == "" || env.BlobSigningKey == env.JWTSecret {
+			logger.Panic("BLOB_SIGNING_KEY must be set, and differ from JWT_SECRET, for the local blob store")
+		}
+		return NewLocalBlobStore(env, clock)
+	case "", "imagekit":
+		return NewImageKitBlobStore(ik, clock)
+	default:
+		logger.Warnf("unknown blob store [%v], falling back to imagekit", env.BlobStore)
+		return NewImageKitBlobStore(ik, clock)
+	}
+}
+
+// BlobURLSigner gives out the urls shown to clients, valid for BLOB_URL_TTL
+type BlobURLSigner struct {
+	logger *Logger
+	store  BlobStore
+	ttl    time.Duration
+}
+
+// NewBlobURLSigner creates a new blob url signer
+func NewBlobURLSigner(env *Env, logger *Logger, store BlobStore) *BlobURLSigner {
+	ttl := env.BlobURLTTL
+	if ttl <= 0 {
+		ttl = defaultBlobURLTTL
+	}
+	return &BlobURLSigner{logger: logger, store: store, ttl: ttl}
+}
+
+// Sign returns a short-lived url to the blob at path, or "" when there is none
+func (s *BlobURLSigner) Sign(path string) string {
+	if path == "" {
+		return ""
+	}
+	signed, err := s.store.SignedURL(path, s.ttl)
+	if err != nil {
+		s.logger.Errorf("fail to sign blob url, path [%v], error [%v]", path, err)
+		return ""
+	}
+	return signed
+}
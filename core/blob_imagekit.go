@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	ikapi "github.com/imagekit-developer/imagekit-go/api"
+	"github.com/imagekit-developer/imagekit-go/api/uploader"
+	ikurl "github.com/imagekit-developer/imagekit-go/url"
+)
+
+// ImageKitBlobStore keeps files on ImageKit
+type ImageKitBlobStore struct {
+	ik    *ImageKit
+	clock Clock
+}
+
+// NewImageKitBlobStore creates a new ImageKit blob store
+func NewImageKitBlobStore(ik *ImageKit, clock Clock) *ImageKitBlobStore {
+	return &ImageKitBlobStore{ik: ik, clock: clock}
+}
+
+// Upload sends the file to ImageKit, which may add a suffix to keep the name unique
+func (s *ImageKitBlobStore) Upload(ctx context.Context, folder, name string, content []byte) (*Blob, error) {
+	response, err := s.ik.Uploader.Upload(ctx, base64.StdEncoding.EncodeToString(content), uploader.UploadParam{
+		FileName: name,
+		Folder:   folder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Blob{
+		ID:   response.Data.FileId,
+		Path: response.Data.FilePath,
+	}, nil
+}
+
+// Delete removes the file by its ImageKit file id
+func (s *ImageKitBlobStore) Delete(ctx context.Context, id string) error {
+	_, err := s.ik.Media.DeleteFile(ctx, id)
+	if errors.Is(err, ikapi.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// SignedURL signs the path and its expiry with the ImageKit private key
+func (s *ImageKitBlobStore) SignedURL(path string, ttl time.Duration) (string, error) {
+	if ttl < time.Second {
+		return "", fmt.Errorf("blob url ttl must be at least a second, got %v", ttl)
+	}
+	return s.ik.Url(ikurl.UrlParam{
+		Path:          path,
+		Signed:        true,
+		ExpireSeconds: int64(ttl / time.Second),
+		UnixTime:      func() int64 { return s.clock.Now().Unix() },
+	})
+}
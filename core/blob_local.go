@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalBlobRoute is where LocalBlobStore files are served from
+const LocalBlobRoute = "/blobs"
+
+var ErrBlobSignatureInvalid = errors.New("invalid or expired blob signature")
+
+// LocalBlobStore keeps files in a directory and serves them under signed urls,
+// for local development and CI without ImageKit keys
+type LocalBlobStore struct {
+	clock   Clock
+	dir     string
+	baseURL string
+	key     []byte
+}
+
+// NewLocalBlobStore creates a new local blob store from BLOB_LOCAL_DIR, BLOB_PUBLIC_URL and BLOB_SIGNING_KEY
+func NewLocalBlobStore(env *Env, clock Clock) *LocalBlobStore {
+	dir := env.BlobLocalDir
+	if dir == "" {
+		dir = filepath.Join("tmp", "blobs")
+	}
+
+	baseURL := env.BlobPublicURL
+	if baseURL == "" {
+		port := env.ServerPort
+		if port == "" {
+			port = "8080"
+		}
+		baseURL = "http://localhost:" + port
+	}
+
+	return &LocalBlobStore{
+		clock:   clock,
+		dir:     dir,
+		baseURL: strings.TrimRight(baseURL, "/") + LocalBlobRoute,
+		key:     []byte(env.BlobSigningKey),
+	}
+}
+
+// Upload writes the file, the path doubles as its id
+func (s *LocalBlobStore) Upload(ctx context.Context, folder, name string, content []byte) (*Blob, error) {
+	blobPath, err := cleanBlobPath(path.Join(folder, name))
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(s.dir, filepath.FromSlash(blobPath))
+	if err = os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(file, content, 0o644); err != nil {
+		return nil, err
+	}
+
+	return &Blob{ID: blobPath, Path: blobPath}, nil
+}
+
+// Delete removes the file
+func (s *LocalBlobStore) Delete(ctx context.Context, id string) error {
+	blobPath, err := cleanBlobPath(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(s.dir, filepath.FromSlash(blobPath)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SignedURL returns a url carrying an hmac of the path and its expiry
+func (s *LocalBlobStore) SignedURL(blobPath string, ttl time.Duration) (string, error) {
+	blobPath, err := cleanBlobPath(blobPath)
+	if err != nil {
+		return "", err
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("blob url ttl must be positive, got %v", ttl)
+	}
+
+	expires := s.clock.Now().Add(ttl).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(blobPath, expires))
+	return s.baseURL + "/" + blobPath + "?" + query.Encode(), nil
+}
+
+// Open checks the signature of a request and returns the file to serve, urls without an
+// expiry are refused
+func (s *LocalBlobStore) Open(blobPath, expires, signature string, now time.Time) (string, error) {
+	blobPath, err := cleanBlobPath(blobPath)
+	if err != nil {
+		return "", ErrBlobNotFound
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || expiresAt <= 0 || now.Unix() > expiresAt {
+		return "", ErrBlobSignatureInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(blobPath, expiresAt))) {
+		return "", ErrBlobSignatureInvalid
+	}
+
+	file := filepath.Join(s.dir, filepath.FromSlash(blobPath))
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return "", ErrBlobNotFound
+	}
+	return file, nil
+}
+
+// ----------------- private -----------------
+
+func (s *LocalBlobStore) sign(blobPath string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(fmt.Sprintf("%s\n%d", blobPath, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// cleanBlobPath keeps paths relative and inside the store directory
+func cleanBlobPath(blobPath string) (string, error) {
+	cleaned := path.Clean("/" + strings.TrimLeft(blobPath, "/"))[1:]
+	if cleaned == "" || cleaned != strings.TrimLeft(blobPath, "/") {
+		return "", fmt.Errorf("invalid blob path %q", blobPath)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLocalBlobStore(t *testing.T, now time.Time) *LocalBlobStore {
+	t.Helper()
+	return NewLocalBlobStore(&Env{
+		BlobLocalDir:   t.TempDir(),
+		BlobPublicURL:  "https://api.test/",
+		BlobSigningKey: "blob-signing-key",
+	}, fixedClock{now: now})
+}
+
+// openURL serves a signed url the way BlobController does
+func openURL(t *testing.T, store *LocalBlobStore, signed string, now time.Time) error {
+	t.Helper()
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Open(
+		strings.TrimPrefix(parsed.Path, LocalBlobRoute+"/"),
+		parsed.Query().Get("expires"),
+		parsed.Query().Get("signature"),
+		now,
+	)
+	return err
+}
+
+func TestLocalBlobStore_SignedURL(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	store := newTestLocalBlobStore(t, now)
+
+	blob, err := store.Upload(context.Background(), "user-1", "photo.jpg", []byte("jpeg"))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	signed, err := store.SignedURL(blob.Path, time.Hour)
+	if err != nil {
+		t.Fatalf("SignedURL() error = %v", err)
+	}
+	if !strings.HasPrefix(signed, "https://api.test/blobs/user-1/photo.jpg?") {
+		t.Fatalf("SignedURL() = %s", signed)
+	}
+	if expires := now.Add(time.Hour).Unix(); !strings.Contains(signed, "expires="+strconv.FormatInt(expires, 10)) {
+		t.Fatalf("SignedURL() = %s, want it to expire at %d", signed, expires)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		at   time.Time
+		want error
+	}{
+		{"fresh", signed, now, nil},
+		{"just before expiry", signed, now.Add(time.Hour), nil},
+		{"expired", signed, now.Add(time.Hour + time.Second), ErrBlobSignatureInvalid},
+		{"tampered path", strings.Replace(signed, "photo.jpg", "other.jpg", 1), now, ErrBlobSignatureInvalid},
+		{"tampered expiry", strings.Replace(signed, "expires=", "expires=9", 1), now, ErrBlobSignatureInvalid},
+		{"no expiry", "https://api.test/blobs/user-1/photo.jpg?expires=0&signature=" + store.sign("user-1/photo.jpg", 0), now, ErrBlobSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := openURL(t, store, tt.url, tt.at); !errors.Is(err, tt.want) {
+				t.Fatalf("Open() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalBlobStore_SignedURLNeedsTTL(t *testing.T) {
+	store := newTestLocalBlobStore(t, time.Now())
+	if _, err := store.SignedURL("user-1/photo.jpg", 0); err == nil {
+		t.Fatal("SignedURL() without ttl gave a url")
+	}
+}
+
+func TestLocalBlobStore_KeyIsNotShared(t *testing.T) {
+	now := time.Now()
+	store := newTestLocalBlobStore(t, now)
+	signed, err := store.SignedURL("user-1/photo.jpg", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewLocalBlobStore(&Env{BlobLocalDir: store.dir, BlobSigningKey: "jwt-secret"}, fixedClock{now: now})
+	if err := openURL(t, other, signed, now); !errors.Is(err, ErrBlobSignatureInvalid) {
+		t.Fatalf("Open() with another key error = %v, want %v", err, ErrBlobSignatureInvalid)
+	}
+}
@@ -0,0 +1,21 @@
+package core
+
+import "time"
+
+// Clock tells the current time, services take it instead of calling time.Now
+// so tests can control it
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+// Now returns the current UTC time
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// NewClock creates the wall clock used by the application
+func NewClock() Clock {
+	return systemClock{}
+}
@@ -30,3 +30,10 @@ type Command interface {
 	//
 	Run() CommandRunner
 }
+
+// DaemonCommand is implemented by commands that keep running once the application has
+// started, e.g. the http server. They run until the process receives SIGINT or SIGTERM.
+type DaemonCommand interface {
+	Command
+	Daemon()
+}
@@ -10,4 +10,14 @@ var Module = fx.Options(
 	fx.Provide(NewDatabase),
 	fx.Provide(NewValidator),
 	fx.Provide(NewImageKit),
+	fx.Provide(NewBlobStore),
+	fx.Provide(NewBlobURLSigner),
+	fx.Provide(NewPhotoModerator),
+	fx.Provide(NewFaceMatcher),
+	fx.Provide(NewRanker),
+	fx.Provide(NewExperiments),
+	fx.Provide(NewClock),
+	fx.Provide(NewMailer),
+	fx.Provide(NewSmsSender),
+	fx.Provide(NewPushSender),
 )
@@ -21,13 +21,70 @@ type Env struct {
 	SmtpUser                   string        `mapstructure:"SMTP_USER"`
 	SmtpPassword               string        `mapstructure:"SMTP_PASS"`
 	SmtpHost                   string        `mapstructure:"SMTP_HOST"`
+	SmtpPort                   string        `mapstructure:"SMTP_PORT"`
+	SmtpFrom                   string        `mapstructure:"SMTP_FROM"`
+	SmtpTLSMode                string        `mapstructure:"SMTP_TLS_MODE"`
+	SmtpTLSSkipVerify          bool          `mapstructure:"SMTP_TLS_SKIP_VERIFY"`
+	Mailer                     string        `mapstructure:"MAILER"`
+	MailSinkDir                string        `mapstructure:"MAIL_SINK_DIR"`
+	SmsSender                  string        `mapstructure:"SMS_SENDER"`
+	JobWorkers                 int           `mapstructure:"JOB_WORKERS"`
+	JobPollInterval            time.Duration `mapstructure:"JOB_POLL_INTERVAL"`
+	JobMaxAttempts             int           `mapstructure:"JOB_MAX_ATTEMPTS"`
+	JobRetention               time.Duration `mapstructure:"JOB_RETENTION"`
+	PushSender                 string        `mapstructure:"PUSH_SENDER"`
+	PushFCMProjectID           string        `mapstructure:"PUSH_FCM_PROJECT_ID"`
+	PushFCMCredentialsFile     string        `mapstructure:"PUSH_FCM_CREDENTIALS_FILE"`
+	PushAPNsKeyFile            string        `mapstructure:"PUSH_APNS_KEY_FILE"`
+	PushAPNsKeyID              string        `mapstructure:"PUSH_APNS_KEY_ID"`
+	PushAPNsTeamID             string        `mapstructure:"PUSH_APNS_TEAM_ID"`
+	PushAPNsTopic              string        `mapstructure:"PUSH_APNS_TOPIC"`
+	PushAPNsSandbox            bool          `mapstructure:"PUSH_APNS_SANDBOX"`
+	NotificationDedupWindow    time.Duration `mapstructure:"NOTIFICATION_DEDUP_WINDOW"`
 	IkPublicKey                string        `mapstructure:"IK_PUBLIC_KEY"`
 	IkPrivateKey               string        `mapstructure:"IK_PRIVATE_KEY"`
 	IkUrlEndpoint              string        `mapstructure:"IK_URL_ENDPOINT"`
+	BlobStore                  string        `mapstructure:"BLOB_STORE"`
+	BlobLocalDir               string        `mapstructure:"BLOB_LOCAL_DIR"`
+	BlobPublicURL              string        `mapstructure:"BLOB_PUBLIC_URL"`
+	BlobSigningKey             string        `mapstructure:"BLOB_SIGNING_KEY"`
+	BlobURLTTL                 time.Duration `mapstructure:"BLOB_URL_TTL"`
+	PhotoModerator             string        `mapstructure:"PHOTO_MODERATOR"`
+	FaceMatcher                string        `mapstructure:"FACE_MATCHER"`
+	VerificationChallengeTTL   time.Duration `mapstructure:"VERIFICATION_CHALLENGE_EXPIRED_IN"`
+	Ranker                     string        `mapstructure:"RANKER"`
+	ExperimentsFile            string        `mapstructure:"EXPERIMENTS_FILE"`
+	ActivityDebounce           time.Duration `mapstructure:"ACTIVITY_DEBOUNCE"`
+	ActivityFlushInterval      time.Duration `mapstructure:"ACTIVITY_FLUSH_INTERVAL"`
+	RecommendMaxInactiveDays   int           `mapstructure:"RECOMMEND_MAX_INACTIVE_DAYS"`
+	OnboardingMinPhotos        int           `mapstructure:"ONBOARDING_MIN_PHOTOS"`
+	OnboardingMinAnswers       int           `mapstructure:"ONBOARDING_MIN_ANSWERS"`
+	OIDCGoogleClientIDs        string        `mapstructure:"OIDC_GOOGLE_CLIENT_IDS"`
+	OIDCGoogleIssuers          string        `mapstructure:"OIDC_GOOGLE_ISSUERS"`
+	OIDCGoogleJWKSURL          string        `mapstructure:"OIDC_GOOGLE_JWKS_URL"`
+	OIDCAppleClientIDs         string        `mapstructure:"OIDC_APPLE_CLIENT_IDS"`
+	OIDCAppleIssuers           string        `mapstructure:"OIDC_APPLE_ISSUERS"`
+	OIDCAppleJWKSURL           string        `mapstructure:"OIDC_APPLE_JWKS_URL"`
 	JWTSecret                  string        `mapstructure:"JWT_SECRET"`
 	AccessTokenExpiresIn       time.Duration `mapstructure:"ACCESS_TOKEN_EXPIRED_IN"`
 	RefreshTokenExpiresIn      time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRED_IN"`
 	EmailVerificationExpiresIn time.Duration `mapstructure:"EMAIL_VERIFICATION_EXPIRED_IN"`
+	EmailVerificationAttempts  int           `mapstructure:"EMAIL_VERIFICATION_MAX_ATTEMPTS"`
+	EmailVerificationCooldown  time.Duration `mapstructure:"EMAIL_VERIFICATION_RESEND_COOLDOWN"`
+	LoginMaxAttempts           int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginMaxAttemptsPerIP      int           `mapstructure:"LOGIN_MAX_ATTEMPTS_PER_IP"`
+	LoginAttemptWindow         time.Duration `mapstructure:"LOGIN_ATTEMPT_WINDOW"`
+	LoginLockoutBase           time.Duration `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax            time.Duration `mapstructure:"LOGIN_LOCKOUT_MAX"`
+	MFAIssuer                  string        `mapstructure:"MFA_ISSUER"`
+	MFAPendingTokenExpiresIn   time.Duration `mapstructure:"MFA_PENDING_TOKEN_EXPIRED_IN"`
+	PhoneCodeExpiresIn         time.Duration `mapstructure:"PHONE_CODE_EXPIRED_IN"`
+	PhoneCodeMaxAttempts       int           `mapstructure:"PHONE_CODE_MAX_ATTEMPTS"`
+	PhoneCodeResendCooldown    time.Duration `mapstructure:"PHONE_CODE_RESEND_COOLDOWN"`
+	PhoneCodeMaxSends          int           `mapstructure:"PHONE_CODE_MAX_SENDS"`
+	PhoneCodeMaxSendsPerUser   int           `mapstructure:"PHONE_CODE_MAX_SENDS_PER_USER"`
+	PhoneCodeSendWindow        time.Duration `mapstructure:"PHONE_CODE_SEND_WINDOW"`
+	AccountDeletionGracePeriod time.Duration `mapstructure:"ACCOUNT_DELETION_GRACE_PERIOD"`
 }
 
 // NewEnv creates a new environment
@@ -48,3 +105,14 @@ func NewEnv() *Env {
 
 	return &env
 }
+
+// mailFrom gives the sender address of outgoing mails
+func (e *Env) mailFrom() string {
+	if e.SmtpFrom != "" {
+		return e.SmtpFrom
+	}
+	if e.SmtpUser != "" {
+		return e.SmtpUser
+	}
+	return "Winglets <no-reply@winglets.local>"
+}
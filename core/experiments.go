@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+// experimentBuckets is the resolution of Experiment.Traffic, 0.01%
+const experimentBuckets = 10000
+
+// Experiment splits a share of the users between variants, it is read from the
+// EXPERIMENTS_FILE JSON file
+type Experiment struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// percentage of the users in the experiment, the others keep the default behaviour
+	Traffic  float64             `json:"traffic"`
+	Variants []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant is one arm of an experiment. Ranker and RankWeights replace the
+// configured ranker for its users, RankWeights winning over Ranker.
+type ExperimentVariant struct {
+	Name        string       `json:"name"`
+	Weight      int          `json:"weight"`
+	Ranker      string       `json:"ranker,omitempty"`
+	RankWeights *RankWeights `json:"rank_weights,omitempty"`
+}
+
+// ExperimentAssignment is the variant a user is in
+type ExperimentAssignment struct {
+	Experiment string            `json:"experiment"`
+	Variant    ExperimentVariant `json:"variant"`
+}
+
+// ExperimentAssignments are all the variants of a user, in the order of the experiments file
+type ExperimentAssignments []ExperimentAssignment
+
+// Experiments holds the experiments of the config and assigns users to their variants
+type Experiments struct {
+	experiments []Experiment
+}
+
+// NewExperiments loads the experiments file, an invalid experiment is left out so it can't
+// take the others down
+func NewExperiments(env *Env, logger *Logger) *Experiments {
+	e := &Experiments{}
+	if env.ExperimentsFile == "" {
+		return e
+	}
+
+	content, err := os.ReadFile(env.ExperimentsFile)
+	if err != nil {
+		logger.Warnf("cannot read experiments file [%v], running without experiments: [%v]", env.ExperimentsFile, err)
+		return e
+	}
+
+	var experiments []Experiment
+	if err = json.Unmarshal(content, &experiments); err != nil {
+		logger.Warnf("cannot parse experiments file [%v], running without experiments: [%v]", env.ExperimentsFile, err)
+		return e
+	}
+
+	seen := make(map[string]bool)
+	for _, experiment := range experiments {
+		if seen[experiment.Name] {
+			logger.Warnf("experiment [%v] is defined twice, keeping the first one", experiment.Name)
+			continue
+		}
+		if err = experiment.validate(); err != nil {
+			logger.Warnf("experiment [%v] is left out: [%v]", experiment.Name, err)
+			continue
+		}
+		seen[experiment.Name] = true
+		e.experiments = append(e.experiments, experiment)
+	}
+	return e
+}
+
+// List gives the loaded experiments, disabled ones included
+func (e *Experiments) List() []Experiment {
+	return append([]Experiment(nil), e.experiments...)
+}
+
+// Assign buckets the user in every running experiment. The same user always lands in the same
+// variant while the experiment's traffic and variants don't change.
+func (e *Experiments) Assign(userID string) ExperimentAssignments {
+	var assignments ExperimentAssignments
+	for _, experiment := range e.experiments {
+		if !experiment.Enabled {
+			continue
+		}
+		if float64(experimentHash(experiment.Name, "traffic", userID)%experimentBuckets) >= experiment.Traffic*experimentBuckets/100 {
+			continue
+		}
+
+		total := 0
+		for _, variant := range experiment.Variants {
+			total += variant.Weight
+		}
+		pick := int(experimentHash(experiment.Name, "variant", userID) % uint64(total))
+		for _, variant := range experiment.Variants {
+			if pick < variant.Weight {
+				assignments = append(assignments, ExperimentAssignment{Experiment: experiment.Name, Variant: variant})
+				break
+			}
+			pick -= variant.Weight
+		}
+	}
+	return assignments
+}
+
+// Ranker gives the ranker of the first variant that sets one
+func (a ExperimentAssignments) Ranker() (Ranker, bool) {
+	for _, assignment := range a {
+		variant := assignment.Variant
+		if variant.RankWeights != nil {
+			return NewWeightedRanker(*variant.RankWeights), true
+		}
+		if variant.Ranker != "" {
+			if ranker, err := NewRankerByName(variant.Ranker); err == nil {
+				return ranker, true
+			}
+		}
+	}
+	return nil, false
+}
+
+type experimentAssignmentsKey struct{}
+
+// WithExperimentAssignments attaches the user's assignments to ctx
+func WithExperimentAssignments(ctx context.Context, assignments ExperimentAssignments) context.Context {
+	return context.WithValue(ctx, experimentAssignmentsKey{}, assignments)
+}
+
+// ExperimentAssignmentsFrom reads the assignments attached to ctx, none when the request
+// didn't go through the experiment middleware
+func ExperimentAssignmentsFrom(ctx context.Context) ExperimentAssignments {
+	assignments, _ := ctx.Value(experimentAssignmentsKey{}).(ExperimentAssignments)
+	return assignments
+}
+
+func (e Experiment) validate() error {
+	if e.Name == "" {
+		return errors.New("name is empty")
+	}
+	if e.Traffic < 0 || e.Traffic > 100 {
+		return fmt.Errorf("traffic %v is not a percentage", e.Traffic)
+	}
+	if len(e.Variants) == 0 {
+		return errors.New("no variants")
+	}
+	names := make(map[string]bool)
+	for _, variant := range e.Variants {
+		if variant.Name == "" || names[variant.Name] {
+			return fmt.Errorf("variant name %q is empty or used twice", variant.Name)
+		}
+		if variant.Weight <= 0 {
+			return fmt.Errorf("variant %q has no weight", variant.Name)
+		}
+		if variant.Ranker != "" {
+			if _, err := NewRankerByName(variant.Ranker); err != nil {
+				return err
+			}
+		}
+		names[variant.Name] = true
+	}
+	return nil
+}
+
+// experimentHash salts the user id with the experiment, so experiments bucket independently
+func experimentHash(experiment, salt, userID string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(experiment + "/" + salt + "/" + userID))
+	return h.Sum64()
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestExperiments(t *testing.T, experiments ...Experiment) *Experiments {
+	t.Helper()
+	content, err := json.Marshal(experiments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "experiments.json")
+	if err = os.WriteFile(file, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return NewExperiments(&Env{ExperimentsFile: file}, &Logger{SugaredLogger: zap.NewNop().Sugar()})
+}
+
+func rankerExperiment(traffic float64) Experiment {
+	return Experiment{
+		Name:    "ranker",
+		Enabled: true,
+		Traffic: traffic,
+		Variants: []ExperimentVariant{
+			{Name: "control", Weight: 1, Ranker: "match"},
+			{Name: "weighted", Weight: 3, Ranker: "weighted"},
+		},
+	}
+}
+
+func variantOf(assignments ExperimentAssignments, experiment string) string {
+	for _, assignment := range assignments {
+		if assignment.Experiment == experiment {
+			return assignment.Variant.Name
+		}
+	}
+	return ""
+}
+
+func testUserIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user-%05d", i)
+	}
+	return ids
+}
+
+func TestExperiments_AssignIsStable(t *testing.T) {
+	experiments := newTestExperiments(t, rankerExperiment(50))
+	reloaded := newTestExperiments(t, rankerExperiment(50))
+
+	for _, userID := range testUserIDs(500) {
+		first := experiments.Assign(userID)
+		if again := experiments.Assign(userID); !reflect.DeepEqual(again, first) {
+			t.Fatalf("Assign(%s) gave %v then %v", userID, first, again)
+		}
+		if restarted := reloaded.Assign(userID); !reflect.DeepEqual(restarted, first) {
+			t.Fatalf("Assign(%s) gave %v, after a reload %v", userID, first, restarted)
+		}
+	}
+}
+
+func TestExperiments_AssignPinned(t *testing.T) {
+	// changing the hash moves users between variants mid-experiment, these must not change
+	experiments := newTestExperiments(t, rankerExperiment(50))
+
+	tests := map[string]string{
+		"user-00000": "weighted",
+		"user-00001": "weighted",
+		"user-00003": "",
+		"user-00004": "weighted",
+		"user-00006": "",
+		"user-00007": "",
+		"user-00010": "control",
+		"user-00029": "control",
+	}
+	for userID, want := range tests {
+		if got := variantOf(experiments.Assign(userID), "ranker"); got != want {
+			t.Errorf("Assign(%s) = %q, want %q", userID, got, want)
+		}
+	}
+}
+
+func TestExperiments_AssignSplitsByTrafficAndWeight(t *testing.T) {
+	experiments := newTestExperiments(t, rankerExperiment(40))
+
+	users := testUserIDs(20000)
+	counts := make(map[string]int)
+	for _, userID := range users {
+		counts[variantOf(experiments.Assign(userID), "ranker")]++
+	}
+
+	in := counts["control"] + counts["weighted"]
+	if share := float64(in) / float64(len(users)); math.Abs(share-0.4) > 0.02 {
+		t.Fatalf("%.3f of the users are in the experiment, want 0.4", share)
+	}
+	if share := float64(counts["weighted"]) / float64(in); math.Abs(share-0.75) > 0.02 {
+		t.Fatalf("%.3f of the experiment is weighted, want 0.75", share)
+	}
+}
+
+func TestExperiments_AssignKeepsVariantsWhenTrafficGrows(t *testing.T) {
+	small := newTestExperiments(t, rankerExperiment(10))
+	large := newTestExperiments(t, rankerExperiment(60))
+
+	for _, userID := range testUserIDs(5000) {
+		before := variantOf(small.Assign(userID), "ranker")
+		if before == "" {
+			continue
+		}
+		if after := variantOf(large.Assign(userID), "ranker"); after != before {
+			t.Fatalf("%s moved from %q to %q when the traffic grew", userID, before, after)
+		}
+	}
+}
+
+func TestExperiments_AssignBucketsExperimentsIndependently(t *testing.T) {
+	other := Experiment{Name: "weights", Enabled: true, Traffic: 50, Variants: []ExperimentVariant{{Name: "on", Weight: 1}}}
+	alone := newTestExperiments(t, rankerExperiment(50))
+	both := newTestExperiments(t, other, rankerExperiment(50))
+
+	users := testUserIDs(20000)
+	inBoth := 0
+	for _, userID := range users {
+		assignments := both.Assign(userID)
+		// adding an experiment doesn't reshuffle the others
+		if got, want := variantOf(assignments, "ranker"), variantOf(alone.Assign(userID), "ranker"); got != want {
+			t.Fatalf("%s is in %q of ranker next to weights, %q alone", userID, got, want)
+		}
+		if variantOf(assignments, "ranker") != "" && variantOf(assignments, "weights") != "" {
+			inBoth++
+		}
+	}
+	if share := float64(inBoth) / float64(len(users)); math.Abs(share-0.25) > 0.02 {
+		t.Fatalf("%.3f of the users are in both experiments, want 0.25", share)
+	}
+}
+
+func TestExperiments_AssignSkips(t *testing.T) {
+	disabled := rankerExperiment(100)
+	disabled.Enabled = false
+	none := rankerExperiment(0)
+	none.Name = "nobody"
+	everyone := rankerExperiment(100)
+	everyone.Name = "everyone"
+
+	experiments := newTestExperiments(t, disabled, none, everyone)
+	for _, userID := range testUserIDs(1000) {
+		assignments := experiments.Assign(userID)
+		if len(assignments) != 1 || assignments[0].Experiment != "everyone" {
+			t.Fatalf("Assign(%s) = %v, want only everyone", userID, assignments)
+		}
+	}
+}
+
+func TestNewExperiments_LeavesOutInvalid(t *testing.T) {
+	valid := rankerExperiment(50)
+	duplicate := rankerExperiment(100)
+	experiments := newTestExperiments(t,
+		valid,
+		duplicate,
+		Experiment{Name: "", Enabled: true, Traffic: 10, Variants: []ExperimentVariant{{Name: "a", Weight: 1}}},
+		Experiment{Name: "traffic", Enabled: true, Traffic: 120, Variants: []ExperimentVariant{{Name: "a", Weight: 1}}},
+		Experiment{Name: "empty", Enabled: true, Traffic: 10},
+		Experiment{Name: "weightless", Enabled: true, Traffic: 10, Variants: []ExperimentVariant{{Name: "a"}}},
+		Experiment{Name: "twins", Enabled: true, Traffic: 10, Variants: []ExperimentVariant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}},
+		Experiment{Name: "ranker-typo", Enabled: true, Traffic: 10, Variants: []ExperimentVariant{{Name: "a", Weight: 1, Ranker: "weigthed"}}},
+	)
+
+	if got := experiments.List(); !reflect.DeepEqual(got, []Experiment{valid}) {
+		t.Fatalf("List() = %+v, want only the first ranker experiment", got)
+	}
+}
@@ -0,0 +1,78 @@
+package core
+
+import "context"
+
+// Verdicts of a FaceMatcher, pending hands the selfie over to the review queue
+const (
+	FaceMatchPending  = "pending"
+	FaceMatchApproved = "approved"
+	FaceMatchRejected = "rejected"
+)
+
+// FaceMatchInput is a verification selfie with the profile photos it has to match
+type FaceMatchInput struct {
+	SelfieURL string
+	PhotoURLs []string
+	Pose      string
+}
+
+// FaceMatchVerdict is the outcome of a face match, Reason is shown to the user when rejected
+type FaceMatchVerdict struct {
+	Status string
+	Reason string
+}
+
+// FaceMatcher compares verification selfies with profile photos, pick the implementation
+// with the FACE_MATCHER env
+type FaceMatcher interface {
+	Match(ctx context.Context, input FaceMatchInput) (FaceMatchVerdict, error)
+}
+
+// NewFaceMatcher creates the matcher configured by the env: manual (default) or fake. The fake
+// one approves every selfie and is refused outside development.
+func NewFaceMatcher(env *Env, logger *Logger) FaceMatcher {
+	switch env.FaceMatcher {
+	case "fake":
+		if env.Environment != "development" {
+			logger.Panic("the fake face matcher approves every selfie, it can only be used with ENV=development")
+		}
+		return NewFakeFaceMatcher()
+	case "", "manual":
+		return NewManualFaceMatcher()
+	default:
+		logger.Warnf("unknown face matcher [%v], falling back to manual", env.FaceMatcher)
+		return NewManualFaceMatcher()
+	}
+}
+
+// ManualFaceMatcher leaves every selfie to the admins
+type ManualFaceMatcher struct{}
+
+// NewManualFaceMatcher creates a new manual face matcher
+func NewManualFaceMatcher() *ManualFaceMatcher {
+	return &ManualFaceMatcher{}
+}
+
+// Match sends the selfie to the review queue
+func (m *ManualFaceMatcher) Match(ctx context.Context, input FaceMatchInput) (FaceMatchVerdict, error) {
+	return FaceMatchVerdict{Status: FaceMatchPending}, nil
+}
+
+// FakeFaceMatcher answers with a set verdict, for tests and local development.
+// It approves by default but never without a profile photo to compare with.
+type FakeFaceMatcher struct {
+	Verdict FaceMatchVerdict
+}
+
+// NewFakeFaceMatcher creates a new fake face matcher approving every selfie
+func NewFakeFaceMatcher() *FakeFaceMatcher {
+	return &FakeFaceMatcher{Verdict: FaceMatchVerdict{Status: FaceMatchApproved}}
+}
+
+// Match returns the set verdict
+func (m *FakeFaceMatcher) Match(ctx context.Context, input FaceMatchInput) (FaceMatchVerdict, error) {
+	if len(input.PhotoURLs) == 0 {
+		return FaceMatchVerdict{Status: FaceMatchRejected, Reason: "add an approved profile photo first"}, nil
+	}
+	return m.Verdict, nil
+}
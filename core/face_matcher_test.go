@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewFaceMatcher(t *testing.T) {
+	logger := &Logger{SugaredLogger: zap.NewNop().Sugar()}
+	input := FaceMatchInput{SelfieURL: "selfie", PhotoURLs: []string{"photo"}}
+
+	tests := []struct {
+		matcher     string
+		environment string
+		want        string
+	}{
+		{"", "production", FaceMatchPending},
+		{"manual", "development", FaceMatchPending},
+		{"unknown", "production", FaceMatchPending},
+		{"fake", "development", FaceMatchApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.matcher+"/"+tt.environment, func(t *testing.T) {
+			matcher := NewFaceMatcher(&Env{FaceMatcher: tt.matcher, Environment: tt.environment}, logger)
+			verdict, err := matcher.Match(context.Background(), input)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if verdict.Status != tt.want {
+				t.Fatalf("verdict = %s, want %s", verdict.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFaceMatcher_RefusesFakeOutsideDevelopment(t *testing.T) {
+	logger := &Logger{SugaredLogger: zap.NewNop().Sugar()}
+
+	for _, environment := range []string{"", "staging", "production"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("NewFaceMatcher() gave the fake matcher with ENV=%q", environment)
+				}
+			}()
+			NewFaceMatcher(&Env{FaceMatcher: "fake", Environment: environment}, logger)
+		}()
+	}
+}
+
+func TestFakeFaceMatcher_NeedsAPhoto(t *testing.T) {
+	verdict, err := NewFakeFaceMatcher().Match(context.Background(), FaceMatchInput{SelfieURL: "selfie"})
+	if err != nil {
+		t.Fatalf("Match() error = %v", err)
+	}
+	if verdict.Status != FaceMatchRejected || verdict.Reason == "" {
+		t.Fatalf("verdict = %+v without a photo, want rejected with a reason", verdict)
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer writes every mail as an .eml file instead of sending it, for local development
+type FileMailer struct {
+	dir  string
+	from string
+	mu   sync.Mutex
+	seq  int
+}
+
+// NewFileMailer creates a new file mailer writing into dir
+func NewFileMailer(dir, from string) *FileMailer {
+	if dir == "" {
+		dir = filepath.Join("tmp", "mails")
+	}
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send writes the mail to the sink directory
+func (m *FileMailer) Send(ctx context.Context, mail Mail) error {
+	data, err := mail.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().UTC().Format("20060102T150405"), m.seq)
+	m.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o644)
+}
+
+// MemoryMailer keeps every mail in memory, for tests
+type MemoryMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+// NewMemoryMailer creates a new memory mailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records the mail
+func (m *MemoryMailer) Send(ctx context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+// Sent returns a copy of the recorded mails
+func (m *MemoryMailer) Sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.mails...)
+}
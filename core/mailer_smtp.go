@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+const (
+	SmtpTLSModeStartTLS = "starttls"
+	SmtpTLSModeTLS      = "tls"
+	SmtpTLSModeNone     = "none"
+)
+
+// SMTPMailer sends mails through an smtp relay
+type SMTPMailer struct {
+	host       string
+	port       string
+	user       string
+	password   string
+	from       string
+	tlsMode    string
+	skipVerify bool
+}
+
+// NewSMTPMailer creates a new smtp mailer
+func NewSMTPMailer(env *Env) *SMTPMailer {
+	port := env.SmtpPort
+	tlsMode := env.SmtpTLSMode
+	if tlsMode == "" {
+		tlsMode = SmtpTLSModeStartTLS
+	}
+	if port == "" {
+		port = "587"
+		if tlsMode == SmtpTLSModeTLS {
+			port = "465"
+		}
+	}
+
+	return &SMTPMailer{
+		host:       env.SmtpHost,
+		port:       port,
+		user:       env.SmtpUser,
+		password:   env.SmtpPassword,
+		from:       env.mailFrom(),
+		tlsMode:    tlsMode,
+		skipVerify: env.SmtpTLSSkipVerify,
+	}
+}
+
+// Send delivers the mail, the context bounds the whole smtp conversation
+func (m *SMTPMailer) Send(ctx context.Context, message Mail) error {
+	data, err := message.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         m.host,
+		InsecureSkipVerify: m.skipVerify,
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if m.tlsMode == SmtpTLSModeTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.tlsMode == SmtpTLSModeStartTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if m.user != "" {
+		if err = client.Auth(smtp.PlainAuth("", m.user, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(addressOf(m.from)); err != nil {
+		return err
+	}
+	for _, to := range message.To {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// addressOf strips the display name of "Name <address>"
+func addressOf(from string) string {
+	if address, err := mail.ParseAddress(from); err == nil {
+		return address.Address
+	}
+	return from
+}
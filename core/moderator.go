@@ -0,0 +1,89 @@
+package core
+
+import "context"
+
+// Verdicts of a PhotoModerator, pending asks for a human to have a look
+const (
+	PhotoVerdictPending  = "pending"
+	PhotoVerdictApproved = "approved"
+	PhotoVerdictRejected = "rejected"
+)
+
+// PhotoModerationInput is the photo to check, URL points to a resized copy
+type PhotoModerationInput struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// PhotoVerdict is the outcome of a moderation, Reason is shown to the owner of a rejected photo
+type PhotoVerdict struct {
+	Status string
+	Reason string
+}
+
+// PhotoModerator checks uploaded photos, pick the implementation with the PHOTO_MODERATOR env
+type PhotoModerator interface {
+	Moderate(ctx context.Context, photo PhotoModerationInput) (PhotoVerdict, error)
+}
+
+// NewPhotoModerator creates the moderator configured by the env: manual (default) or rules.
+// Classifiers for nudity, minors or faces plug in here by implementing PhotoModerator.
+func NewPhotoModerator(env *Env, logger *Logger) PhotoModerator {
+	switch env.PhotoModerator {
+	case "", "manual":
+		return NewManualPhotoModerator()
+	case "rules":
+		if env.Environment != "development" {
+			logger.Warnf("the rules photo moderator approves every photo of the right shape, it is meant for development only")
+		}
+		return NewRulesPhotoModerator()
+	default:
+		logger.Warnf("unknown photo moderator [%v], falling back to manual", env.PhotoModerator)
+		return NewManualPhotoModerator()
+	}
+}
+
+// ManualPhotoModerator leaves every photo to the admins
+type ManualPhotoModerator struct{}
+
+// NewManualPhotoModerator creates a new manual photo moderator
+func NewManualPhotoModerator() *ManualPhotoModerator {
+	return &ManualPhotoModerator{}
+}
+
+// Moderate sends the photo to the review queue
+func (m *ManualPhotoModerator) Moderate(ctx context.Context, photo PhotoModerationInput) (PhotoVerdict, error) {
+	return PhotoVerdict{Status: PhotoVerdictPending}, nil
+}
+
+const moderationMaxAspectRatio = 2.5
+
+// RulesPhotoModerator only looks at the shape of a photo, for local development. It doesn't
+// see nudity, minors or missing faces, everything that passes the rules is approved.
+type RulesPhotoModerator struct{}
+
+// NewRulesPhotoModerator creates a new rule based photo moderator
+func NewRulesPhotoModerator() *RulesPhotoModerator {
+	return &RulesPhotoModerator{}
+}
+
+// Moderate rejects banners and strips, which rarely show a person
+func (m *RulesPhotoModerator) Moderate(ctx context.Context, photo PhotoModerationInput) (PhotoVerdict, error) {
+	if photo.Width <= 0 || photo.Height <= 0 {
+		return PhotoVerdict{Status: PhotoVerdictPending}, nil
+	}
+
+	long, short := photo.Width, photo.Height
+	if short > long {
+		long, short = short, long
+	}
+	if float64(long)/float64(short) > moderationMaxAspectRatio {
+		return PhotoVerdict{
+			Status: PhotoVerdictRejected,
+			Reason: "photo is too narrow, crop it closer to a portrait or a square",
+		}, nil
+	}
+
+	return PhotoVerdict{Status: PhotoVerdictApproved}, nil
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewPhotoModerator(t *testing.T) {
+	logger := &Logger{SugaredLogger: zap.NewNop().Sugar()}
+
+	tests := []struct {
+		moderator   string
+		environment string
+		want        string
+	}{
+		{"", "production", PhotoVerdictPending},
+		{"manual", "development", PhotoVerdictPending},
+		{"unknown", "development", PhotoVerdictPending},
+		{"rules", "development", PhotoVerdictApproved},
+		// an explicit choice is kept outside development, with a warning
+		{"rules", "production", PhotoVerdictApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.moderator+"/"+tt.environment, func(t *testing.T) {
+			moderator := NewPhotoModerator(&Env{PhotoModerator: tt.moderator, Environment: tt.environment}, logger)
+			verdict, err := moderator.Moderate(context.Background(), PhotoModerationInput{Width: 800, Height: 1000})
+			if err != nil {
+				t.Fatalf("Moderate() error = %v", err)
+			}
+			if verdict.Status != tt.want {
+				t.Fatalf("verdict = %s, want %s", verdict.Status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	PushPlatformAndroid = "android"
+	PushPlatformIOS     = "ios"
+)
+
+// ErrPushTokenInvalid is returned when the push service no longer knows the device token, the
+// device should be forgotten
+var ErrPushTokenInvalid = errors.New("push token is no longer valid")
+
+// Push is a notification ready to be delivered to one device
+type Push struct {
+	Platform string
+	Token    string
+	Title    string
+	Body     string
+	// handed to the app with the notification
+	Data map[string]string
+	// a newer push with the same key replaces the older one on the device
+	CollapseKey string
+}
+
+// PushSender delivers push notifications, pick the implementation with the PUSH_SENDER env
+type PushSender interface {
+	Send(ctx context.Context, push Push) error
+}
+
+// NewPushSender creates the sender configured by the env: console (default), memory or live,
+// which sends android pushes through FCM and ios pushes through APNs
+func NewPushSender(env *Env, logger *Logger) PushSender {
+	switch env.PushSender {
+	case "memory":
+		return NewMemoryPushSender()
+	case "", "console":
+		return NewConsolePushSender(logger)
+	case "live":
+		sender, err := newLivePushSender(env)
+		if err != nil {
+			logger.Warnf("cannot set up live push sender, falling back to console: [%v]", err)
+			return NewConsolePushSender(logger)
+		}
+		return sender
+	default:
+		logger.Warnf("unknown push sender [%v], falling back to console", env.PushSender)
+		return NewConsolePushSender(logger)
+	}
+}
+
+func newLivePushSender(env *Env) (*PlatformPushSender, error) {
+	fcm, err := NewFCMPushSender(env.PushFCMProjectID, env.PushFCMCredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("fcm: %w", err)
+	}
+	apns, err := NewAPNsPushSender(env.PushAPNsKeyFile, env.PushAPNsKeyID, env.PushAPNsTeamID, env.PushAPNsTopic, env.PushAPNsSandbox)
+	if err != nil {
+		return nil, fmt.Errorf("apns: %w", err)
+	}
+	return NewPlatformPushSender(fcm, apns), nil
+}
+
+// PlatformPushSender hands every push to the sender of its platform
+type PlatformPushSender struct {
+	android PushSender
+	ios     PushSender
+}
+
+// NewPlatformPushSender creates a new platform push sender
+func NewPlatformPushSender(android, ios PushSender) *PlatformPushSender {
+	return &PlatformPushSender{android: android, ios: ios}
+}
+
+// Send picks the sender of the push's platform
+func (s *PlatformPushSender) Send(ctx context.Context, push Push) error {
+	switch push.Platform {
+	case PushPlatformAndroid:
+		return s.android.Send(ctx, push)
+	case PushPlatformIOS:
+		return s.ios.Send(ctx, push)
+	default:
+		return fmt.Errorf("unknown push platform %q", push.Platform)
+	}
+}
+
+// ConsolePushSender writes every push to the log instead of sending it, for local development
+type ConsolePushSender struct {
+	logger *Logger
+}
+
+// NewConsolePushSender creates a new console push sender
+func NewConsolePushSender(logger *Logger) *ConsolePushSender {
+	return &ConsolePushSender{logger: logger}
+}
+
+// Send logs the push
+func (s *ConsolePushSender) Send(ctx context.Context, push Push) error {
+	s.logger.Infof("push to [%v %v]: %v - %v %v", push.Platform, push.Token, push.Title, push.Body, push.Data)
+	return nil
+}
+
+// MemoryPushSender keeps every push in memory, for tests
+type MemoryPushSender struct {
+	mu     sync.Mutex
+	pushes []Push
+}
+
+// NewMemoryPushSender creates a new memory push sender
+func NewMemoryPushSender() *MemoryPushSender {
+	return &MemoryPushSender{}
+}
+
+// Send records the push
+func (s *MemoryPushSender) Send(ctx context.Context, push Push) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushes = append(s.pushes, push)
+	return nil
+}
+
+// Sent returns a copy of the recorded pushes
+func (s *MemoryPushSender) Sent() []Push {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Push(nil), s.pushes...)
+}
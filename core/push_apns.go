@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	apnsEndpoint        = "https://api.push.apple.com"
+	apnsSandboxEndpoint = "https://api.sandbox.push.apple.com"
+	// APNs refuses provider tokens older than an hour and signed more often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsPushSender sends pushes to Apple devices with token based authentication
+type APNsPushSender struct {
+	endpoint string
+	keyID    string
+	teamID   string
+	topic    string
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+
+	mu       sync.Mutex
+	token    string
+	signedAt time.Time
+}
+
+// NewAPNsPushSender creates a new APNs push sender from a .p8 signing key, topic is the
+// bundle id of the app
+func NewAPNsPushSender(keyFile, keyID, teamID, topic string, sandbox bool) (*APNsPushSender, error) {
+	if keyFile == "" || keyID == "" || teamID == "" || topic == "" {
+		return nil, errors.New("key file, key id, team id and topic are required")
+	}
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, jwt.ErrNotECPrivateKey
+	}
+
+	endpoint := apnsEndpoint
+	if sandbox {
+		endpoint = apnsSandboxEndpoint
+	}
+	return &APNsPushSender{
+		endpoint: endpoint,
+		keyID:    keyID,
+		teamID:   teamID,
+		topic:    topic,
+		key:      key,
+		client:   &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+// Send delivers the push, ErrPushTokenInvalid means APNs dropped the token
+func (s *APNsPushSender) Send(ctx context.Context, push Push) error {
+	token, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": push.Title,
+				"body":  push.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for key, value := range push.Data {
+		if key != "aps" {
+			payload[key] = value
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/3/device/"+push.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", s.topic)
+	req.Header.Set("apns-push-type", "alert")
+	if push.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", push.CollapseKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var reply struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&reply)
+	if resp.StatusCode == http.StatusGone || reply.Reason == "BadDeviceToken" || reply.Reason == "Unregistered" {
+		return ErrPushTokenInvalid
+	}
+	return fmt.Errorf("apns: unexpected status %d: %s", resp.StatusCode, reply.Reason)
+}
+
+// providerToken returns the signed provider token, signing a new one when it gets old
+func (s *APNsPushSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Sub(s.signedAt) < apnsTokenLifetime {
+		return s.token, nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.keyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.signedAt = signed, now
+	return s.token, nil
+}
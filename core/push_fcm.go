@@ -0,0 +1,172 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	fcmEndpoint = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	// an access token is renewed this long before it expires
+	fcmTokenMargin = time.Minute
+	pushTimeout    = 10 * time.Second
+)
+
+// fcmCredentials is the part of a Google service account key file FCM needs
+type fcmCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMPushSender sends pushes with the FCM HTTP v1 API, signed in as a service account
+type FCMPushSender struct {
+	endpoint    string
+	credentials fcmCredentials
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMPushSender creates a new FCM push sender from a service account key file
+func NewFCMPushSender(projectID, credentialsFile string) (*FCMPushSender, error) {
+	if projectID == "" || credentialsFile == "" {
+		return nil, errors.New("project id and credentials file are required")
+	}
+	content, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var credentials fcmCredentials
+	if err = json.Unmarshal(content, &credentials); err != nil {
+		return nil, err
+	}
+	if credentials.ClientEmail == "" || credentials.PrivateKey == "" || credentials.TokenURI == "" {
+		return nil, errors.New("credentials file is not a service account key")
+	}
+
+	return &FCMPushSender{
+		endpoint:    fmt.Sprintf(fcmEndpoint, projectID),
+		credentials: credentials,
+		client:      &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+// Send delivers the push, ErrPushTokenInvalid means FCM dropped the token
+func (s *FCMPushSender) Send(ctx context.Context, push Push) error {
+	accessToken, err := s.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	message := map[string]interface{}{
+		"token": push.Token,
+		"notification": map[string]string{
+			"title": push.Title,
+			"body":  push.Body,
+		},
+	}
+	if len(push.Data) > 0 {
+		message["data"] = push.Data
+	}
+	if push.CollapseKey != "" {
+		message["android"] = map[string]string{"collapse_key": push.CollapseKey}
+	}
+	body, err := json.Marshal(map[string]interface{}{"message": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode == http.StatusNotFound || strings.Contains(string(reply), "UNREGISTERED") {
+		return ErrPushTokenInvalid
+	}
+	return fmt.Errorf("fcm: unexpected status %d: %s", resp.StatusCode, reply)
+}
+
+// token returns a cached access token, exchanging a signed assertion for a new one when it
+// is about to expire
+func (s *FCMPushSender) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accessToken != "" && time.Now().Add(fcmTokenMargin).Before(s.expiresAt) {
+		return s.accessToken, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(s.credentials.PrivateKey))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.credentials.ClientEmail,
+		"scope": fcmScope,
+		"aud":   s.credentials.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.credentials.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm: token exchange failed with status %d", resp.StatusCode)
+	}
+
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&grant); err != nil {
+		return "", err
+	}
+	s.accessToken = grant.AccessToken
+	s.expiresAt = now.Add(time.Duration(grant.ExpiresIn) * time.Second)
+	return s.accessToken, nil
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"time"
+)
+
+// RankCandidate is a scored profile waiting to be ordered. MatchPercentage goes from 0 to 1 as
+// utils.CalculateMatchPercentage gives it, LikesReceived and PassesReceived count how other
+// users swiped on it and Completeness goes from 0 to 100.
+type RankCandidate struct {
+	ID              string
+	MatchPercentage float64
+	LikesReceived   int
+	PassesReceived  int
+	Completeness    int
+	LastActiveAt    time.Time
+}
+
+// RankRequest is one feed to order. The same Seed gives the same order, whatever the order of
+// Candidates, so a feed doesn't reshuffle on every refresh.
+type RankRequest struct {
+	ViewerID   string
+	Now        time.Time
+	Seed       int64
+	Candidates []RankCandidate
+}
+
+// Ranker orders recommendations after they were scored, pick the implementation with the
+// RANKER env
+type Ranker interface {
+	Rank(request RankRequest) []RankCandidate
+}
+
+// NewRanker creates the ranker configured by the env: weighted (default) or match, which keeps
+// the plain match percentage order
+func NewRanker(env *Env, logger *Logger) Ranker {
+	ranker, err := NewRankerByName(env.Ranker)
+	if err != nil {
+		logger.Warnf("unknown ranker [%v], falling back to weighted", env.Ranker)
+		return NewWeightedRanker(DefaultRankWeights)
+	}
+	return ranker
+}
+
+// NewRankerByName creates a ranker from its name, an empty name gives the default one
+func NewRankerByName(name string) (Ranker, error) {
+	switch name {
+	case "", "weighted":
+		return NewWeightedRanker(DefaultRankWeights), nil
+	case "match":
+		return NewMatchRanker(), nil
+	default:
+		return nil, fmt.Errorf("unknown ranker %q", name)
+	}
+}
+
+// RankSeed derives the seed of a viewer's feed for the day of now
+func RankSeed(viewerID string, now time.Time) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(viewerID + now.UTC().Format("2006-01-02")))
+	return int64(h.Sum64())
+}
+
+// MatchRanker orders by match percentage only, ties by id
+type MatchRanker struct{}
+
+// NewMatchRanker creates a new match percentage ranker
+func NewMatchRanker() *MatchRanker {
+	return &MatchRanker{}
+}
+
+func (r *MatchRanker) Rank(request RankRequest) []RankCandidate {
+	ranked := append([]RankCandidate(nil), request.Candidates...)
+	sort.Slice(ranked, func(i, j int) bool {
+		left, right := clamp01(ranked[i].MatchPercentage), clamp01(ranked[j].MatchPercentage)
+		if left != right {
+			return left > right
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	return ranked
+}
+
+// RankWeights sets how much each signal counts in WeightedRanker, every signal is in [0, 1]
+type RankWeights struct {
+	Compatibility float64 `json:"compatibility"`
+	Desirability  float64 `json:"desirability"`
+	Recency       float64 `json:"recency"`
+	Completeness  float64 `json:"completeness"`
+	Exploration   float64 `json:"exploration"`
+	// taken off the score of a popular candidate for every popular one already placed
+	PopularityPenalty float64 `json:"popularity_penalty"`
+}
+
+// DefaultRankWeights keeps compatibility first, the other signals break near ties
+var DefaultRankWeights = RankWeights{
+	Compatibility:     0.55,
+	Desirability:      0.15,
+	Recency:           0.1,
+	Completeness:      0.1,
+	Exploration:       0.1,
+	PopularityPenalty: 0.05,
+}
+
+const (
+	// prior of the like-rate, as if every profile had 2 likes and 2 passes already
+	likeRatePriorLikes  = 2
+	likeRatePriorPasses = 2
+	// activity older than this gives no recency at all
+	rankRecencyHorizon = 14 * 24 * time.Hour
+	// like-rate from which a candidate counts as popular
+	rankPopularLikeRate = 0.65
+)
+
+// WeightedRanker mixes compatibility with a Bayesian like-rate, how recently the candidate was
+// active, how complete the profile is and a seeded exploration term that favours profiles few
+// people have swiped on yet. Popular candidates are then spread out so they don't fill the
+// top of every feed.
+type WeightedRanker struct {
+	weights RankWeights
+}
+
+// NewWeightedRanker creates a new weighted ranker
+func NewWeightedRanker(weights RankWeights) *WeightedRanker {
+	return &WeightedRanker{weights: weights}
+}
+
+func (r *WeightedRanker) Rank(request RankRequest) []RankCandidate {
+	type scored struct {
+		candidate RankCandidate
+		score     float64
+		popular   bool
+	}
+	pool := make([]scored, 0, len(request.Candidates))
+	for _, candidate := range request.Candidates {
+		likeRate := LikeRate(candidate.LikesReceived, candidate.PassesReceived)
+		// the less a profile was seen, the more room the exploration term has
+		uncertainty := 1 / math.Sqrt(1+float64(candidate.LikesReceived+candidate.PassesReceived))
+
+		score := r.weights.Compatibility*clamp01(candidate.MatchPercentage) +
+			r.weights.Desirability*likeRate +
+			r.weights.Recency*recency(candidate.LastActiveAt, request.Now) +
+			r.weights.Completeness*clamp01(float64(candidate.Completeness)/100) +
+			r.weights.Exploration*uncertainty*explorationNoise(request.Seed, candidate.ID)
+
+		pool = append(pool, scored{candidate: candidate, score: score, popular: likeRate >= rankPopularLikeRate})
+	}
+
+	// candidates are picked one at a time so that each popular pick lowers the next ones
+	ranked := make([]RankCandidate, 0, len(pool))
+	popularPlaced := 0
+	for len(pool) > 0 {
+		best, bestScore := 0, math.Inf(-1)
+		for i, item := range pool {
+			score := item.score
+			if item.popular {
+				score -= r.weights.PopularityPenalty * float64(popularPlaced)
+			}
+			if score > bestScore || (score == bestScore && item.candidate.ID < pool[best].candidate.ID) {
+				best, bestScore = i, score
+			}
+		}
+		if pool[best].popular {
+			popularPlaced++
+		}
+		ranked = append(ranked, pool[best].candidate)
+		pool = append(pool[:best], pool[best+1:]...)
+	}
+	return ranked
+}
+
+// explorationNoise draws a number in [0, 1) from the seed and the candidate id, a candidate
+// keeps its draw in whatever order the candidates come
+func explorationNoise(seed int64, id string) float64 {
+	h := fnv.New64a()
+	var seedBytes [8]byte
+	binary.LittleEndian.PutUint64(seedBytes[:], uint64(seed))
+	_, _ = h.Write(seedBytes[:])
+	_, _ = h.Write([]byte(id))
+
+	// fnv barely mixes the last bytes into the high bits, finish like splitmix64 does
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / (1 << 53)
+}
+
+// LikeRate is the share of likes among the swipes a profile received, pulled towards 0.5 while
+// there are few of them
+func LikeRate(likes, passes int) float64 {
+	return float64(likes+likeRatePriorLikes) / float64(likes+passes+likeRatePriorLikes+likeRatePriorPasses)
+}
+
+// recency falls linearly from 1 for activity at now to 0 at rankRecencyHorizon
+func recency(lastActiveAt, now time.Time) float64 {
+	if lastActiveAt.IsZero() {
+		return 0
+	}
+	return clamp01(1 - now.Sub(lastActiveAt).Hours()/rankRecencyHorizon.Hours())
+}
+
+// clamp01 also turns NaN, given for users without common answers, into 0
+func clamp01(value float64) float64 {
+	if math.IsNaN(value) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, value))
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rankTestNow = time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func rankTestCandidates() []RankCandidate {
+	return []RankCandidate{
+		{ID: "ana", MatchPercentage: 0.9, LikesReceived: 30, PassesReceived: 5, Completeness: 90, LastActiveAt: rankTestNow.Add(-time.Hour)},
+		{ID: "bea", MatchPercentage: 0.9, LikesReceived: 2, PassesReceived: 20, Completeness: 40, LastActiveAt: rankTestNow.Add(-10 * 24 * time.Hour)},
+		{ID: "cai", MatchPercentage: 0.72, Completeness: 100, LastActiveAt: rankTestNow},
+		{ID: "dan", MatchPercentage: 0.72, LikesReceived: 12, PassesReceived: 12, Completeness: 70, LastActiveAt: rankTestNow.Add(-48 * time.Hour)},
+		{ID: "eli", MatchPercentage: 0.5, LikesReceived: 40, PassesReceived: 2, Completeness: 100, LastActiveAt: rankTestNow},
+		{ID: "fay", MatchPercentage: 0.5, Completeness: 20},
+		{ID: "gus", MatchPercentage: 0.31, LikesReceived: 1, Completeness: 60, LastActiveAt: rankTestNow.Add(-3 * time.Hour)},
+		{ID: "hal", MatchPercentage: 0.1, LikesReceived: 50, PassesReceived: 1, Completeness: 100, LastActiveAt: rankTestNow},
+	}
+}
+
+func rankedIDs(ranked []RankCandidate) []string {
+	ids := make([]string, 0, len(ranked))
+	for _, candidate := range ranked {
+		ids = append(ids, candidate.ID)
+	}
+	return ids
+}
+
+func TestRankersIgnoreTheInputOrder(t *testing.T) {
+	seed := RankSeed("viewer-1", rankTestNow)
+
+	tests := []struct {
+		name   string
+		ranker Ranker
+		seed   int64
+		want   []string
+	}{
+		{
+			name:   "match",
+			ranker: NewMatchRanker(),
+			seed:   seed,
+			want:   []string{"ana", "bea", "cai", "dan", "eli", "fay", "gus", "hal"},
+		},
+		{
+			name:   "weighted",
+			ranker: NewWeightedRanker(DefaultRankWeights),
+			seed:   seed,
+			want:   []string{"ana", "cai", "dan", "bea", "eli", "gus", "fay", "hal"},
+		},
+		{
+			name:   "weighted, another seed",
+			ranker: NewWeightedRanker(DefaultRankWeights),
+			seed:   42,
+			want:   []string{"ana", "cai", "dan", "bea", "eli", "gus", "fay", "hal"},
+		},
+		{
+			name:   "weighted ties",
+			ranker: NewWeightedRanker(RankWeights{Compatibility: 1}),
+			seed:   seed,
+			want:   []string{"ana", "bea", "cai", "dan", "eli", "fay", "gus", "hal"},
+		},
+		{
+			name:   "exploration only",
+			ranker: NewWeightedRanker(RankWeights{Exploration: 1}),
+			seed:   seed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := rankTestCandidates()
+			first := rankedIDs(tt.ranker.Rank(RankRequest{ViewerID: "viewer-1", Now: rankTestNow, Seed: tt.seed, Candidates: candidates}))
+			if tt.want != nil && !reflect.DeepEqual(first, tt.want) {
+				t.Fatalf("Rank() = %v, want %v", first, tt.want)
+			}
+
+			shuffler := rand.New(rand.NewSource(7))
+			for i := 0; i < 20; i++ {
+				shuffled := rankTestCandidates()
+				shuffler.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+				got := rankedIDs(tt.ranker.Rank(RankRequest{ViewerID: "viewer-1", Now: rankTestNow, Seed: tt.seed, Candidates: shuffled}))
+				if !reflect.DeepEqual(got, first) {
+					t.Fatalf("shuffled input ranked %v, want %v", got, first)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedRankerSeedChangesExploration(t *testing.T) {
+	ranker := NewWeightedRanker(RankWeights{Exploration: 1})
+	orders := make(map[string]bool)
+	for seed := int64(0); seed < 10; seed++ {
+		ranked := ranker.Rank(RankRequest{Now: rankTestNow, Seed: seed, Candidates: rankTestCandidates()})
+		orders[strings.Join(rankedIDs(ranked), ",")] = true
+	}
+	if len(orders) < 5 {
+		t.Fatalf("10 seeds gave %d orders, the exploration term barely depends on the seed", len(orders))
+	}
+}
+
+func TestExplorationNoise(t *testing.T) {
+	for seed := int64(-3); seed < 3; seed++ {
+		for _, id := range []string{"", "a", "b", "user-1", "user-2"} {
+			noise := explorationNoise(seed, id)
+			if noise < 0 || noise >= 1 {
+				t.Fatalf("explorationNoise(%d, %q) = %v, want [0, 1)", seed, id, noise)
+			}
+			if again := explorationNoise(seed, id); again != noise {
+				t.Fatalf("explorationNoise(%d, %q) gave %v then %v", seed, id, noise, again)
+			}
+		}
+	}
+	if explorationNoise(1, "user-1") == explorationNoise(1, "user-2") {
+		t.Fatal("two candidates got the same draw")
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Sms is a text message ready to be delivered
+type Sms struct {
+	To   string
+	Text string
+}
+
+// SmsSender delivers text messages, pick the implementation with the SMS_SENDER env
+type SmsSender interface {
+	Send(ctx context.Context, sms Sms) error
+}
+
+// NewSmsSender creates the sender configured by the env: console (default) or memory.
+// Gateways plug in here by implementing SmsSender.
+func NewSmsSender(env *Env, logger *Logger) SmsSender {
+	switch env.SmsSender {
+	case "memory":
+		return NewMemorySmsSender()
+	case "", "console":
+		return NewConsoleSmsSender(logger)
+	default:
+		logger.Warnf("unknown sms sender [%v], falling back to console", env.SmsSender)
+		return NewConsoleSmsSender(logger)
+	}
+}
+
+// ConsoleSmsSender writes every message to the log instead of sending it, for local development
+type ConsoleSmsSender struct {
+	logger *Logger
+}
+
+// NewConsoleSmsSender creates a new console sms sender
+func NewConsoleSmsSender(logger *Logger) *ConsoleSmsSender {
+	return &ConsoleSmsSender{logger: logger}
+}
+
+// Send logs the message
+func (s *ConsoleSmsSender) Send(ctx context.Context, sms Sms) error {
+	s.logger.Infof("sms to [%v]: %v", sms.To, sms.Text)
+	return nil
+}
+
+// MemorySmsSender keeps every message in memory, for tests
+type MemorySmsSender struct {
+	mu       sync.Mutex
+	messages []Sms
+}
+
+// NewMemorySmsSender creates a new memory sms sender
+func NewMemorySmsSender() *MemorySmsSender {
+	return &MemorySmsSender{}
+}
+
+// Send records the message
+func (s *MemorySmsSender) Send(ctx context.Context, sms Sms) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, sms)
+	return nil
+}
+
+// Sent returns a copy of the recorded messages
+func (s *MemorySmsSender) Sent() []Sms {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sms(nil), s.messages...)
+}
@@ -17,6 +17,8 @@ require (
 
 require (
 	github.com/go-playground/validator/v10 v10.9.0
+	github.com/google/uuid v1.6.0
+	github.com/imagekit-developer/imagekit-go v0.0.0-20231221064253-557eb49f9c53
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/rs/cors/wrapper/gin v0.0.0-20220223021805-a4a5ce87d5a2
 	go.uber.org/fx v1.17.1
@@ -59,8 +61,6 @@ require (
 
 require (
 	github.com/creasty/defaults v1.6.0 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/imagekit-developer/imagekit-go v0.0.0-20231221064253-557eb49f9c53 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	gopkg.in/validator.v2 v2.0.1 // indirect
 )
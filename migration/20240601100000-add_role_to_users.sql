@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE `users` DROP COLUMN `role`;
+
+-- +migrate Up
+ALTER TABLE `users` ADD COLUMN `role` VARCHAR(20) NOT NULL DEFAULT 'user' AFTER `password`;
@@ -0,0 +1,14 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `login_attempts`;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+    `scope` VARCHAR(20) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `failed_attempts` INT NOT NULL DEFAULT 0,
+    `last_failed_at` DATETIME DEFAULT NULL,
+    `locked_until` DATETIME DEFAULT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`scope`, `subject`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
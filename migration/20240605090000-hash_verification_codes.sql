@@ -0,0 +1,9 @@
+-- +migrate Down
+ALTER TABLE `users` DROP COLUMN `verification_attempts`;
+ALTER TABLE `users` MODIFY COLUMN `verification_code` VARCHAR(30) DEFAULT NULL;
+
+-- +migrate Up
+-- codes are stored as sha256 hex digests from now on, pending plaintext codes are invalidated
+ALTER TABLE `users` MODIFY COLUMN `verification_code` VARCHAR(64) DEFAULT NULL;
+ALTER TABLE `users` ADD COLUMN `verification_attempts` INT NOT NULL DEFAULT 0 AFTER `verification_status`;
+UPDATE `users` SET `verification_code` = NULL WHERE `verification_status` = 0;
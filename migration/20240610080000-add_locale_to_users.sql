@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE `users` DROP COLUMN `locale`;
+
+-- +migrate Up
+ALTER TABLE `users` ADD COLUMN `locale` VARCHAR(10) NOT NULL DEFAULT 'en' AFTER `role`;
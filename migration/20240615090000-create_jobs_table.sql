@@ -0,0 +1,19 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `jobs`;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS `jobs` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `type` VARCHAR(100) NOT NULL,
+    `payload` TEXT NOT NULL,
+    `status` VARCHAR(20) NOT NULL DEFAULT 'pending',
+    `attempts` INT NOT NULL DEFAULT 0,
+    `max_attempts` INT NOT NULL DEFAULT 8,
+    `run_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `locked_until` DATETIME DEFAULT NULL,
+    `last_error` TEXT DEFAULT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `idx_jobs_status_run_at` (`status`, `run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
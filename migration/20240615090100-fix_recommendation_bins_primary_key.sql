@@ -0,0 +1,6 @@
+-- +migrate Down
+ALTER TABLE `recommendation_bins` DROP PRIMARY KEY, ADD PRIMARY KEY (`user_id`);
+
+-- +migrate Up
+-- the primary key only covered user_id, so every user could only ever have one recommendation in the bin
+ALTER TABLE `recommendation_bins` DROP PRIMARY KEY, ADD PRIMARY KEY (`user_id`, `recommended_user_id`);
@@ -0,0 +1,17 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `identities`;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS `identities` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `provider` VARCHAR(20) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `email` VARCHAR(255) DEFAULT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `identity_provider_subject_unique` UNIQUE (`provider`, `subject`),
+    CONSTRAINT `identity_user_provider_unique` UNIQUE (`user_id`, `provider`),
+    CONSTRAINT `fk_identities_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
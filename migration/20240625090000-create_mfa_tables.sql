@@ -0,0 +1,26 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `mfa_recovery_codes`;
+DROP TABLE IF EXISTS `user_totp`;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS `user_totp` (
+    `user_id` VARCHAR(36) NOT NULL,
+    `secret` VARCHAR(64) NOT NULL,
+    `confirmed_at` DATETIME DEFAULT NULL,
+    `last_used_step` BIGINT NOT NULL DEFAULT 0,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`user_id`),
+    CONSTRAINT `fk_user_totp_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `mfa_recovery_codes` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `code_hash` VARCHAR(64) NOT NULL,
+    `used_at` DATETIME DEFAULT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `mfa_recovery_code_unique` UNIQUE (`user_id`, `code_hash`),
+    CONSTRAINT `fk_mfa_recovery_codes_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,25 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `phone_verifications`;
+ALTER TABLE `users` DROP INDEX `user_phone_number_unique`;
+ALTER TABLE `users` DROP COLUMN `phone_verified_at`;
+ALTER TABLE `users` DROP COLUMN `phone_number`;
+
+-- +migrate Up
+-- only verified numbers are stored on users, pending ones live in phone_verifications
+ALTER TABLE `users` ADD COLUMN `phone_number` VARCHAR(16) DEFAULT NULL AFTER `email`;
+ALTER TABLE `users` ADD COLUMN `phone_verified_at` DATETIME DEFAULT NULL AFTER `phone_number`;
+ALTER TABLE `users` ADD CONSTRAINT `user_phone_number_unique` UNIQUE (`phone_number`);
+
+CREATE TABLE IF NOT EXISTS `phone_verifications` (
+    `phone_number` VARCHAR(16) NOT NULL,
+    `user_id` VARCHAR(36) NOT NULL,
+    `code_hash` VARCHAR(64) NOT NULL,
+    `attempts` INT NOT NULL DEFAULT 0,
+    `sent_at` DATETIME NOT NULL,
+    `send_count` INT NOT NULL DEFAULT 0,
+    `window_started_at` DATETIME NOT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`phone_number`),
+    CONSTRAINT `fk_phone_verifications_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
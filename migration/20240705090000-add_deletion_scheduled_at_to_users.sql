@@ -0,0 +1,6 @@
+-- +migrate Down
+ALTER TABLE `users` DROP COLUMN `deletion_scheduled_at`;
+
+-- +migrate Up
+-- the account is erased once this time has passed, unless the user restores it before
+ALTER TABLE `users` ADD COLUMN `deletion_scheduled_at` DATETIME DEFAULT NULL;
@@ -0,0 +1,99 @@
+-- +migrate Down
+ALTER TABLE `profiles`
+    ADD COLUMN `image_id_1` VARCHAR(50) DEFAULT NULL,
+    ADD COLUMN `image_id_2` VARCHAR(50) DEFAULT NULL,
+    ADD COLUMN `image_id_3` VARCHAR(50) DEFAULT NULL,
+    ADD COLUMN `image_id_4` VARCHAR(50) DEFAULT NULL,
+    ADD COLUMN `image_id_5` VARCHAR(50) DEFAULT NULL,
+    ADD COLUMN `image_url_1` TEXT DEFAULT NULL,
+    ADD COLUMN `image_url_2` TEXT DEFAULT NULL,
+    ADD COLUMN `image_url_3` TEXT DEFAULT NULL,
+    ADD COLUMN `image_url_4` TEXT DEFAULT NULL,
+    ADD COLUMN `image_url_5` TEXT DEFAULT NULL;
+
+-- the first five photos go back to the slots, in order
+UPDATE `profiles` p
+    JOIN (
+        SELECT `user_id`, `file_id`, `url`,
+               ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `position`) AS `slot`
+        FROM `profile_photos`
+    ) ph ON ph.`user_id` = p.`id` AND ph.`slot` = 1
+SET p.`image_id_1` = ph.`file_id`, p.`image_url_1` = ph.`url`;
+UPDATE `profiles` p
+    JOIN (
+        SELECT `user_id`, `file_id`, `url`,
+               ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `position`) AS `slot`
+        FROM `profile_photos`
+    ) ph ON ph.`user_id` = p.`id` AND ph.`slot` = 2
+SET p.`image_id_2` = ph.`file_id`, p.`image_url_2` = ph.`url`;
+UPDATE `profiles` p
+    JOIN (
+        SELECT `user_id`, `file_id`, `url`,
+               ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `position`) AS `slot`
+        FROM `profile_photos`
+    ) ph ON ph.`user_id` = p.`id` AND ph.`slot` = 3
+SET p.`image_id_3` = ph.`file_id`, p.`image_url_3` = ph.`url`;
+UPDATE `profiles` p
+    JOIN (
+        SELECT `user_id`, `file_id`, `url`,
+               ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `position`) AS `slot`
+        FROM `profile_photos`
+    ) ph ON ph.`user_id` = p.`id` AND ph.`slot` = 4
+SET p.`image_id_4` = ph.`file_id`, p.`image_url_4` = ph.`url`;
+UPDATE `profiles` p
+    JOIN (
+        SELECT `user_id`, `file_id`, `url`,
+               ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `position`) AS `slot`
+        FROM `profile_photos`
+    ) ph ON ph.`user_id` = p.`id` AND ph.`slot` = 5
+SET p.`image_id_5` = ph.`file_id`, p.`image_url_5` = ph.`url`;
+
+DROP TABLE IF EXISTS `profile_photos`;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS `profile_photos` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `file_id` VARCHAR(255) NOT NULL,
+    `url` TEXT NOT NULL,
+    `position` INT NOT NULL DEFAULT 0,
+    `is_primary` TINYINT(1) NOT NULL DEFAULT 0,
+    `width` INT NOT NULL DEFAULT 0,
+    `height` INT NOT NULL DEFAULT 0,
+    `status` VARCHAR(20) NOT NULL DEFAULT 'active',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `profile_photo_user_position_index` (`user_id`, `position`),
+    CONSTRAINT `fk_profile_photos_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- filled slots keep their order, the lowest one becomes the primary photo.
+-- the size of old uploads is unknown and stays 0
+INSERT INTO `profile_photos` (`user_id`, `file_id`, `url`, `position`, `is_primary`)
+SELECT `user_id`, `file_id`, `url`,
+       ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `slot`) - 1,
+       ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `slot`) = 1
+FROM (
+    SELECT `id` AS `user_id`, 1 AS `slot`, `image_id_1` AS `file_id`, `image_url_1` AS `url` FROM `profiles` WHERE `image_id_1` <> ''
+    UNION ALL
+    SELECT `id`, 2, `image_id_2`, `image_url_2` FROM `profiles` WHERE `image_id_2` <> ''
+    UNION ALL
+    SELECT `id`, 3, `image_id_3`, `image_url_3` FROM `profiles` WHERE `image_id_3` <> ''
+    UNION ALL
+    SELECT `id`, 4, `image_id_4`, `image_url_4` FROM `profiles` WHERE `image_id_4` <> ''
+    UNION ALL
+    SELECT `id`, 5, `image_id_5`, `image_url_5` FROM `profiles` WHERE `image_id_5` <> ''
+) AS `slots`;
+
+ALTER TABLE `profiles`
+    DROP COLUMN `image_id_1`,
+    DROP COLUMN `image_id_2`,
+    DROP COLUMN `image_id_3`,
+    DROP COLUMN `image_id_4`,
+    DROP COLUMN `image_id_5`,
+    DROP COLUMN `image_url_1`,
+    DROP COLUMN `image_url_2`,
+    DROP COLUMN `image_url_3`,
+    DROP COLUMN `image_url_4`,
+    DROP COLUMN `image_url_5`;
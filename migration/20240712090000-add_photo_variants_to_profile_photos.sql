@@ -0,0 +1,14 @@
+-- +migrate Down
+ALTER TABLE `profile_photos`
+    DROP COLUMN `thumbnail_file_id`,
+    DROP COLUMN `thumbnail_url`,
+    DROP COLUMN `card_file_id`,
+    DROP COLUMN `card_url`;
+
+-- +migrate Up
+-- resized copies stored next to the full size image, empty for photos uploaded before processing
+ALTER TABLE `profile_photos`
+    ADD COLUMN `thumbnail_file_id` VARCHAR(255) NOT NULL DEFAULT '' AFTER `url`,
+    ADD COLUMN `thumbnail_url` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `thumbnail_file_id`,
+    ADD COLUMN `card_file_id` VARCHAR(255) NOT NULL DEFAULT '' AFTER `thumbnail_url`,
+    ADD COLUMN `card_url` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `card_file_id`;
@@ -0,0 +1,15 @@
+-- +migrate Down
+UPDATE `profile_photos` SET `status` = 'active';
+ALTER TABLE `profile_photos`
+    DROP COLUMN `moderation_reason`,
+    DROP COLUMN `moderated_at`,
+    ALTER COLUMN `status` SET DEFAULT 'active';
+
+-- +migrate Up
+-- photos wait as pending until the moderator approves or rejects them, the reason is shown to the owner only
+ALTER TABLE `profile_photos`
+    ADD COLUMN `moderation_reason` VARCHAR(255) NOT NULL DEFAULT '' AFTER `status`,
+    ADD COLUMN `moderated_at` DATETIME DEFAULT NULL AFTER `moderation_reason`,
+    ALTER COLUMN `status` SET DEFAULT 'pending';
+-- photos uploaded before moderation existed stay visible
+UPDATE `profile_photos` SET `status` = 'approved' WHERE `status` = 'active';
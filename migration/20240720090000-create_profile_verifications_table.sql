@@ -0,0 +1,28 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `profile_verifications`;
+ALTER TABLE `profiles` DROP COLUMN `verified_at`;
+
+-- +migrate Up
+-- set once a selfie matching the profile photos was approved, shown as the verified badge
+ALTER TABLE `profiles` ADD COLUMN `verified_at` DATETIME DEFAULT NULL;
+
+-- one row per pose challenge: challenged until the selfie arrives, then pending until reviewed
+CREATE TABLE IF NOT EXISTS `profile_verifications` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `pose` VARCHAR(50) NOT NULL,
+    `status` VARCHAR(20) NOT NULL DEFAULT 'challenged',
+    `selfie_file_id` VARCHAR(255) NOT NULL DEFAULT '',
+    `selfie_url` VARCHAR(1024) NOT NULL DEFAULT '',
+    `reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `reviewed_by` VARCHAR(36) NOT NULL DEFAULT '',
+    `challenge_expires_at` DATETIME NOT NULL,
+    `submitted_at` DATETIME DEFAULT NULL,
+    `reviewed_at` DATETIME DEFAULT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `profile_verification_user_index` (`user_id`, `id`),
+    INDEX `profile_verification_status_index` (`status`, `submitted_at`),
+    CONSTRAINT `fk_profile_verifications_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,251 @@
+-- +migrate Down
+ALTER TABLE `profiles`
+    ADD COLUMN `height` VARCHAR(50) AFTER `birthday`,
+    ADD COLUMN `hobby` TEXT AFTER `horoscope`,
+    ADD COLUMN `language` TEXT AFTER `hobby`;
+
+UPDATE `profiles` SET `height` = CAST(`height_cm` AS CHAR) WHERE `height_cm` IS NOT NULL;
+UPDATE `profiles` p
+JOIN (
+    SELECT pi.`profile_id`, GROUP_CONCAT(i.`name` ORDER BY i.`id` SEPARATOR ',') AS `names`
+    FROM `profile_interests` pi JOIN `interests` i ON i.`id` = pi.`interest_id`
+    GROUP BY pi.`profile_id`
+) x ON x.`profile_id` = p.`id`
+SET p.`hobby` = x.`names`;
+UPDATE `profiles` p
+JOIN (
+    SELECT pl.`profile_id`, GROUP_CONCAT(l.`name` ORDER BY l.`id` SEPARATOR ',') AS `names`
+    FROM `profile_languages` pl JOIN `languages` l ON l.`id` = pl.`language_id`
+    GROUP BY pl.`profile_id`
+) x ON x.`profile_id` = p.`id`
+SET p.`language` = x.`names`;
+-- the values kept aside go back next to the matched ones
+UPDATE `profiles` p
+JOIN (
+    SELECT `profile_id`, GROUP_CONCAT(`value` ORDER BY `id` SEPARATOR ',') AS `names`
+    FROM `profile_legacy_values` WHERE `field` = 'hobby'
+    GROUP BY `profile_id`
+) x ON x.`profile_id` = p.`id`
+SET p.`hobby` = CONCAT_WS(',', p.`hobby`, x.`names`);
+UPDATE `profiles` p
+JOIN (
+    SELECT `profile_id`, GROUP_CONCAT(`value` ORDER BY `id` SEPARATOR ',') AS `names`
+    FROM `profile_legacy_values` WHERE `field` = 'language'
+    GROUP BY `profile_id`
+) x ON x.`profile_id` = p.`id`
+SET p.`language` = CONCAT_WS(',', p.`language`, x.`names`);
+UPDATE `profiles` p
+JOIN `profile_legacy_values` v ON v.`profile_id` = p.`id` AND v.`field` = 'height'
+SET p.`height` = v.`value`;
+
+ALTER TABLE `profiles` DROP COLUMN `height_cm`;
+DROP TABLE IF EXISTS `profile_legacy_values`;
+DROP TABLE IF EXISTS `profile_prompts`;
+DROP TABLE IF EXISTS `profile_languages`;
+DROP TABLE IF EXISTS `profile_interests`;
+DROP TABLE IF EXISTS `prompt_questions`;
+DROP TABLE IF EXISTS `languages`;
+DROP TABLE IF EXISTS `interests`;
+
+-- +migrate Up
+-- curated lookups, profiles refer to them instead of free text
+CREATE TABLE IF NOT EXISTS `interests` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `slug` VARCHAR(50) NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `category` VARCHAR(50) NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `interest_slug_index` (`slug`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `languages` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `code` VARCHAR(10) NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `native_name` VARCHAR(100) NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `language_code_index` (`code`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `prompt_questions` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `slug` VARCHAR(50) NOT NULL,
+    `question` VARCHAR(255) NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `prompt_question_slug_index` (`slug`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `profile_interests` (
+    `profile_id` VARCHAR(36) NOT NULL,
+    `interest_id` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`profile_id`, `interest_id`),
+    CONSTRAINT `fk_profile_interests_profile_id` FOREIGN KEY (`profile_id`) REFERENCES `profiles` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_profile_interests_interest_id` FOREIGN KEY (`interest_id`) REFERENCES `interests` (`id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `profile_languages` (
+    `profile_id` VARCHAR(36) NOT NULL,
+    `language_id` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`profile_id`, `language_id`),
+    CONSTRAINT `fk_profile_languages_profile_id` FOREIGN KEY (`profile_id`) REFERENCES `profiles` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_profile_languages_language_id` FOREIGN KEY (`language_id`) REFERENCES `languages` (`id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- question and answer pairs shown on the profile, ordered by position
+CREATE TABLE IF NOT EXISTS `profile_prompts` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `profile_id` VARCHAR(36) NOT NULL,
+    `question_id` INT UNSIGNED NOT NULL,
+    `answer` VARCHAR(300) NOT NULL,
+    `position` INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `profile_prompt_question_index` (`profile_id`, `question_id`),
+    CONSTRAINT `fk_profile_prompts_profile_id` FOREIGN KEY (`profile_id`) REFERENCES `profiles` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_profile_prompts_question_id` FOREIGN KEY (`question_id`) REFERENCES `prompt_questions` (`id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+INSERT INTO `interests` (`slug`, `name`, `category`) VALUES
+    ('hiking', 'Hiking', 'outdoors'),
+    ('camping', 'Camping', 'outdoors'),
+    ('beach', 'Beach', 'outdoors'),
+    ('cycling', 'Cycling', 'sports'),
+    ('running', 'Running', 'sports'),
+    ('gym', 'Gym', 'sports'),
+    ('yoga', 'Yoga', 'sports'),
+    ('football', 'Football', 'sports'),
+    ('badminton', 'Badminton', 'sports'),
+    ('swimming', 'Swimming', 'sports'),
+    ('photography', 'Photography', 'arts'),
+    ('painting', 'Painting', 'arts'),
+    ('writing', 'Writing', 'arts'),
+    ('dancing', 'Dancing', 'arts'),
+    ('guitar', 'Guitar', 'music'),
+    ('concerts', 'Concerts', 'music'),
+    ('karaoke', 'Karaoke', 'music'),
+    ('reading', 'Reading', 'entertainment'),
+    ('movies', 'Movies', 'entertainment'),
+    ('anime', 'Anime', 'entertainment'),
+    ('board_games', 'Board games', 'entertainment'),
+    ('video_games', 'Video games', 'entertainment'),
+    ('cooking', 'Cooking', 'food'),
+    ('coffee', 'Coffee', 'food'),
+    ('street_food', 'Street food', 'food'),
+    ('wine', 'Wine', 'food'),
+    ('travel', 'Travel', 'lifestyle'),
+    ('pets', 'Pets', 'lifestyle'),
+    ('fashion', 'Fashion', 'lifestyle'),
+    ('meditation', 'Meditation', 'lifestyle'),
+    ('volunteering', 'Volunteering', 'lifestyle'),
+    ('technology', 'Technology', 'lifestyle');
+
+INSERT INTO `languages` (`code`, `name`, `native_name`) VALUES
+    ('vi', 'Vietnamese', 'Tiếng Việt'),
+    ('en', 'English', 'English'),
+    ('zh', 'Chinese', '中文'),
+    ('ja', 'Japanese', '日本語'),
+    ('ko', 'Korean', '한국어'),
+    ('th', 'Thai', 'ไทย'),
+    ('km', 'Khmer', 'ខ្មែរ'),
+    ('lo', 'Lao', 'ລາວ'),
+    ('id', 'Indonesian', 'Bahasa Indonesia'),
+    ('ms', 'Malay', 'Bahasa Melayu'),
+    ('tl', 'Tagalog', 'Tagalog'),
+    ('hi', 'Hindi', 'हिन्दी'),
+    ('fr', 'French', 'Français'),
+    ('de', 'German', 'Deutsch'),
+    ('es', 'Spanish', 'Español'),
+    ('it', 'Italian', 'Italiano'),
+    ('pt', 'Portuguese', 'Português'),
+    ('nl', 'Dutch', 'Nederlands'),
+    ('ru', 'Russian', 'Русский'),
+    ('ar', 'Arabic', 'العربية');
+
+INSERT INTO `prompt_questions` (`slug`, `question`) VALUES
+    ('perfect_sunday', 'My perfect Sunday'),
+    ('green_flags', 'Green flags I look for'),
+    ('never_shut_up_about', 'I won''t shut up about'),
+    ('simple_pleasures', 'My simple pleasures'),
+    ('travel_story', 'The best trip I''ve taken'),
+    ('unpopular_opinion', 'My most unpopular opinion'),
+    ('together_we_could', 'Together we could'),
+    ('comfort_food', 'My comfort food'),
+    ('learning_now', 'Something I''m learning right now'),
+    ('first_date', 'The perfect first date');
+
+-- free text that doesn't fit the taxonomy is kept here, out of the profile, until it has been
+-- reviewed for new lookups and a later migration drops the table
+CREATE TABLE IF NOT EXISTS `profile_legacy_values` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `profile_id` VARCHAR(36) NOT NULL,
+    `field` VARCHAR(20) NOT NULL,
+    `value` VARCHAR(255) NOT NULL,
+    PRIMARY KEY (`id`),
+    INDEX `profile_legacy_value_field_index` (`field`),
+    CONSTRAINT `fk_profile_legacy_values_profile_id` FOREIGN KEY (`profile_id`) REFERENCES `profiles` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- numeric height, parsed from "170", "170cm", "170 cm", "1m70" or "1.70m"; anything else is
+-- kept aside
+ALTER TABLE `profiles` ADD COLUMN `height_cm` SMALLINT UNSIGNED DEFAULT NULL AFTER `birthday`;
+UPDATE `profiles`
+SET `height_cm` = CASE
+    WHEN TRIM(`height`) REGEXP '^[0-9]{3} ?(cm)?$'
+        THEN CAST(REGEXP_SUBSTR(`height`, '[0-9]{3}') AS UNSIGNED)
+    WHEN TRIM(`height`) REGEXP '^[12][.,m][0-9]{2}m?$'
+        THEN CAST(LEFT(TRIM(`height`), 1) AS UNSIGNED) * 100 + CAST(SUBSTRING(TRIM(`height`), 3, 2) AS UNSIGNED)
+    END
+WHERE `height` IS NOT NULL;
+UPDATE `profiles` SET `height_cm` = NULL WHERE `height_cm` NOT BETWEEN 100 AND 250;
+INSERT INTO `profile_legacy_values` (`profile_id`, `field`, `value`)
+SELECT `id`, 'height', TRIM(`height`)
+FROM `profiles`
+WHERE `height_cm` IS NULL AND TRIM(`height`) <> '';
+
+-- the comma strings are split and matched case-insensitively against the lookups,
+-- values that are not in the taxonomy are kept aside
+INSERT IGNORE INTO `profile_interests` (`profile_id`, `interest_id`)
+SELECT p.`id`, i.`id`
+FROM `profiles` p
+JOIN JSON_TABLE(
+    CONCAT('["', REPLACE(REPLACE(REPLACE(p.`hobby`, '\\', ''), '"', ''), ',', '","'), '"]'),
+    '$[*]' COLUMNS (`value` VARCHAR(255) PATH '$')
+) h
+JOIN `interests` i ON LOWER(TRIM(h.`value`)) IN (LOWER(i.`name`), i.`slug`)
+WHERE p.`hobby` IS NOT NULL AND p.`hobby` <> '';
+
+INSERT INTO `profile_legacy_values` (`profile_id`, `field`, `value`)
+SELECT DISTINCT p.`id`, 'hobby', LEFT(TRIM(h.`value`), 255)
+FROM `profiles` p
+JOIN JSON_TABLE(
+    CONCAT('["', REPLACE(REPLACE(REPLACE(p.`hobby`, '\\', ''), '"', ''), ',', '","'), '"]'),
+    '$[*]' COLUMNS (`value` VARCHAR(255) PATH '$')
+) h
+LEFT JOIN `interests` i ON LOWER(TRIM(h.`value`)) IN (LOWER(i.`name`), i.`slug`)
+WHERE p.`hobby` IS NOT NULL AND p.`hobby` <> ''
+  AND i.`id` IS NULL AND TRIM(h.`value`) <> '';
+
+INSERT IGNORE INTO `profile_languages` (`profile_id`, `language_id`)
+SELECT p.`id`, l.`id`
+FROM `profiles` p
+JOIN JSON_TABLE(
+    CONCAT('["', REPLACE(REPLACE(REPLACE(p.`language`, '\\', ''), '"', ''), ',', '","'), '"]'),
+    '$[*]' COLUMNS (`value` VARCHAR(255) PATH '$')
+) v
+JOIN `languages` l ON LOWER(TRIM(v.`value`)) IN (LOWER(l.`name`), LOWER(l.`native_name`), l.`code`)
+WHERE p.`language` IS NOT NULL AND p.`language` <> '';
+
+INSERT INTO `profile_legacy_values` (`profile_id`, `field`, `value`)
+SELECT DISTINCT p.`id`, 'language', LEFT(TRIM(v.`value`), 255)
+FROM `profiles` p
+JOIN JSON_TABLE(
+    CONCAT('["', REPLACE(REPLACE(REPLACE(p.`language`, '\\', ''), '"', ''), ',', '","'), '"]'),
+    '$[*]' COLUMNS (`value` VARCHAR(255) PATH '$')
+) v
+LEFT JOIN `languages` l ON LOWER(TRIM(v.`value`)) IN (LOWER(l.`name`), LOWER(l.`native_name`), l.`code`)
+WHERE p.`language` IS NOT NULL AND p.`language` <> ''
+  AND l.`id` IS NULL AND TRIM(v.`value`) <> '';
+
+ALTER TABLE `profiles`
+    DROP COLUMN `height`,
+    DROP COLUMN `hobby`,
+    DROP COLUMN `language`;
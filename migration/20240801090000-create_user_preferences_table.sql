@@ -0,0 +1,16 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `user_preferences`;
+
+-- +migrate Up
+-- who a user wants to be recommended, the last onboarding step
+CREATE TABLE IF NOT EXISTS `user_preferences` (
+    `user_id` VARCHAR(36) NOT NULL,
+    `interested_in` VARCHAR(10) NOT NULL,
+    `min_age` INT NOT NULL,
+    `max_age` INT NOT NULL,
+    `max_distance` DOUBLE NOT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`user_id`),
+    CONSTRAINT `fk_user_preferences_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- +migrate Down
+ALTER TABLE `profiles`
+    DROP COLUMN `discovery_paused`,
+    DROP COLUMN `incognito`,
+    DROP COLUMN `hide_age`,
+    DROP COLUMN `hide_distance`;
+
+-- +migrate Up
+-- paused and incognito profiles stay out of recommendations, incognito ones are still shown
+-- to the people they liked
+ALTER TABLE `profiles`
+    ADD COLUMN `discovery_paused` TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN `incognito` TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN `hide_age` TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN `hide_distance` TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+-- +migrate Down
+ALTER TABLE `profiles` DROP COLUMN `location`;
+
+-- +migrate Up
+-- city-level place shown instead of coordinates, filled by the profiles:geocode command for
+-- existing rows
+ALTER TABLE `profiles` ADD COLUMN `location` VARCHAR(255) NOT NULL DEFAULT '' AFTER `home_town`;
+
+-- precise coordinates are not kept, they snap to a grid of 0.01 degree like new ones.
+-- this can't be undone
+UPDATE `profiles`
+SET `coordinates` = CONCAT(
+        CAST(ROUND(CAST(SUBSTRING_INDEX(`coordinates`, ',', 1) AS DECIMAL(10, 6)), 2) AS DECIMAL(10, 6)),
+        ',',
+        CAST(ROUND(CAST(SUBSTRING_INDEX(`coordinates`, ',', -1) AS DECIMAL(10, 6)), 2) AS DECIMAL(10, 6)))
+WHERE `coordinates` LIKE '%,%';
@@ -0,0 +1,13 @@
+-- +migrate Down
+ALTER TABLE `profiles`
+    DROP COLUMN `travel_location`,
+    DROP COLUMN `travel_coordinates`,
+    DROP COLUMN `travel_expires_at`;
+
+-- +migrate Up
+-- travel mode: until travel_expires_at the user discovers and is discovered from the centre of
+-- another city
+ALTER TABLE `profiles`
+    ADD COLUMN `travel_location` VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `travel_coordinates` VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `travel_expires_at` DATETIME DEFAULT NULL;
@@ -0,0 +1,17 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `experiment_events`;
+
+-- +migrate Up
+-- exposures and swipes of users in an experiment, tagged with their variant
+CREATE TABLE IF NOT EXISTS `experiment_events` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `experiment` VARCHAR(64) NOT NULL,
+    `variant` VARCHAR(64) NOT NULL,
+    `user_id` VARCHAR(36) NOT NULL,
+    `event` VARCHAR(16) NOT NULL,
+    `subject_id` VARCHAR(36) NOT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `idx_experiment_events_experiment` (`experiment`, `variant`, `event`),
+    INDEX `idx_experiment_events_user_id` (`user_id`)
+);
@@ -0,0 +1,13 @@
+-- +migrate Down
+ALTER TABLE `profiles`
+    DROP INDEX `idx_profiles_last_active_at`,
+    DROP COLUMN `last_active_at`;
+
+-- +migrate Up
+-- when the user last made an authenticated request, written in batches a few minutes late at most
+ALTER TABLE `profiles`
+    ADD COLUMN `last_active_at` DATETIME DEFAULT NULL,
+    ADD INDEX `idx_profiles_last_active_at` (`last_active_at`);
+
+-- the last profile change is the best guess for users seen before the column existed
+UPDATE `profiles` SET `last_active_at` = COALESCE(`updated_at`, `created_at`);
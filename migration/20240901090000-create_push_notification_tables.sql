@@ -0,0 +1,50 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `notifications`;
+DROP TABLE IF EXISTS `notification_preferences`;
+DROP TABLE IF EXISTS `devices`;
+
+-- +migrate Up
+-- push tokens of the user's devices, a token belongs to the last user who registered it
+CREATE TABLE IF NOT EXISTS `devices` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `platform` VARCHAR(10) NOT NULL,
+    `token` VARCHAR(512) NOT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_devices_token` (`token`),
+    INDEX `idx_devices_user_id` (`user_id`),
+    CONSTRAINT `fk_devices_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
+
+-- which pushes the user wants and when they must wait, quiet hours are HH:MM in time_zone
+CREATE TABLE IF NOT EXISTS `notification_preferences` (
+    `user_id` VARCHAR(36) NOT NULL,
+    `new_matches` TINYINT(1) NOT NULL DEFAULT 1,
+    `new_likes` TINYINT(1) NOT NULL DEFAULT 1,
+    `quiet_hours_start` VARCHAR(5) NOT NULL DEFAULT '',
+    `quiet_hours_end` VARCHAR(5) NOT NULL DEFAULT '',
+    `time_zone` VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`user_id`),
+    CONSTRAINT `fk_notification_preferences_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
+
+-- every notification queued, dedup_key keeps the same event from being pushed twice
+CREATE TABLE IF NOT EXISTS `notifications` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `kind` VARCHAR(32) NOT NULL,
+    `dedup_key` VARCHAR(128) NOT NULL,
+    `title` VARCHAR(255) NOT NULL,
+    `body` VARCHAR(1024) NOT NULL,
+    `data` TEXT,
+    `send_at` DATETIME NOT NULL,
+    `sent_at` DATETIME DEFAULT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `idx_notifications_dedup` (`user_id`, `dedup_key`, `created_at`),
+    CONSTRAINT `fk_notifications_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- +migrate Down
+-- the deleted jobs held rendered mails with plaintext codes and can't be brought back
+
+-- +migrate Up
+-- mail.send jobs carried the whole rendered mail, verification code and address included.
+-- verification mails are queued as mail.verification with the user id only from now on
+DELETE FROM `jobs` WHERE `type` = 'mail.send';
@@ -0,0 +1,10 @@
+-- +migrate Down
+ALTER TABLE `phone_verifications` DROP INDEX `phone_verification_send_id_unique`;
+ALTER TABLE `phone_verifications` DROP COLUMN `send_id`;
+
+-- +migrate Up
+-- sms.send jobs carried the number and the plaintext code. phone codes are queued as
+-- sms.phone_code with the send id only, the code is issued when the job runs
+ALTER TABLE `phone_verifications` ADD COLUMN `send_id` VARCHAR(36) DEFAULT NULL AFTER `phone_number`;
+ALTER TABLE `phone_verifications` ADD CONSTRAINT `phone_verification_send_id_unique` UNIQUE (`send_id`);
+DELETE FROM `jobs` WHERE `type` = 'sms.send';
@@ -0,0 +1,18 @@
+-- +migrate Down
+ALTER TABLE `profile_photos`
+    ADD INDEX `profile_photo_user_position_index` (`user_id`, `position`),
+    DROP INDEX `profile_photo_user_position_unique`;
+
+-- +migrate Up
+-- concurrent uploads could give two photos the same position, number the galleries again
+-- in their current order before positions become unique
+UPDATE `profile_photos` p
+    JOIN (
+        SELECT `id`, ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `position`, `id`) - 1 AS `renumbered`
+        FROM `profile_photos`
+    ) n ON n.`id` = p.`id`
+SET p.`position` = n.`renumbered`;
+
+ALTER TABLE `profile_photos`
+    ADD CONSTRAINT `profile_photo_user_position_unique` UNIQUE (`user_id`, `position`),
+    DROP INDEX `profile_photo_user_position_index`;
@@ -0,0 +1,57 @@
+-- +migrate Down
+-- the signed urls are gone, the paths are put back in their place and have to be signed again
+ALTER TABLE `profile_photos`
+    ADD COLUMN `url` TEXT NOT NULL AFTER `file_id`,
+    ADD COLUMN `thumbnail_url` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `thumbnail_file_id`,
+    ADD COLUMN `card_url` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `card_file_id`;
+UPDATE `profile_photos` SET `url` = `path`, `thumbnail_url` = `thumbnail_path`, `card_url` = `card_path`;
+ALTER TABLE `profile_photos`
+    DROP COLUMN `path`,
+    DROP COLUMN `thumbnail_path`,
+    DROP COLUMN `card_path`;
+
+ALTER TABLE `profile_verifications`
+    ADD COLUMN `selfie_url` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `selfie_file_id`;
+UPDATE `profile_verifications` SET `selfie_url` = `selfie_path`;
+ALTER TABLE `profile_verifications`
+    DROP COLUMN `selfie_path`;
+
+-- +migrate Up
+-- urls were stored signed without an expiry, so rejected photos and selfies stayed reachable.
+-- keep the blob paths instead and sign short-lived urls when they are shown.
+-- local blobs live under /blobs/, ImageKit paths follow the url endpoint
+ALTER TABLE `profile_photos`
+    ADD COLUMN `path` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `file_id`,
+    ADD COLUMN `thumbnail_path` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `thumbnail_file_id`,
+    ADD COLUMN `card_path` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `card_file_id`;
+UPDATE `profile_photos` SET
+    `path` = CASE
+        WHEN `url` LIKE '%/blobs/%' THEN REGEXP_REPLACE(`url`, '^[^?]*/blobs/([^?]*).*$', '$1')
+        WHEN `url` LIKE 'https://ik.imagekit.io/%' THEN REGEXP_REPLACE(`url`, '^https://ik\\.imagekit\\.io/[^/]+(/[^?]*).*$', '$1')
+        ELSE REGEXP_REPLACE(`url`, '^https?://[^/]+(/[^?]*).*$', '$1')
+    END,
+    `thumbnail_path` = CASE
+        WHEN `thumbnail_url` LIKE '%/blobs/%' THEN REGEXP_REPLACE(`thumbnail_url`, '^[^?]*/blobs/([^?]*).*$', '$1')
+        WHEN `thumbnail_url` LIKE 'https://ik.imagekit.io/%' THEN REGEXP_REPLACE(`thumbnail_url`, '^https://ik\\.imagekit\\.io/[^/]+(/[^?]*).*$', '$1')
+        ELSE REGEXP_REPLACE(`thumbnail_url`, '^https?://[^/]+(/[^?]*).*$', '$1')
+    END,
+    `card_path` = CASE
+        WHEN `card_url` LIKE '%/blobs/%' THEN REGEXP_REPLACE(`card_url`, '^[^?]*/blobs/([^?]*).*$', '$1')
+        WHEN `card_url` LIKE 'https://ik.imagekit.io/%' THEN REGEXP_REPLACE(`card_url`, '^https://ik\\.imagekit\\.io/[^/]+(/[^?]*).*$', '$1')
+        ELSE REGEXP_REPLACE(`card_url`, '^https?://[^/]+(/[^?]*).*$', '$1')
+    END;
+ALTER TABLE `profile_photos`
+    DROP COLUMN `url`,
+    DROP COLUMN `thumbnail_url`,
+    DROP COLUMN `card_url`;
+
+ALTER TABLE `profile_verifications`
+    ADD COLUMN `selfie_path` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `selfie_file_id`;
+UPDATE `profile_verifications` SET
+    `selfie_path` = CASE
+        WHEN `selfie_url` LIKE '%/blobs/%' THEN REGEXP_REPLACE(`selfie_url`, '^[^?]*/blobs/([^?]*).*$', '$1')
+        WHEN `selfie_url` LIKE 'https://ik.imagekit.io/%' THEN REGEXP_REPLACE(`selfie_url`, '^https://ik\\.imagekit\\.io/[^/]+(/[^?]*).*$', '$1')
+        ELSE REGEXP_REPLACE(`selfie_url`, '^https?://[^/]+(/[^?]*).*$', '$1')
+    END;
+ALTER TABLE `profile_verifications`
+    DROP COLUMN `selfie_url`;
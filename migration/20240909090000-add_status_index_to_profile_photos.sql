@@ -0,0 +1,8 @@
+-- +migrate Down
+ALTER TABLE `profile_photos`
+    DROP INDEX `profile_photo_status_index`;
+
+-- +migrate Up
+-- the admin review queue lists pending photos oldest first
+ALTER TABLE `profile_photos`
+    ADD INDEX `profile_photo_status_index` (`status`, `id`);
@@ -0,0 +1,8 @@
+-- +migrate Down
+ALTER TABLE `users`
+    DROP COLUMN `session_version`;
+
+-- +migrate Up
+-- tokens carry the version they were signed for, bumping it signs every session out
+ALTER TABLE `users`
+    ADD COLUMN `session_version` INT NOT NULL DEFAULT 0;
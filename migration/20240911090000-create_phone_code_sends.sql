@@ -0,0 +1,14 @@
+-- +migrate Down
+DROP TABLE IF EXISTS `phone_code_sends`;
+
+-- +migrate Up
+-- every code texted for a user, phone_verifications only counts the sends to one number
+CREATE TABLE IF NOT EXISTS `phone_code_sends` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` VARCHAR(36) NOT NULL,
+    `phone_number` VARCHAR(16) NOT NULL,
+    `sent_at` DATETIME NOT NULL,
+    PRIMARY KEY (`id`),
+    INDEX `phone_code_send_user_sent_at_index` (`user_id`, `sent_at`),
+    CONSTRAINT `fk_phone_code_sends_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// ---------------- DTO ----------------
+
+type AccountErasePayload struct {
+	UserID string `json:"user_id"`
+}
+
+// AccountExport is everything we hold about a user, handed out on request
+type AccountExport struct {
+	ExportedAt         int64                       `json:"exported_at"`
+	User               ExportedUser                `json:"user"`
+	Profile            *ExportedProfile            `json:"profile"`
+	Preferences        *SerializableUserPreference `json:"preferences"`
+	Answers            []SerializableAnswer        `json:"answers"`
+	Matches            []ExportedMatch             `json:"matches"`
+	RecommendedUserIDs []string                    `json:"recommended_user_ids"`
+	Identities         []SerializableIdentity      `json:"identities"`
+	MFAEnabled         bool                        `json:"mfa_enabled"`
+}
+
+type ExportedUser struct {
+	ID                  string     `json:"id"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	PhoneNumber         *string    `json:"phone_number"`
+	PhoneVerifiedAt     *time.Time `json:"phone_verified_at"`
+	Locale              string     `json:"locale"`
+	Role                string     `json:"role"`
+	CreatedAt           time.Time  `json:"created_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+type ExportedProfile struct {
+	*SerializableProfile
+	Coordinates string     `json:"coordinates"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+type ExportedMatch struct {
+	MatcherID   string    `json:"matcher_id"`
+	MatcheeID   string    `json:"matchee_id"`
+	MatchStatus int       `json:"match_status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
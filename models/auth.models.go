@@ -4,16 +4,25 @@ import "github.com/dgrijalva/jwt-go"
 
 // ---------------- DTO ----------------
 
+// JWTPurposeMFAPending marks a token that only proves the password, it has to be exchanged
+// for a session with a second factor
+const JWTPurposeMFAPending = "mfa_pending"
+
 // JWTClaim represents the authorized object encrypted in the JWT token
 type JWTClaim struct {
 	UserID    string `json:"user_id"`
 	UserEmail string `json:"user_email"`
+	Purpose   string `json:"purpose,omitempty"`
+	// SessionVersion is the users.session_version the token was signed for, bumping it
+	// revokes every token signed before
+	SessionVersion int `json:"session_version,omitempty"`
 	jwt.StandardClaims
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	Locale   string `json:"locale" validate:"omitempty,oneof=en vi"`
 }
 
 type SignInRequest struct {
@@ -21,9 +30,12 @@ type SignInRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// VerifyEmailRequest proves the mailbox. Anyone could register the email before, so the password
+// set at registration is dropped: the client sends it again in Password to keep it.
 type VerifyEmailRequest struct {
 	Email            string `json:"email" validate:"required,email"`
 	VerificationCode string `json:"verification_code" validate:"required"`
+	Password         string `json:"password"`
 }
 
 type SendVerificationEmailRequest struct {
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+const (
+	ExperimentEventExposure = "exposure" // the subject was shown in the user's feed
+	ExperimentEventLike     = "like"
+	ExperimentEventPass     = "pass"
+	ExperimentEventMatch    = "match" // the user's like made a mutual match
+)
+
+// ExperimentEvent is something a user in an experiment did, tagged with their variant
+type ExperimentEvent struct {
+	ID         uint      `gorm:"primaryKey;column:id"`
+	Experiment string    `gorm:"column:experiment"`
+	Variant    string    `gorm:"column:variant"`
+	UserID     string    `gorm:"column:user_id"`
+	Event      string    `gorm:"column:event"`
+	SubjectID  string    `gorm:"column:subject_id"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+}
+
+// TableName gives table name of model
+func (e *ExperimentEvent) TableName() string {
+	return "experiment_events"
+}
+
+// ---------------- DTO ----------------
+
+// ExperimentEventsPayload is the payload of the job storing experiment events
+type ExperimentEventsPayload struct {
+	Events []ExperimentEvent `json:"events"`
+}
+
+// ExperimentEventCount counts the events of one kind in a variant
+type ExperimentEventCount struct {
+	Variant string `gorm:"column:variant"`
+	Event   string `gorm:"column:event"`
+	Events  int    `gorm:"column:events"`
+	Users   int    `gorm:"column:users"`
+}
+
+// ExperimentVariantReport compares the outcomes of one variant with the others
+type ExperimentVariantReport struct {
+	Variant   string `json:"variant"`
+	Users     int    `json:"users"`
+	Exposures int    `json:"exposures"`
+	Likes     int    `json:"likes"`
+	Passes    int    `json:"passes"`
+	Matches   int    `json:"matches"`
+	// likes per exposure
+	LikeRate float64 `json:"like_rate"`
+	// mutual matches per exposure
+	MatchRate float64 `json:"match_rate"`
+}
+
+// ExperimentReport is the outcome of every variant of an experiment
+type ExperimentReport struct {
+	Experiment  string                    `json:"experiment"`
+	Variants    []ExperimentVariantReport `json:"variants"`
+	GeneratedAt time.Time                 `json:"generated_at"`
+}
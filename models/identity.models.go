@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+const (
+	IdentityProviderGoogle = "google"
+	IdentityProviderApple  = "apple"
+)
+
+// Identity links a user to an account of an OpenID Connect provider
+type Identity struct {
+	ID        uint   `gorm:"primaryKey;column:id"`
+	UserID    string `gorm:"column:user_id"`
+	Provider  string `gorm:"column:provider"`
+	Subject   string `gorm:"column:subject"`
+	Email     string `gorm:"column:email"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName gives table name of model
+func (i *Identity) TableName() string {
+	return "identities"
+}
+
+// ---------------- DTO ----------------
+
+func (i *Identity) Serialize() *SerializableIdentity {
+	if i == nil {
+		return nil
+	}
+	return &SerializableIdentity{
+		Provider: i.Provider,
+		Email:    i.Email,
+		LinkedAt: i.CreatedAt.Unix(),
+	}
+}
+
+type SerializableIdentity struct {
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+	LinkedAt int64  `json:"linked_at"`
+}
+
+type OIDCSignInRequest struct {
+	IDToken string `json:"id_token" validate:"required"`
+	Nonce   string `json:"nonce"`
+}
+
+// OIDCIdentity is what we keep from a validated id token
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
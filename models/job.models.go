@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusDead    = "dead"
+)
+
+// Job is a unit of background work stored in the outbox until a worker runs it
+type Job struct {
+	ID          uint       `gorm:"primaryKey;column:id"`
+	Type        string     `gorm:"column:type"`
+	Payload     string     `gorm:"column:payload"`
+	Status      string     `gorm:"column:status"`
+	Attempts    int        `gorm:"column:attempts"`
+	MaxAttempts int        `gorm:"column:max_attempts"`
+	RunAt       time.Time  `gorm:"column:run_at"`
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+	LastError   string     `gorm:"column:last_error"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName gives table name of model
+func (j *Job) TableName() string {
+	return "jobs"
+}
+
+// ---------------- DTO ----------------
+
+type JobFilter struct {
+	Status string
+	Limit  int
+}
+
+// VerificationEmailPayload names the user to mail, the code is issued and the mail rendered
+// when the job runs so that neither is kept in the outbox
+type VerificationEmailPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// PhoneCodePayload names the send the code is for, the code is issued and the number looked
+// up when the job runs
+type PhoneCodePayload struct {
+	UserID string `json:"user_id"`
+	SendID string `json:"send_id"`
+}
+
+type ImageDeletePayload struct {
+	FileID string `json:"file_id"`
+}
+
+// PhotoModerationPayload names the files that were checked, a verdict on files the photo
+// no longer points to is dropped
+type PhotoModerationPayload struct {
+	UserID  string `json:"user_id"`
+	PhotoID uint   `json:"photo_id"`
+	FileID  string `json:"file_id"`
+}
+
+type ProfileVerificationMatchPayload struct {
+	VerificationID uint `json:"verification_id"`
+}
+
+type RecommendationBinPayload struct {
+	UserID             string   `json:"user_id"`
+	RecommendedUserIDs []string `json:"recommended_user_ids"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+const (
+	LoginAttemptScopeAccount = "account"
+	LoginAttemptScopeIP      = "ip"
+)
+
+// LoginAttempt tracks failed sign in attempts for an account (email) or a client ip
+type LoginAttempt struct {
+	Scope          string     `gorm:"primaryKey;column:scope"`
+	Subject        string     `gorm:"primaryKey;column:subject"`
+	FailedAttempts int        `gorm:"column:failed_attempts"`
+	LastFailedAt   time.Time  `gorm:"column:last_failed_at"`
+	LockedUntil    *time.Time `gorm:"column:locked_until"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// TableName gives table name of model
+func (l *LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// IsLocked reports whether the subject is locked at the given time
+func (l *LoginAttempt) IsLocked(now time.Time) bool {
+	return l != nil && l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+// ---------------- DTO ----------------
+
+type UnlockAccountRequest struct {
+	Email string `json:"email" validate:"omitempty,email,required_without=IP"`
+	IP    string `json:"ip" validate:"omitempty,ip,required_without=Email"`
+}
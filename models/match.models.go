@@ -7,6 +7,12 @@ import (
 
 // ============= DAO ================
 
+const (
+	MatchStatusWaiting = 0 // the matcher liked the matchee
+	MatchStatusMatched = 1 // both liked each other
+	MatchStatusPassed  = 2 // the matcher passed on the matchee
+)
+
 type Match struct {
 	MatcherId   string `gorm:"primaryKey;column:matcher_id"`
 	MatcheeId   string `gorm:"primaryKey;column:matchee_id"`
@@ -27,11 +33,19 @@ type MatchCalculationResult struct {
 	MatchedProfile  Profile
 }
 
+// SwipeCounts is how other users swiped on a profile
+type SwipeCounts struct {
+	UserID string `gorm:"column:user_id"`
+	Likes  int    `gorm:"column:likes"`
+	Passes int    `gorm:"column:passes"`
+}
+
 type GetRecommendationRequest struct {
-	MinAge      int     `json:"min_age"`
-	MaxAge      int     `json:"max_age"`
-	MinDistance float64 `json:"min_distance"`
-	MaxDistance float64 `json:"max_distance"`
+	MinAge       int     `json:"min_age"`
+	MaxAge       int     `json:"max_age"`
+	MinDistance  float64 `json:"min_distance"`
+	MaxDistance  float64 `json:"max_distance"`
+	VerifiedOnly bool    `json:"verified_only"`
 }
 
 type SmashRequest struct {
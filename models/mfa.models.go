@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+// UserTOTP holds the authenticator app secret of a user, two-factor authentication is
+// enabled once the enrolment has been confirmed with a valid code
+type UserTOTP struct {
+	UserID       string     `gorm:"primaryKey;column:user_id"`
+	Secret       string     `gorm:"column:secret"`
+	ConfirmedAt  *time.Time `gorm:"column:confirmed_at"`
+	LastUsedStep int64      `gorm:"column:last_used_step"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TableName gives table name of model
+func (t *UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+// IsConfirmed reports whether the enrolment was finished
+func (t *UserTOTP) IsConfirmed() bool {
+	return t != nil && t.ConfirmedAt != nil
+}
+
+// MFARecoveryCode is a single-use fallback code, only its sha256 hash is stored
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;column:id"`
+	UserID    string     `gorm:"column:user_id"`
+	CodeHash  string     `gorm:"column:code_hash"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time
+}
+
+// TableName gives table name of model
+func (c *MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// ---------------- DTO ----------------
+
+type MFAEnrolmentConfirmRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"time"
+)
+
+// ---------------- DAO ----------------
+
+const (
+	NotificationKindNewMatch = "new_match"
+	NotificationKindNewLike  = "new_like"
+)
+
+// Device is a push token of one of the user's devices
+type Device struct {
+	ID        uint      `gorm:"primaryKey;column:id"`
+	UserID    string    `gorm:"column:user_id"`
+	Platform  string    `gorm:"column:platform"`
+	Token     string    `gorm:"column:token"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// TableName gives table name of model
+func (d *Device) TableName() string {
+	return "devices"
+}
+
+// NotificationPreference is which pushes the user wants and their quiet hours
+type NotificationPreference struct {
+	UserID     string `gorm:"primaryKey;column:user_id"`
+	NewMatches bool   `gorm:"column:new_matches"`
+	NewLikes   bool   `gorm:"column:new_likes"`
+	// HH:MM in TimeZone, no quiet hours while empty
+	QuietHoursStart string    `gorm:"column:quiet_hours_start"`
+	QuietHoursEnd   string    `gorm:"column:quiet_hours_end"`
+	TimeZone        string    `gorm:"column:time_zone"`
+	CreatedAt       time.Time `gorm:"column:created_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at"`
+}
+
+// TableName gives table name of model
+func (p *NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// DefaultNotificationPreference is used until the user saves their own, every push on and
+// no quiet hours
+func DefaultNotificationPreference(userID string) *NotificationPreference {
+	return &NotificationPreference{
+		UserID:     userID,
+		NewMatches: true,
+		NewLikes:   true,
+		TimeZone:   "UTC",
+	}
+}
+
+// Allows tells whether the user wants pushes of the kind
+func (p *NotificationPreference) Allows(kind string) bool {
+	switch kind {
+	case NotificationKindNewMatch:
+		return p.NewMatches
+	case NotificationKindNewLike:
+		return p.NewLikes
+	default:
+		return true
+	}
+}
+
+// QuietUntil gives the end of the quiet hours now falls in, false when now is not quiet
+func (p *NotificationPreference) QuietUntil(now time.Time) (time.Time, bool) {
+	if p.QuietHoursStart == "" || p.QuietHoursEnd == "" {
+		return time.Time{}, false
+	}
+	location, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	start, errStart := time.Parse("15:04", p.QuietHoursStart)
+	end, errEnd := time.Parse("15:04", p.QuietHoursEnd)
+	if errStart != nil || errEnd != nil {
+		return time.Time{}, false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	var quiet bool
+	switch {
+	case startMinute < endMinute:
+		quiet = minute >= startMinute && minute < endMinute
+	case startMinute > endMinute:
+		// the quiet hours span midnight
+		quiet = minute >= startMinute || minute < endMinute
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, location)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// Notification is a push queued for a user, sent to all of their devices
+type Notification struct {
+	ID       uint   `gorm:"primaryKey;column:id"`
+	UserID   string `gorm:"column:user_id"`
+	Kind     string `gorm:"column:kind"`
+	DedupKey string `gorm:"column:dedup_key"`
+	Title    string `gorm:"column:title"`
+	Body     string `gorm:"column:body"`
+	// JSON object handed to the app
+	Data      string     `gorm:"column:data"`
+	SendAt    time.Time  `gorm:"column:send_at"`
+	SentAt    *time.Time `gorm:"column:sent_at"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+}
+
+// TableName gives table name of model
+func (n *Notification) TableName() string {
+	return "notifications"
+}
+
+// ---------------- DTO ----------------
+
+func (d *Device) Serialize() *SerializableDevice {
+	if d == nil {
+		return nil
+	}
+	return &SerializableDevice{
+		ID:        d.ID,
+		Platform:  d.Platform,
+		CreatedAt: d.CreatedAt,
+	}
+}
+
+type SerializableDevice struct {
+	ID        uint      `json:"id"`
+	Platform  string    `json:"platform"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (p *NotificationPreference) Serialize() *SerializableNotificationPreference {
+	if p == nil {
+		return nil
+	}
+	return &SerializableNotificationPreference{
+		NewMatches:      p.NewMatches,
+		NewLikes:        p.NewLikes,
+		QuietHoursStart: p.QuietHoursStart,
+		QuietHoursEnd:   p.QuietHoursEnd,
+		TimeZone:        p.TimeZone,
+	}
+}
+
+type SerializableNotificationPreference struct {
+	NewMatches      bool   `json:"new_matches"`
+	NewLikes        bool   `json:"new_likes"`
+	QuietHoursStart string `json:"quiet_hours_start"`
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+	TimeZone        string `json:"time_zone"`
+}
+
+type DeviceRequest struct {
+	Token    string `json:"token" validate:"required,max=512"`
+	Platform string `json:"platform" validate:"required,oneof=ios android"`
+}
+
+type DeviceDeleteRequest struct {
+	Token string `json:"token" validate:"required,max=512"`
+}
+
+// NotificationPreferenceRequest changes the settings present, an empty quiet hours start and
+// end turn the quiet hours off
+type NotificationPreferenceRequest struct {
+	NewMatches      *bool   `json:"new_matches"`
+	NewLikes        *bool   `json:"new_likes"`
+	QuietHoursStart *string `json:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+	TimeZone        *string `json:"time_zone" validate:"omitempty,timezone"`
+}
+
+// NotificationPayload is the payload of the job pushing a notification
+type NotificationPayload struct {
+	NotificationID uint `json:"notification_id"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// ---------------- DTO ----------------
+
+// Onboarding steps, in the order the client walks through them
+const (
+	OnboardingStepAccountVerified   = "account_verified"
+	OnboardingStepProfileCreated    = "profile_created"
+	OnboardingStepPhotosUploaded    = "photos_uploaded"
+	OnboardingStepQuestionsAnswered = "questions_answered"
+	OnboardingStepPreferencesSet    = "preferences_set"
+	OnboardingStepDone              = "done"
+)
+
+// OnboardingStep reports one step, Current and Target count photos or answers
+type OnboardingStep struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Done     bool   `json:"done"`
+	Current  int    `json:"current,omitempty"`
+	Target   int    `json:"target,omitempty"`
+}
+
+// OnboardingState is where a user stands. CurrentStep is the first step not done yet, or
+// OnboardingStepDone, and Complete tells whether every required step is done.
+type OnboardingState struct {
+	Steps        []OnboardingStep `json:"steps"`
+	CurrentStep  string           `json:"current_step"`
+	Complete     bool             `json:"complete"`
+	Completeness int              `json:"completeness"`
+}
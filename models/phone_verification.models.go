@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+// PhoneVerification is the pending code of a phone number. It outlives verified codes so
+// that the sends to a number stay rate limited whoever asks for them.
+type PhoneVerification struct {
+	PhoneNumber     string    `gorm:"primaryKey;column:phone_number"`
+	SendID          string    `gorm:"column:send_id"`
+	UserID          string    `gorm:"column:user_id"`
+	CodeHash        string    `gorm:"column:code_hash"`
+	Attempts        int       `gorm:"column:attempts"`
+	SentAt          time.Time `gorm:"column:sent_at"`
+	SendCount       int       `gorm:"column:send_count"`
+	WindowStartedAt time.Time `gorm:"column:window_started_at"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// TableName gives table name of model
+func (p *PhoneVerification) TableName() string {
+	return "phone_verifications"
+}
+
+// PhoneCodeSend is one code texted for a user, the sends of a user are rate limited across
+// numbers
+type PhoneCodeSend struct {
+	ID          uint      `gorm:"primaryKey;column:id"`
+	UserID      string    `gorm:"column:user_id"`
+	PhoneNumber string    `gorm:"column:phone_number"`
+	SentAt      time.Time `gorm:"column:sent_at"`
+}
+
+// TableName gives table name of model
+func (p *PhoneCodeSend) TableName() string {
+	return "phone_code_sends"
+}
+
+// ---------------- DTO ----------------
+
+type PhoneCodeRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,e164"`
+}
+
+type PhoneVerifyRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,e164"`
+	Code        string `json:"code" validate:"required"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+// UserPreference is who a user wants to be recommended
+type UserPreference struct {
+	UserID       string    `gorm:"primaryKey;column:user_id"`
+	InterestedIn string    `gorm:"column:interested_in"`
+	MinAge       int       `gorm:"column:min_age"`
+	MaxAge       int       `gorm:"column:max_age"`
+	MaxDistance  float64   `gorm:"column:max_distance"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at"`
+}
+
+// TableName gives table name of model
+func (p *UserPreference) TableName() string {
+	return "user_preferences"
+}
+
+// ---------------- DTO ----------------
+
+func (p *UserPreference) Serialize() *SerializableUserPreference {
+	if p == nil {
+		return nil
+	}
+	return &SerializableUserPreference{
+		InterestedIn: p.InterestedIn,
+		MinAge:       p.MinAge,
+		MaxAge:       p.MaxAge,
+		MaxDistance:  p.MaxDistance,
+	}
+}
+
+type SerializableUserPreference struct {
+	InterestedIn string  `json:"interested_in"`
+	MinAge       int     `json:"min_age"`
+	MaxAge       int     `json:"max_age"`
+	MaxDistance  float64 `json:"max_distance"`
+}
+
+type UserPreferenceRequest struct {
+	InterestedIn string  `json:"interested_in" validate:"required,oneof=male female"`
+	MinAge       int     `json:"min_age" validate:"required,min=18,max=100"`
+	MaxAge       int     `json:"max_age" validate:"required,min=18,max=100,gtefield=MinAge"`
+	MaxDistance  float64 `json:"max_distance" validate:"required,gt=0,max=500"`
+}
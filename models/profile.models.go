@@ -2,36 +2,38 @@ package models
 
 import (
 	"gorm.io/gorm"
-	"strings"
 	"time"
 )
 
+// MinProfileAge is the youngest age a profile can have
+const MinProfileAge = 18
+
 // ---------- DAO ----------------
 
 // Profile model
 type Profile struct {
 	gorm.Model
-	ID          string    `gorm:"primaryKey;column:id"`
-	Name        string    `gorm:"column:name"`
-	Gender      string    `gorm:"column:gender"`
-	Birthday    time.Time `gorm:"column:birthday"`
-	Height      string    `gorm:"column:height"`
-	Horoscope   string    `gorm:"column:horoscope"`
-	Hobby       string    `gorm:"column:hobby"`
-	Language    string    `gorm:"column:language"`
-	Education   string    `gorm:"column:education"`
-	HomeTown    string    `gorm:"column:home_town"`
-	Coordinates string    `gorm:"column:coordinates"`
-	ImageId1    string    `gorm:"column:image_id_1"`
-	ImageId2    string    `gorm:"column:image_id_2"`
-	ImageId3    string    `gorm:"column:image_id_3"`
-	ImageId4    string    `gorm:"column:image_id_4"`
-	ImageId5    string    `gorm:"column:image_id_5"`
-	ImageUrl1   string    `gorm:"column:image_url_1"`
-	ImageUrl2   string    `gorm:"column:image_url_2"`
-	ImageUrl3   string    `gorm:"column:image_url_3"`
-	ImageUrl4   string    `gorm:"column:image_url_4"`
-	ImageUrl5   string    `gorm:"column:image_url_5"`
+	ID          string            `gorm:"primaryKey;column:id"`
+	Name        string            `gorm:"column:name"`
+	Gender      string            `gorm:"column:gender"`
+	Birthday    time.Time         `gorm:"column:birthday"`
+	HeightCm    *int              `gorm:"column:height_cm"`
+	Horoscope   string            `gorm:"column:horoscope"`
+	Education   string            `gorm:"column:education"`
+	HomeTown    string            `gorm:"column:home_town"`
+	Location    string            `gorm:"column:location"`
+	Coordinates string            `gorm:"column:coordinates"`
+	Photos      []ProfilePhoto    `gorm:"foreignKey:UserID;references:ID"`
+	Interests   []ProfileInterest `gorm:"foreignKey:ProfileID;references:ID"`
+	Languages   []ProfileLanguage `gorm:"foreignKey:ProfileID;references:ID"`
+	Prompts     []ProfilePrompt   `gorm:"foreignKey:ProfileID;references:ID"`
+	VerifiedAt  *time.Time        `gorm:"column:verified_at"`
+	// written by ActivityService, nil until the user's first request
+	LastActiveAt *time.Time `gorm:"column:last_active_at"`
+	ProfileVisibility
+	Travel
+	// read from users, filled by ProfileRepository.GetProfileById
+	PhoneVerifiedAt *time.Time `gorm:"->;column:phone_verified_at"`
 }
 
 // TableName gives table name of model
@@ -39,107 +41,241 @@ func (p *Profile) TableName() string {
 	return "profiles"
 }
 
+// ProfileVisibility controls who sees the profile and how much of it
+type ProfileVisibility struct {
+	// left out of recommendations, only the owner sees the profile
+	DiscoveryPaused bool `gorm:"column:discovery_paused" json:"discovery_paused"`
+	// only the people the user liked see the profile
+	Incognito    bool `gorm:"column:incognito" json:"incognito"`
+	HideAge      bool `gorm:"column:hide_age" json:"hide_age"`
+	HideDistance bool `gorm:"column:hide_distance" json:"hide_distance"`
+}
+
+// Hidden tells whether the profile is kept from other users, paused ones from all of them
+func (v ProfileVisibility) Hidden() bool {
+	return v.DiscoveryPaused || v.Incognito
+}
+
+// Travel overrides where the user discovers from until it expires
+type Travel struct {
+	TravelLocation    string     `gorm:"column:travel_location"`
+	TravelCoordinates string     `gorm:"column:travel_coordinates"`
+	TravelExpiresAt   *time.Time `gorm:"column:travel_expires_at"`
+}
+
+// Traveling tells whether the travel location is in effect at now
+func (t Travel) Traveling(now time.Time) bool {
+	return t.TravelExpiresAt != nil && t.TravelExpiresAt.After(now) && t.TravelCoordinates != ""
+}
+
+// DiscoveryCoordinates are the coordinates recommendations start from, the travel ones while
+// travelling
+func (p *Profile) DiscoveryCoordinates(now time.Time) string {
+	if p.Traveling(now) {
+		return p.TravelCoordinates
+	}
+	return p.Coordinates
+}
+
+const (
+	ActivityActiveToday    = "active_today"
+	ActivityActiveThisWeek = "active_this_week"
+)
+
+// LastActive gives when the user was last active, zero when unknown
+func (p *Profile) LastActive() time.Time {
+	if p.LastActiveAt == nil {
+		return time.Time{}
+	}
+	return *p.LastActiveAt
+}
+
+// Activity gives the recently active badge of the profile at now, empty when the user wasn't
+// active this week
+func (p *Profile) Activity(now time.Time) string {
+	lastActive := p.LastActive()
+	switch {
+	case lastActive.IsZero():
+		return ""
+	case now.Sub(lastActive) < 24*time.Hour:
+		return ActivityActiveToday
+	case now.Sub(lastActive) < 7*24*time.Hour:
+		return ActivityActiveThisWeek
+	default:
+		return ""
+	}
+}
+
+// Completeness scores from 0 to 100 how much of the profile is filled in, photos and prompts
+// weigh the most. Rejected photos don't count.
+func (p *Profile) Completeness() int {
+	if p == nil {
+		return 0
+	}
+
+	photos := 0
+	for _, photo := range p.Photos {
+		if photo.Status != ProfilePhotoStatusRejected {
+			photos++
+		}
+	}
+
+	// name, gender and birthday are required to create the profile
+	score := 20.0
+	score += 30 * float64(min(photos, 4)) / 4
+	score += 10 * float64(min(len(p.Prompts), MaxProfilePrompts)) / MaxProfilePrompts
+	score += 10 * float64(min(len(p.Interests), 3)) / 3
+	for _, filled := range []bool{
+		p.HeightCm != nil,
+		p.Horoscope != "",
+		p.Education != "",
+		p.HomeTown != "",
+		len(p.Languages) > 0,
+		p.VerifiedAt != nil,
+	} {
+		if filled {
+			score += 5
+		}
+	}
+	return int(score)
+}
+
 // ---------- DTO ----------------
 
-func (p *Profile) Serialize() *SerializableProfile {
+// Serialize shows the profile to other users, with approved photos only and without the
+// birthday when the age is hidden
+func (p *Profile) Serialize(sign URLSigner) *SerializableProfile {
 	if p == nil {
 		return nil
 	}
-	return &SerializableProfile{
+	result := &SerializableProfile{
 		ID:                p.ID,
 		Name:              p.Name,
 		Gender:            p.Gender,
 		BirthdayInSeconds: p.Birthday.Unix(),
-		Height:            p.Height,
+		HeightCm:          p.HeightCm,
 		Horoscope:         p.Horoscope,
-		Hobby:             strings.Split(p.Hobby, ","),
-		Language:          strings.Split(p.Language, ","),
+		Interests:         SerializeProfileInterests(p.Interests),
+		Languages:         SerializeProfileLanguages(p.Languages),
+		Prompts:           SerializeProfilePrompts(p.Prompts),
 		Education:         p.Education,
+		Location:          p.Location,
 		HomeTown:          p.HomeTown,
-		Image1:            p.ImageUrl1,
-		Image2:            p.ImageUrl2,
-		Image3:            p.ImageUrl3,
-		Image4:            p.ImageUrl4,
-		Image5:            p.ImageUrl5,
+		Photos:            SerializeApprovedProfilePhotos(p.Photos, sign),
+		PhoneVerified:     p.PhoneVerifiedAt != nil,
+		VerifiedAt:        p.VerifiedAt,
+	}
+	if p.HideAge {
+		result.BirthdayInSeconds = 0
+	}
+	if p.Traveling(time.Now()) {
+		result.TravelingTo = p.TravelLocation
 	}
+	return result
 }
 
-func (p *Profile) ConvertToMatchProfile() *MatchProfile {
+// SerializeForOwner shows every photo, with the reason of rejected ones, and the visibility
+// settings
+func (p *Profile) SerializeForOwner(sign URLSigner) *SerializableProfile {
+	result := p.Serialize(sign)
+	if result != nil {
+		result.BirthdayInSeconds = p.Birthday.Unix()
+		result.Photos = SerializeProfilePhotos(p.Photos, sign)
+		visibility := p.ProfileVisibility
+		result.Visibility = &visibility
+	}
+	return result
+}
+
+func (p *Profile) ConvertToMatchProfile(sign URLSigner) *MatchProfile {
 	if p == nil {
 		return nil
 	}
 
+	var birthday *time.Time
+	if !p.HideAge {
+		birthday = &p.Birthday
+	}
+	now := time.Now()
+	var travelingTo string
+	if p.Traveling(now) {
+		travelingTo = p.TravelLocation
+	}
+
 	return &MatchProfile{
-		ID:        p.ID,
-		Name:      p.Name,
-		Gender:    p.Gender,
-		Birthday:  p.Birthday,
-		Height:    p.Height,
-		Horoscope: p.Horoscope,
-		Hobby:     strings.Split(p.Hobby, ","),
-		Language:  strings.Split(p.Language, ","),
-		Education: p.Education,
-		HomeTown:  p.HomeTown,
-		Image1:    p.ImageUrl1,
-		Image2:    p.ImageUrl2,
-		Image3:    p.ImageUrl3,
-		Image4:    p.ImageUrl4,
-		Image5:    p.ImageUrl5,
+		ID:          p.ID,
+		Name:        p.Name,
+		Gender:      p.Gender,
+		Birthday:    birthday,
+		HeightCm:    p.HeightCm,
+		Horoscope:   p.Horoscope,
+		Interests:   SerializeProfileInterests(p.Interests),
+		Languages:   SerializeProfileLanguages(p.Languages),
+		Prompts:     SerializeProfilePrompts(p.Prompts),
+		Education:   p.Education,
+		Location:    p.Location,
+		TravelingTo: travelingTo,
+		HomeTown:    p.HomeTown,
+		Photos:      SerializeApprovedProfilePhotos(p.Photos, sign),
+		VerifiedAt:  p.VerifiedAt,
+		Activity:    p.Activity(now),
 	}
 }
 
 type SerializableProfile struct {
-	ID                string   `json:"id"`
-	Name              string   `json:"name"`
-	Gender            string   `json:"gender"`
-	BirthdayInSeconds int64    `json:"birthday_in_seconds"`
-	Height            string   `json:"height"`
-	Horoscope         string   `json:"horoscope"`
-	Hobby             []string `json:"hobby"`
-	Language          []string `json:"language"`
-	Education         string   `json:"education"`
-	Location          string   `json:"location"`
-	HomeTown          string   `json:"home_town"`
-	Image1            string   `json:"image_1"`
-	Image2            string   `json:"image_2"`
-	Image3            string   `json:"image_3"`
-	Image4            string   `json:"image_4"`
-	Image5            string   `json:"image_5"`
-	Answered          int      `json:"answered"`
+	ID                string                      `json:"id"`
+	Name              string                      `json:"name"`
+	Gender            string                      `json:"gender"`
+	BirthdayInSeconds int64                       `json:"birthday_in_seconds,omitempty"`
+	HeightCm          *int                        `json:"height_cm"`
+	Horoscope         string                      `json:"horoscope"`
+	Interests         []SerializableInterest      `json:"interests"`
+	Languages         []SerializableLanguage      `json:"languages"`
+	Prompts           []SerializableProfilePrompt `json:"prompts"`
+	Education         string                      `json:"education"`
+	Location          string                      `json:"location"`
+	TravelingTo       string                      `json:"traveling_to,omitempty"`
+	HomeTown          string                      `json:"home_town"`
+	Photos            []SerializableProfilePhoto  `json:"photos"`
+	Answered          int                         `json:"answered"`
+	PhoneVerified     bool                        `json:"phone_verified"`
+	VerifiedAt        *time.Time                  `json:"verified_at"`
+	Visibility        *ProfileVisibility          `json:"visibility,omitempty"`
 }
 
 type MatchProfile struct {
-	ID              string    `json:"id"`
-	Name            string    `json:"name"`
-	Gender          string    `json:"gender"`
-	Birthday        time.Time `json:"birthday"`
-	Height          string    `json:"height"`
-	Horoscope       string    `json:"horoscope"`
-	Hobby           []string  `json:"hobby"`
-	Language        []string  `json:"language"`
-	Education       string    `json:"education"`
-	Location        string    `json:"location"`
-	HomeTown        string    `json:"home_town"`
-	Distance        float64   `json:"distance"`
-	MatchPercentage float64   `json:"match_percentage"`
-	Image1          string    `json:"image_1"`
-	Image2          string    `json:"image_2"`
-	Image3          string    `json:"image_3"`
-	Image4          string    `json:"image_4"`
-	Image5          string    `json:"image_5"`
+	ID              string                      `json:"id"`
+	Name            string                      `json:"name"`
+	Gender          string                      `json:"gender"`
+	Birthday        *time.Time                  `json:"birthday,omitempty"`
+	HeightCm        *int                        `json:"height_cm"`
+	Horoscope       string                      `json:"horoscope"`
+	Interests       []SerializableInterest      `json:"interests"`
+	Languages       []SerializableLanguage      `json:"languages"`
+	Prompts         []SerializableProfilePrompt `json:"prompts"`
+	Education       string                      `json:"education"`
+	Location        string                      `json:"location"`
+	TravelingTo     string                      `json:"traveling_to,omitempty"`
+	HomeTown        string                      `json:"home_town"`
+	Distance        float64                     `json:"distance"`
+	MatchPercentage float64                     `json:"match_percentage"`
+	Photos          []SerializableProfilePhoto  `json:"photos"`
+	VerifiedAt      *time.Time                  `json:"verified_at"`
+	// active_today or active_this_week
+	Activity string `json:"activity,omitempty"`
 }
 
 type ProfileCreateRequest struct {
-	Name              string   `json:"name" validate:"required"`
-	Gender            string   `json:"gender" validate:"oneof=male female,required"`
-	BirthdayInSeconds int64    `json:"birthday_in_seconds" validate:"required"`
-	Height            string   `json:"height"`
-	Horoscope         string   `json:"horoscope"`
-	Hobby             []string `json:"hobby"`
-	Language          []string `json:"language"`
-	Education         string   `json:"education"`
-	Location          string   `json:"location"`
-	HomeTown          string   `json:"home_town"`
+	Name              string                 `json:"name" validate:"required"`
+	Gender            string                 `json:"gender" validate:"oneof=male female,required"`
+	BirthdayInSeconds int64                  `json:"birthday_in_seconds" validate:"required"`
+	HeightCm          *int                   `json:"height_cm"`
+	Horoscope         string                 `json:"horoscope"`
+	Interests         []string               `json:"interests"`
+	Languages         []string               `json:"languages"`
+	Prompts           []ProfilePromptRequest `json:"prompts"`
+	Education         string                 `json:"education"`
+	HomeTown          string                 `json:"home_town"`
 	Coordinates       struct {
 		Longitude float64 `json:"longitude"`
 		Latitude  float64 `json:"latitude"`
@@ -147,40 +283,59 @@ type ProfileCreateRequest struct {
 }
 
 type ProfileUpdateRequest struct {
-	Name              string   `json:"name"`
-	Gender            string   `json:"gender" validate:"oneof=male female"`
-	BirthdayInSeconds int64    `json:"birthday_in_seconds"`
-	Height            string   `json:"height"`
-	Horoscope         string   `json:"horoscope"`
-	Hobby             []string `json:"hobby"`
-	Language          []string `json:"language"`
-	Education         string   `json:"education"`
-	Location          string   `json:"location"`
-	HomeTown          string   `json:"home_town"`
+	Name              string                 `json:"name"`
+	Gender            string                 `json:"gender" validate:"omitempty,oneof=male female"`
+	BirthdayInSeconds int64                  `json:"birthday_in_seconds"`
+	HeightCm          *int                   `json:"height_cm"`
+	Horoscope         string                 `json:"horoscope"`
+	Interests         []string               `json:"interests"`
+	Languages         []string               `json:"languages"`
+	Prompts           []ProfilePromptRequest `json:"prompts"`
+	Education         string                 `json:"education"`
+	HomeTown          string                 `json:"home_town"`
 	Coordinates       struct {
 		Longitude float64 `json:"longitude"`
 		Latitude  float64 `json:"latitude"`
 	} `json:"coordinates"`
-	ImageId1  string `json:"image_id_1"`
-	ImageId2  string `json:"image_id_2"`
-	ImageId3  string `json:"image_id_3"`
-	ImageId4  string `json:"image_id_4"`
-	ImageId5  string `json:"image_id_5"`
-	ImageUrl1 string `json:"image_url_1"`
-	ImageUrl2 string `json:"image_url_2"`
-	ImageUrl3 string `json:"image_url_3"`
-	ImageUrl4 string `json:"image_url_4"`
-	ImageUrl5 string `json:"image_url_5"`
 }
 
-type ImageUploadResult struct {
-	SlotId  int
-	FileId  string
-	FileUrl string
+// ProfilePatchRequest changes only the fields it carries. An empty string clears an optional
+// text field, a height of 0 clears the height and an empty list clears the entries.
+type ProfilePatchRequest struct {
+	Name              *string                    `json:"name" validate:"omitempty,min=1,max=50"`
+	Gender            *string                    `json:"gender" validate:"omitempty,oneof=male female"`
+	BirthdayInSeconds *int64                     `json:"birthday_in_seconds"`
+	HeightCm          *int                       `json:"height_cm"`
+	Horoscope         *string                    `json:"horoscope" validate:"omitempty,max=50"`
+	Interests         []string                   `json:"interests"`
+	Languages         []string                   `json:"languages"`
+	Prompts           []ProfilePromptRequest     `json:"prompts"`
+	Education         *string                    `json:"education" validate:"omitempty,max=255"`
+	HomeTown          *string                    `json:"home_town" validate:"omitempty,max=255"`
+	Coordinates       *ProfileCoordinatesRequest `json:"coordinates" validate:"omitempty"`
+}
+
+type ProfileCoordinatesRequest struct {
+	Longitude *float64 `json:"longitude" validate:"required,min=-180,max=180"`
+	Latitude  *float64 `json:"latitude" validate:"required,min=-90,max=90"`
+}
+
+type ProfileVisibilityRequest struct {
+	DiscoveryPaused *bool `json:"discovery_paused"`
+	Incognito       *bool `json:"incognito"`
+	HideAge         *bool `json:"hide_age"`
+	HideDistance    *bool `json:"hide_distance"`
+}
+
+// TravelRequest picks a city of GET /api/profile/travel/cities by its "City, Country" label
+type TravelRequest struct {
+	City string `json:"city" validate:"required"`
+	Days int    `json:"days" validate:"omitempty,min=1,max=14"`
 }
 
-type ProfileImageDeleteRequest struct {
-	Slots []int `json:"slots"`
+type SerializableTravel struct {
+	City      string    `json:"city"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type ProfileFilter struct {
@@ -192,4 +347,7 @@ type ProfileFilter struct {
 	MaxDistance    float64
 	Longitude      float64
 	Latitude       float64
+	VerifiedOnly   bool
+	// leaves out users inactive for longer, 0 keeps everyone
+	MaxInactiveDays int
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProfile_HideAge(t *testing.T) {
+	birthday := time.Date(1996, 5, 17, 0, 0, 0, 0, time.UTC)
+	sign := func(path string) string { return "https://blobs.test/" + path }
+
+	tests := []struct {
+		name    string
+		hideAge bool
+	}{
+		{"age shown", false},
+		{"age hidden", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := &Profile{ID: "ana", Birthday: birthday, ProfileVisibility: ProfileVisibility{HideAge: tt.hideAge}}
+
+			wantSeconds := birthday.Unix()
+			if tt.hideAge {
+				wantSeconds = 0
+			}
+			if got := profile.Serialize(sign).BirthdayInSeconds; got != wantSeconds {
+				t.Errorf("Serialize() birthday = %d, want %d", got, wantSeconds)
+			}
+
+			match := profile.ConvertToMatchProfile(sign)
+			if tt.hideAge && match.Birthday != nil {
+				t.Errorf("ConvertToMatchProfile() birthday = %v, want none", match.Birthday)
+			}
+			if !tt.hideAge && (match.Birthday == nil || !match.Birthday.Equal(birthday)) {
+				t.Errorf("ConvertToMatchProfile() birthday = %v, want %v", match.Birthday, birthday)
+			}
+
+			// the owner always sees their own birthday
+			if got := profile.SerializeForOwner(sign).BirthdayInSeconds; got != birthday.Unix() {
+				t.Errorf("SerializeForOwner() birthday = %d, want %d", got, birthday.Unix())
+			}
+		})
+	}
+}
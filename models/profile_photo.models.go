@@ -0,0 +1,166 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+const (
+	MaxProfilePhotos = 6
+
+	ProfilePhotoStatusPending  = "pending"
+	ProfilePhotoStatusApproved = "approved"
+	ProfilePhotoStatusRejected = "rejected"
+)
+
+// ProfilePhoto is one photo of a user's gallery, ordered by position. Other users only
+// see it once moderation approved it. The paths are signed into urls when shown.
+type ProfilePhoto struct {
+	ID               uint       `gorm:"primaryKey;column:id"`
+	UserID           string     `gorm:"column:user_id"`
+	FileID           string     `gorm:"column:file_id"`
+	Path             string     `gorm:"column:path"`
+	ThumbnailFileID  string     `gorm:"column:thumbnail_file_id"`
+	ThumbnailPath    string     `gorm:"column:thumbnail_path"`
+	CardFileID       string     `gorm:"column:card_file_id"`
+	CardPath         string     `gorm:"column:card_path"`
+	Position         int        `gorm:"column:position"`
+	IsPrimary        bool       `gorm:"column:is_primary"`
+	Width            int        `gorm:"column:width"`
+	Height           int        `gorm:"column:height"`
+	Status           string     `gorm:"column:status;default:pending"`
+	ModerationReason string     `gorm:"column:moderation_reason"`
+	ModeratedAt      *time.Time `gorm:"column:moderated_at"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// TableName gives table name of model
+func (p *ProfilePhoto) TableName() string {
+	return "profile_photos"
+}
+
+func (p *ProfilePhoto) IsApproved() bool {
+	return p.Status == ProfilePhotoStatusApproved
+}
+
+// FileIDs lists every stored file of the photo, photos uploaded before resizing only have one
+func (p *ProfilePhoto) FileIDs() []string {
+	var ids []string
+	for _, id := range []string{p.FileID, p.ThumbnailFileID, p.CardFileID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// CardOrFullPath is the path of the card size, photos uploaded before resizing only have the full one
+func (p *ProfilePhoto) CardOrFullPath() string {
+	if p.CardPath != "" {
+		return p.CardPath
+	}
+	return p.Path
+}
+
+// ---------------- DTO ----------------
+
+// URLSigner turns the path of a stored file into a short-lived url
+type URLSigner func(path string) string
+
+func (p *ProfilePhoto) Serialize(sign URLSigner) *SerializableProfilePhoto {
+	if p == nil {
+		return nil
+	}
+	thumbnailPath := p.ThumbnailPath
+	if thumbnailPath == "" {
+		thumbnailPath = p.Path
+	}
+	return &SerializableProfilePhoto{
+		ID:           p.ID,
+		URL:          sign(p.Path),
+		ThumbnailURL: sign(thumbnailPath),
+		CardURL:      sign(p.CardOrFullPath()),
+		Position:     p.Position,
+		IsPrimary:    p.IsPrimary,
+		Width:        p.Width,
+		Height:       p.Height,
+		Status:       p.Status,
+		Reason:       p.ModerationReason,
+	}
+}
+
+type SerializableProfilePhoto struct {
+	ID           uint   `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	CardURL      string `json:"card_url"`
+	Position     int    `json:"position"`
+	IsPrimary    bool   `json:"is_primary"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Status       string `json:"status"`
+	Reason       string `json:"moderation_reason,omitempty"`
+}
+
+// SerializeProfilePhotos keeps the gallery order, it shows every photo and is meant for the owner
+func SerializeProfilePhotos(photos []ProfilePhoto, sign URLSigner) []SerializableProfilePhoto {
+	result := make([]SerializableProfilePhoto, 0, len(photos))
+	for _, photo := range photos {
+		result = append(result, *photo.Serialize(sign))
+	}
+	return result
+}
+
+// SerializeApprovedProfilePhotos keeps the approved photos for other users. When the
+// primary photo is still waiting, the first approved one stands in for it.
+func SerializeApprovedProfilePhotos(photos []ProfilePhoto, sign URLSigner) []SerializableProfilePhoto {
+	result := make([]SerializableProfilePhoto, 0, len(photos))
+	hasPrimary := false
+	for _, photo := range photos {
+		if !photo.IsApproved() {
+			continue
+		}
+		hasPrimary = hasPrimary || photo.IsPrimary
+		result = append(result, *photo.Serialize(sign))
+	}
+	if !hasPrimary && len(result) > 0 {
+		result[0].IsPrimary = true
+	}
+	return result
+}
+
+// ProfilePhotoUpload is a file received for the gallery
+type ProfilePhotoUpload struct {
+	Filename string
+	Content  []byte
+}
+
+// Outcomes of one file of an upload
+const (
+	ProfilePhotoUploadUploaded = "uploaded"
+	ProfilePhotoUploadRejected = "rejected"
+	ProfilePhotoUploadFailed   = "failed"
+)
+
+// ProfilePhotoUploadResult tells what happened to one file of an upload, rejected files
+// need fixing by the user, failed ones can be sent again as they are
+type ProfilePhotoUploadResult struct {
+	Filename string                    `json:"filename"`
+	Status   string                    `json:"status"`
+	Reason   string                    `json:"reason,omitempty"`
+	Photo    *SerializableProfilePhoto `json:"photo,omitempty"`
+}
+
+// SerializablePhotoReview is a photo of the review queue with its owner, for admins
+type SerializablePhotoReview struct {
+	UserID string `json:"user_id"`
+	SerializableProfilePhoto
+}
+
+type ProfilePhotoRejectRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+type ProfilePhotoReorderRequest struct {
+	PhotoIDs []uint `json:"photo_ids" validate:"required,min=1,unique"`
+}
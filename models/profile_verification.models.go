@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// ---------------- DAO ----------------
+
+const (
+	ProfileVerificationStatusChallenged = "challenged"
+	ProfileVerificationStatusPending    = "pending"
+	ProfileVerificationStatusApproved   = "approved"
+	ProfileVerificationStatusRejected   = "rejected"
+
+	// ProfileVerificationReviewerMatcher marks verdicts of the face matcher instead of an admin
+	ProfileVerificationReviewerMatcher = "face_matcher"
+)
+
+// VerificationPoses are the challenges a selfie can be asked for, by key
+var VerificationPoses = map[string]string{
+	"thumbs_up":       "Give a thumbs up with your right hand",
+	"peace_sign":      "Make a peace sign next to your face",
+	"hand_on_head":    "Put your left hand on top of your head",
+	"touch_nose":      "Touch your nose with your index finger",
+	"wave":            "Wave at the camera with your palm open",
+	"three_fingers":   "Hold up three fingers next to your chin",
+	"cover_left_eye":  "Cover your left eye with your hand",
+	"point_at_camera": "Point at the camera",
+}
+
+// ProfileVerification is a selfie challenge of a user. The selfie goes through the
+// photo pipeline, then waits for the face matcher or an admin.
+type ProfileVerification struct {
+	ID                 uint       `gorm:"primaryKey;column:id"`
+	UserID             string     `gorm:"column:user_id"`
+	Pose               string     `gorm:"column:pose"`
+	Status             string     `gorm:"column:status;default:challenged"`
+	SelfieFileID       string     `gorm:"column:selfie_file_id"`
+	SelfiePath         string     `gorm:"column:selfie_path"`
+	Reason             string     `gorm:"column:reason"`
+	ReviewedBy         string     `gorm:"column:reviewed_by"`
+	ChallengeExpiresAt time.Time  `gorm:"column:challenge_expires_at"`
+	SubmittedAt        *time.Time `gorm:"column:submitted_at"`
+	ReviewedAt         *time.Time `gorm:"column:reviewed_at"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// TableName gives table name of model
+func (v *ProfileVerification) TableName() string {
+	return "profile_verifications"
+}
+
+// ---------------- DTO ----------------
+
+func (v *ProfileVerification) Serialize(sign URLSigner) *SerializableProfileVerification {
+	if v == nil {
+		return nil
+	}
+	return &SerializableProfileVerification{
+		ID:                 v.ID,
+		UserID:             v.UserID,
+		Pose:               v.Pose,
+		PoseInstruction:    VerificationPoses[v.Pose],
+		Status:             v.Status,
+		SelfieURL:          sign(v.SelfiePath),
+		Reason:             v.Reason,
+		ChallengeExpiresAt: v.ChallengeExpiresAt,
+		SubmittedAt:        v.SubmittedAt,
+		ReviewedAt:         v.ReviewedAt,
+	}
+}
+
+type SerializableProfileVerification struct {
+	ID                 uint       `json:"id"`
+	UserID             string     `json:"user_id"`
+	Pose               string     `json:"pose"`
+	PoseInstruction    string     `json:"pose_instruction"`
+	Status             string     `json:"status"`
+	SelfieURL          string     `json:"selfie_url,omitempty"`
+	Reason             string     `json:"reason,omitempty"`
+	ChallengeExpiresAt time.Time  `json:"challenge_expires_at"`
+	SubmittedAt        *time.Time `json:"submitted_at"`
+	ReviewedAt         *time.Time `json:"reviewed_at"`
+}
+
+type ProfileVerificationRejectRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// ============= DTO ================
+
+const (
+	RecoEvaluationSourceHistorical = "historical" // profiles, answers and swipes from the database
+	RecoEvaluationSourceSynthetic  = "synthetic"  // a generated population, see RecoEvaluationOptions.Users
+
+	RecoEvaluationScorerAnswers = "answers" // the match percentage the recommendations use
+	RecoEvaluationScorerRandom  = "random"  // a seeded random score, as a baseline
+)
+
+// RecoEvaluationOptions picks what an offline evaluation replays and how it scores and ranks
+type RecoEvaluationOptions struct {
+	Source string
+	Users  int
+	Seed   int64
+	Scorer string
+	Ranker string
+	K      int
+}
+
+// RecoEvaluationReport sums up how well a scorer and ranker put the eventual mutual matches
+// of each viewer at the top of their feed
+type RecoEvaluationReport struct {
+	Source string `json:"source"`
+	Scorer string `json:"scorer"`
+	Ranker string `json:"ranker"`
+	Seed   int64  `json:"seed"`
+	K      int    `json:"k"`
+	Users  int    `json:"users"`
+	// viewers with at least one mutual match, the only ones precision and recall are taken on
+	Viewers int `json:"viewers"`
+	// mean share of the top k that are mutual matches
+	PrecisionAtK float64 `json:"precision_at_k"`
+	// mean share of the mutual matches found in the top k
+	RecallAtK float64 `json:"recall_at_k"`
+	// share of the candidates that reached at least one top k
+	Coverage          float64           `json:"coverage"`
+	ScoreDistribution ScoreDistribution `json:"score_distribution"`
+	GeneratedAt       time.Time         `json:"generated_at"`
+}
+
+// ScoreDistribution describes the scores given to every viewer and candidate pair
+type ScoreDistribution struct {
+	Scored int `json:"scored"`
+	// pairs without a common answer, counted as 0 in the buckets
+	Unscorable int           `json:"unscorable"`
+	Mean       float64       `json:"mean"`
+	P50        float64       `json:"p50"`
+	P90        float64       `json:"p90"`
+	Buckets    []ScoreBucket `json:"buckets"`
+}
+
+// ScoreBucket counts the scores in [From, To), the last bucket includes To
+type ScoreBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
@@ -0,0 +1,173 @@
+package models
+
+// ---------------- DAO ----------------
+
+const (
+	MaxProfileInterests   = 10
+	MaxProfileLanguages   = 5
+	MaxProfilePrompts     = 3
+	MaxPromptAnswerLength = 300
+	MinProfileHeightCm    = 100
+	MaxProfileHeightCm    = 250
+)
+
+// Interest is an entry of the curated interests taxonomy
+type Interest struct {
+	ID       uint   `gorm:"primaryKey;column:id"`
+	Slug     string `gorm:"column:slug"`
+	Name     string `gorm:"column:name"`
+	Category string `gorm:"column:category"`
+}
+
+// TableName gives table name of model
+func (i *Interest) TableName() string {
+	return "interests"
+}
+
+// Language is a spoken language, by ISO 639-1 code
+type Language struct {
+	ID         uint   `gorm:"primaryKey;column:id"`
+	Code       string `gorm:"column:code"`
+	Name       string `gorm:"column:name"`
+	NativeName string `gorm:"column:native_name"`
+}
+
+// TableName gives table name of model
+func (l *Language) TableName() string {
+	return "languages"
+}
+
+// PromptQuestion is a question users can answer on their profile
+type PromptQuestion struct {
+	ID       uint   `gorm:"primaryKey;column:id"`
+	Slug     string `gorm:"column:slug"`
+	Question string `gorm:"column:question"`
+}
+
+// TableName gives table name of model
+func (q *PromptQuestion) TableName() string {
+	return "prompt_questions"
+}
+
+type ProfileInterest struct {
+	ProfileID  string   `gorm:"primaryKey;column:profile_id"`
+	InterestID uint     `gorm:"primaryKey;column:interest_id"`
+	Interest   Interest `gorm:"foreignKey:InterestID"`
+}
+
+// TableName gives table name of model
+func (p *ProfileInterest) TableName() string {
+	return "profile_interests"
+}
+
+type ProfileLanguage struct {
+	ProfileID  string   `gorm:"primaryKey;column:profile_id"`
+	LanguageID uint     `gorm:"primaryKey;column:language_id"`
+	Language   Language `gorm:"foreignKey:LanguageID"`
+}
+
+// TableName gives table name of model
+func (p *ProfileLanguage) TableName() string {
+	return "profile_languages"
+}
+
+// ProfilePrompt is the answer of a user to a prompt question
+type ProfilePrompt struct {
+	ID         uint           `gorm:"primaryKey;column:id"`
+	ProfileID  string         `gorm:"column:profile_id"`
+	QuestionID uint           `gorm:"column:question_id"`
+	Question   PromptQuestion `gorm:"foreignKey:QuestionID"`
+	Answer     string         `gorm:"column:answer"`
+	Position   int            `gorm:"column:position"`
+}
+
+// TableName gives table name of model
+func (p *ProfilePrompt) TableName() string {
+	return "profile_prompts"
+}
+
+// ProfileDetails replaces the taxonomy entries of a profile, a nil list is left as it is
+type ProfileDetails struct {
+	InterestIDs []uint
+	LanguageIDs []uint
+	Prompts     []ProfilePrompt
+}
+
+// ---------------- DTO ----------------
+
+type SerializableInterest struct {
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type SerializableLanguage struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	NativeName string `json:"native_name"`
+}
+
+type SerializablePromptQuestion struct {
+	Slug     string `json:"slug"`
+	Question string `json:"question"`
+}
+
+type SerializableProfilePrompt struct {
+	QuestionSlug string `json:"question_slug"`
+	Question     string `json:"question"`
+	Answer       string `json:"answer"`
+}
+
+// SerializableTaxonomy lists everything a profile can pick from
+type SerializableTaxonomy struct {
+	Interests       []SerializableInterest       `json:"interests"`
+	Languages       []SerializableLanguage       `json:"languages"`
+	PromptQuestions []SerializablePromptQuestion `json:"prompt_questions"`
+	Limits          map[string]int               `json:"limits"`
+}
+
+func (i *Interest) Serialize() SerializableInterest {
+	return SerializableInterest{Slug: i.Slug, Name: i.Name, Category: i.Category}
+}
+
+func (l *Language) Serialize() SerializableLanguage {
+	return SerializableLanguage{Code: l.Code, Name: l.Name, NativeName: l.NativeName}
+}
+
+func (q *PromptQuestion) Serialize() SerializablePromptQuestion {
+	return SerializablePromptQuestion{Slug: q.Slug, Question: q.Question}
+}
+
+// SerializeProfileInterests never returns nil, so empty lists serialise as []
+func SerializeProfileInterests(interests []ProfileInterest) []SerializableInterest {
+	result := make([]SerializableInterest, 0, len(interests))
+	for _, interest := range interests {
+		result = append(result, interest.Interest.Serialize())
+	}
+	return result
+}
+
+func SerializeProfileLanguages(languages []ProfileLanguage) []SerializableLanguage {
+	result := make([]SerializableLanguage, 0, len(languages))
+	for _, language := range languages {
+		result = append(result, language.Language.Serialize())
+	}
+	return result
+}
+
+func SerializeProfilePrompts(prompts []ProfilePrompt) []SerializableProfilePrompt {
+	result := make([]SerializableProfilePrompt, 0, len(prompts))
+	for _, prompt := range prompts {
+		result = append(result, SerializableProfilePrompt{
+			QuestionSlug: prompt.Question.Slug,
+			Question:     prompt.Question.Question,
+			Answer:       prompt.Answer,
+		})
+	}
+	return result
+}
+
+type ProfilePromptRequest struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
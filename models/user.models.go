@@ -10,14 +10,32 @@ import (
 // User model
 type User struct {
 	gorm.Model
-	ID                 string    `gorm:"primaryKey;column:id"`
-	Email              string    `gorm:"column:email"`
-	Password           string    `gorm:"column:password"`
-	VerificationCode   string    `gorm:"column:verification_code"`
-	VerificationStatus int       `gorm:"column:verification_status"`
-	VerificationTime   time.Time `gorm:"column:verification_time"`
+	ID                   string     `gorm:"primaryKey;column:id"`
+	Email                string     `gorm:"column:email"`
+	PhoneNumber          *string    `gorm:"column:phone_number"`
+	PhoneVerifiedAt      *time.Time `gorm:"column:phone_verified_at"`
+	Password             string     `gorm:"column:password"`
+	Role                 string     `gorm:"column:role;default:user"`
+	Locale               string     `gorm:"column:locale;default:en"`
+	VerificationCode     string     `gorm:"column:verification_code"`
+	VerificationStatus   int        `gorm:"column:verification_status"`
+	VerificationAttempts int        `gorm:"column:verification_attempts"`
+	VerificationTime     time.Time  `gorm:"column:verification_time"`
+	DeletionScheduledAt  *time.Time `gorm:"column:deletion_scheduled_at"`
+	SessionVersion       int        `gorm:"column:session_version"`
 }
 
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+const (
+	LocaleEnglish    = "en"
+	LocaleVietnamese = "vi"
+	DefaultLocale    = LocaleEnglish
+)
+
 // TableName gives table name of model
 func (u *User) TableName() string {
 	return "users"
@@ -51,10 +69,11 @@ type SerializableNestedUser struct {
 }
 
 type OneUserFilter struct {
-	ID     string               `form:"id"`
-	Email  string               `form:"email"`
-	Joins  *ArrStringFilterType `form:"joins"`
-	Fields *ArrStringFilterType `form:"fields"`
+	ID          string               `form:"id"`
+	Email       string               `form:"email"`
+	PhoneNumber string               `form:"phone_number"`
+	Joins       *ArrStringFilterType `form:"joins"`
+	Fields      *ArrStringFilterType `form:"fields"`
 }
 
 type UserUpdateRequest struct {
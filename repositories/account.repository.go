@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+)
+
+type IAccountRepository interface {
+	ScheduleDeletion(userID string, eraseAt time.Time) error
+	CancelDeletion(userID string) (bool, error)
+	FindProfile(userID string) (*models.Profile, error)
+	FindMatches(userID string) ([]models.Match, error)
+	FindPhotos(userID string) ([]models.ProfilePhoto, error)
+	FindVerifications(userID string) ([]models.ProfileVerification, error)
+	FindPreference(userID string) (*models.UserPreference, error)
+	Erase(userID, email string, jobTypes []string) error
+}
+
+// AccountRepository works on everything stored about a user at once
+type AccountRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewAccountRepository creates a new account repository
+func NewAccountRepository(db *core.Database, logger *core.Logger) IAccountRepository {
+	return &AccountRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *AccountRepository) ScheduleDeletion(userID string, eraseAt time.Time) error {
+	return r.Database.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("deletion_scheduled_at", eraseAt).Error
+}
+
+// CancelDeletion reports false when no deletion was scheduled
+func (r *AccountRepository) CancelDeletion(userID string) (bool, error) {
+	tx := r.Database.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).
+		Update("deletion_scheduled_at", nil)
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// FindProfile returns the profile of the user, soft-deleted or not
+func (r *AccountRepository) FindProfile(userID string) (*models.Profile, error) {
+	var profile models.Profile
+	err := r.Database.Unscoped().
+		Scopes(preloadProfileDetails).
+		First(&profile, "id = ?", userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// FindMatches returns the matches the user is part of, on either side
+func (r *AccountRepository) FindMatches(userID string) ([]models.Match, error) {
+	var matches []models.Match
+	err := r.Database.Unscoped().
+		Where("matcher_id = ? OR matchee_id = ?", userID, userID).
+		Find(&matches).Error
+	return matches, err
+}
+
+func (r *AccountRepository) FindPhotos(userID string) ([]models.ProfilePhoto, error) {
+	var photos []models.ProfilePhoto
+	err := r.Database.Where("user_id = ?", userID).Find(&photos).Error
+	return photos, err
+}
+
+func (r *AccountRepository) FindVerifications(userID string) ([]models.ProfileVerification, error) {
+	var verifications []models.ProfileVerification
+	err := r.Database.Where("user_id = ?", userID).Find(&verifications).Error
+	return verifications, err
+}
+
+func (r *AccountRepository) FindPreference(userID string) (*models.UserPreference, error) {
+	var preference models.UserPreference
+	if err := r.Database.First(&preference, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// Erase hard-deletes the user and every row that refers to them in one transaction, the
+// user's own queued jobs of jobTypes included
+func (r *AccountRepository) Erase(userID, email string, jobTypes []string) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		steps := []struct {
+			model interface{}
+			query string
+			args  []interface{}
+		}{
+			{&models.Answer{}, "user_id = ?", []interface{}{userID}},
+			{&models.Match{}, "matcher_id = ? OR matchee_id = ?", []interface{}{userID, userID}},
+			{&models.RecommendationBin{}, "user_id = ? OR recommended_user_id = ?", []interface{}{userID, userID}},
+			{&models.Identity{}, "user_id = ?", []interface{}{userID}},
+			{&models.MFARecoveryCode{}, "user_id = ?", []interface{}{userID}},
+			{&models.UserTOTP{}, "user_id = ?", []interface{}{userID}},
+			{&models.PhoneVerification{}, "user_id = ?", []interface{}{userID}},
+			{&models.PhoneCodeSend{}, "user_id = ?", []interface{}{userID}},
+			{&models.ProfilePhoto{}, "user_id = ?", []interface{}{userID}},
+			{&models.ProfileVerification{}, "user_id = ?", []interface{}{userID}},
+			{&models.ProfileInterest{}, "profile_id = ?", []interface{}{userID}},
+			{&models.ProfileLanguage{}, "profile_id = ?", []interface{}{userID}},
+			{&models.ProfilePrompt{}, "profile_id = ?", []interface{}{userID}},
+			{&models.UserPreference{}, "user_id = ?", []interface{}{userID}},
+			{&models.ExperimentEvent{}, "user_id = ? OR subject_id = ?", []interface{}{userID, userID}},
+			{&models.Device{}, "user_id = ?", []interface{}{userID}},
+			{&models.NotificationPreference{}, "user_id = ?", []interface{}{userID}},
+			{&models.Notification{}, "user_id = ? OR dedup_key IN ?", []interface{}{userID, []string{"match:" + userID, "like:" + userID}}},
+			{&models.LoginAttempt{}, "scope = ? AND subject = ?", []interface{}{models.LoginAttemptScopeAccount, email}},
+			{&models.Job{}, "type IN ? AND JSON_EXTRACT(payload, '$.user_id') = ?", []interface{}{jobTypes, userID}},
+			{&models.Profile{}, "id = ?", []interface{}{userID}},
+			{&models.User{}, "id = ?", []interface{}{userID}},
+		}
+
+		for _, step := range steps {
+			if err := tx.Unscoped().Where(step.query, step.args...).Delete(step.model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
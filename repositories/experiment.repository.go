@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+// experimentEventBatchSize caps the rows of one insert
+const experimentEventBatchSize = 200
+
+type IExperimentRepository interface {
+	CreateEvents(events []models.ExperimentEvent) error
+	CountEvents(experiment string) ([]models.ExperimentEventCount, error)
+}
+
+type ExperimentRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+func NewExperimentRepository(db *core.Database, logger *core.Logger) IExperimentRepository {
+	return &ExperimentRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *ExperimentRepository) CreateEvents(events []models.ExperimentEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.Database.CreateInBatches(&events, experimentEventBatchSize).Error
+}
+
+// CountEvents counts the events and the distinct users of each variant and kind of event
+func (r *ExperimentRepository) CountEvents(experiment string) ([]models.ExperimentEventCount, error) {
+	var counts []models.ExperimentEventCount
+	err := r.Database.Model(&models.ExperimentEvent{}).
+		Select("variant, event, COUNT(*) AS events, COUNT(DISTINCT user_id) AS users").
+		Where("experiment = ?", experiment).
+		Group("variant, event").
+		Order("variant").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+type IIdentityRepository interface {
+	Create(models.Identity) error
+	First(provider, subject string) (*models.Identity, error)
+	FindListByUserId(userID string) ([]models.Identity, error)
+	Delete(userID, provider string) (bool, error)
+}
+
+// IdentityRepository database structure
+type IdentityRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *core.Database, logger *core.Logger) IIdentityRepository {
+	return &IdentityRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *IdentityRepository) Create(identity models.Identity) error {
+	return r.Database.Model(&models.Identity{}).Create(&identity).Error
+}
+
+func (r *IdentityRepository) First(provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	db := r.Database.Model(&models.Identity{})
+	if err := db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) FindListByUserId(userID string) ([]models.Identity, error) {
+	var identities []models.Identity
+	db := r.Database.Model(&models.Identity{})
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *IdentityRepository) Delete(userID, provider string) (bool, error) {
+	tx := r.Database.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.Identity{})
+	return tx.RowsAffected > 0, tx.Error
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+type IJobRepository interface {
+	Create(models.Job) (*models.Job, error)
+	First(id uint) (*models.Job, error)
+	List(models.JobFilter) ([]models.Job, error)
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.Job, error)
+	MarkDone(id uint) error
+	MarkFailed(id uint, lastError string, runAt time.Time) error
+	MarkDead(id uint, lastError string) error
+	Retry(id uint, runAt time.Time) (bool, error)
+	DeleteDone(before time.Time, limit int) (int64, error)
+}
+
+// JobRepository database structure
+type JobRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *core.Database, logger *core.Logger) IJobRepository {
+	return &JobRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *JobRepository) Create(job models.Job) (*models.Job, error) {
+	db := r.Database.Model(&models.Job{})
+	if err := db.Create(&job).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *JobRepository) First(id uint) (*models.Job, error) {
+	var job models.Job
+	if err := r.Database.Model(&models.Job{}).First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *JobRepository) List(filter models.JobFilter) ([]models.Job, error) {
+	var jobs []models.Job
+	db := r.Database.Model(&models.Job{})
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.Limit > 0 {
+		db = db.Limit(filter.Limit)
+	}
+	if err := db.Order("id DESC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimDue leases up to limit due jobs to the caller. Jobs whose lease expired (the worker
+// crashed while running them) are due again. A job is only handed to one caller.
+func (r *JobRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.Job, error) {
+	var candidates []models.Job
+	err := r.Database.Model(&models.Job{}).
+		Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until <= ?)",
+			models.JobStatusPending, now, models.JobStatusRunning, now).
+		Order("run_at").
+		Limit(limit).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	lockedUntil := now.Add(lease)
+	var claimed []models.Job
+	for _, job := range candidates {
+		tx := r.Database.Model(&models.Job{}).
+			Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+			Updates(map[string]interface{}{
+				"status":       models.JobStatusRunning,
+				"locked_until": lockedUntil,
+				"attempts":     job.Attempts + 1,
+			})
+		if tx.Error != nil {
+			return claimed, tx.Error
+		}
+		if tx.RowsAffected == 0 {
+			// another worker got it first
+			continue
+		}
+		job.Status = models.JobStatusRunning
+		job.LockedUntil = &lockedUntil
+		job.Attempts++
+		claimed = append(claimed, job)
+	}
+
+	return claimed, nil
+}
+
+func (r *JobRepository) MarkDone(id uint) error {
+	return r.Database.Model(&models.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusDone,
+			"locked_until": nil,
+			"last_error":   nil,
+		}).Error
+}
+
+func (r *JobRepository) MarkFailed(id uint, lastError string, runAt time.Time) error {
+	return r.Database.Model(&models.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusPending,
+			"locked_until": nil,
+			"last_error":   lastError,
+			"run_at":       runAt,
+		}).Error
+}
+
+func (r *JobRepository) MarkDead(id uint, lastError string) error {
+	return r.Database.Model(&models.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusDead,
+			"locked_until": nil,
+			"last_error":   lastError,
+		}).Error
+}
+
+// Retry puts a dead job back in the queue with a fresh attempt budget
+func (r *JobRepository) Retry(id uint, runAt time.Time) (bool, error) {
+	tx := r.Database.Model(&models.Job{}).
+		Where("id = ? AND status = ?", id, models.JobStatusDead).
+		Updates(map[string]interface{}{
+			"status":   models.JobStatusPending,
+			"attempts": 0,
+			"run_at":   runAt,
+		})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// DeleteDone deletes up to limit jobs that were done before the given time
+func (r *JobRepository) DeleteDone(before time.Time, limit int) (int64, error) {
+	tx := r.Database.
+		Where("status = ? AND updated_at < ?", models.JobStatusDone, before).
+		Limit(limit).
+		Delete(&models.Job{})
+	return tx.RowsAffected, tx.Error
+}
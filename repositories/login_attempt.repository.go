@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ILoginAttemptRepository interface {
+	First(scope, subject string) (*models.LoginAttempt, error)
+	RecordFailure(scope, subject string, failedAt, quietSince time.Time) (*models.LoginAttempt, error)
+	Lock(scope, subject string, lockedUntil time.Time) error
+	Delete(scope, subject string) error
+}
+
+// LoginAttemptRepository database structure
+type LoginAttemptRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewLoginAttemptRepository creates a new login attempt repository
+func NewLoginAttemptRepository(db *core.Database, logger *core.Logger) ILoginAttemptRepository {
+	return &LoginAttemptRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *LoginAttemptRepository) First(scope, subject string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	db := r.Database.Model(&models.LoginAttempt{})
+	if err := db.Where("scope = ? AND subject = ?", scope, subject).First(&attempt).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts a failed attempt in one statement, so that parallel guesses can't
+// overwrite each other's count, and returns the attempt as counted. The count starts over when
+// the subject has been quiet, neither failing nor locked, since quietSince.
+func (r *LoginAttemptRepository) RecordFailure(scope, subject string, failedAt, quietSince time.Time) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.Database.Transaction(func(tx *gorm.DB) error {
+		quiet := "GREATEST(last_failed_at, COALESCE(locked_until, last_failed_at)) < ?"
+		// MySQL assigns left to right, last_failed_at goes last so the others see the old one
+		err := tx.Clauses(clause.OnConflict{
+			DoUpdates: []clause.Assignment{
+				{Column: clause.Column{Name: "failed_attempts"}, Value: gorm.Expr("IF("+quiet+", 1, failed_attempts + 1)", quietSince)},
+				{Column: clause.Column{Name: "locked_until"}, Value: gorm.Expr("IF("+quiet+", NULL, locked_until)", quietSince)},
+				{Column: clause.Column{Name: "last_failed_at"}, Value: failedAt},
+				{Column: clause.Column{Name: "updated_at"}, Value: failedAt},
+			},
+		}).Create(&models.LoginAttempt{
+			Scope:          scope,
+			Subject:        subject,
+			FailedAttempts: 1,
+			LastFailedAt:   failedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND subject = ?", scope, subject).
+			First(&attempt).Error
+	})
+	if err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Lock locks the subject out until lockedUntil. A lockout is only ever extended, a shorter one
+// computed by a parallel failure doesn't cut it.
+func (r *LoginAttemptRepository) Lock(scope, subject string, lockedUntil time.Time) error {
+	return r.Database.Model(&models.LoginAttempt{}).
+		Where("scope = ? AND subject = ? AND (locked_until IS NULL OR locked_until < ?)", scope, subject, lockedUntil).
+		Update("locked_until", lockedUntil).Error
+}
+
+func (r *LoginAttemptRepository) Delete(scope, subject string) error {
+	db := r.Database.Model(&models.LoginAttempt{})
+	return db.Where("scope = ? AND subject = ?", scope, subject).Delete(&models.LoginAttempt{}).Error
+}
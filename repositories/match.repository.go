@@ -10,6 +10,8 @@ type IMatchRepository interface {
 	First(string, string) (*models.Match, error)
 	Create(models.Match) error
 	Update(models.Match) error
+	HasLiked(likerID, likedID string) (bool, error)
+	CountReceivedSwipes(userIDs []string) (map[string]models.SwipeCounts, error)
 }
 
 type MatchRepository struct {
@@ -63,3 +65,40 @@ func (r *MatchRepository) Update(match models.Match) error {
 	return nil
 
 }
+
+// HasLiked tells whether liker liked liked, on their own or as part of a match
+func (r *MatchRepository) HasLiked(likerID, likedID string) (bool, error) {
+	var count int64
+	err := r.Database.Model(&models.Match{}).
+		Where("(matcher_id = ? AND matchee_id = ? AND match_status IN ?) OR "+
+			"(matcher_id = ? AND matchee_id = ? AND match_status = ?)",
+			likerID, likedID, []int{models.MatchStatusWaiting, models.MatchStatusMatched},
+			likedID, likerID, models.MatchStatusMatched).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CountReceivedSwipes counts the likes and passes each of the users received
+func (r *MatchRepository) CountReceivedSwipes(userIDs []string) (map[string]models.SwipeCounts, error) {
+	counts := make(map[string]models.SwipeCounts, len(userIDs))
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []models.SwipeCounts
+	err := r.Database.Model(&models.Match{}).
+		Select("matchee_id AS user_id, "+
+			"SUM(CASE WHEN match_status IN ? THEN 1 ELSE 0 END) AS likes, "+
+			"SUM(CASE WHEN match_status = ? THEN 1 ELSE 0 END) AS passes",
+			[]int{models.MatchStatusWaiting, models.MatchStatusMatched}, models.MatchStatusPassed).
+		Where("matchee_id IN ?", userIDs).
+		Group("matchee_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.UserID] = row
+	}
+	return counts, nil
+}
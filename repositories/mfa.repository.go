@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IMFARepository interface {
+	FirstTOTP(userID string) (*models.UserTOTP, error)
+	SaveTOTP(models.UserTOTP) error
+	ConfirmTOTP(userID string, step int64, confirmedAt time.Time, recoveryCodeHashes []string) error
+	UseTOTPStep(userID string, step int64) (bool, error)
+	UseRecoveryCode(userID, codeHash string, usedAt time.Time) (bool, error)
+	CountUnusedRecoveryCodes(userID string) (int64, error)
+	Delete(userID string) error
+}
+
+// MFARepository database structure
+type MFARepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewMFARepository creates a new mfa repository
+func NewMFARepository(db *core.Database, logger *core.Logger) IMFARepository {
+	return &MFARepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *MFARepository) FirstTOTP(userID string) (*models.UserTOTP, error) {
+	var totp models.UserTOTP
+	if err := r.Database.Where("user_id = ?", userID).First(&totp).Error; err != nil {
+		return nil, err
+	}
+	return &totp, nil
+}
+
+// SaveTOTP inserts the secret or overwrites the user's unconfirmed one
+func (r *MFARepository) SaveTOTP(totp models.UserTOTP) error {
+	if err := r.Database.Clauses(clause.OnConflict{UpdateAll: true}).Create(&totp).Error; err != nil {
+		r.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ConfirmTOTP enables the secret and replaces the user's recovery codes in one transaction
+func (r *MFARepository) ConfirmTOTP(userID string, step int64, confirmedAt time.Time, recoveryCodeHashes []string) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserTOTP{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]interface{}{
+				"confirmed_at":   confirmedAt,
+				"last_used_step": step,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.MFARecoveryCode, 0, len(recoveryCodeHashes))
+		for _, hash := range recoveryCodeHashes {
+			codes = append(codes, models.MFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseTOTPStep records the step of an accepted code. It reports false when the step, or a later
+// one, was already used so that a code cannot be replayed.
+func (r *MFARepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	tx := r.Database.Model(&models.UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks the code as used, it reports false for unknown or used codes
+func (r *MFARepository) UseRecoveryCode(userID, codeHash string, usedAt time.Time) (bool, error) {
+	tx := r.Database.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", usedAt)
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected == 1, nil
+}
+
+func (r *MFARepository) CountUnusedRecoveryCodes(userID string) (int64, error) {
+	var count int64
+	err := r.Database.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// Delete removes the secret and the recovery codes of the user
+func (r *MFARepository) Delete(userID string) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error
+	})
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm/clause"
+)
+
+type INotificationRepository interface {
+	SaveDevice(models.Device) error
+	DeleteDevice(userID, token string) (bool, error)
+	DeleteDeviceByToken(token string) error
+	FindDevices(userID string) ([]models.Device, error)
+	FirstPreference(userID string) (*models.NotificationPreference, error)
+	SavePreference(models.NotificationPreference) (*models.NotificationPreference, error)
+	HasRecent(userID, dedupKey string, since time.Time) (bool, error)
+	Create(*models.Notification) error
+	First(id uint) (*models.Notification, error)
+	MarkSent(id uint, at time.Time) error
+}
+
+// NotificationRepository stores devices, notification preferences and the notifications sent
+type NotificationRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *core.Database, logger *core.Logger) INotificationRepository {
+	return &NotificationRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+// SaveDevice registers the token, moving it to the user when another user had it
+func (r *NotificationRepository) SaveDevice(device models.Device) error {
+	return r.Database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "updated_at"}),
+	}).Create(&device).Error
+}
+
+// DeleteDevice reports false when the user has no such token
+func (r *NotificationRepository) DeleteDevice(userID, token string) (bool, error) {
+	tx := r.Database.Where("user_id = ? AND token = ?", userID, token).Delete(&models.Device{})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+func (r *NotificationRepository) DeleteDeviceByToken(token string) error {
+	return r.Database.Where("token = ?", token).Delete(&models.Device{}).Error
+}
+
+func (r *NotificationRepository) FindDevices(userID string) ([]models.Device, error) {
+	var devices []models.Device
+	err := r.Database.Where("user_id = ?", userID).Order("id").Find(&devices).Error
+	return devices, err
+}
+
+func (r *NotificationRepository) FirstPreference(userID string) (*models.NotificationPreference, error) {
+	var preference models.NotificationPreference
+	if err := r.Database.First(&preference, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// SavePreference creates the notification preferences of the user or overwrites them
+func (r *NotificationRepository) SavePreference(preference models.NotificationPreference) (*models.NotificationPreference, error) {
+	if err := r.Database.Clauses(clause.OnConflict{UpdateAll: true}).Create(&preference).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// HasRecent tells whether a notification with the dedup key was queued for the user since
+func (r *NotificationRepository) HasRecent(userID, dedupKey string, since time.Time) (bool, error) {
+	var count int64
+	err := r.Database.Model(&models.Notification{}).
+		Where("user_id = ? AND dedup_key = ? AND created_at >= ?", userID, dedupKey, since).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *NotificationRepository) Create(notification *models.Notification) error {
+	return r.Database.Create(notification).Error
+}
+
+func (r *NotificationRepository) First(id uint) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.Database.First(&notification, id).Error; err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *NotificationRepository) MarkSent(id uint, at time.Time) error {
+	return r.Database.Model(&models.Notification{}).Where("id = ?", id).Update("sent_at", at).Error
+}
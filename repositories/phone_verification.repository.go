@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IPhoneVerificationRepository interface {
+	First(phoneNumber string) (*models.PhoneVerification, error)
+	FirstBySendID(sendID string) (*models.PhoneVerification, error)
+	SaveSend(models.PhoneVerification) error
+	FindSendTimesByUser(userID string, since time.Time) ([]time.Time, error)
+	SetCode(sendID, codeHash string, issuedAt time.Time) (bool, error)
+	ConsumeAttempt(phoneNumber string, maxAttempts int) (bool, error)
+	ClearCode(phoneNumber string) error
+}
+
+// PhoneVerificationRepository database structure
+type PhoneVerificationRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewPhoneVerificationRepository creates a new phone verification repository
+func NewPhoneVerificationRepository(db *core.Database, logger *core.Logger) IPhoneVerificationRepository {
+	return &PhoneVerificationRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *PhoneVerificationRepository) First(phoneNumber string) (*models.PhoneVerification, error) {
+	var verification models.PhoneVerification
+	if err := r.Database.Where("phone_number = ?", phoneNumber).First(&verification).Error; err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+func (r *PhoneVerificationRepository) FirstBySendID(sendID string) (*models.PhoneVerification, error) {
+	var verification models.PhoneVerification
+	if err := r.Database.Where("send_id = ?", sendID).First(&verification).Error; err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// SaveSend inserts the verification or overwrites the existing one of the number, and logs
+// the send for the user in the same transaction
+func (r *PhoneVerificationRepository) SaveSend(verification models.PhoneVerification) error {
+	err := r.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&verification).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PhoneCodeSend{
+			UserID:      verification.UserID,
+			PhoneNumber: verification.PhoneNumber,
+			SentAt:      verification.SentAt,
+		}).Error
+	})
+	if err != nil {
+		r.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+// FindSendTimesByUser lists when codes were sent for the user since the given time, oldest
+// first
+func (r *PhoneVerificationRepository) FindSendTimesByUser(userID string, since time.Time) ([]time.Time, error) {
+	var sentAt []time.Time
+	err := r.Database.Model(&models.PhoneCodeSend{}).
+		Where("user_id = ? AND sent_at > ?", userID, since).
+		Order("sent_at").
+		Pluck("sent_at", &sentAt).Error
+	return sentAt, err
+}
+
+// SetCode stores the code of the send and restarts its attempts and expiry. It reports false
+// when a newer send replaced this one.
+func (r *PhoneVerificationRepository) SetCode(sendID, codeHash string, issuedAt time.Time) (bool, error) {
+	tx := r.Database.Model(&models.PhoneVerification{}).
+		Where("send_id = ?", sendID).
+		Updates(map[string]interface{}{
+			"code_hash": codeHash,
+			"attempts":  0,
+			"sent_at":   issuedAt,
+		})
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// ConsumeAttempt uses up one attempt of the pending code. It reports false when the
+// code has no attempt left.
+func (r *PhoneVerificationRepository) ConsumeAttempt(phoneNumber string, maxAttempts int) (bool, error) {
+	tx := r.Database.Model(&models.PhoneVerification{}).
+		Where("phone_number = ? AND attempts < ?", phoneNumber, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// ClearCode invalidates the pending code but keeps the send counters
+func (r *PhoneVerificationRepository) ClearCode(phoneNumber string) error {
+	return r.Database.Model(&models.PhoneVerification{}).
+		Where("phone_number = ?", phoneNumber).
+		Update("code_hash", "").Error
+}
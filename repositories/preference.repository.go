@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm/clause"
+)
+
+type IPreferenceRepository interface {
+	First(userID string) (*models.UserPreference, error)
+	Save(models.UserPreference) (*models.UserPreference, error)
+}
+
+// PreferenceRepository database structure
+type PreferenceRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewPreferenceRepository creates a new preference repository
+func NewPreferenceRepository(db *core.Database, logger *core.Logger) IPreferenceRepository {
+	return &PreferenceRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *PreferenceRepository) First(userID string) (*models.UserPreference, error) {
+	var preference models.UserPreference
+	if err := r.Database.First(&preference, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// Save creates the preferences of the user or overwrites them
+func (r *PreferenceRepository) Save(preference models.UserPreference) (*models.UserPreference, error) {
+	if err := r.Database.Clauses(clause.OnConflict{UpdateAll: true}).Create(&preference).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
@@ -15,8 +15,12 @@ type IProfileRepository interface {
 	GetProfileById(string) (*models.Profile, error)
 	GetListProfile(models.ProfileFilter) ([]models.Profile, error)
 	UpdateProfileById(string, models.Profile) (*models.Profile, error)
-	UpdateProfileImageById(string, []int) (*models.Profile, error)
 	DeleteProfileById(string) error
+	ReplaceProfileDetails(string, models.ProfileDetails) error
+	PatchProfileById(string, map[string]interface{}, models.ProfileDetails) error
+	FindListWithoutLocation(afterID string, limit int) ([]models.Profile, error)
+	SetLocation(id, location string) error
+	SetLastActive(ids []string, at time.Time) error
 }
 
 type ProfileRepository struct {
@@ -72,7 +76,11 @@ func (r *ProfileRepository) CreateProfile(profile models.Profile) (*models.Profi
 func (r *ProfileRepository) GetProfileById(id string) (*models.Profile, error) {
 	db := r.Database.Model(&models.Profile{})
 	var profile models.Profile
-	err := db.First(&profile, "id = ?", id).Error
+	err := db.
+		Select("profiles.*, users.phone_verified_at").
+		Joins("LEFT JOIN users ON users.id = profiles.id").
+		Scopes(preloadProfileDetails).
+		First(&profile, "profiles.id = ?", id).Error
 	if err != nil {
 		r.logger.Debug("Profile not found")
 		return nil, err
@@ -90,6 +98,16 @@ func (r *ProfileRepository) GetListProfile(filter models.ProfileFilter) ([]model
 	}
 
 	db := r.Database.Model(&models.Profile{})
+	if filter.VerifiedOnly {
+		db = db.Where("verified_at IS NOT NULL")
+	}
+	if filter.MaxInactiveDays > 0 {
+		db = db.Where("last_active_at >= ?", time.Now().AddDate(0, 0, -filter.MaxInactiveDays).UTC())
+	}
+	// paused profiles never show up, incognito ones only for the people they liked
+	db = db.Where("discovery_paused = 0 AND (incognito = 0 OR id IN "+
+		"(SELECT matcher_id FROM matches WHERE matchee_id = ? AND match_status IN ? AND deleted_at IS NULL))",
+		filter.ExcludedUserId, []int{models.MatchStatusWaiting, models.MatchStatusMatched})
 	var profiles, results []models.Profile
 	r.logger.Info(fmt.Sprintf("Filter: %+v", filter))
 	if filter.MinAge > 0 && filter.MinDistance >= 0 && filter.Longitude != 0 && filter.Latitude != 0 {
@@ -101,6 +119,7 @@ func (r *ProfileRepository) GetListProfile(filter models.ProfileFilter) ([]model
 				"AND birthday >= ? AND birthday <= ? "+
 				"AND id NOT IN (SELECT recommended_user_id FROM recommendation_bins WHERE user_id = ?) "+
 				"AND id NOT IN (SELECT matcher_id FROM matches WHERE matchee_id = ? AND match_status = 2) "+
+				"AND id NOT IN (SELECT id FROM users WHERE deletion_scheduled_at IS NOT NULL) "+
 				"AND id <> ?",
 				filter.Gender,
 				minimum, maximum,
@@ -108,9 +127,12 @@ func (r *ProfileRepository) GetListProfile(filter models.ProfileFilter) ([]model
 				filter.ExcludedUserId,
 				filter.ExcludedUserId).
 			Limit(20).
+			Scopes(preloadProfileDetails).
 			Find(&profiles)
+		now := time.Now()
 		for _, profile := range profiles {
-			longitude, latitude, err := utils.CoordinatesStringToPairFloat64(profile.Coordinates)
+			// travellers are found around the city they travel to
+			longitude, latitude, err := utils.CoordinatesStringToPairFloat64(profile.DiscoveryCoordinates(now))
 
 			if err != nil {
 				return nil, err
@@ -124,7 +146,7 @@ func (r *ProfileRepository) GetListProfile(filter models.ProfileFilter) ([]model
 
 	} else {
 		r.logger.Debug("Finding all profiles")
-		db.Find(&profiles)
+		db.Scopes(preloadProfileDetails).Find(&profiles)
 		results = profiles
 	}
 
@@ -149,30 +171,6 @@ func (r *ProfileRepository) UpdateProfileById(id string, profile models.Profile)
 	return &profile, nil
 }
 
-func (r *ProfileRepository) UpdateProfileImageById(id string, slots []int) (*models.Profile, error) {
-	db := r.Database.Model(&models.Profile{})
-
-	var existingProfile models.Profile
-	err := db.First(&existingProfile, "id = ?", id).Error
-	if err != nil {
-		r.logger.Debug(err)
-		return nil, err
-	}
-
-	updateMap := make(map[string]interface{})
-
-	for _, slotId := range slots {
-		updateMap[fmt.Sprintf("ImageId%d", slotId)] = nil
-		updateMap[fmt.Sprintf("ImageUrl%d", slotId)] = nil
-	}
-	if err = db.Model(&existingProfile).Updates(updateMap).Error; err != nil {
-		r.logger.Debug(err)
-		return nil, err
-	}
-
-	return &existingProfile, nil
-}
-
 func (r *ProfileRepository) DeleteProfileById(id string) error {
 	db := r.Database.Model(&models.Profile{})
 	var profile models.Profile
@@ -191,3 +189,112 @@ func (r *ProfileRepository) DeleteProfileById(id string) error {
 	}
 	return nil
 }
+
+// ReplaceProfileDetails swaps the interests, languages and prompts given in details in one
+// transaction, nil lists are left untouched
+func (r *ProfileRepository) ReplaceProfileDetails(id string, details models.ProfileDetails) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		return replaceProfileDetails(tx, id, details)
+	})
+}
+
+// PatchProfileById writes the given columns, zero values included, and the details in one
+// transaction. gorm.ErrRecordNotFound is returned when the profile doesn't exist.
+func (r *ProfileRepository) PatchProfileById(id string, changes map[string]interface{}, details models.ProfileDetails) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		var existingProfile models.Profile
+		if err := tx.Select("id").First(&existingProfile, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			if err := tx.Model(&models.Profile{}).Where("id = ?", id).Updates(changes).Error; err != nil {
+				return err
+			}
+		}
+		return replaceProfileDetails(tx, id, details)
+	})
+}
+
+func photosInGalleryOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// FindListWithoutLocation pages through profiles with coordinates but no location, by id
+func (r *ProfileRepository) FindListWithoutLocation(afterID string, limit int) ([]models.Profile, error) {
+	var profiles []models.Profile
+	err := r.Database.
+		Select("id", "coordinates").
+		Where("location = '' AND coordinates LIKE '%,%' AND id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&profiles).Error
+	return profiles, err
+}
+
+func (r *ProfileRepository) SetLocation(id, location string) error {
+	return r.Database.Model(&models.Profile{}).Where("id = ?", id).Update("location", location).Error
+}
+
+// SetLastActive moves the last activity of the profiles forward to at, updated_at is left alone
+// since activity is not a change of the profile
+func (r *ProfileRepository) SetLastActive(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.Database.Model(&models.Profile{}).
+		Where("id IN ? AND (last_active_at IS NULL OR last_active_at < ?)", ids, at).
+		UpdateColumn("last_active_at", at).Error
+}
+
+func replaceProfileDetails(tx *gorm.DB, id string, details models.ProfileDetails) error {
+	if details.InterestIDs != nil {
+		if err := tx.Where("profile_id = ?", id).Delete(&models.ProfileInterest{}).Error; err != nil {
+			return err
+		}
+		for _, interestID := range details.InterestIDs {
+			if err := tx.Create(&models.ProfileInterest{ProfileID: id, InterestID: interestID}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if details.LanguageIDs != nil {
+		if err := tx.Where("profile_id = ?", id).Delete(&models.ProfileLanguage{}).Error; err != nil {
+			return err
+		}
+		for _, languageID := range details.LanguageIDs {
+			if err := tx.Create(&models.ProfileLanguage{ProfileID: id, LanguageID: languageID}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if details.Prompts != nil {
+		if err := tx.Where("profile_id = ?", id).Delete(&models.ProfilePrompt{}).Error; err != nil {
+			return err
+		}
+		for position, prompt := range details.Prompts {
+			prompt.ID = 0
+			prompt.ProfileID = id
+			prompt.Position = position
+			if err := tx.Omit("Question").Create(&prompt).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func promptsInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// preloadProfileDetails loads everything a serialized profile shows
+func preloadProfileDetails(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Photos", photosInGalleryOrder).
+		Preload("Interests.Interest").
+		Preload("Languages.Language").
+		Preload("Prompts", promptsInOrder).
+		Preload("Prompts.Question")
+}
@@ -0,0 +1,260 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IProfilePhotoRepository interface {
+	Create(photo models.ProfilePhoto, max int) (*models.ProfilePhoto, bool, error)
+	First(userID string, id uint) (*models.ProfilePhoto, error)
+	FindListByUserId(userID string) ([]models.ProfilePhoto, error)
+	Reorder(userID string, ids []uint) error
+	SetPrimary(userID string, id uint) error
+	ReplaceFiles(models.ProfilePhoto) error
+	SetModeration(id uint, fileID, status, reason string, at time.Time) error
+	FindListByStatus(status string, limit int) ([]models.ProfilePhoto, error)
+	Review(id uint, status, reason string, at time.Time) (bool, error)
+	Delete(userID string, id uint) error
+}
+
+// ProfilePhotoRepository database structure
+type ProfilePhotoRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewProfilePhotoRepository creates a new profile photo repository
+func NewProfilePhotoRepository(db *core.Database, logger *core.Logger) IProfilePhotoRepository {
+	return &ProfilePhotoRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+// Create appends the photo to the end of the gallery, the first photo becomes the primary one.
+// It reports false when the gallery already holds max photos.
+func (r *ProfilePhotoRepository) Create(photo models.ProfilePhoto, max int) (*models.ProfilePhoto, bool, error) {
+	created := false
+	err := r.Database.Transaction(func(tx *gorm.DB) error {
+		if err := lockGallery(tx, photo.UserID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ?", photo.UserID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(max) {
+			return nil
+		}
+
+		photo.Position = int(count)
+		photo.IsPrimary = count == 0
+		if err := tx.Create(&photo).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		r.logger.Error(err)
+		return nil, false, err
+	}
+	if !created {
+		return nil, false, nil
+	}
+	return &photo, true, nil
+}
+
+func (r *ProfilePhotoRepository) First(userID string, id uint) (*models.ProfilePhoto, error) {
+	var photo models.ProfilePhoto
+	if err := r.Database.Where("user_id = ? AND id = ?", userID, id).First(&photo).Error; err != nil {
+		return nil, err
+	}
+	return &photo, nil
+}
+
+func (r *ProfilePhotoRepository) FindListByUserId(userID string) ([]models.ProfilePhoto, error) {
+	var photos []models.ProfilePhoto
+	err := r.Database.Where("user_id = ?", userID).Order("position").Find(&photos).Error
+	return photos, err
+}
+
+// ReplaceFiles points the photo to newly stored files, it keeps its place in the gallery
+func (r *ProfilePhotoRepository) ReplaceFiles(photo models.ProfilePhoto) error {
+	result := r.Database.Model(&models.ProfilePhoto{}).
+		Where("user_id = ? AND id = ?", photo.UserID, photo.ID).
+		Updates(map[string]interface{}{
+			"file_id":           photo.FileID,
+			"path":              photo.Path,
+			"thumbnail_file_id": photo.ThumbnailFileID,
+			"thumbnail_path":    photo.ThumbnailPath,
+			"card_file_id":      photo.CardFileID,
+			"card_path":         photo.CardPath,
+			"width":             photo.Width,
+			"height":            photo.Height,
+			"status":            photo.Status,
+			"moderation_reason": photo.ModerationReason,
+			"moderated_at":      photo.ModeratedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetModeration stores a verdict, unless the photo was replaced since fileID was checked
+func (r *ProfilePhotoRepository) SetModeration(id uint, fileID, status, reason string, at time.Time) error {
+	return r.Database.Model(&models.ProfilePhoto{}).
+		Where("id = ? AND file_id = ?", id, fileID).
+		Updates(map[string]interface{}{
+			"status":            status,
+			"moderation_reason": reason,
+			"moderated_at":      at,
+		}).Error
+}
+
+// FindListByStatus lists photos of every user oldest first, the order of the review queue
+func (r *ProfilePhotoRepository) FindListByStatus(status string, limit int) ([]models.ProfilePhoto, error) {
+	var photos []models.ProfilePhoto
+	err := r.Database.Where("status = ?", status).
+		Order("id").
+		Limit(limit).
+		Find(&photos).Error
+	return photos, err
+}
+
+// Review stores the verdict of an admin on a pending photo. It reports false when the photo
+// was not pending.
+func (r *ProfilePhotoRepository) Review(id uint, status, reason string, at time.Time) (bool, error) {
+	tx := r.Database.Model(&models.ProfilePhoto{}).
+		Where("id = ? AND status = ?", id, models.ProfilePhotoStatusPending).
+		Updates(map[string]interface{}{
+			"status":            status,
+			"moderation_reason": reason,
+			"moderated_at":      at,
+		})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// Reorder sets the positions to the order of ids, which must hold every photo of the user
+func (r *ProfilePhotoRepository) Reorder(userID string, ids []uint) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		if err := lockGallery(tx, userID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ? AND id IN ?", userID, ids).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		var total int64
+		if err := tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ?", userID).
+			Count(&total).Error; err != nil {
+			return err
+		}
+		if count != int64(len(ids)) || total != count {
+			return gorm.ErrRecordNotFound
+		}
+
+		// positions are unique per user, move them out of the way first
+		if err := tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ?", userID).
+			Update("position", gorm.Expr("position + ?", len(ids))).Error; err != nil {
+			return err
+		}
+
+		for position, id := range ids {
+			if err := tx.Model(&models.ProfilePhoto{}).
+				Where("user_id = ? AND id = ?", userID, id).
+				Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ProfilePhotoRepository) SetPrimary(userID string, id uint) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ? AND id = ?", userID, id).
+			Update("is_primary", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var photo models.ProfilePhoto
+			if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&photo).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ? AND id <> ?", userID, id).
+			Update("is_primary", false).Error
+	})
+}
+
+// Delete removes the photo and closes the gap it leaves, when it was the primary photo
+// the next one in order takes over
+func (r *ProfilePhotoRepository) Delete(userID string, id uint) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		if err := lockGallery(tx, userID); err != nil {
+			return err
+		}
+
+		var photo models.ProfilePhoto
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&photo).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&photo).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ProfilePhoto{}).
+			Where("user_id = ? AND position > ?", userID, photo.Position).
+			Order("position").
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+
+		if !photo.IsPrimary {
+			return nil
+		}
+
+		var next models.ProfilePhoto
+		err := tx.Where("user_id = ?", userID).Order("position").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("is_primary", true).Error
+	})
+}
+
+// lockGallery takes the lock on the user row that serializes the changes to their gallery, so
+// that concurrent uploads can't go over the cap or share a position
+func lockGallery(tx *gorm.DB, userID string) error {
+	var user models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", userID).
+		Take(&user).Error
+}
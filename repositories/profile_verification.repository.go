@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+)
+
+type IProfileVerificationRepository interface {
+	Create(models.ProfileVerification) (*models.ProfileVerification, error)
+	First(id uint) (*models.ProfileVerification, error)
+	Latest(userID string) (*models.ProfileVerification, error)
+	FindListByStatus(status string, limit int) ([]models.ProfileVerification, error)
+	Submit(id uint, fileID, path string, at time.Time) (bool, error)
+	Review(id uint, status, reason, reviewer string, at time.Time) (bool, error)
+}
+
+// ProfileVerificationRepository database structure
+type ProfileVerificationRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewProfileVerificationRepository creates a new profile verification repository
+func NewProfileVerificationRepository(db *core.Database, logger *core.Logger) IProfileVerificationRepository {
+	return &ProfileVerificationRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *ProfileVerificationRepository) Create(verification models.ProfileVerification) (*models.ProfileVerification, error) {
+	if err := r.Database.Create(&verification).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+	return &verification, nil
+}
+
+func (r *ProfileVerificationRepository) First(id uint) (*models.ProfileVerification, error) {
+	var verification models.ProfileVerification
+	if err := r.Database.Where("id = ?", id).First(&verification).Error; err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// Latest returns the most recent challenge of the user
+func (r *ProfileVerificationRepository) Latest(userID string) (*models.ProfileVerification, error) {
+	var verification models.ProfileVerification
+	if err := r.Database.Where("user_id = ?", userID).Order("id DESC").First(&verification).Error; err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// FindListByStatus lists verifications oldest submission first, the order of the review queue
+func (r *ProfileVerificationRepository) FindListByStatus(status string, limit int) ([]models.ProfileVerification, error) {
+	var verifications []models.ProfileVerification
+	err := r.Database.Where("status = ?", status).
+		Order("submitted_at, id").
+		Limit(limit).
+		Find(&verifications).Error
+	return verifications, err
+}
+
+// Submit attaches the selfie to a challenge that is still waiting for one
+func (r *ProfileVerificationRepository) Submit(id uint, fileID, path string, at time.Time) (bool, error) {
+	tx := r.Database.Model(&models.ProfileVerification{}).
+		Where("id = ? AND status = ?", id, models.ProfileVerificationStatusChallenged).
+		Updates(map[string]interface{}{
+			"status":         models.ProfileVerificationStatusPending,
+			"selfie_file_id": fileID,
+			"selfie_path":    path,
+			"submitted_at":   at,
+		})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// Review stores the verdict on a pending verification, an approval verifies the profile
+// in the same transaction. It reports false when the verification was not pending.
+func (r *ProfileVerificationRepository) Review(id uint, status, reason, reviewer string, at time.Time) (bool, error) {
+	reviewed := false
+	err := r.Database.Transaction(func(tx *gorm.DB) error {
+		var verification models.ProfileVerification
+		if err := tx.Where("id = ?", id).First(&verification).Error; err != nil {
+			return err
+		}
+
+		update := tx.Model(&models.ProfileVerification{}).
+			Where("id = ? AND status = ?", id, models.ProfileVerificationStatusPending).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reason":      reason,
+				"reviewed_by": reviewer,
+				"reviewed_at": at,
+			})
+		if update.Error != nil || update.RowsAffected == 0 {
+			return update.Error
+		}
+		reviewed = true
+
+		if status != models.ProfileVerificationStatusApproved {
+			return nil
+		}
+		return tx.Model(&models.Profile{}).
+			Where("id = ?", verification.UserID).
+			Update("verified_at", at).Error
+	})
+	return reviewed, err
+}
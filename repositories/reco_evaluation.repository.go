@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+// IRecoEvaluationRepository reads everything an offline evaluation replays at once
+type IRecoEvaluationRepository interface {
+	FindProfiles() ([]models.Profile, error)
+	FindAnswers() ([]models.Answer, error)
+	FindSwipes() ([]models.Match, error)
+	FindPreferences() ([]models.UserPreference, error)
+}
+
+type RecoEvaluationRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+func NewRecoEvaluationRepository(db *core.Database, logger *core.Logger) IRecoEvaluationRepository {
+	return &RecoEvaluationRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *RecoEvaluationRepository) FindProfiles() ([]models.Profile, error) {
+	var profiles []models.Profile
+	err := r.Database.Model(&models.Profile{}).
+		Scopes(preloadProfileDetails).
+		Order("profiles.id").
+		Find(&profiles).Error
+	return profiles, err
+}
+
+func (r *RecoEvaluationRepository) FindAnswers() ([]models.Answer, error) {
+	var answers []models.Answer
+	err := r.Database.Model(&models.Answer{}).Find(&answers).Error
+	return answers, err
+}
+
+func (r *RecoEvaluationRepository) FindSwipes() ([]models.Match, error) {
+	var swipes []models.Match
+	err := r.Database.Model(&models.Match{}).Find(&swipes).Error
+	return swipes, err
+}
+
+func (r *RecoEvaluationRepository) FindPreferences() ([]models.UserPreference, error) {
+	var preferences []models.UserPreference
+	err := r.Database.Model(&models.UserPreference{}).Find(&preferences).Error
+	return preferences, err
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"github.com/hodukihugi/winglets-api/core"
 	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm/clause"
 )
 
 type IRecommendationBinRepository interface {
@@ -36,9 +37,10 @@ func (r *RecommendationBinRepository) GetRecommendedUserByUserId(userId string)
 	return result, nil
 }
 
+// Create adds the user to the bin, adding an already binned user is a no-op
 func (r *RecommendationBinRepository) Create(recommendedUser models.RecommendationBin) error {
 	db := r.Database.Model(models.RecommendationBin{})
-	if err := db.Create(&recommendedUser).Error; err != nil {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&recommendedUser).Error; err != nil {
 		r.logger.Error(err)
 		return err
 	}
@@ -10,4 +10,17 @@ var Module = fx.Options(
 	fx.Provide(NewMatchRepository),
 	fx.Provide(NewQuestionRepository),
 	fx.Provide(NewRecommendationBinRepository),
+	fx.Provide(NewLoginAttemptRepository),
+	fx.Provide(NewJobRepository),
+	fx.Provide(NewIdentityRepository),
+	fx.Provide(NewMFARepository),
+	fx.Provide(NewPhoneVerificationRepository),
+	fx.Provide(NewAccountRepository),
+	fx.Provide(NewProfilePhotoRepository),
+	fx.Provide(NewProfileVerificationRepository),
+	fx.Provide(NewTaxonomyRepository),
+	fx.Provide(NewPreferenceRepository),
+	fx.Provide(NewRecoEvaluationRepository),
+	fx.Provide(NewExperimentRepository),
+	fx.Provide(NewNotificationRepository),
 )
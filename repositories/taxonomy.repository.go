@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+type ITaxonomyRepository interface {
+	FindInterests() ([]models.Interest, error)
+	FindLanguages() ([]models.Language, error)
+	FindPromptQuestions() ([]models.PromptQuestion, error)
+	FindInterestsBySlugs(slugs []string) ([]models.Interest, error)
+	FindLanguagesByCodes(codes []string) ([]models.Language, error)
+	FindPromptQuestionsBySlugs(slugs []string) ([]models.PromptQuestion, error)
+}
+
+// TaxonomyRepository reads the curated lookups profiles pick from
+type TaxonomyRepository struct {
+	*core.Database
+	logger *core.Logger
+}
+
+// NewTaxonomyRepository creates a new taxonomy repository
+func NewTaxonomyRepository(db *core.Database, logger *core.Logger) ITaxonomyRepository {
+	return &TaxonomyRepository{
+		Database: db,
+		logger:   logger,
+	}
+}
+
+func (r *TaxonomyRepository) FindInterests() ([]models.Interest, error) {
+	var interests []models.Interest
+	err := r.Database.Order("category, name").Find(&interests).Error
+	return interests, err
+}
+
+func (r *TaxonomyRepository) FindLanguages() ([]models.Language, error) {
+	var languages []models.Language
+	err := r.Database.Order("name").Find(&languages).Error
+	return languages, err
+}
+
+func (r *TaxonomyRepository) FindPromptQuestions() ([]models.PromptQuestion, error) {
+	var questions []models.PromptQuestion
+	err := r.Database.Order("id").Find(&questions).Error
+	return questions, err
+}
+
+func (r *TaxonomyRepository) FindInterestsBySlugs(slugs []string) ([]models.Interest, error) {
+	var interests []models.Interest
+	err := r.Database.Where("slug IN ?", slugs).Find(&interests).Error
+	return interests, err
+}
+
+func (r *TaxonomyRepository) FindLanguagesByCodes(codes []string) ([]models.Language, error) {
+	var languages []models.Language
+	err := r.Database.Where("code IN ?", codes).Find(&languages).Error
+	return languages, err
+}
+
+func (r *TaxonomyRepository) FindPromptQuestionsBySlugs(slugs []string) ([]models.PromptQuestion, error) {
+	var questions []models.PromptQuestion
+	err := r.Database.Where("slug IN ?", slugs).Find(&questions).Error
+	return questions, err
+}
@@ -6,12 +6,18 @@ import (
 	"github.com/hodukihugi/winglets-api/models"
 	"gorm.io/gorm"
 	"strings"
+	"time"
 )
 
 type IUserRepository interface {
-	Create(models.User) error
+	Create(models.User) (*models.User, error)
 	First(models.OneUserFilter) (*models.User, error)
 	UpdateById(string, models.User) error
+	ReplaceVerificationCode(id, codeHash string, issuedAt, issuedBefore time.Time) (bool, error)
+	SetVerificationCode(id, codeHash string, issuedAt time.Time) (bool, error)
+	ConsumeVerificationAttempt(id string, maxAttempts int) (bool, error)
+	MarkEmailVerified(id, passwordHash string) (bool, error)
+	MarkPhoneVerified(id, phoneNumber string, verifiedAt time.Time) error
 }
 
 // UserRepository database structure
@@ -28,10 +34,13 @@ func NewUserRepository(db *core.Database, logger *core.Logger) IUserRepository {
 	}
 }
 
-func (r *UserRepository) Create(user models.User) error {
+func (r *UserRepository) Create(user models.User) (*models.User, error) {
 	user.Email = strings.ToLower(user.Email)
 	user.ID = uuid.New().String()
-	return r.DB.Create(&user).Error
+	if err := r.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 func (r *UserRepository) First(filter models.OneUserFilter) (user *models.User, err error) {
@@ -57,6 +66,85 @@ func (r *UserRepository) UpdateById(id string, user models.User) error {
 	return nil
 }
 
+// ReplaceVerificationCode stores a new code and resets the attempt counter, but only when
+// the current code was issued before issuedBefore. It reports whether the code was replaced.
+func (r *UserRepository) ReplaceVerificationCode(id, codeHash string, issuedAt, issuedBefore time.Time) (bool, error) {
+	tx := r.Database.Model(&models.User{}).
+		Where("id = ? AND verification_status = 0", id).
+		Where("verification_time IS NULL OR verification_time <= ?", issuedBefore).
+		Updates(map[string]interface{}{
+			"verification_code":     codeHash,
+			"verification_time":     issuedAt,
+			"verification_attempts": 0,
+		})
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// SetVerificationCode stores a new code and resets the attempt counter of an unverified user,
+// it reports false when the user is verified or gone
+func (r *UserRepository) SetVerificationCode(id, codeHash string, issuedAt time.Time) (bool, error) {
+	tx := r.Database.Model(&models.User{}).
+		Where("id = ? AND verification_status = 0", id).
+		Updates(map[string]interface{}{
+			"verification_code":     codeHash,
+			"verification_time":     issuedAt,
+			"verification_attempts": 0,
+		})
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// ConsumeVerificationAttempt uses up one verification attempt. It reports false when
+// the user has no attempt left.
+func (r *UserRepository) ConsumeVerificationAttempt(id string, maxAttempts int) (bool, error) {
+	tx := r.Database.Model(&models.User{}).
+		Where("id = ? AND verification_attempts < ?", id, maxAttempts).
+		Update("verification_attempts", gorm.Expr("verification_attempts + 1"))
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// MarkEmailVerified hands the account to whoever proved the mailbox: the password set before
+// is replaced by passwordHash, empty for none, and the sessions signed before are revoked. It
+// reports false when the email was verified in the meantime.
+func (r *UserRepository) MarkEmailVerified(id, passwordHash string) (bool, error) {
+	tx := r.Database.Model(&models.User{}).
+		Where("id = ? AND verification_status = 0", id).
+		Updates(map[string]interface{}{
+			"password":              passwordHash,
+			"verification_status":   1,
+			"verification_code":     nil,
+			"verification_attempts": 0,
+			"session_version":       gorm.Expr("session_version + 1"),
+		})
+	if tx.Error != nil {
+		r.logger.Debug(tx.Error)
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// MarkPhoneVerified stores the verified number, the unique index refuses numbers
+// verified by another user in the meantime
+func (r *UserRepository) MarkPhoneVerified(id, phoneNumber string, verifiedAt time.Time) error {
+	return r.Database.Model(&models.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"phone_number":      phoneNumber,
+			"phone_verified_at": verifiedAt,
+		}).Error
+}
+
 // -------- Private functions ---------
 func (r *UserRepository) filterUser(filter models.OneUserFilter, tx *gorm.DB) {
 	if filter.Fields != nil && len(filter.Fields.Values()) > 0 {
@@ -80,4 +168,8 @@ func (r *UserRepository) filterUser(filter models.OneUserFilter, tx *gorm.DB) {
 	if filter.ID != "" {
 		tx.Where("users.id = ?", filter.ID)
 	}
+
+	if filter.PhoneNumber != "" {
+		tx.Where("users.phone_number = ?", filter.PhoneNumber)
+	}
 }
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+const defaultAccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+var ErrAccountDeletionNotScheduled = errors.New("account deletion is not scheduled")
+
+// erasedJobTypes are the jobs queued for a user, named by their user_id, that go with the
+// account. Other users' jobs may list the user too and are left alone, so is the running erase.
+var erasedJobTypes = []string{
+	JobTypeSendVerificationEmail,
+	JobTypeSendPhoneCode,
+	JobTypeModeratePhoto,
+	JobTypeCreateRecommendationBin,
+}
+
+type IAccountService interface {
+	ScheduleDeletion(userID string) (time.Time, error)
+	CancelDeletion(userID string) error
+	Erase(userID string) error
+	Export(userID string) (*models.AccountExport, error)
+}
+
+// AccountService deletes accounts after a grace period and exports the data of an account
+type AccountService struct {
+	logger       *core.Logger
+	clock        core.Clock
+	repository   repositories.IAccountRepository
+	userRepo     repositories.IUserRepository
+	answerRepo   repositories.IAnswerRepository
+	binRepo      repositories.IRecommendationBinRepository
+	identityRepo repositories.IIdentityRepository
+	mfaService   IMFAService
+	jobService   IJobService
+	urlSigner    *core.BlobURLSigner
+	gracePeriod  time.Duration
+}
+
+// NewAccountService creates a new account service and registers the erase job
+func NewAccountService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.IAccountRepository,
+	userRepo repositories.IUserRepository,
+	answerRepo repositories.IAnswerRepository,
+	binRepo repositories.IRecommendationBinRepository,
+	identityRepo repositories.IIdentityRepository,
+	mfaService IMFAService,
+	jobService IJobService,
+	urlSigner *core.BlobURLSigner,
+) IAccountService {
+	s := &AccountService{
+		logger:       logger,
+		clock:        clock,
+		repository:   repository,
+		userRepo:     userRepo,
+		answerRepo:   answerRepo,
+		binRepo:      binRepo,
+		identityRepo: identityRepo,
+		mfaService:   mfaService,
+		jobService:   jobService,
+		urlSigner:    urlSigner,
+		gracePeriod:  env.AccountDeletionGracePeriod,
+	}
+
+	if s.gracePeriod <= 0 {
+		s.gracePeriod = defaultAccountDeletionGracePeriod
+	}
+
+	jobService.Register(JobTypeEraseAccount, func(ctx context.Context, payload []byte) error {
+		var erase models.AccountErasePayload
+		if err := json.Unmarshal(payload, &erase); err != nil {
+			return err
+		}
+		return s.Erase(erase.UserID)
+	})
+
+	return s
+}
+
+// ScheduleDeletion marks the account for erasure once the grace period is over and returns
+// when that happens. Asking again keeps the first date.
+func (s *AccountService) ScheduleDeletion(userID string) (time.Time, error) {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if user.DeletionScheduledAt != nil {
+		return *user.DeletionScheduledAt, nil
+	}
+
+	// the job goes first: an erase job without the mark does nothing, while a mark without
+	// the job would never be erased and keeps the next try from queueing it
+	eraseAt := s.clock.Now().Add(s.gracePeriod)
+	if err = s.jobService.EnqueueAt(JobTypeEraseAccount, models.AccountErasePayload{UserID: userID}, eraseAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if err = s.repository.ScheduleDeletion(userID, eraseAt); err != nil {
+		return time.Time{}, err
+	}
+
+	s.logger.Warnw("security event",
+		"event", "account_deletion_scheduled",
+		"user_id", userID,
+		"erase_at", eraseAt,
+	)
+	return eraseAt, nil
+}
+
+// CancelDeletion keeps the account, the pending erase job turns into a no-op
+func (s *AccountService) CancelDeletion(userID string) error {
+	cancelled, err := s.repository.CancelDeletion(userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrAccountDeletionNotScheduled
+	}
+
+	s.logger.Warnw("security event",
+		"event", "account_deletion_cancelled",
+		"user_id", userID,
+	)
+	return nil
+}
+
+// Erase removes the account for good when its deletion is due: the rows are hard-deleted, then
+// the profile images and selfies are deleted from the blob store through the job outbox
+func (s *AccountService) Erase(userID string) error {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// restored, or scheduled again later by another job
+	if user.DeletionScheduledAt == nil || s.clock.Now().Before(*user.DeletionScheduledAt) {
+		return nil
+	}
+
+	var fileIDs []string
+	photos, err := s.repository.FindPhotos(userID)
+	if err != nil {
+		return err
+	}
+	for _, photo := range photos {
+		fileIDs = append(fileIDs, photo.FileIDs()...)
+	}
+
+	verifications, err := s.repository.FindVerifications(userID)
+	if err != nil {
+		return err
+	}
+	for _, verification := range verifications {
+		if verification.SelfieFileID != "" {
+			fileIDs = append(fileIDs, verification.SelfieFileID)
+		}
+	}
+
+	if err = s.repository.Erase(userID, user.Email, erasedJobTypes); err != nil {
+		return err
+	}
+
+	// the rows are gone, the erase job with them, so a failed enqueue can't be retried
+	for _, fileID := range fileIDs {
+		if err = s.jobService.Enqueue(JobTypeDeleteImage, models.ImageDeletePayload{FileID: fileID}); err != nil {
+			s.logger.Errorw("fail to queue image delete of erased account",
+				"user_id", userID,
+				"file_id", fileID,
+				"error", err,
+			)
+		}
+	}
+
+	s.logger.Warnw("security event",
+		"event", "account_erased",
+		"user_id", userID,
+	)
+	return nil
+}
+
+// Export collects everything we hold about the user
+func (s *AccountService) Export(userID string) (*models.AccountExport, error) {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.AccountExport{
+		ExportedAt: s.clock.Now().Unix(),
+		User: models.ExportedUser{
+			ID:                  user.ID,
+			Email:               user.Email,
+			EmailVerified:       user.VerificationStatus == 1,
+			PhoneNumber:         user.PhoneNumber,
+			PhoneVerifiedAt:     user.PhoneVerifiedAt,
+			Locale:              user.Locale,
+			Role:                user.Role,
+			CreatedAt:           user.CreatedAt,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		},
+		Answers:            []models.SerializableAnswer{},
+		Matches:            []models.ExportedMatch{},
+		RecommendedUserIDs: []string{},
+		Identities:         []models.SerializableIdentity{},
+	}
+
+	profile, err := s.repository.FindProfile(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if profile != nil {
+		profile.PhoneVerifiedAt = user.PhoneVerifiedAt
+		export.Profile = &models.ExportedProfile{
+			SerializableProfile: profile.SerializeForOwner(s.urlSigner.Sign),
+			Coordinates:         profile.Coordinates,
+			CreatedAt:           profile.CreatedAt,
+		}
+		if profile.DeletedAt.Valid {
+			export.Profile.DeletedAt = &profile.DeletedAt.Time
+		}
+	}
+
+	preference, err := s.repository.FindPreference(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	export.Preferences = preference.Serialize()
+
+	answers, err := s.answerRepo.FindListAnswerByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, answer := range answers {
+		export.Answers = append(export.Answers, *answer.Serialize())
+	}
+
+	matches, err := s.repository.FindMatches(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		export.Matches = append(export.Matches, models.ExportedMatch{
+			MatcherID:   match.MatcherId,
+			MatcheeID:   match.MatcheeId,
+			MatchStatus: match.MatchStatus,
+			CreatedAt:   match.CreatedAt,
+		})
+	}
+
+	bins, err := s.binRepo.GetRecommendedUserByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, bin := range bins {
+		export.RecommendedUserIDs = append(export.RecommendedUserIDs, bin.RecommendedUserID)
+	}
+
+	identities, err := s.identityRepo.FindListByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, *identity.Serialize())
+	}
+
+	if export.MFAEnabled, err = s.mfaService.IsEnabled(userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
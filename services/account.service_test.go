@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+// fakeAccountRepository records what Erase was given and how many jobs were queued by then
+type fakeAccountRepository struct {
+	repositories.IAccountRepository
+	jobs         *fakeJobService
+	users        *fakeUserRepository
+	scheduleErr  error
+	photos       []models.ProfilePhoto
+	selfies      []models.ProfileVerification
+	eraseErr     error
+	erasedWith   []string
+	erasedJobs   []string
+	queuedBefore int
+}
+
+func (r *fakeAccountRepository) ScheduleDeletion(userID string, eraseAt time.Time) error {
+	if r.scheduleErr != nil {
+		return r.scheduleErr
+	}
+	r.users.users[userID].DeletionScheduledAt = &eraseAt
+	return nil
+}
+
+func (r *fakeAccountRepository) FindPhotos(userID string) ([]models.ProfilePhoto, error) {
+	return r.photos, nil
+}
+
+func (r *fakeAccountRepository) FindVerifications(userID string) ([]models.ProfileVerification, error) {
+	return r.selfies, nil
+}
+
+func (r *fakeAccountRepository) Erase(userID, email string, jobTypes []string) error {
+	r.erasedWith = []string{userID, email}
+	r.erasedJobs = jobTypes
+	r.queuedBefore = len(r.jobs.Queued(JobTypeDeleteImage))
+	return r.eraseErr
+}
+
+func newTestAccountService(t *testing.T, eraseErr error) (*fakeClock, *fakeUserRepository, *fakeAccountRepository, *fakeJobService, IAccountService) {
+	t.Helper()
+	clock := newFakeClock()
+	users := newFakeUserRepository()
+	jobs := newFakeJobService(clock)
+	accounts := &fakeAccountRepository{
+		jobs:  jobs,
+		users: users,
+		photos: []models.ProfilePhoto{
+			{FileID: "full-1", CardFileID: "card-1", ThumbnailFileID: "thumb-1"},
+		},
+		selfies:  []models.ProfileVerification{{SelfieFileID: "selfie-1"}, {}},
+		eraseErr: eraseErr,
+	}
+	service := NewAccountService(&core.Env{}, newTestLogger(), clock, accounts, users, nil, nil, nil, nil, jobs, newTestURLSigner(newFakeBlobStore()))
+	return clock, users, accounts, jobs, service
+}
+
+func TestAccountService_EraseQueuesImageDeletesAfterCommit(t *testing.T) {
+	clock, users, accounts, jobs, service := newTestAccountService(t, nil)
+
+	phoneNumber := "+84901234567"
+	user, _ := users.Create(models.User{Email: "ana@example.com", PhoneNumber: &phoneNumber})
+	eraseAt := clock.Now().Add(time.Hour)
+	users.users[user.ID].DeletionScheduledAt = &eraseAt
+
+	// not due yet
+	if err := service.Erase(user.ID); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if accounts.erasedWith != nil {
+		t.Fatal("erased before the deletion was due")
+	}
+
+	clock.Advance(time.Hour)
+	if err := service.Erase(user.ID); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+
+	want := []string{user.ID, "ana@example.com"}
+	for i := range want {
+		if accounts.erasedWith[i] != want[i] {
+			t.Fatalf("Erase() called with %v, want %v", accounts.erasedWith, want)
+		}
+	}
+	for _, jobType := range accounts.erasedJobs {
+		if jobType == JobTypeEraseAccount {
+			t.Fatal("Erase() deletes the running erase job")
+		}
+	}
+	if accounts.queuedBefore != 0 {
+		t.Fatalf("%d image deletes queued before the rows were erased", accounts.queuedBefore)
+	}
+
+	var fileIDs []string
+	for _, job := range jobs.Queued(JobTypeDeleteImage) {
+		fileIDs = append(fileIDs, job.Payload)
+	}
+	if len(fileIDs) != 4 {
+		t.Fatalf("queued deletes %v, want the 3 photo files and the selfie", fileIDs)
+	}
+}
+
+func TestAccountService_FailedEraseKeepsImages(t *testing.T) {
+	clock, users, _, jobs, service := newTestAccountService(t, errors.New("deadlock"))
+
+	user, _ := users.Create(models.User{Email: "ana@example.com"})
+	eraseAt := clock.Now()
+	users.users[user.ID].DeletionScheduledAt = &eraseAt
+
+	if err := service.Erase(user.ID); err == nil {
+		t.Fatal("Erase() error = nil, want the repository error")
+	}
+	if queued := jobs.Queued(JobTypeDeleteImage); len(queued) != 0 {
+		t.Fatalf("queued %d image deletes for an account that was not erased", len(queued))
+	}
+}
+
+func TestAccountService_ScheduleDeletionRetriesAFailedEnqueue(t *testing.T) {
+	clock, users, _, jobs, service := newTestAccountService(t, nil)
+	user, _ := users.Create(models.User{Email: "ana@example.com"})
+
+	jobs.err = errors.New("database is down")
+	if _, err := service.ScheduleDeletion(user.ID); err == nil {
+		t.Fatal("ScheduleDeletion() error = nil, want the enqueue error")
+	}
+	if users.users[user.ID].DeletionScheduledAt != nil {
+		t.Fatal("the deletion is marked without an erase job")
+	}
+
+	jobs.err = nil
+	eraseAt, err := service.ScheduleDeletion(user.ID)
+	if err != nil {
+		t.Fatalf("ScheduleDeletion() error = %v", err)
+	}
+	if !eraseAt.Equal(clock.Now().Add(defaultAccountDeletionGracePeriod)) {
+		t.Fatalf("erase at %v, want after the grace period", eraseAt)
+	}
+	if queued := jobs.Queued(JobTypeEraseAccount); len(queued) != 1 {
+		t.Fatalf("%d erase jobs queued, want 1", len(queued))
+	}
+}
+
+func TestAccountService_UnmarkedEraseJobDoesNothing(t *testing.T) {
+	clock, users, accounts, jobs, service := newTestAccountService(t, nil)
+	user, _ := users.Create(models.User{Email: "ana@example.com"})
+
+	accounts.scheduleErr = errors.New("database is down")
+	if _, err := service.ScheduleDeletion(user.ID); err == nil {
+		t.Fatal("ScheduleDeletion() error = nil, want the repository error")
+	}
+
+	clock.Advance(defaultAccountDeletionGracePeriod)
+	if errs := jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+	if accounts.erasedWith != nil {
+		t.Fatal("the account was erased without the deletion being marked")
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+const (
+	defaultActivityDebounce      = 5 * time.Minute
+	defaultActivityFlushInterval = 30 * time.Second
+	activityFlushBatchSize       = 500
+)
+
+type IActivityService interface {
+	Touch(userID string)
+	Flush() error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// ActivityService records when users were last active. A user counts at most once per
+// debounce and the touches are written together every flush interval, so requests never wait
+// on the write. Call Start to run the flushes.
+type ActivityService struct {
+	logger        *core.Logger
+	clock         core.Clock
+	profileRepo   repositories.IProfileRepository
+	debounce      time.Duration
+	flushInterval time.Duration
+
+	mu sync.Mutex
+	// users touched since the last flush
+	pending map[string]struct{}
+	// when each user was last queued, for the debounce
+	touched map[string]time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewActivityService creates a new activity service
+func NewActivityService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	profileRepo repositories.IProfileRepository,
+) IActivityService {
+	s := &ActivityService{
+		logger:        logger,
+		clock:         clock,
+		profileRepo:   profileRepo,
+		debounce:      env.ActivityDebounce,
+		flushInterval: env.ActivityFlushInterval,
+		pending:       make(map[string]struct{}),
+		touched:       make(map[string]time.Time),
+	}
+
+	if s.debounce <= 0 {
+		s.debounce = defaultActivityDebounce
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = defaultActivityFlushInterval
+	}
+
+	return s
+}
+
+// Touch marks the user as active now
+func (s *ActivityService) Touch(userID string) {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.touched[userID]; ok && now.Sub(last) < s.debounce {
+		return
+	}
+	s.touched[userID] = now
+	s.pending[userID] = struct{}{}
+}
+
+// Flush writes the pending touches with the time of the flush, which is at most one flush
+// interval late. Users that could not be written are kept for the next flush.
+func (s *ActivityService) Flush() error {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]struct{})
+	for userID, last := range s.touched {
+		if now.Sub(last) >= s.debounce {
+			delete(s.touched, userID)
+		}
+	}
+	s.mu.Unlock()
+
+	ids := make([]string, 0, len(pending))
+	for userID := range pending {
+		ids = append(ids, userID)
+	}
+
+	for start := 0; start < len(ids); start += activityFlushBatchSize {
+		end := start + activityFlushBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := s.profileRepo.SetLastActive(ids[start:end], now); err != nil {
+			s.requeue(ids[start:])
+			return err
+		}
+	}
+	return nil
+}
+
+// Start flushes every flush interval until Stop
+func (s *ActivityService) Start(ctx context.Context) error {
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					s.logger.Errorf("fail to record activity: [%v]", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the flushes and writes what is still pending
+func (s *ActivityService) Stop(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	s.wg.Wait()
+	return s.Flush()
+}
+
+// ----------------- private -----------------
+
+func (s *ActivityService) requeue(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, userID := range ids {
+		s.pending[userID] = struct{}{}
+	}
+}
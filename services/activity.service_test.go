@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+// fakeActivityRepository records the SetLastActive writes, the calls listed in failing fail
+type fakeActivityRepository struct {
+	repositories.IProfileRepository
+	mu      sync.Mutex
+	calls   int
+	failing map[int]bool
+	writes  []activityWrite
+}
+
+type activityWrite struct {
+	ids []string
+	at  time.Time
+}
+
+func (r *fakeActivityRepository) SetLastActive(ids []string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.failing[r.calls] {
+		return errors.New("database is down")
+	}
+	r.writes = append(r.writes, activityWrite{ids: append([]string(nil), ids...), at: at})
+	return nil
+}
+
+// written gives the sorted ids of every write since the last call
+func (r *fakeActivityRepository) written() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for _, write := range r.writes {
+		ids = append(ids, write.ids...)
+	}
+	r.writes = nil
+	sort.Strings(ids)
+	return ids
+}
+
+func newTestActivityService(clock *fakeClock, repository *fakeActivityRepository) *ActivityService {
+	env := &core.Env{ActivityDebounce: 5 * time.Minute, ActivityFlushInterval: time.Hour}
+	return NewActivityService(env, newTestLogger(), clock, repository).(*ActivityService)
+}
+
+func TestActivityService_Debounce(t *testing.T) {
+	clock := newFakeClock()
+	repository := &fakeActivityRepository{}
+	service := newTestActivityService(clock, repository)
+
+	service.Touch("ana")
+	service.Touch("ben")
+	clock.Advance(time.Minute)
+	service.Touch("ana")
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := repository.written(), []string{"ana", "ben"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first flush wrote %v, want %v", got, want)
+	}
+	if repository.calls != 1 {
+		t.Fatalf("first flush made %d writes, want 1", repository.calls)
+	}
+
+	// still within the debounce of the first touch
+	clock.Advance(4*time.Minute - time.Second)
+	service.Touch("ana")
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := repository.written(); got != nil {
+		t.Fatalf("touch within the debounce wrote %v", got)
+	}
+
+	// the debounce runs from the first touch, not the flush
+	clock.Advance(time.Second)
+	service.Touch("ana")
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := repository.written(), []string{"ana"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("touch after the debounce wrote %v, want %v", got, want)
+	}
+}
+
+func TestActivityService_FlushWritesTheFlushTime(t *testing.T) {
+	clock := newFakeClock()
+	repository := &fakeActivityRepository{}
+	service := newTestActivityService(clock, repository)
+
+	service.Touch("ana")
+	clock.Advance(20 * time.Second)
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(repository.writes) != 1 || !repository.writes[0].at.Equal(testNow.Add(20*time.Second)) {
+		t.Fatalf("writes = %+v, want one at the flush", repository.writes)
+	}
+
+	// nothing pending, nothing written
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if repository.calls != 1 {
+		t.Fatalf("an empty flush wrote, %d writes", repository.calls)
+	}
+}
+
+func TestActivityService_FlushForgetsOldTouches(t *testing.T) {
+	clock := newFakeClock()
+	service := newTestActivityService(clock, &fakeActivityRepository{})
+
+	service.Touch("ana")
+	clock.Advance(time.Minute)
+	service.Touch("ben")
+	clock.Advance(4 * time.Minute)
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if _, ok := service.touched["ana"]; ok {
+		t.Fatal("ana's touch is kept after the debounce")
+	}
+	if _, ok := service.touched["ben"]; !ok {
+		t.Fatal("ben's touch is dropped within the debounce")
+	}
+}
+
+func TestActivityService_FlushRequeuesFailedBatches(t *testing.T) {
+	clock := newFakeClock()
+	// the first batch is written, the second fails
+	repository := &fakeActivityRepository{failing: map[int]bool{2: true}}
+	service := newTestActivityService(clock, repository)
+
+	var users []string
+	for i := 0; i < activityFlushBatchSize+20; i++ {
+		users = append(users, fmt.Sprintf("user-%04d", i))
+		service.Touch(users[i])
+	}
+
+	if err := service.Flush(); err == nil {
+		t.Fatal("Flush() error = nil, want the failed write")
+	}
+	first := repository.written()
+	if len(first) != activityFlushBatchSize {
+		t.Fatalf("first flush wrote %d users, want %d", len(first), activityFlushBatchSize)
+	}
+
+	// touched again, but within the debounce, they are written once with the requeued ones
+	service.Touch(users[0])
+	clock.Advance(30 * time.Second)
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	second := repository.written()
+	if len(second) != 20 {
+		t.Fatalf("second flush wrote %d users, want the 20 that failed", len(second))
+	}
+
+	all := append(first, second...)
+	sort.Strings(all)
+	if !reflect.DeepEqual(all, users) {
+		t.Fatalf("the two flushes wrote %d distinct users, want all %d", len(all), len(users))
+	}
+}
+
+func TestActivityService_FlushRequeuesWhenTheFirstBatchFails(t *testing.T) {
+	clock := newFakeClock()
+	repository := &fakeActivityRepository{failing: map[int]bool{1: true}}
+	service := newTestActivityService(clock, repository)
+
+	service.Touch("ana")
+	service.Touch("ben")
+	if err := service.Flush(); err == nil {
+		t.Fatal("Flush() error = nil, want the failed write")
+	}
+	service.Touch("cai")
+	if err := service.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := repository.written(), []string{"ana", "ben", "cai"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("retry wrote %v, want %v", got, want)
+	}
+}
+
+func TestActivityService_StopFlushes(t *testing.T) {
+	clock := newFakeClock()
+	repository := &fakeActivityRepository{}
+	service := newTestActivityService(clock, repository)
+
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() before Start error = %v", err)
+	}
+
+	if err := service.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	service.Touch("ana")
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if got, want := repository.written(), []string{"ana"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Stop() wrote %v, want %v", got, want)
+	}
+}
+
+func TestNewActivityService_Defaults(t *testing.T) {
+	service := NewActivityService(&core.Env{}, newTestLogger(), newFakeClock(), &fakeActivityRepository{}).(*ActivityService)
+	if service.debounce != defaultActivityDebounce || service.flushInterval != defaultActivityFlushInterval {
+		t.Fatalf("debounce %v, flush interval %v, want the defaults", service.debounce, service.flushInterval)
+	}
+}
@@ -9,20 +9,42 @@ import (
 	"github.com/hodukihugi/winglets-api/models"
 	"github.com/hodukihugi/winglets-api/repositories"
 	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
 	"time"
 )
 
+const (
+	defaultEmailVerificationAttempts = 5
+	defaultEmailVerificationCooldown = time.Minute
+	defaultMFAPendingTokenExpiresIn  = 5 * time.Minute
+)
+
+var (
+	ErrEmailAlreadyVerified         = errors.New("email has already been verified")
+	ErrVerificationCodeInvalid      = errors.New("invalid verification code")
+	ErrVerificationCodeExpired      = errors.New("email verification timeout")
+	ErrVerificationAttemptsExceeded = errors.New("too many verification attempts")
+	ErrVerificationResendTooSoon    = errors.New("verification email was sent recently")
+	ErrSessionRevoked               = errors.New("session has been revoked")
+)
+
 type IAuthService interface {
 	Authorize(string) (*models.JWTClaim, error)
+	AuthorizeMFAPending(string) (*models.JWTClaim, error)
+	GenerateMFAPendingToken(user models.User) (string, int64, error)
 	GenerateJWTTokens(user models.User) (string, string, int64, int64, error)
 	Register(request models.RegisterRequest) (*models.User, error)
 	Refresh(user models.User) (string, int64, error)
+	ResendVerificationCode(email string) (*models.User, time.Duration, error)
+	IssueVerificationCode(userID string) (*models.User, string, error)
+	VerifyEmail(email, code, password string) error
 }
 
 // AuthService service relating to authorization
 type AuthService struct {
 	env      *core.Env
 	logger   *core.Logger
+	clock    core.Clock
 	userRepo repositories.IUserRepository
 }
 
@@ -30,32 +52,61 @@ type AuthService struct {
 func NewAuthService(
 	env *core.Env,
 	logger *core.Logger,
+	clock core.Clock,
 	userRepo repositories.IUserRepository,
 ) IAuthService {
 	return &AuthService{
 		env:      env,
 		logger:   logger,
+		clock:    clock,
 		userRepo: userRepo,
 	}
 }
 
-// Authorize authorizes the generated token
+// Authorize authorizes the generated token, mfa pending tokens are refused
 func (s *AuthService) Authorize(tokenString string) (*models.JWTClaim, error) {
-	var claim jwt.Claims = &models.JWTClaim{}
+	claim, err := s.parseJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
 
-	token, err := jwt.ParseWithClaims(tokenString, claim, func(t *jwt.Token) (interface{}, error) {
-		return []byte(s.env.JWTSecret), nil
-	})
+	if claim.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+
+	if err = s.checkSession(claim); err != nil {
+		return nil, err
+	}
+
+	return claim, nil
+}
 
+// AuthorizeMFAPending authorizes a token issued by GenerateMFAPendingToken
+func (s *AuthService) AuthorizeMFAPending(tokenString string) (*models.JWTClaim, error) {
+	claim, err := s.parseJWT(tokenString)
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
+	if claim.Purpose != models.JWTPurposeMFAPending {
 		return nil, errors.New("invalid token")
 	}
 
-	return claim.(*models.JWTClaim), nil
+	if err = s.checkSession(claim); err != nil {
+		return nil, err
+	}
+
+	return claim, nil
+}
+
+// GenerateMFAPendingToken creates the short-lived token handed out after the password check
+// of an account with two-factor authentication
+func (s *AuthService) GenerateMFAPendingToken(user models.User) (string, int64, error) {
+	expiresIn := s.env.MFAPendingTokenExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = defaultMFAPendingTokenExpiresIn
+	}
+	return s.signJWTWithPurpose(user, expiresIn, models.JWTPurposeMFAPending)
 }
 
 func (s *AuthService) Refresh(user models.User) (string, int64, error) {
@@ -77,40 +128,191 @@ func (s *AuthService) GenerateJWTTokens(user models.User) (string, string, int64
 	return accessToken, refreshToken, accessExpired, refreshExpired, nil
 }
 
+// Register creates an unverified user, the verification code is issued when the mail is sent
 func (s *AuthService) Register(request models.RegisterRequest) (*models.User, error) {
 	hashedPassword, err := utils.HashPassword(request.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	//generate verification token
-	randomBytes := make([]byte, 10)
-	_, err = rand.Read(randomBytes)
-	if err != nil {
-		return nil, err
+	locale := request.Locale
+	if locale == "" {
+		locale = models.DefaultLocale
 	}
 
-	verificationCode := base32.StdEncoding.EncodeToString(randomBytes)[:10]
-
-	registerUser := models.User{
+	// the registration starts the resend cool-down, its mail is on the way
+	return s.userRepo.Create(models.User{
 		Email:              request.Email,
+		Locale:             locale,
 		Password:           hashedPassword,
-		VerificationCode:   verificationCode,
 		VerificationStatus: 0,
-		VerificationTime:   time.Now().UTC(),
+		VerificationTime:   s.clock.Now(),
+	})
+}
+
+// ResendVerificationCode lets a new verification mail go out for the email. The pending code
+// is invalidated, the new one is issued when the mail is sent. When the previous code is still
+// inside the resend cool-down it returns ErrVerificationResendTooSoon and the time left.
+func (s *AuthService) ResendVerificationCode(email string) (*models.User, time.Duration, error) {
+	user, err := s.userRepo.First(models.OneUserFilter{Email: email})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if user.VerificationStatus == 1 {
+		return nil, 0, ErrEmailAlreadyVerified
+	}
+
+	now := s.clock.Now()
+	cooldown := s.verificationCooldown()
+	replaced, err := s.userRepo.ReplaceVerificationCode(user.ID, "", now, now.Add(-cooldown))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !replaced {
+		retryAfter := user.VerificationTime.Add(cooldown).Sub(now)
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+		return nil, retryAfter, ErrVerificationResendTooSoon
+	}
+
+	return user, 0, nil
+}
+
+// IssueVerificationCode stores the hash of a new code for the user and returns the plaintext
+// code to mail, the code expires EMAIL_VERIFICATION_EXPIRED_IN from now
+func (s *AuthService) IssueVerificationCode(userID string) (*models.User, string, error) {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if user.VerificationStatus == 1 {
+		return nil, "", ErrEmailAlreadyVerified
+	}
+
+	verificationCode, err := newVerificationCode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.clock.Now()
+	issued, err := s.userRepo.SetVerificationCode(user.ID, utils.HashToken(verificationCode), now)
+	if err != nil {
+		return nil, "", err
+	}
+	if !issued {
+		return nil, "", ErrEmailAlreadyVerified
+	}
+
+	user.VerificationTime = now
+	return user, verificationCode, nil
+}
+
+// VerifyEmail checks the code against the stored hash. Every check uses up one attempt,
+// after the last one the user has to request a new code. Whoever registered the email may not
+// own it, so the password becomes the one given here, none when empty, and the sessions
+// signed before are revoked.
+func (s *AuthService) VerifyEmail(email, code, password string) error {
+	user, err := s.userRepo.First(models.OneUserFilter{Email: email})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVerificationCodeInvalid
+		}
+		return err
+	}
+
+	if user.VerificationStatus == 1 {
+		return ErrEmailAlreadyVerified
+	}
+
+	if user.VerificationCode == "" {
+		return ErrVerificationCodeInvalid
+	}
+
+	if !s.clock.Now().Before(user.VerificationTime.Add(s.env.EmailVerificationExpiresIn)) {
+		return ErrVerificationCodeExpired
+	}
+
+	consumed, err := s.userRepo.ConsumeVerificationAttempt(user.ID, s.verificationAttempts())
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return ErrVerificationAttemptsExceeded
+	}
+
+	if !utils.CompareTokenHash(user.VerificationCode, code) {
+		return ErrVerificationCodeInvalid
 	}
 
-	return &registerUser, s.userRepo.Create(registerUser)
+	var passwordHash string
+	if password != "" {
+		if passwordHash, err = utils.HashPassword(password); err != nil {
+			return err
+		}
+	}
+
+	verified, err := s.userRepo.MarkEmailVerified(user.ID, passwordHash)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrEmailAlreadyVerified
+	}
+	return nil
 }
 
 // ----------------- private -----------------
 
+func (s *AuthService) parseJWT(tokenString string) (*models.JWTClaim, error) {
+	var claim jwt.Claims = &models.JWTClaim{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claim, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.env.JWTSecret), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claim.(*models.JWTClaim), nil
+}
+
+// checkSession refuses tokens signed before the user's sessions were revoked
+func (s *AuthService) checkSession(claim *models.JWTClaim) error {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: claim.UserID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+	if user.SessionVersion != claim.SessionVersion {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
 func (s *AuthService) signJWT(user models.User, expirationPeriod time.Duration) (string, int64, error) {
-	now := time.Now().UTC()
+	return s.signJWTWithPurpose(user, expirationPeriod, "")
+}
+
+func (s *AuthService) signJWTWithPurpose(user models.User, expirationPeriod time.Duration, purpose string) (string, int64, error) {
+	now := s.clock.Now()
 	exp := now.Add(expirationPeriod).Unix()
 	jwtToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, models.JWTClaim{
-		UserID:    user.ID,
-		UserEmail: user.Email,
+		UserID:         user.ID,
+		UserEmail:      user.Email,
+		Purpose:        purpose,
+		SessionVersion: user.SessionVersion,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: exp,
 			IssuedAt:  now.Unix(),
@@ -120,3 +322,25 @@ func (s *AuthService) signJWT(user models.User, expirationPeriod time.Duration)
 	}).SignedString([]byte(s.env.JWTSecret))
 	return jwtToken, exp, err
 }
+
+func (s *AuthService) verificationAttempts() int {
+	if s.env.EmailVerificationAttempts > 0 {
+		return s.env.EmailVerificationAttempts
+	}
+	return defaultEmailVerificationAttempts
+}
+
+func (s *AuthService) verificationCooldown() time.Duration {
+	if s.env.EmailVerificationCooldown > 0 {
+		return s.env.EmailVerificationCooldown
+	}
+	return defaultEmailVerificationCooldown
+}
+
+func newVerificationCode() (string, error) {
+	randomBytes := make([]byte, 10)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(randomBytes)[:10], nil
+}
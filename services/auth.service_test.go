@@ -0,0 +1,205 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+func newTestAuthService(clock core.Clock, users *fakeUserRepository) IAuthService {
+	env := &core.Env{
+		JWTSecret:                  "test-secret",
+		AccessTokenExpiresIn:       time.Hour,
+		RefreshTokenExpiresIn:      24 * time.Hour,
+		EmailVerificationExpiresIn: 15 * time.Minute,
+		EmailVerificationAttempts:  3,
+		EmailVerificationCooldown:  time.Minute,
+	}
+	return NewAuthService(env, newTestLogger(), clock, users)
+}
+
+func registerAndIssue(t *testing.T, service IAuthService) (*models.User, string) {
+	t.Helper()
+	user, err := service.Register(models.RegisterRequest{Email: "ana@example.com", Password: "secret-password"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	user, code, err := service.IssueVerificationCode(user.ID)
+	if err != nil {
+		t.Fatalf("IssueVerificationCode() error = %v", err)
+	}
+	return user, code
+}
+
+func TestAuthService_VerifyEmailExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    error
+	}{
+		{"right after issuing", 0, nil},
+		{"just before expiry", 15*time.Minute - time.Second, nil},
+		{"at expiry", 15 * time.Minute, ErrVerificationCodeExpired},
+		{"after expiry", time.Hour, ErrVerificationCodeExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			users := newFakeUserRepository()
+			service := newTestAuthService(clock, users)
+			user, code := registerAndIssue(t, service)
+
+			clock.Advance(tt.elapsed)
+			if err := service.VerifyEmail(user.Email, code, ""); !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyEmail() error = %v, want %v", err, tt.want)
+			}
+			if verified := users.users[user.ID].VerificationStatus == 1; verified != (tt.want == nil) {
+				t.Fatalf("verified = %v", verified)
+			}
+		})
+	}
+}
+
+func TestAuthService_VerifyEmailAttemptLimit(t *testing.T) {
+	clock := newFakeClock()
+	users := newFakeUserRepository()
+	service := newTestAuthService(clock, users)
+	user, code := registerAndIssue(t, service)
+
+	for i := 0; i < 3; i++ {
+		if err := service.VerifyEmail(user.Email, "WRONGCODE0", ""); !errors.Is(err, ErrVerificationCodeInvalid) {
+			t.Fatalf("attempt %d: VerifyEmail() error = %v, want %v", i+1, err, ErrVerificationCodeInvalid)
+		}
+	}
+
+	// the right code is refused too once the attempts are used up
+	if err := service.VerifyEmail(user.Email, code, ""); !errors.Is(err, ErrVerificationAttemptsExceeded) {
+		t.Fatalf("VerifyEmail() error = %v, want %v", err, ErrVerificationAttemptsExceeded)
+	}
+
+	// a new code resets the attempts
+	clock.Advance(time.Minute)
+	if _, _, err := service.ResendVerificationCode(user.Email); err != nil {
+		t.Fatalf("ResendVerificationCode() error = %v", err)
+	}
+	_, code, err := service.IssueVerificationCode(user.ID)
+	if err != nil {
+		t.Fatalf("IssueVerificationCode() error = %v", err)
+	}
+	if err = service.VerifyEmail(user.Email, code, ""); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+}
+
+func TestAuthService_VerifyEmailReplacesThePassword(t *testing.T) {
+	users := newFakeUserRepository()
+	// jwt-go checks exp against the wall clock
+	service := newTestAuthService(core.NewClock(), users)
+	user, code := registerAndIssue(t, service)
+	before, _, _, _, err := service.GenerateJWTTokens(*user)
+	if err != nil {
+		t.Fatalf("GenerateJWTTokens() error = %v", err)
+	}
+
+	if err = service.VerifyEmail(user.Email, code, "owner-password"); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	stored := users.users[user.ID]
+	if utils.VerifyPassword(stored.Password, "secret-password") == nil {
+		t.Fatal("the password set at registration still works")
+	}
+	if err = utils.VerifyPassword(stored.Password, "owner-password"); err != nil {
+		t.Fatalf("the password given with the code doesn't work, error = %v", err)
+	}
+
+	if _, err = service.Authorize(before); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Authorize() of a token signed before error = %v, want %v", err, ErrSessionRevoked)
+	}
+	after, _, _, _, err := service.GenerateJWTTokens(*stored)
+	if err != nil {
+		t.Fatalf("GenerateJWTTokens() error = %v", err)
+	}
+	if _, err = service.Authorize(after); err != nil {
+		t.Fatalf("Authorize() of a token signed after error = %v", err)
+	}
+}
+
+func TestAuthService_VerifyEmailWithoutPasswordClearsIt(t *testing.T) {
+	users := newFakeUserRepository()
+	service := newTestAuthService(newFakeClock(), users)
+	user, code := registerAndIssue(t, service)
+
+	if err := service.VerifyEmail(user.Email, code, ""); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if password := users.users[user.ID].Password; password != "" {
+		t.Fatalf("password = %q after verifying without one, want none", password)
+	}
+}
+
+func TestAuthService_ResendVerificationCodeCooldown(t *testing.T) {
+	clock := newFakeClock()
+	users := newFakeUserRepository()
+	service := newTestAuthService(clock, users)
+	user, code := registerAndIssue(t, service)
+
+	clock.Advance(20 * time.Second)
+	_, retryAfter, err := service.ResendVerificationCode(user.Email)
+	if !errors.Is(err, ErrVerificationResendTooSoon) {
+		t.Fatalf("ResendVerificationCode() error = %v, want %v", err, ErrVerificationResendTooSoon)
+	}
+	if retryAfter != 40*time.Second {
+		t.Fatalf("retryAfter = %v, want 40s", retryAfter)
+	}
+
+	// the refused resend leaves the pending code alone
+	if users.users[user.ID].VerificationCode == "" {
+		t.Fatal("the pending code was cleared by a refused resend")
+	}
+
+	clock.Advance(40 * time.Second)
+	resent, _, err := service.ResendVerificationCode(user.Email)
+	if err != nil {
+		t.Fatalf("ResendVerificationCode() error = %v", err)
+	}
+	if resent.ID != user.ID {
+		t.Fatalf("resent to %s, want %s", resent.ID, user.ID)
+	}
+
+	// the pending code is invalidated by the resend
+	if err = service.VerifyEmail(user.Email, code, ""); !errors.Is(err, ErrVerificationCodeInvalid) {
+		t.Fatalf("VerifyEmail() with the old code error = %v, want %v", err, ErrVerificationCodeInvalid)
+	}
+
+	// and the cool-down starts over
+	if _, _, err = service.ResendVerificationCode(user.Email); !errors.Is(err, ErrVerificationResendTooSoon) {
+		t.Fatalf("second ResendVerificationCode() error = %v, want %v", err, ErrVerificationResendTooSoon)
+	}
+}
+
+func TestAuthService_VerifiedUsersGetNoCode(t *testing.T) {
+	clock := newFakeClock()
+	users := newFakeUserRepository()
+	service := newTestAuthService(clock, users)
+	user, code := registerAndIssue(t, service)
+
+	if err := service.VerifyEmail(user.Email, code, ""); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if _, _, err := service.ResendVerificationCode(user.Email); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("ResendVerificationCode() error = %v, want %v", err, ErrEmailAlreadyVerified)
+	}
+	if _, _, err := service.IssueVerificationCode(user.ID); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("IssueVerificationCode() error = %v, want %v", err, ErrEmailAlreadyVerified)
+	}
+	if err := service.VerifyEmail(user.Email, code, ""); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("VerifyEmail() error = %v, want %v", err, ErrEmailAlreadyVerified)
+	}
+}
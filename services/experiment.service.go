@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+type IExperimentService interface {
+	Experiments() []core.Experiment
+	Assign(userID string) core.ExperimentAssignments
+	RecordExposures(ctx context.Context, userID string, subjectIDs []string)
+	RecordSwipe(ctx context.Context, userID, subjectID, event string)
+}
+
+// ExperimentService assigns users to experiment variants and records what they see and do,
+// so variants can be compared with reco:compare
+type ExperimentService struct {
+	logger      *core.Logger
+	clock       core.Clock
+	experiments *core.Experiments
+	repository  repositories.IExperimentRepository
+	jobService  IJobService
+}
+
+// NewExperimentService creates a new experiment service
+func NewExperimentService(
+	logger *core.Logger,
+	clock core.Clock,
+	experiments *core.Experiments,
+	repository repositories.IExperimentRepository,
+	jobService IJobService,
+) IExperimentService {
+	s := &ExperimentService{
+		logger:      logger,
+		clock:       clock,
+		experiments: experiments,
+		repository:  repository,
+		jobService:  jobService,
+	}
+
+	jobService.Register(JobTypeRecordExperimentEvents, func(ctx context.Context, payload []byte) error {
+		var events models.ExperimentEventsPayload
+		if err := json.Unmarshal(payload, &events); err != nil {
+			return err
+		}
+		return s.repository.CreateEvents(events.Events)
+	})
+
+	return s
+}
+
+func (s *ExperimentService) Experiments() []core.Experiment {
+	return s.experiments.List()
+}
+
+func (s *ExperimentService) Assign(userID string) core.ExperimentAssignments {
+	return s.experiments.Assign(userID)
+}
+
+// RecordExposures tags the profiles shown to the user with each of the user's variants
+func (s *ExperimentService) RecordExposures(ctx context.Context, userID string, subjectIDs []string) {
+	var events []models.ExperimentEvent
+	for _, subjectID := range subjectIDs {
+		events = append(events, s.events(ctx, userID, subjectID, models.ExperimentEventExposure)...)
+	}
+	s.record(userID, events)
+}
+
+// RecordSwipe tags a like, a pass or a match with each of the user's variants
+func (s *ExperimentService) RecordSwipe(ctx context.Context, userID, subjectID, event string) {
+	s.record(userID, s.events(ctx, userID, subjectID, event))
+}
+
+// ----------------- private -----------------
+
+func (s *ExperimentService) events(ctx context.Context, userID, subjectID, event string) []models.ExperimentEvent {
+	now := s.clock.Now()
+	var events []models.ExperimentEvent
+	for _, assignment := range core.ExperimentAssignmentsFrom(ctx) {
+		events = append(events, models.ExperimentEvent{
+			Experiment: assignment.Experiment,
+			Variant:    assignment.Variant.Name,
+			UserID:     userID,
+			Event:      event,
+			SubjectID:  subjectID,
+			CreatedAt:  now,
+		})
+	}
+	return events
+}
+
+// record queues the events, losing some is better than failing the request that made them
+func (s *ExperimentService) record(userID string, events []models.ExperimentEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if err := s.jobService.Enqueue(JobTypeRecordExperimentEvents, models.ExperimentEventsPayload{Events: events}); err != nil {
+		s.logger.Errorf("fail to queue experiment events, user [%v], error [%v]", userID, err)
+	}
+}
@@ -0,0 +1,488 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var testNow = time.Date(2024, 9, 2, 10, 0, 0, 0, time.UTC)
+
+func newTestLogger() *core.Logger {
+	return &core.Logger{SugaredLogger: zap.NewNop().Sugar()}
+}
+
+// fakeClock is a core.Clock that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: testNow}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type fakeJob struct {
+	Type    string
+	Payload string
+	RunAt   time.Time
+}
+
+// fakeJobService keeps queued jobs in memory, RunDue hands the due ones to their handlers
+type fakeJobService struct {
+	IJobService
+	mu       sync.Mutex
+	clock    core.Clock
+	handlers map[string]JobHandler
+	jobs     []fakeJob
+	// err fails every enqueue when set
+	err error
+}
+
+func newFakeJobService(clock core.Clock) *fakeJobService {
+	return &fakeJobService{clock: clock, handlers: make(map[string]JobHandler)}
+}
+
+func (f *fakeJobService) Register(jobType string, handler JobHandler) {
+	f.handlers[jobType] = handler
+}
+
+func (f *fakeJobService) Enqueue(jobType string, payload interface{}) error {
+	return f.EnqueueAt(jobType, payload, f.clock.Now())
+}
+
+func (f *fakeJobService) EnqueueAt(jobType string, payload interface{}, runAt time.Time) error {
+	if f.err != nil {
+		return f.err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs = append(f.jobs, fakeJob{Type: jobType, Payload: string(data), RunAt: runAt})
+	return nil
+}
+
+// Queued returns the jobs of the type, due or not
+func (f *fakeJobService) Queued(jobType string) []fakeJob {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var jobs []fakeJob
+	for _, job := range f.jobs {
+		if job.Type == jobType {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// RunDue runs the due jobs once, failed ones stay queued like a retry would
+func (f *fakeJobService) RunDue(t *testing.T) []error {
+	t.Helper()
+	var remaining []fakeJob
+	var errs []error
+	for _, job := range f.jobs {
+		if job.RunAt.After(f.clock.Now()) {
+			remaining = append(remaining, job)
+			continue
+		}
+		handler, ok := f.handlers[job.Type]
+		if !ok {
+			t.Fatalf("no handler registered for job type %s", job.Type)
+		}
+		if err := handler(context.Background(), []byte(job.Payload)); err != nil {
+			errs = append(errs, err)
+			remaining = append(remaining, job)
+		}
+	}
+	f.jobs = remaining
+	return errs
+}
+
+// fakeUserRepository keeps users in memory with the conditional updates of UserRepository
+type fakeUserRepository struct {
+	repositories.IUserRepository
+	users map[string]*models.User
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{users: make(map[string]*models.User)}
+}
+
+func (r *fakeUserRepository) Create(user models.User) (*models.User, error) {
+	user.Email = strings.ToLower(user.Email)
+	user.ID = uuid.New().String()
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return nil, gorm.ErrInvalidData
+		}
+	}
+	stored := user
+	r.users[user.ID] = &stored
+	return &user, nil
+}
+
+func (r *fakeUserRepository) First(filter models.OneUserFilter) (*models.User, error) {
+	for _, user := range r.users {
+		if (filter.ID == "" || user.ID == filter.ID) &&
+			(filter.Email == "" || user.Email == filter.Email) &&
+			(filter.PhoneNumber == "" || (user.PhoneNumber != nil && *user.PhoneNumber == filter.PhoneNumber)) {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepository) ReplaceVerificationCode(id, codeHash string, issuedAt, issuedBefore time.Time) (bool, error) {
+	user, ok := r.users[id]
+	if !ok || user.VerificationStatus != 0 || user.VerificationTime.After(issuedBefore) {
+		return false, nil
+	}
+	user.VerificationCode = codeHash
+	user.VerificationTime = issuedAt
+	user.VerificationAttempts = 0
+	return true, nil
+}
+
+func (r *fakeUserRepository) SetVerificationCode(id, codeHash string, issuedAt time.Time) (bool, error) {
+	user, ok := r.users[id]
+	if !ok || user.VerificationStatus != 0 {
+		return false, nil
+	}
+	user.VerificationCode = codeHash
+	user.VerificationTime = issuedAt
+	user.VerificationAttempts = 0
+	return true, nil
+}
+
+func (r *fakeUserRepository) ConsumeVerificationAttempt(id string, maxAttempts int) (bool, error) {
+	user, ok := r.users[id]
+	if !ok || user.VerificationAttempts >= maxAttempts {
+		return false, nil
+	}
+	user.VerificationAttempts++
+	return true, nil
+}
+
+func (r *fakeUserRepository) MarkEmailVerified(id, passwordHash string) (bool, error) {
+	user, ok := r.users[id]
+	if !ok || user.VerificationStatus != 0 {
+		return false, nil
+	}
+	user.Password = passwordHash
+	user.VerificationStatus = 1
+	user.VerificationCode = ""
+	user.VerificationAttempts = 0
+	user.SessionVersion++
+	return true, nil
+}
+
+func (r *fakeUserRepository) MarkPhoneVerified(id, phoneNumber string, verifiedAt time.Time) error {
+	if user, ok := r.users[id]; ok {
+		user.PhoneNumber = &phoneNumber
+		user.PhoneVerifiedAt = &verifiedAt
+	}
+	return nil
+}
+
+type fakeIdentityRepository struct {
+	identities []models.Identity
+}
+
+func (r *fakeIdentityRepository) Create(identity models.Identity) error {
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *fakeIdentityRepository) First(provider, subject string) (*models.Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			found := identity
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeIdentityRepository) FindListByUserId(userID string) ([]models.Identity, error) {
+	var identities []models.Identity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *fakeIdentityRepository) Delete(userID, provider string) (bool, error) {
+	for i, identity := range r.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type fakePhoneVerificationRepository struct {
+	repositories.IPhoneVerificationRepository
+	verifications map[string]*models.PhoneVerification
+	sends         []models.PhoneCodeSend
+}
+
+func newFakePhoneVerificationRepository() *fakePhoneVerificationRepository {
+	return &fakePhoneVerificationRepository{verifications: make(map[string]*models.PhoneVerification)}
+}
+
+func (r *fakePhoneVerificationRepository) First(phoneNumber string) (*models.PhoneVerification, error) {
+	if verification, ok := r.verifications[phoneNumber]; ok {
+		found := *verification
+		return &found, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakePhoneVerificationRepository) FirstBySendID(sendID string) (*models.PhoneVerification, error) {
+	for _, verification := range r.verifications {
+		if verification.SendID == sendID {
+			found := *verification
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakePhoneVerificationRepository) SaveSend(verification models.PhoneVerification) error {
+	r.verifications[verification.PhoneNumber] = &verification
+	r.sends = append(r.sends, models.PhoneCodeSend{
+		UserID:      verification.UserID,
+		PhoneNumber: verification.PhoneNumber,
+		SentAt:      verification.SentAt,
+	})
+	return nil
+}
+
+func (r *fakePhoneVerificationRepository) FindSendTimesByUser(userID string, since time.Time) ([]time.Time, error) {
+	var sentAt []time.Time
+	for _, send := range r.sends {
+		if send.UserID == userID && send.SentAt.After(since) {
+			sentAt = append(sentAt, send.SentAt)
+		}
+	}
+	return sentAt, nil
+}
+
+func (r *fakePhoneVerificationRepository) SetCode(sendID, codeHash string, issuedAt time.Time) (bool, error) {
+	for _, verification := range r.verifications {
+		if verification.SendID == sendID {
+			verification.CodeHash = codeHash
+			verification.Attempts = 0
+			verification.SentAt = issuedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakePhoneVerificationRepository) ConsumeAttempt(phoneNumber string, maxAttempts int) (bool, error) {
+	verification, ok := r.verifications[phoneNumber]
+	if !ok || verification.Attempts >= maxAttempts {
+		return false, nil
+	}
+	verification.Attempts++
+	return true, nil
+}
+
+func (r *fakePhoneVerificationRepository) ClearCode(phoneNumber string) error {
+	if verification, ok := r.verifications[phoneNumber]; ok {
+		verification.CodeHash = ""
+	}
+	return nil
+}
+
+// fakeProfilePhotoRepository serializes Create like the gallery lock does
+type fakeProfilePhotoRepository struct {
+	repositories.IProfilePhotoRepository
+	mu     sync.Mutex
+	nextID uint
+	photos []models.ProfilePhoto
+}
+
+func (r *fakeProfilePhotoRepository) Create(photo models.ProfilePhoto, max int) (*models.ProfilePhoto, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, existing := range r.photos {
+		if existing.UserID == photo.UserID {
+			count++
+		}
+	}
+	if count >= max {
+		return nil, false, nil
+	}
+	r.nextID++
+	photo.ID = r.nextID
+	photo.Position = count
+	photo.IsPrimary = count == 0
+	r.photos = append(r.photos, photo)
+	return &photo, true, nil
+}
+
+func (r *fakeProfilePhotoRepository) FindListByUserId(userID string) ([]models.ProfilePhoto, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var photos []models.ProfilePhoto
+	for _, photo := range r.photos {
+		if photo.UserID == userID {
+			photos = append(photos, photo)
+		}
+	}
+	return photos, nil
+}
+
+func (r *fakeProfilePhotoRepository) First(userID string, id uint) (*models.ProfilePhoto, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, photo := range r.photos {
+		if photo.UserID == userID && photo.ID == id {
+			return &photo, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeProfilePhotoRepository) SetModeration(id uint, fileID, status, reason string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.photos {
+		if r.photos[i].ID == id && r.photos[i].FileID == fileID {
+			r.photos[i].Status, r.photos[i].ModerationReason, r.photos[i].ModeratedAt = status, reason, &at
+		}
+	}
+	return nil
+}
+
+func (r *fakeProfilePhotoRepository) FindListByStatus(status string, limit int) ([]models.ProfilePhoto, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var photos []models.ProfilePhoto
+	for _, photo := range r.photos {
+		if photo.Status == status && len(photos) < limit {
+			photos = append(photos, photo)
+		}
+	}
+	return photos, nil
+}
+
+func (r *fakeProfilePhotoRepository) Review(id uint, status, reason string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.photos {
+		if r.photos[i].ID == id && r.photos[i].Status == models.ProfilePhotoStatusPending {
+			r.photos[i].Status, r.photos[i].ModerationReason, r.photos[i].ModeratedAt = status, reason, &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeBlobStore names blobs after their path
+type fakeBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newFakeBlobStore() *fakeBlobStore {
+	return &fakeBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (b *fakeBlobStore) Upload(ctx context.Context, folder, name string, content []byte) (*core.Blob, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path := folder + "/" + name
+	b.blobs[path] = content
+	return &core.Blob{ID: path, Path: path}, nil
+}
+
+func (b *fakeBlobStore) Delete(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blobs, id)
+	return nil
+}
+
+func (b *fakeBlobStore) SignedURL(path string, ttl time.Duration) (string, error) {
+	return fmt.Sprintf("https://blobs.test/%s?ttl=%s", path, ttl), nil
+}
+
+func newTestURLSigner(store core.BlobStore) *core.BlobURLSigner {
+	return core.NewBlobURLSigner(&core.Env{}, newTestLogger(), store)
+}
+
+// fakeProfileRepository keeps profiles in memory, PatchProfileById records the changes and
+// applies the travel columns
+type fakeProfileRepository struct {
+	repositories.IProfileRepository
+	profiles map[string]models.Profile
+	patches  []map[string]interface{}
+	details  []models.ProfileDetails
+}
+
+func (r *fakeProfileRepository) GetProfileById(id string) (*models.Profile, error) {
+	profile, ok := r.profiles[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &profile, nil
+}
+
+func (r *fakeProfileRepository) PatchProfileById(id string, changes map[string]interface{}, details models.ProfileDetails) error {
+	profile, ok := r.profiles[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	r.patches = append(r.patches, changes)
+	r.details = append(r.details, details)
+	for column, value := range changes {
+		switch column {
+		case "travel_location":
+			profile.TravelLocation = value.(string)
+		case "travel_coordinates":
+			profile.TravelCoordinates = value.(string)
+		case "travel_expires_at":
+			if at, ok := value.(time.Time); ok {
+				profile.TravelExpiresAt = &at
+			} else {
+				profile.TravelExpiresAt = nil
+			}
+		}
+	}
+	r.profiles[id] = profile
+	return nil
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+const (
+	JobTypeSendVerificationEmail   = "mail.verification"
+	JobTypeSendPhoneCode           = "sms.phone_code"
+	JobTypeDeleteImage             = "image.delete"
+	JobTypeCreateRecommendationBin = "recommendation_bin.create"
+	JobTypeEraseAccount            = "account.erase"
+	JobTypeModeratePhoto           = "photo.moderate"
+	JobTypeMatchVerification       = "profile_verification.match"
+	JobTypeRecordExperimentEvents  = "experiment_events.record"
+	JobTypeSendNotification        = "notification.send"
+)
+
+const (
+	defaultJobWorkers      = 4
+	defaultJobPollInterval = time.Second
+	defaultJobMaxAttempts  = 8
+	defaultJobRetention    = 7 * 24 * time.Hour
+	jobPurgeInterval       = time.Hour
+	jobPurgeBatch          = 1000
+	jobLease               = 5 * time.Minute
+	jobTimeout             = time.Minute
+	jobRetryBase           = 10 * time.Second
+	jobRetryMax            = time.Hour
+)
+
+var ErrJobNotRetryable = errors.New("only dead jobs can be retried")
+
+// JobHandler runs one job, returning an error schedules a retry
+type JobHandler func(ctx context.Context, payload []byte) error
+
+type IJobService interface {
+	Enqueue(jobType string, payload interface{}) error
+	EnqueueAt(jobType string, payload interface{}, runAt time.Time) error
+	Register(jobType string, handler JobHandler)
+	List(filter models.JobFilter) ([]models.Job, error)
+	Retry(id uint) error
+	Purge() (int64, error)
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// JobService stores side effects in the jobs table (the outbox) and runs them with a pool
+// of workers. Failed jobs are retried with an exponential back-off and dead-lettered once
+// they run out of attempts. Payloads hold ids rather than personal data, and done jobs are
+// purged once they are older than the retention.
+type JobService struct {
+	repository   repositories.IJobRepository
+	logger       *core.Logger
+	clock        core.Clock
+	handlersMu   sync.RWMutex
+	handlers     map[string]JobHandler
+	workers      int
+	pollInterval time.Duration
+	maxAttempts  int
+	retention    time.Duration
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewJobService creates a new job service, call Start to run the workers
+func NewJobService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.IJobRepository,
+	blobStore core.BlobStore,
+	recommendationBinRepository repositories.IRecommendationBinRepository,
+) IJobService {
+	s := &JobService{
+		repository:   repository,
+		logger:       logger,
+		clock:        clock,
+		workers:      env.JobWorkers,
+		pollInterval: env.JobPollInterval,
+		maxAttempts:  env.JobMaxAttempts,
+		retention:    env.JobRetention,
+	}
+
+	if s.workers <= 0 {
+		s.workers = defaultJobWorkers
+	}
+	if s.pollInterval <= 0 {
+		s.pollInterval = defaultJobPollInterval
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultJobMaxAttempts
+	}
+	if s.retention <= 0 {
+		s.retention = defaultJobRetention
+	}
+
+	s.handlers = map[string]JobHandler{
+		JobTypeDeleteImage: func(ctx context.Context, payload []byte) error {
+			var image models.ImageDeletePayload
+			if err := json.Unmarshal(payload, &image); err != nil {
+				return err
+			}
+			return blobStore.Delete(ctx, image.FileID)
+		},
+		JobTypeCreateRecommendationBin: func(ctx context.Context, payload []byte) error {
+			var bin models.RecommendationBinPayload
+			if err := json.Unmarshal(payload, &bin); err != nil {
+				return err
+			}
+			for _, recommendedUserID := range bin.RecommendedUserIDs {
+				err := recommendationBinRepository.Create(models.RecommendationBin{
+					UserID:            bin.UserID,
+					RecommendedUserID: recommendedUserID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	return s
+}
+
+// Enqueue stores a job to be run as soon as a worker is free
+func (s *JobService) Enqueue(jobType string, payload interface{}) error {
+	return s.EnqueueAt(jobType, payload, s.clock.Now())
+}
+
+// EnqueueAt stores a job to be run once runAt has passed
+func (s *JobService) EnqueueAt(jobType string, payload interface{}, runAt time.Time) error {
+	if _, ok := s.handler(jobType); !ok {
+		return fmt.Errorf("unknown job type %s", jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repository.Create(models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      models.JobStatusPending,
+		MaxAttempts: s.maxAttempts,
+		RunAt:       runAt,
+	})
+	return err
+}
+
+// Register adds the handler of a job type owned by another service, which could not be
+// handed to NewJobService without a dependency cycle
+func (s *JobService) Register(jobType string, handler JobHandler) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.handlers[jobType] = handler
+}
+
+func (s *JobService) List(filter models.JobFilter) ([]models.Job, error) {
+	return s.repository.List(filter)
+}
+
+// Retry requeues a dead job
+func (s *JobService) Retry(id uint) error {
+	retried, err := s.repository.Retry(id, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if !retried {
+		return ErrJobNotRetryable
+	}
+	return nil
+}
+
+// Start runs the worker pool and the purge of done jobs
+func (s *JobService) Start(ctx context.Context) error {
+	s.stop = make(chan struct{})
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	s.wg.Add(1)
+	go s.purge()
+	s.logger.Infof("Started %d job workers", s.workers)
+	return nil
+}
+
+// Purge deletes the done jobs that finished before the retention, it returns how many
+// were deleted
+func (s *JobService) Purge() (int64, error) {
+	before := s.clock.Now().Add(-s.retention)
+	var total int64
+	for {
+		deleted, err := s.repository.DeleteDone(before, jobPurgeBatch)
+		total += deleted
+		if err != nil || deleted < jobPurgeBatch {
+			return total, err
+		}
+	}
+}
+
+// Stop waits for the running jobs to finish, unfinished jobs are picked up again
+// once their lease expires
+func (s *JobService) Stop(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ----------------- private -----------------
+
+func (s *JobService) work() {
+	defer s.wg.Done()
+
+	for {
+		jobs, err := s.repository.ClaimDue(s.clock.Now(), jobLease, 1)
+		if err != nil {
+			s.logger.Errorf("fail to claim jobs: [%v]", err)
+		}
+
+		for _, job := range jobs {
+			s.run(job)
+		}
+
+		if len(jobs) > 0 {
+			continue
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *JobService) purge() {
+	defer s.wg.Done()
+
+	for {
+		deleted, err := s.Purge()
+		if err != nil {
+			s.logger.Errorf("fail to purge done jobs: [%v]", err)
+		} else if deleted > 0 {
+			s.logger.Infof("purged %d done jobs", deleted)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(jobPurgeInterval):
+		}
+	}
+}
+
+func (s *JobService) run(job models.Job) {
+	handler, ok := s.handler(job.Type)
+	if !ok {
+		s.logger.Errorf("job %d has unknown type %s, dead-lettering it", job.ID, job.Type)
+		if err := s.repository.MarkDead(job.ID, "unknown job type"); err != nil {
+			s.logger.Errorf("fail to dead-letter job %d: [%v]", job.ID, err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	err := handler(ctx, []byte(job.Payload))
+	cancel()
+
+	if err == nil {
+		if err = s.repository.MarkDone(job.ID); err != nil {
+			s.logger.Errorf("fail to mark job %d done: [%v]", job.ID, err)
+		}
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		s.logger.Errorf("job %d (%s) failed %d times, dead-lettering it: [%v]", job.ID, job.Type, job.Attempts, err)
+		if err = s.repository.MarkDead(job.ID, err.Error()); err != nil {
+			s.logger.Errorf("fail to dead-letter job %d: [%v]", job.ID, err)
+		}
+		return
+	}
+
+	backoff := jobRetryBase
+	for i := 1; i < job.Attempts && backoff < jobRetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > jobRetryMax {
+		backoff = jobRetryMax
+	}
+
+	s.logger.Warnf("job %d (%s) failed, retrying in %v: [%v]", job.ID, job.Type, backoff, err)
+	if err = s.repository.MarkFailed(job.ID, err.Error(), s.clock.Now().Add(backoff)); err != nil {
+		s.logger.Errorf("fail to reschedule job %d: [%v]", job.ID, err)
+	}
+}
+
+func (s *JobService) handler(jobType string) (JobHandler, bool) {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	handler, ok := s.handlers[jobType]
+	return handler, ok
+}
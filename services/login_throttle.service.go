@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLoginMaxAttempts      = 5
+	defaultLoginMaxAttemptsPerIP = 20
+	defaultLoginAttemptWindow    = 15 * time.Minute
+	defaultLoginLockoutBase      = time.Minute
+	defaultLoginLockoutMax       = time.Hour
+)
+
+type ILoginThrottleService interface {
+	Check(email, ip string) (time.Duration, error)
+	RecordFailure(email, ip string) (time.Duration, error)
+	RecordSuccess(email string) error
+	Unlock(email, ip string) error
+}
+
+// LoginThrottleService tracks failed sign in attempts per account and per client ip,
+// locking a subject out with an exponentially growing back-off once it exceeds its limit
+type LoginThrottleService struct {
+	repository    repositories.ILoginAttemptRepository
+	logger        *core.Logger
+	maxAttempts   int
+	maxAttemptsIP int
+	window        time.Duration
+	lockoutBase   time.Duration
+	lockoutMax    time.Duration
+	clock         core.Clock
+}
+
+// NewLoginThrottleService creates a new login throttle service
+func NewLoginThrottleService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.ILoginAttemptRepository,
+) ILoginThrottleService {
+	s := &LoginThrottleService{
+		repository:    repository,
+		logger:        logger,
+		maxAttempts:   env.LoginMaxAttempts,
+		maxAttemptsIP: env.LoginMaxAttemptsPerIP,
+		window:        env.LoginAttemptWindow,
+		lockoutBase:   env.LoginLockoutBase,
+		lockoutMax:    env.LoginLockoutMax,
+		clock:         clock,
+	}
+
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultLoginMaxAttempts
+	}
+	if s.maxAttemptsIP <= 0 {
+		s.maxAttemptsIP = defaultLoginMaxAttemptsPerIP
+	}
+	if s.window <= 0 {
+		s.window = defaultLoginAttemptWindow
+	}
+	if s.lockoutBase <= 0 {
+		s.lockoutBase = defaultLoginLockoutBase
+	}
+	if s.lockoutMax <= 0 {
+		s.lockoutMax = defaultLoginLockoutMax
+	}
+
+	return s
+}
+
+// Check returns how long the caller has to wait before it may try to sign in again,
+// zero means the attempt is allowed
+func (s *LoginThrottleService) Check(email, ip string) (time.Duration, error) {
+	now := s.clock.Now()
+	var retryAfter time.Duration
+
+	for _, subject := range s.subjects(email, ip) {
+		attempt, err := s.repository.First(subject.scope, subject.key)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return 0, err
+		}
+
+		if attempt.IsLocked(now) {
+			if wait := attempt.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	return retryAfter, nil
+}
+
+// RecordFailure registers a failed attempt and returns the lockout it caused, if any
+func (s *LoginThrottleService) RecordFailure(email, ip string) (time.Duration, error) {
+	now := s.clock.Now()
+	var retryAfter time.Duration
+
+	for _, subject := range s.subjects(email, ip) {
+		// forget old failures once the subject has been quiet for a whole window
+		attempt, err := s.repository.RecordFailure(subject.scope, subject.key, now, now.Add(-s.window))
+		if err != nil {
+			return 0, err
+		}
+
+		if lockout := s.lockoutFor(attempt.FailedAttempts, subject.limit); lockout > 0 {
+			lockedUntil := now.Add(lockout)
+			if err = s.repository.Lock(subject.scope, subject.key, lockedUntil); err != nil {
+				return 0, err
+			}
+			if lockout > retryAfter {
+				retryAfter = lockout
+			}
+			s.logger.Warnw("security event",
+				"event", "login_lockout",
+				"scope", subject.scope,
+				"subject", subject.key,
+				"failed_attempts", attempt.FailedAttempts,
+				"locked_until", lockedUntil,
+			)
+		}
+	}
+
+	return retryAfter, nil
+}
+
+// RecordSuccess clears the failures of the account. The ip counter is kept on purpose
+// so that signing in to one account does not reset guessing against others.
+func (s *LoginThrottleService) RecordSuccess(email string) error {
+	return s.repository.Delete(models.LoginAttemptScopeAccount, normalizeEmail(email))
+}
+
+// Unlock lifts the lockout of an account and/or an ip
+func (s *LoginThrottleService) Unlock(email, ip string) error {
+	for _, subject := range s.subjects(email, ip) {
+		if err := s.repository.Delete(subject.scope, subject.key); err != nil {
+			return err
+		}
+		s.logger.Warnw("security event",
+			"event", "login_unlock",
+			"scope", subject.scope,
+			"subject", subject.key,
+		)
+	}
+	return nil
+}
+
+// ----------------- private -----------------
+
+type throttleSubject struct {
+	scope string
+	key   string
+	limit int
+}
+
+func (s *LoginThrottleService) subjects(email, ip string) []throttleSubject {
+	var subjects []throttleSubject
+	if email = normalizeEmail(email); email != "" {
+		subjects = append(subjects, throttleSubject{models.LoginAttemptScopeAccount, email, s.maxAttempts})
+	}
+	if ip != "" {
+		subjects = append(subjects, throttleSubject{models.LoginAttemptScopeIP, ip, s.maxAttemptsIP})
+	}
+	return subjects
+}
+
+// lockoutFor doubles the lockout for every failure past the limit
+func (s *LoginThrottleService) lockoutFor(failedAttempts, limit int) time.Duration {
+	if failedAttempts < limit {
+		return 0
+	}
+
+	lockout := s.lockoutBase
+	for i := limit; i < failedAttempts && lockout < s.lockoutMax; i++ {
+		lockout *= 2
+	}
+
+	if lockout > s.lockoutMax {
+		lockout = s.lockoutMax
+	}
+	return lockout
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
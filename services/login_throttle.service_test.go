@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+)
+
+// fakeLoginAttemptRepository keeps the attempts in memory with the counting of
+// LoginAttemptRepository
+type fakeLoginAttemptRepository struct {
+	attempts map[string]*models.LoginAttempt
+}
+
+func newFakeLoginAttemptRepository() *fakeLoginAttemptRepository {
+	return &fakeLoginAttemptRepository{attempts: make(map[string]*models.LoginAttempt)}
+}
+
+func (r *fakeLoginAttemptRepository) First(scope, subject string) (*models.LoginAttempt, error) {
+	attempt, ok := r.attempts[scope+":"+subject]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *attempt
+	return &found, nil
+}
+
+func (r *fakeLoginAttemptRepository) RecordFailure(scope, subject string, failedAt, quietSince time.Time) (*models.LoginAttempt, error) {
+	attempt, ok := r.attempts[scope+":"+subject]
+	if !ok {
+		attempt = &models.LoginAttempt{Scope: scope, Subject: subject}
+		r.attempts[scope+":"+subject] = attempt
+	}
+	lastActivity := attempt.LastFailedAt
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(lastActivity) {
+		lastActivity = *attempt.LockedUntil
+	}
+	if lastActivity.Before(quietSince) {
+		attempt.FailedAttempts = 0
+		attempt.LockedUntil = nil
+	}
+	attempt.FailedAttempts++
+	attempt.LastFailedAt = failedAt
+	found := *attempt
+	return &found, nil
+}
+
+func (r *fakeLoginAttemptRepository) Lock(scope, subject string, lockedUntil time.Time) error {
+	if attempt, ok := r.attempts[scope+":"+subject]; ok && (attempt.LockedUntil == nil || attempt.LockedUntil.Before(lockedUntil)) {
+		attempt.LockedUntil = &lockedUntil
+	}
+	return nil
+}
+
+func (r *fakeLoginAttemptRepository) Delete(scope, subject string) error {
+	delete(r.attempts, scope+":"+subject)
+	return nil
+}
+
+func newTestLoginThrottleService(clock core.Clock) ILoginThrottleService {
+	env := &core.Env{
+		LoginMaxAttempts:      3,
+		LoginMaxAttemptsPerIP: 5,
+		LoginAttemptWindow:    15 * time.Minute,
+		LoginLockoutBase:      time.Minute,
+		LoginLockoutMax:       5 * time.Minute,
+	}
+	return NewLoginThrottleService(env, newTestLogger(), clock, newFakeLoginAttemptRepository())
+}
+
+func recordFailure(t *testing.T, service ILoginThrottleService, email, ip string) time.Duration {
+	t.Helper()
+	lockout, err := service.RecordFailure(email, ip)
+	if err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+	return lockout
+}
+
+func checkLogin(t *testing.T, service ILoginThrottleService, email, ip string) time.Duration {
+	t.Helper()
+	retryAfter, err := service.Check(email, ip)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	return retryAfter
+}
+
+func TestLoginThrottleService_BackOff(t *testing.T) {
+	clock := newFakeClock()
+	service := newTestLoginThrottleService(clock)
+
+	// every failure from the third on doubles the lockout, up to the max
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, wantLockout := range want {
+		// a new ip every time, only the account counts
+		if got := recordFailure(t, service, "Ana@Example.com ", fmt.Sprintf("10.0.0.%d", i+1)); got != wantLockout {
+			t.Fatalf("failure %d locked out for %v, want %v", i+1, got, wantLockout)
+		}
+		if got := checkLogin(t, service, "ana@example.com", "10.0.1.1"); got != wantLockout {
+			t.Fatalf("after failure %d Check() = %v, want %v", i+1, got, wantLockout)
+		}
+		clock.Advance(wantLockout)
+	}
+}
+
+func TestLoginThrottleService_WindowReset(t *testing.T) {
+	clock := newFakeClock()
+	service := newTestLoginThrottleService(clock)
+
+	recordFailure(t, service, "ana@example.com", "")
+	recordFailure(t, service, "ana@example.com", "")
+	clock.Advance(15*time.Minute + time.Second)
+	// the earlier failures are forgotten
+	if got := recordFailure(t, service, "ana@example.com", ""); got != 0 {
+		t.Fatalf("failure after a quiet window locked out for %v", got)
+	}
+
+	recordFailure(t, service, "ana@example.com", "")
+	if got := recordFailure(t, service, "ana@example.com", ""); got != time.Minute {
+		t.Fatalf("third failure in the window locked out for %v, want 1m", got)
+	}
+	// the window runs from the end of the lockout, not the last failure
+	clock.Advance(15 * time.Minute)
+	if got := recordFailure(t, service, "ana@example.com", ""); got != 2*time.Minute {
+		t.Fatalf("failure within the window after the lockout locked out for %v, want 2m", got)
+	}
+	clock.Advance(2*time.Minute + 15*time.Minute + time.Second)
+	if got := recordFailure(t, service, "ana@example.com", ""); got != 0 {
+		t.Fatalf("failure a window after the lockout locked out for %v", got)
+	}
+}
+
+func TestLoginThrottleService_LimitsPerIPAndAccount(t *testing.T) {
+	clock := newFakeClock()
+	service := newTestLoginThrottleService(clock)
+
+	// one ip guessing a different account every time
+	emails := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+	for i, email := range emails {
+		lockout := recordFailure(t, service, email, "10.0.0.1")
+		if i < len(emails)-1 && lockout != 0 {
+			t.Fatalf("failure %d from the ip locked out for %v", i+1, lockout)
+		}
+		if i == len(emails)-1 && lockout != time.Minute {
+			t.Fatalf("fifth failure from the ip locked out for %v, want 1m", lockout)
+		}
+	}
+	if got := checkLogin(t, service, "fresh@example.com", "10.0.0.1"); got != time.Minute {
+		t.Fatalf("Check() from the locked ip = %v, want 1m", got)
+	}
+	if got := checkLogin(t, service, "a@example.com", "10.0.0.2"); got != 0 {
+		t.Fatalf("Check() of a guessed account from another ip = %v, want 0", got)
+	}
+
+	// signing in clears the account, the ip stays locked
+	if err := service.RecordSuccess("a@example.com"); err != nil {
+		t.Fatalf("RecordSuccess() error = %v", err)
+	}
+	if got := checkLogin(t, service, "a@example.com", "10.0.0.1"); got != time.Minute {
+		t.Fatalf("Check() after a success from the locked ip = %v, want 1m", got)
+	}
+
+	if err := service.Unlock("", "10.0.0.1"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if got := checkLogin(t, service, "a@example.com", "10.0.0.1"); got != 0 {
+		t.Fatalf("Check() after Unlock() = %v, want 0", got)
+	}
+}
+
+func TestLoginThrottleService_CheckWaitsForTheLongestLockout(t *testing.T) {
+	clock := newFakeClock()
+	service := newTestLoginThrottleService(clock)
+
+	// the account is locked for 2m, the ip for 1m
+	for i := 0; i < 4; i++ {
+		recordFailure(t, service, "ana@example.com", "10.0.0.1")
+	}
+	recordFailure(t, service, "ben@example.com", "10.0.0.1")
+
+	clock.Advance(30 * time.Second)
+	if got := checkLogin(t, service, "ana@example.com", "10.0.0.1"); got != 90*time.Second {
+		t.Fatalf("Check() = %v, want the 90s left on the account", got)
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"path"
+	texttemplate "text/template"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+)
+
+//go:embed templates/mail
+var mailTemplateFS embed.FS
+
+var mailLocales = []string{models.LocaleEnglish, models.LocaleVietnamese}
+
+const verificationEmailTemplate = "verification_email"
+
+type IMailService interface {
+	SendVerificationEmail(userID string) error
+}
+
+type localizedMailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// MailService sends mails in the user's language through the job outbox. Only the user id is
+// queued, the mail is rendered when the job runs.
+type MailService struct {
+	env         *core.Env
+	logger      *core.Logger
+	mailer      core.Mailer
+	authService IAuthService
+	jobService  IJobService
+	templates   map[string]map[string]localizedMailTemplate
+}
+
+// NewMailService creates a new mail service, it panics when the embedded templates are broken
+func NewMailService(
+	env *core.Env,
+	logger *core.Logger,
+	mailer core.Mailer,
+	authService IAuthService,
+	jobService IJobService,
+) IMailService {
+	templates := make(map[string]map[string]localizedMailTemplate)
+	for _, locale := range mailLocales {
+		templates[locale] = make(map[string]localizedMailTemplate)
+		for _, name := range []string{verificationEmailTemplate} {
+			base := path.Join("templates/mail", locale, name)
+			templates[locale][name] = localizedMailTemplate{
+				text: texttemplate.Must(texttemplate.ParseFS(mailTemplateFS, base+".txt")),
+				html: htmltemplate.Must(htmltemplate.ParseFS(mailTemplateFS, base+".html")),
+			}
+		}
+	}
+
+	s := &MailService{
+		env:         env,
+		logger:      logger,
+		mailer:      mailer,
+		authService: authService,
+		jobService:  jobService,
+		templates:   templates,
+	}
+
+	jobService.Register(JobTypeSendVerificationEmail, func(ctx context.Context, payload []byte) error {
+		var verification models.VerificationEmailPayload
+		if err := json.Unmarshal(payload, &verification); err != nil {
+			return err
+		}
+		return s.deliverVerificationEmail(ctx, verification.UserID)
+	})
+
+	return s
+}
+
+// SendVerificationEmail queues the verification code mail, delivery happens in the background
+func (s *MailService) SendVerificationEmail(userID string) error {
+	return s.jobService.Enqueue(JobTypeSendVerificationEmail, models.VerificationEmailPayload{UserID: userID})
+}
+
+// ----------------- private -----------------
+
+// deliverVerificationEmail issues a new code and mails it, users verified or erased in the
+// meantime are skipped
+func (s *MailService) deliverVerificationEmail(ctx context.Context, userID string) error {
+	user, code, err := s.authService.IssueVerificationCode(userID)
+	if errors.Is(err, ErrEmailAlreadyVerified) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mail, err := s.render(*user, verificationEmailTemplate, map[string]interface{}{
+		"Code":             code,
+		"ExpiresInMinutes": int(s.env.EmailVerificationExpiresIn.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, *mail)
+}
+
+func (s *MailService) render(user models.User, name string, data interface{}) (*core.Mail, error) {
+	byName, ok := s.templates[user.Locale]
+	if !ok {
+		byName = s.templates[models.DefaultLocale]
+	}
+
+	tmpl, ok := byName[name]
+	if !ok {
+		return nil, fmt.Errorf("mail template %s not found", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "body", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return &core.Mail{
+		To:      []string{user.Email},
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
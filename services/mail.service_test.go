@@ -0,0 +1,100 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+var mailedCodePattern = regexp.MustCompile(`(?m)^\s+([A-Z2-7]{10})$`)
+
+func mailedCode(t *testing.T, mail core.Mail) string {
+	t.Helper()
+	match := mailedCodePattern.FindStringSubmatch(mail.Text)
+	if match == nil {
+		t.Fatalf("no verification code in mail:\n%s", mail.Text)
+	}
+	return match[1]
+}
+
+func TestMailService_VerificationEmailQueuesOnlyTheUserID(t *testing.T) {
+	clock := newFakeClock()
+	env := &core.Env{EmailVerificationExpiresIn: 15 * time.Minute}
+	users := newFakeUserRepository()
+	jobs := newFakeJobService(clock)
+	mailer := core.NewMemoryMailer()
+	authService := NewAuthService(env, newTestLogger(), clock, users)
+	mailService := NewMailService(env, newTestLogger(), mailer, authService, jobs)
+
+	user, err := authService.Register(models.RegisterRequest{Email: "Ana@Example.com", Password: "secret-password"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err = mailService.SendVerificationEmail(user.ID); err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+
+	queued := jobs.Queued(JobTypeSendVerificationEmail)
+	if len(queued) != 1 {
+		t.Fatalf("queued %d verification mails, want 1", len(queued))
+	}
+	if want := `{"user_id":"` + user.ID + `"}`; queued[0].Payload != want {
+		t.Fatalf("payload = %s, want %s", queued[0].Payload, want)
+	}
+	if users.users[user.ID].VerificationCode != "" {
+		t.Fatal("a code was issued before the mail was sent")
+	}
+
+	clock.Advance(10 * time.Minute)
+	if errs := jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+
+	sent := mailer.Sent()
+	if len(sent) != 1 || len(sent[0].To) != 1 || sent[0].To[0] != "ana@example.com" {
+		t.Fatalf("sent = %+v, want one mail to ana@example.com", sent)
+	}
+	code := mailedCode(t, sent[0])
+	if strings.Contains(users.users[user.ID].VerificationCode, code) {
+		t.Fatal("the plaintext code is stored")
+	}
+
+	// the code expires from when it was mailed, not from the registration
+	clock.Advance(14 * time.Minute)
+	if err = authService.VerifyEmail("ana@example.com", code, ""); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+}
+
+func TestMailService_VerificationEmailSkipsVerifiedUsers(t *testing.T) {
+	clock := newFakeClock()
+	env := &core.Env{EmailVerificationExpiresIn: 15 * time.Minute}
+	users := newFakeUserRepository()
+	jobs := newFakeJobService(clock)
+	mailer := core.NewMemoryMailer()
+	authService := NewAuthService(env, newTestLogger(), clock, users)
+	mailService := NewMailService(env, newTestLogger(), mailer, authService, jobs)
+
+	user, err := authService.Register(models.RegisterRequest{Email: "ana@example.com", Password: "secret-password"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err = mailService.SendVerificationEmail(user.ID); err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+	if err = mailService.SendVerificationEmail("erased-user"); err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+	_, _ = users.MarkEmailVerified(user.ID, "")
+
+	if errs := jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Fatalf("sent %d mails, want none", len(sent))
+	}
+}
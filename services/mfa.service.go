@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMFAIssuer = "Winglets"
+	mfaRecoveryCodes = 10
+	mfaTOTPSkewSteps = 1
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor enrolment has not been started")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFACodeInvalid    = errors.New("invalid two-factor code")
+)
+
+type IMFAService interface {
+	IsEnabled(userID string) (bool, error)
+	BeginEnrolment(userID, account string) (string, string, error)
+	ConfirmEnrolment(userID, code string) ([]string, error)
+	Verify(userID, code string) error
+	Reset(userID string) error
+}
+
+// MFAService manages TOTP two-factor authentication and its single-use recovery codes
+type MFAService struct {
+	logger     *core.Logger
+	clock      core.Clock
+	repository repositories.IMFARepository
+	issuer     string
+}
+
+// NewMFAService creates a new mfa service
+func NewMFAService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.IMFARepository,
+) IMFAService {
+	issuer := env.MFAIssuer
+	if issuer == "" {
+		issuer = defaultMFAIssuer
+	}
+
+	return &MFAService{
+		logger:     logger,
+		clock:      clock,
+		repository: repository,
+		issuer:     issuer,
+	}
+}
+
+// IsEnabled reports whether the user has to pass a second factor to sign in
+func (s *MFAService) IsEnabled(userID string) (bool, error) {
+	totp, err := s.repository.FirstTOTP(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.IsConfirmed(), nil
+}
+
+// BeginEnrolment stores a new unconfirmed secret and returns it with its otpauth uri.
+// Starting over replaces a previous unconfirmed secret.
+func (s *MFAService) BeginEnrolment(userID, account string) (string, string, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err = s.repository.SaveTOTP(models.UserTOTP{
+		UserID: userID,
+		Secret: secret,
+	}); err != nil {
+		return "", "", err
+	}
+
+	return secret, utils.TOTPURI(s.issuer, account, secret), nil
+}
+
+// ConfirmEnrolment enables two-factor authentication once the user proves the authenticator
+// app works, and returns the plaintext recovery codes. They are shown this one time only.
+func (s *MFAService) ConfirmEnrolment(userID, code string) ([]string, error) {
+	totp, err := s.repository.FirstTOTP(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	if totp.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	now := s.clock.Now()
+	step, ok := utils.ValidateTOTP(totp.Secret, strings.TrimSpace(code), now, mfaTOTPSkewSteps)
+	if !ok {
+		return nil, ErrMFACodeInvalid
+	}
+
+	codes := make([]string, 0, mfaRecoveryCodes)
+	hashes := make([]string, 0, mfaRecoveryCodes)
+	for i := 0; i < mfaRecoveryCodes; i++ {
+		recoveryCode, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, recoveryCode)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	if err = s.repository.ConfirmTOTP(userID, step, now, hashes); err != nil {
+		return nil, err
+	}
+
+	s.logger.Warnw("security event",
+		"event", "mfa_enabled",
+		"user_id", userID,
+	)
+	return codes, nil
+}
+
+// Verify accepts a current authenticator code, each one only once, or an unused recovery code
+func (s *MFAService) Verify(userID, code string) error {
+	totp, err := s.repository.FirstTOTP(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+	if !totp.IsConfirmed() {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := utils.ValidateTOTP(totp.Secret, code, s.clock.Now(), mfaTOTPSkewSteps); ok {
+		used, err := s.repository.UseTOTPStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrMFACodeInvalid
+		}
+		return nil
+	}
+
+	used, err := s.repository.UseRecoveryCode(userID, utils.HashToken(normalizeRecoveryCode(code)), s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrMFACodeInvalid
+	}
+
+	remaining, err := s.repository.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		s.logger.Errorf("fail to count recovery codes, user [%v], error [%v]", userID, err)
+	}
+	s.logger.Warnw("security event",
+		"event", "mfa_recovery_code_used",
+		"user_id", userID,
+		"remaining", remaining,
+	)
+	return nil
+}
+
+// Reset turns two-factor authentication off, used by admins for users who lost their device
+func (s *MFAService) Reset(userID string) error {
+	if err := s.repository.Delete(userID); err != nil {
+		return err
+	}
+
+	s.logger.Warnw("security event",
+		"event", "mfa_reset",
+		"user_id", userID,
+	)
+	return nil
+}
+
+// ----------------- private -----------------
+
+// newRecoveryCode returns a 50 bit code like "k7q2m-xp4ra"
+func newRecoveryCode() (string, error) {
+	randomBytes := make([]byte, 10)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.EncodeToString(randomBytes))
+	return encoded[:5] + "-" + encoded[5:10], nil
+}
+
+// normalizeRecoveryCode makes the comparison forgiving about case, spaces and the dash
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
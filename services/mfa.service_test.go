@@ -0,0 +1,262 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+)
+
+// fakeMFARepository keeps one user's secret and recovery codes with the conditional updates of
+// MFARepository
+type fakeMFARepository struct {
+	totp          *models.UserTOTP
+	recoveryCodes []models.MFARecoveryCode
+}
+
+func (r *fakeMFARepository) FirstTOTP(userID string) (*models.UserTOTP, error) {
+	if r.totp == nil || r.totp.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *r.totp
+	return &found, nil
+}
+
+func (r *fakeMFARepository) SaveTOTP(totp models.UserTOTP) error {
+	r.totp = &totp
+	return nil
+}
+
+func (r *fakeMFARepository) ConfirmTOTP(userID string, step int64, confirmedAt time.Time, recoveryCodeHashes []string) error {
+	r.totp.ConfirmedAt = &confirmedAt
+	r.totp.LastUsedStep = step
+	r.recoveryCodes = nil
+	for _, hash := range recoveryCodeHashes {
+		r.recoveryCodes = append(r.recoveryCodes, models.MFARecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	return nil
+}
+
+func (r *fakeMFARepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	if r.totp == nil || r.totp.LastUsedStep >= step {
+		return false, nil
+	}
+	r.totp.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(userID, codeHash string, usedAt time.Time) (bool, error) {
+	for i, code := range r.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			r.recoveryCodes[i].UsedAt = &usedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeMFARepository) CountUnusedRecoveryCodes(userID string) (int64, error) {
+	var count int64
+	for _, code := range r.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeMFARepository) Delete(userID string) error {
+	r.totp, r.recoveryCodes = nil, nil
+	return nil
+}
+
+// enrolTestMFA enables two-factor authentication for ana at the clock's time and returns the
+// secret with the recovery codes
+func enrolTestMFA(t *testing.T, service IMFAService, clock core.Clock) (string, []string) {
+	t.Helper()
+	secret, uri, err := service.BeginEnrolment("ana", "ana@example.com")
+	if err != nil {
+		t.Fatalf("BeginEnrolment() error = %v", err)
+	}
+	if !strings.HasPrefix(uri, "otpauth://totp/Winglets:ana@example.com?") {
+		t.Fatalf("uri = %s", uri)
+	}
+	codes, err := service.ConfirmEnrolment("ana", totpCodeAt(t, secret, utils.TOTPStep(clock.Now())))
+	if err != nil {
+		t.Fatalf("ConfirmEnrolment() error = %v", err)
+	}
+	return secret, codes
+}
+
+func totpCodeAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestMFAService_Enrolment(t *testing.T) {
+	clock := newFakeClock()
+	service := NewMFAService(&core.Env{}, newTestLogger(), clock, &fakeMFARepository{})
+
+	if _, err := service.ConfirmEnrolment("ana", "123456"); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Fatalf("ConfirmEnrolment() before BeginEnrolment error = %v, want %v", err, ErrMFANotEnrolled)
+	}
+
+	secret, _, err := service.BeginEnrolment("ana", "ana@example.com")
+	if err != nil {
+		t.Fatalf("BeginEnrolment() error = %v", err)
+	}
+	if enabled, _ := service.IsEnabled("ana"); enabled {
+		t.Fatal("enabled before the enrolment was confirmed")
+	}
+	if _, err = service.ConfirmEnrolment("ana", totpCodeAt(t, secret, utils.TOTPStep(clock.Now())-2)); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("ConfirmEnrolment() with an old code error = %v, want %v", err, ErrMFACodeInvalid)
+	}
+
+	codes, err := service.ConfirmEnrolment("ana", " "+totpCodeAt(t, secret, utils.TOTPStep(clock.Now()))+" ")
+	if err != nil {
+		t.Fatalf("ConfirmEnrolment() error = %v", err)
+	}
+	if len(codes) != mfaRecoveryCodes {
+		t.Fatalf("%d recovery codes, want %d", len(codes), mfaRecoveryCodes)
+	}
+	if enabled, _ := service.IsEnabled("ana"); !enabled {
+		t.Fatal("not enabled after the enrolment was confirmed")
+	}
+	if _, _, err = service.BeginEnrolment("ana", "ana@example.com"); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Fatalf("BeginEnrolment() once enabled error = %v, want %v", err, ErrMFAAlreadyEnabled)
+	}
+}
+
+func TestMFAService_VerifySkewWindow(t *testing.T) {
+	clock := newFakeClock()
+	service := NewMFAService(&core.Env{}, newTestLogger(), clock, &fakeMFARepository{})
+	secret, _ := enrolTestMFA(t, service, clock)
+
+	clock.Advance(4 * utils.TOTPPeriod)
+	current := utils.TOTPStep(clock.Now())
+
+	tests := []struct {
+		name string
+		step int64
+		want error
+	}{
+		{"two steps back", current - 2, ErrMFACodeInvalid},
+		{"one step back", current - 1, nil},
+		{"one step ahead", current + 1, nil},
+		{"two steps ahead", current + 2, ErrMFACodeInvalid},
+	}
+	for _, tt := range tests {
+		if err := service.Verify("ana", totpCodeAt(t, secret, tt.step)); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: Verify() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestMFAService_VerifyRefusesReplay(t *testing.T) {
+	clock := newFakeClock()
+	service := NewMFAService(&core.Env{}, newTestLogger(), clock, &fakeMFARepository{})
+	secret, _ := enrolTestMFA(t, service, clock)
+	current := utils.TOTPStep(clock.Now())
+
+	// the code that confirmed the enrolment is used up
+	if err := service.Verify("ana", totpCodeAt(t, secret, current)); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("Verify() with the enrolment code error = %v, want %v", err, ErrMFACodeInvalid)
+	}
+
+	clock.Advance(utils.TOTPPeriod)
+	code := totpCodeAt(t, secret, current+1)
+	if err := service.Verify("ana", code); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err := service.Verify("ana", code); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("Verify() of the same code again error = %v, want %v", err, ErrMFACodeInvalid)
+	}
+	// an earlier step is still in the skew window, but a later one was used
+	if err := service.Verify("ana", totpCodeAt(t, secret, current)); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("Verify() of an earlier step error = %v, want %v", err, ErrMFACodeInvalid)
+	}
+}
+
+func TestMFAService_RecoveryCodeWorksOnce(t *testing.T) {
+	clock := newFakeClock()
+	repository := &fakeMFARepository{}
+	service := NewMFAService(&core.Env{}, newTestLogger(), clock, repository)
+	_, codes := enrolTestMFA(t, service, clock)
+
+	// case, spaces and the dash don't matter
+	typed := strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))
+	if err := service.Verify("ana", typed); err != nil {
+		t.Fatalf("Verify() with a recovery code error = %v", err)
+	}
+	if err := service.Verify("ana", codes[0]); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("Verify() with a used recovery code error = %v, want %v", err, ErrMFACodeInvalid)
+	}
+	if remaining, _ := repository.CountUnusedRecoveryCodes("ana"); remaining != mfaRecoveryCodes-1 {
+		t.Fatalf("%d recovery codes left, want %d", remaining, mfaRecoveryCodes-1)
+	}
+	if err := service.Verify("ana", "aaaaa-bbbbb"); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("Verify() with an unknown recovery code error = %v, want %v", err, ErrMFACodeInvalid)
+	}
+}
+
+func TestMFAService_VerifyWithoutMFA(t *testing.T) {
+	clock := newFakeClock()
+	service := NewMFAService(&core.Env{}, newTestLogger(), clock, &fakeMFARepository{})
+
+	if err := service.Verify("ana", "123456"); !errors.Is(err, ErrMFANotEnabled) {
+		t.Fatalf("Verify() without a secret error = %v, want %v", err, ErrMFANotEnabled)
+	}
+	if _, _, err := service.BeginEnrolment("ana", "ana@example.com"); err != nil {
+		t.Fatalf("BeginEnrolment() error = %v", err)
+	}
+	if err := service.Verify("ana", "123456"); !errors.Is(err, ErrMFANotEnabled) {
+		t.Fatalf("Verify() before the enrolment was confirmed error = %v, want %v", err, ErrMFANotEnabled)
+	}
+
+	enrolTestMFA(t, service, clock)
+	if err := service.Reset("ana"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if enabled, _ := service.IsEnabled("ana"); enabled {
+		t.Fatal("enabled after Reset()")
+	}
+}
+
+func TestAuthService_AuthorizeRefusesMFAPendingToken(t *testing.T) {
+	users := newFakeUserRepository()
+	// jwt-go checks exp against the wall clock
+	service := newTestAuthService(core.NewClock(), users)
+	user, _ := users.Create(models.User{Email: "ana@example.com", VerificationStatus: 1})
+
+	pending, _, err := service.GenerateMFAPendingToken(*user)
+	if err != nil {
+		t.Fatalf("GenerateMFAPendingToken() error = %v", err)
+	}
+	if _, err = service.Authorize(pending); err == nil {
+		t.Fatal("Authorize() accepted an mfa pending token")
+	}
+	claim, err := service.AuthorizeMFAPending(pending)
+	if err != nil {
+		t.Fatalf("AuthorizeMFAPending() error = %v", err)
+	}
+	if claim.UserID != user.ID {
+		t.Fatalf("AuthorizeMFAPending() user = %s, want %s", claim.UserID, user.ID)
+	}
+
+	access, _, _, _, err := service.GenerateJWTTokens(*user)
+	if err != nil {
+		t.Fatalf("GenerateJWTTokens() error = %v", err)
+	}
+	if _, err = service.AuthorizeMFAPending(access); err == nil {
+		t.Fatal("AuthorizeMFAPending() accepted a session token")
+	}
+}
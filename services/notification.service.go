@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+const defaultNotificationDedupWindow = 24 * time.Hour
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+type INotificationService interface {
+	RegisterDevice(userID string, request models.DeviceRequest) error
+	UnregisterDevice(userID, token string) error
+	GetPreferences(userID string) (*models.NotificationPreference, error)
+	UpdatePreferences(userID string, request models.NotificationPreferenceRequest) (*models.NotificationPreference, error)
+	NotifyMatch(userID, matchedID string) error
+	NotifyLike(userID, likerID string) error
+}
+
+// NotificationService pushes what happens to a user to their devices. Notifications the user
+// turned off are dropped, the same event is pushed once per dedup window and pushes falling in
+// the quiet hours wait for their end.
+type NotificationService struct {
+	logger      *core.Logger
+	clock       core.Clock
+	repository  repositories.INotificationRepository
+	profileRepo repositories.IProfileRepository
+	jobService  IJobService
+	pushSender  core.PushSender
+	dedupWindow time.Duration
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.INotificationRepository,
+	profileRepo repositories.IProfileRepository,
+	jobService IJobService,
+	pushSender core.PushSender,
+) INotificationService {
+	s := &NotificationService{
+		logger:      logger,
+		clock:       clock,
+		repository:  repository,
+		profileRepo: profileRepo,
+		jobService:  jobService,
+		pushSender:  pushSender,
+		dedupWindow: env.NotificationDedupWindow,
+	}
+
+	if s.dedupWindow <= 0 {
+		s.dedupWindow = defaultNotificationDedupWindow
+	}
+
+	jobService.Register(JobTypeSendNotification, func(ctx context.Context, payload []byte) error {
+		var notification models.NotificationPayload
+		if err := json.Unmarshal(payload, &notification); err != nil {
+			return err
+		}
+		return s.deliver(ctx, notification.NotificationID)
+	})
+
+	return s
+}
+
+func (s *NotificationService) RegisterDevice(userID string, request models.DeviceRequest) error {
+	return s.repository.SaveDevice(models.Device{
+		UserID:   userID,
+		Platform: request.Platform,
+		Token:    request.Token,
+	})
+}
+
+func (s *NotificationService) UnregisterDevice(userID, token string) error {
+	deleted, err := s.repository.DeleteDevice(userID, token)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// GetPreferences returns the saved preferences, or the defaults
+func (s *NotificationService) GetPreferences(userID string) (*models.NotificationPreference, error) {
+	preference, err := s.repository.FirstPreference(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreference(userID), nil
+	}
+	return preference, err
+}
+
+// UpdatePreferences changes the settings present in the request
+func (s *NotificationService) UpdatePreferences(userID string, request models.NotificationPreferenceRequest) (*models.NotificationPreference, error) {
+	preference, err := s.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.NewMatches != nil {
+		preference.NewMatches = *request.NewMatches
+	}
+	if request.NewLikes != nil {
+		preference.NewLikes = *request.NewLikes
+	}
+	if request.QuietHoursStart != nil {
+		if err = validateQuietHour("quiet_hours_start", *request.QuietHoursStart); err != nil {
+			return nil, err
+		}
+		preference.QuietHoursStart = *request.QuietHoursStart
+	}
+	if request.QuietHoursEnd != nil {
+		if err = validateQuietHour("quiet_hours_end", *request.QuietHoursEnd); err != nil {
+			return nil, err
+		}
+		preference.QuietHoursEnd = *request.QuietHoursEnd
+	}
+	if request.TimeZone != nil && *request.TimeZone != "" {
+		preference.TimeZone = *request.TimeZone
+	}
+
+	if (preference.QuietHoursStart == "") != (preference.QuietHoursEnd == "") {
+		field := "quiet_hours_end"
+		if preference.QuietHoursStart == "" {
+			field = "quiet_hours_start"
+		}
+		return nil, &FieldError{Field: field, Message: "quiet hours need both a start and an end"}
+	}
+
+	return s.repository.SavePreference(*preference)
+}
+
+// NotifyMatch tells the user they matched with matchedID
+func (s *NotificationService) NotifyMatch(userID, matchedID string) error {
+	matched, err := s.profileRepo.GetProfileById(matchedID)
+	if err != nil {
+		return err
+	}
+	return s.notify(userID, models.NotificationKindNewMatch, "match:"+matchedID,
+		"It's a match!",
+		fmt.Sprintf("You and %s liked each other.", matched.Name),
+		map[string]string{"kind": models.NotificationKindNewMatch, "user_id": matchedID})
+}
+
+// NotifyLike tells the user someone liked them, without saying who
+func (s *NotificationService) NotifyLike(userID, likerID string) error {
+	return s.notify(userID, models.NotificationKindNewLike, "like:"+likerID,
+		"Someone liked you",
+		"Keep swiping to find out who.",
+		map[string]string{"kind": models.NotificationKindNewLike})
+}
+
+// ----------------- private -----------------
+
+func (s *NotificationService) notify(userID, kind, dedupKey, title, body string, data map[string]string) error {
+	preference, err := s.GetPreferences(userID)
+	if err != nil {
+		return err
+	}
+	if !preference.Allows(kind) {
+		return nil
+	}
+
+	now := s.clock.Now()
+	recent, err := s.repository.HasRecent(userID, dedupKey, now.Add(-s.dedupWindow))
+	if err != nil || recent {
+		return err
+	}
+
+	devices, err := s.repository.FindDevices(userID)
+	if err != nil || len(devices) == 0 {
+		return err
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	sendAt := now
+	if until, quiet := preference.QuietUntil(now); quiet {
+		sendAt = until
+	}
+
+	notification := &models.Notification{
+		UserID:    userID,
+		Kind:      kind,
+		DedupKey:  dedupKey,
+		Title:     title,
+		Body:      body,
+		Data:      string(encoded),
+		SendAt:    sendAt,
+		CreatedAt: now,
+	}
+	if err = s.repository.Create(notification); err != nil {
+		return err
+	}
+	return s.jobService.EnqueueAt(JobTypeSendNotification, models.NotificationPayload{NotificationID: notification.ID}, sendAt)
+}
+
+// validateQuietHour accepts HH:MM, or empty to turn the quiet hours off
+func validateQuietHour(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", value); err != nil {
+		return &FieldError{Field: field, Message: "quiet hours are written HH:MM"}
+	}
+	return nil
+}
+
+// deliver pushes the notification to every device of the user. Tokens the push service
+// dropped are forgotten, the job is retried only when no device got the push.
+func (s *NotificationService) deliver(ctx context.Context, id uint) error {
+	notification, err := s.repository.First(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if notification.SentAt != nil {
+		return nil
+	}
+
+	var data map[string]string
+	if notification.Data != "" {
+		if err = json.Unmarshal([]byte(notification.Data), &data); err != nil {
+			return err
+		}
+	}
+
+	devices, err := s.repository.FindDevices(notification.UserID)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	failed, forgotten := 0, 0
+	for _, device := range devices {
+		err = s.pushSender.Send(ctx, core.Push{
+			Platform:    device.Platform,
+			Token:       device.Token,
+			Title:       notification.Title,
+			Body:        notification.Body,
+			Data:        data,
+			CollapseKey: notification.Kind,
+		})
+		if errors.Is(err, core.ErrPushTokenInvalid) {
+			s.logger.Infof("forgetting invalid push token, device [%v], user [%v]", device.ID, device.UserID)
+			if err = s.repository.DeleteDeviceByToken(device.Token); err != nil {
+				s.logger.Errorf("fail to delete device [%v]: [%v]", device.ID, err)
+			}
+			forgotten++
+			continue
+		}
+		if err != nil {
+			s.logger.Errorf("fail to push notification [%v] to device [%v]: [%v]", id, device.ID, err)
+			lastErr = err
+			failed++
+		}
+	}
+	if failed > 0 && failed+forgotten == len(devices) {
+		return lastErr
+	}
+
+	return s.repository.MarkSent(id, s.clock.Now())
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+// fakeNotificationRepository keeps devices, preferences and notifications in memory
+type fakeNotificationRepository struct {
+	repositories.INotificationRepository
+	mu            sync.Mutex
+	devices       []models.Device
+	preferences   map[string]models.NotificationPreference
+	notifications []models.Notification
+}
+
+func newFakeNotificationRepository() *fakeNotificationRepository {
+	return &fakeNotificationRepository{preferences: make(map[string]models.NotificationPreference)}
+}
+
+func (r *fakeNotificationRepository) FindDevices(userID string) ([]models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []models.Device
+	for _, device := range r.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (r *fakeNotificationRepository) DeleteDeviceByToken(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var kept []models.Device
+	for _, device := range r.devices {
+		if device.Token != token {
+			kept = append(kept, device)
+		}
+	}
+	r.devices = kept
+	return nil
+}
+
+func (r *fakeNotificationRepository) FirstPreference(userID string) (*models.NotificationPreference, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	preference, ok := r.preferences[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &preference, nil
+}
+
+func (r *fakeNotificationRepository) HasRecent(userID, dedupKey string, since time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, notification := range r.notifications {
+		if notification.UserID == userID && notification.DedupKey == dedupKey && !notification.CreatedAt.Before(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeNotificationRepository) Create(notification *models.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notification.ID = uint(len(r.notifications) + 1)
+	r.notifications = append(r.notifications, *notification)
+	return nil
+}
+
+func (r *fakeNotificationRepository) First(id uint) (*models.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, notification := range r.notifications {
+		if notification.ID == id {
+			return &notification, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeNotificationRepository) MarkSent(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.notifications {
+		if r.notifications[i].ID == id {
+			r.notifications[i].SentAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *fakeNotificationRepository) forUser(userID string) []models.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notifications []models.Notification
+	for _, notification := range r.notifications {
+		if notification.UserID == userID {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications
+}
+
+// fakePushSender records pushes, tokens in invalid are unknown to the push service and
+// tokens in failing can't be reached
+type fakePushSender struct {
+	mu      sync.Mutex
+	pushes  []core.Push
+	invalid map[string]bool
+	failing map[string]bool
+}
+
+func (s *fakePushSender) Send(ctx context.Context, push core.Push) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.invalid[push.Token] {
+		return core.ErrPushTokenInvalid
+	}
+	if s.failing[push.Token] {
+		return errors.New("push service unavailable")
+	}
+	s.pushes = append(s.pushes, push)
+	return nil
+}
+
+type notificationFixture struct {
+	clock      *fakeClock
+	repository *fakeNotificationRepository
+	jobs       *fakeJobService
+	sender     *fakePushSender
+	service    INotificationService
+}
+
+func newNotificationFixture() *notificationFixture {
+	clock := newFakeClock()
+	f := &notificationFixture{
+		clock:      clock,
+		repository: newFakeNotificationRepository(),
+		jobs:       newFakeJobService(clock),
+		sender:     &fakePushSender{invalid: map[string]bool{}, failing: map[string]bool{}},
+	}
+	f.repository.devices = []models.Device{
+		{ID: 1, UserID: "ana", Platform: core.PushPlatformIOS, Token: "ana-phone"},
+		{ID: 2, UserID: "ana", Platform: core.PushPlatformAndroid, Token: "ana-tablet"},
+		{ID: 3, UserID: "ben", Platform: core.PushPlatformAndroid, Token: "ben-phone"},
+	}
+	profiles := &fakeProfileRepository{profiles: map[string]models.Profile{
+		"ana": {ID: "ana", Name: "Ana"},
+		"ben": {ID: "ben", Name: "Ben"},
+	}}
+	f.service = NewNotificationService(&core.Env{NotificationDedupWindow: time.Hour}, newTestLogger(), clock,
+		f.repository, profiles, f.jobs, f.sender)
+	return f
+}
+
+func TestNotificationService_QuietHours(t *testing.T) {
+	// testNow is 10:00 UTC
+	tests := []struct {
+		name       string
+		start, end string
+		timeZone   string
+		wantSendAt time.Time
+	}{
+		{"no quiet hours", "", "", "UTC", testNow},
+		{"outside the quiet hours", "22:00", "07:00", "UTC", testNow},
+		{"inside the quiet hours", "09:00", "11:30", "UTC", testNow.Add(90 * time.Minute)},
+		// 10:00 UTC is 03:00 in Los Angeles
+		{"across midnight in the user's time zone", "22:00", "07:00", "America/Los_Angeles", testNow.Add(4 * time.Hour)},
+		{"ends at the hour", "10:00", "10:01", "UTC", testNow.Add(time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newNotificationFixture()
+			f.repository.preferences["ana"] = models.NotificationPreference{
+				UserID: "ana", NewMatches: true, NewLikes: true,
+				QuietHoursStart: tt.start, QuietHoursEnd: tt.end, TimeZone: tt.timeZone,
+			}
+
+			if err := f.service.NotifyLike("ana", "ben"); err != nil {
+				t.Fatalf("NotifyLike() error = %v", err)
+			}
+
+			queued := f.jobs.Queued(JobTypeSendNotification)
+			if len(queued) != 1 {
+				t.Fatalf("%d pushes queued, want 1", len(queued))
+			}
+			if !queued[0].RunAt.Equal(tt.wantSendAt) {
+				t.Fatalf("push runs at %v, want %v", queued[0].RunAt, tt.wantSendAt)
+			}
+
+			// nothing goes out before the quiet hours end
+			if errs := f.jobs.RunDue(t); len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			if tt.wantSendAt.After(testNow) {
+				if len(f.sender.pushes) != 0 {
+					t.Fatalf("%d pushes sent during the quiet hours", len(f.sender.pushes))
+				}
+				f.clock.Advance(tt.wantSendAt.Sub(testNow))
+				if errs := f.jobs.RunDue(t); len(errs) > 0 {
+					t.Fatal(errs)
+				}
+			}
+			if len(f.sender.pushes) != 2 {
+				t.Fatalf("%d pushes sent, want one per device", len(f.sender.pushes))
+			}
+		})
+	}
+}
+
+func TestNotificationService_Dedup(t *testing.T) {
+	f := newNotificationFixture()
+
+	for i := 0; i < 3; i++ {
+		if err := f.service.NotifyMatch("ana", "ben"); err != nil {
+			t.Fatalf("NotifyMatch() error = %v", err)
+		}
+	}
+	if got := len(f.repository.forUser("ana")); got != 1 {
+		t.Fatalf("%d match notifications within the window, want 1", got)
+	}
+
+	// another event is not a duplicate
+	if err := f.service.NotifyLike("ana", "ben"); err != nil {
+		t.Fatalf("NotifyLike() error = %v", err)
+	}
+	if got := len(f.repository.forUser("ana")); got != 2 {
+		t.Fatalf("%d notifications, want the like on top of the match", got)
+	}
+
+	// the window includes its edge
+	f.clock.Advance(time.Hour)
+	if err := f.service.NotifyMatch("ana", "ben"); err != nil {
+		t.Fatalf("NotifyMatch() error = %v", err)
+	}
+	if got := len(f.repository.forUser("ana")); got != 2 {
+		t.Fatalf("%d notifications at the end of the window, want 2", got)
+	}
+
+	// and is over right after it
+	f.clock.Advance(time.Second)
+	if err := f.service.NotifyMatch("ana", "ben"); err != nil {
+		t.Fatalf("NotifyMatch() error = %v", err)
+	}
+	if got := len(f.repository.forUser("ana")); got != 3 {
+		t.Fatalf("%d notifications after the window, want 3", got)
+	}
+}
+
+func TestNotificationService_RespectsPreferences(t *testing.T) {
+	f := newNotificationFixture()
+	f.repository.preferences["ana"] = models.NotificationPreference{UserID: "ana", NewMatches: true, NewLikes: false, TimeZone: "UTC"}
+
+	if err := f.service.NotifyLike("ana", "ben"); err != nil {
+		t.Fatalf("NotifyLike() error = %v", err)
+	}
+	if err := f.service.NotifyMatch("ana", "ben"); err != nil {
+		t.Fatalf("NotifyMatch() error = %v", err)
+	}
+
+	notifications := f.repository.forUser("ana")
+	if len(notifications) != 1 || notifications[0].Kind != models.NotificationKindNewMatch {
+		t.Fatalf("notifications = %+v, want the match only", notifications)
+	}
+	if notifications[0].Body != "You and Ben liked each other." {
+		t.Fatalf("body = %q", notifications[0].Body)
+	}
+}
+
+func TestNotificationService_DeliverForgetsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name        string
+		invalid     []string
+		failing     []string
+		wantErr     bool
+		wantSent    bool
+		wantDevices []string
+	}{
+		{"every device reached", nil, nil, false, true, []string{"ana-phone", "ana-tablet"}},
+		{"invalid token is forgotten", []string{"ana-tablet"}, nil, false, true, []string{"ana-phone"}},
+		{"every token invalid", []string{"ana-phone", "ana-tablet"}, nil, false, true, nil},
+		{"one device unreachable", nil, []string{"ana-phone"}, false, true, []string{"ana-phone", "ana-tablet"}},
+		{"no device reached is retried", []string{"ana-tablet"}, []string{"ana-phone"}, true, false, []string{"ana-phone"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newNotificationFixture()
+			for _, token := range tt.invalid {
+				f.sender.invalid[token] = true
+			}
+			for _, token := range tt.failing {
+				f.sender.failing[token] = true
+			}
+
+			if err := f.service.NotifyLike("ana", "ben"); err != nil {
+				t.Fatalf("NotifyLike() error = %v", err)
+			}
+			errs := f.jobs.RunDue(t)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("delivery errors = %v, want error %v", errs, tt.wantErr)
+			}
+			if retried := len(f.jobs.Queued(JobTypeSendNotification)) > 0; retried != tt.wantErr {
+				t.Fatalf("job kept for a retry = %v, want %v", retried, tt.wantErr)
+			}
+
+			notification := f.repository.forUser("ana")[0]
+			if sent := notification.SentAt != nil; sent != tt.wantSent {
+				t.Fatalf("marked sent = %v, want %v", sent, tt.wantSent)
+			}
+
+			devices, _ := f.repository.FindDevices("ana")
+			var tokens []string
+			for _, device := range devices {
+				tokens = append(tokens, device.Token)
+			}
+			if len(tokens) != len(tt.wantDevices) {
+				t.Fatalf("devices left = %v, want %v", tokens, tt.wantDevices)
+			}
+			for i := range tokens {
+				if tokens[i] != tt.wantDevices[i] {
+					t.Fatalf("devices left = %v, want %v", tokens, tt.wantDevices)
+				}
+			}
+		})
+	}
+}
+
+func TestNotificationService_DeliverSkipsSentNotifications(t *testing.T) {
+	f := newNotificationFixture()
+	if err := f.service.NotifyLike("ana", "ben"); err != nil {
+		t.Fatalf("NotifyLike() error = %v", err)
+	}
+	job := f.jobs.Queued(JobTypeSendNotification)[0]
+	if errs := f.jobs.RunDue(t); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	// a job run twice, after a crash before it was marked done, pushes once
+	if err := f.jobs.EnqueueAt(job.Type, json.RawMessage(job.Payload), job.RunAt); err != nil {
+		t.Fatal(err)
+	}
+	if errs := f.jobs.RunDue(t); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(f.sender.pushes) != 2 {
+		t.Fatalf("%d pushes, want one per device", len(f.sender.pushes))
+	}
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	jwksCacheTTL        = time.Hour
+	jwksRefreshInterval = time.Minute
+	jwksFetchTimeout    = 10 * time.Second
+)
+
+var (
+	ErrOIDCProviderUnsupported = errors.New("unsupported identity provider")
+	ErrOIDCTokenInvalid        = errors.New("invalid id token")
+	ErrOIDCEmailMissing        = errors.New("id token has no email")
+	ErrOIDCEmailTaken          = errors.New("email is registered, sign in with password to link this provider")
+	ErrIdentityAlreadyLinked   = errors.New("identity is already linked")
+	ErrIdentityNotLinked       = errors.New("identity is not linked")
+	ErrLastSignInMethod        = errors.New("cannot remove the last sign in method")
+)
+
+type IOIDCService interface {
+	SignIn(provider, idToken, nonce string) (*models.User, error)
+	Link(userID, provider, idToken, nonce string) error
+	Unlink(userID, provider string) error
+	ListIdentities(userID string) ([]models.Identity, error)
+}
+
+type oidcProvider struct {
+	name      string
+	clientIDs []string
+	issuers   []string
+	jwksURL   string
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+type oidcClaims struct {
+	Email string `json:"email"`
+	// google sends a boolean, apple a "true"/"false" string
+	EmailVerified interface{} `json:"email_verified"`
+	Nonce         string      `json:"nonce"`
+	jwt.StandardClaims
+}
+
+// OIDCService signs users in with OpenID Connect id tokens and links provider accounts to users
+type OIDCService struct {
+	logger       *core.Logger
+	clock        core.Clock
+	userRepo     repositories.IUserRepository
+	identityRepo repositories.IIdentityRepository
+	providers    map[string]*oidcProvider
+}
+
+// NewOIDCService creates a new oidc service, providers without client ids are disabled
+func NewOIDCService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	userRepo repositories.IUserRepository,
+	identityRepo repositories.IIdentityRepository,
+) IOIDCService {
+	s := &OIDCService{
+		logger:       logger,
+		clock:        clock,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		providers:    make(map[string]*oidcProvider),
+	}
+
+	configs := []*oidcProvider{
+		{
+			name:      models.IdentityProviderGoogle,
+			clientIDs: splitList(env.OIDCGoogleClientIDs),
+			issuers:   splitList(env.OIDCGoogleIssuers),
+			jwksURL:   env.OIDCGoogleJWKSURL,
+		},
+		{
+			name:      models.IdentityProviderApple,
+			clientIDs: splitList(env.OIDCAppleClientIDs),
+			issuers:   splitList(env.OIDCAppleIssuers),
+			jwksURL:   env.OIDCAppleJWKSURL,
+		},
+	}
+	for _, config := range configs {
+		if len(config.clientIDs) == 0 || len(config.issuers) == 0 || config.jwksURL == "" {
+			continue
+		}
+		s.providers[config.name] = config
+	}
+
+	return s
+}
+
+// SignIn returns the user owning the provider account. Unknown accounts are linked to the
+// user with the same email when the provider verified it, or get a new user.
+func (s *OIDCService) SignIn(provider, idToken, nonce string) (*models.User, error) {
+	identity, err := s.verify(provider, idToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.identityRepo.First(identity.Provider, identity.Subject)
+	if err == nil {
+		return s.userRepo.First(models.OneUserFilter{ID: existing.UserID})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, ErrOIDCEmailMissing
+	}
+
+	user, err := s.userRepo.First(models.OneUserFilter{Email: identity.Email})
+	switch {
+	case err == nil:
+		// an email the provider didn't verify could belong to someone else
+		if !identity.EmailVerified {
+			return nil, ErrOIDCEmailTaken
+		}
+		// nobody proved the account's email, the provider just did: its owner takes the account
+		// over, the password set at registration and the sessions signed with it are dropped
+		if user.VerificationStatus == 0 {
+			if _, err = s.userRepo.MarkEmailVerified(user.ID, ""); err != nil {
+				return nil, err
+			}
+			s.logger.Warnw("security event",
+				"event", "unverified_account_taken_over",
+				"user_id", user.ID,
+				"provider", identity.Provider,
+			)
+			if user, err = s.userRepo.First(models.OneUserFilter{ID: user.ID}); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		verificationStatus := 0
+		if identity.EmailVerified {
+			verificationStatus = 1
+		}
+		// no password: the account can only sign in through its providers until one is set
+		user, err = s.userRepo.Create(models.User{
+			Email:              identity.Email,
+			VerificationStatus: verificationStatus,
+			VerificationTime:   s.clock.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err = s.identityRepo.Create(models.Identity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Link attaches the provider account of the id token to the user
+func (s *OIDCService) Link(userID, provider, idToken, nonce string) error {
+	identity, err := s.verify(provider, idToken, nonce)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.identityRepo.First(identity.Provider, identity.Subject)
+	if err == nil {
+		if existing.UserID == userID {
+			return nil
+		}
+		return ErrIdentityAlreadyLinked
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	identities, err := s.identityRepo.FindListByUserId(userID)
+	if err != nil {
+		return err
+	}
+	for _, linked := range identities {
+		if linked.Provider == identity.Provider {
+			return ErrIdentityAlreadyLinked
+		}
+	}
+
+	return s.identityRepo.Create(models.Identity{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+}
+
+// Unlink detaches the provider from the user, as long as the user can still sign in
+func (s *OIDCService) Unlink(userID, provider string) error {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		return err
+	}
+
+	identities, err := s.identityRepo.FindListByUserId(userID)
+	if err != nil {
+		return err
+	}
+
+	linked := false
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			linked = true
+		}
+	}
+	if !linked {
+		return ErrIdentityNotLinked
+	}
+
+	if user.Password == "" && len(identities) == 1 {
+		return ErrLastSignInMethod
+	}
+
+	_, err = s.identityRepo.Delete(userID, provider)
+	return err
+}
+
+func (s *OIDCService) ListIdentities(userID string) ([]models.Identity, error) {
+	return s.identityRepo.FindListByUserId(userID)
+}
+
+// ----------------- private -----------------
+
+// verify checks the signature, issuer, audience, expiry and nonce of the id token
+func (s *OIDCService) verify(providerName, idToken, nonce string) (*models.OIDCIdentity, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrOIDCProviderUnsupported
+	}
+
+	var claims oidcClaims
+	token, err := jwt.ParseWithClaims(idToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return s.key(provider, kid)
+	})
+	if err != nil || !token.Valid {
+		s.logger.Debugf("reject %s id token: [%v]", providerName, err)
+		return nil, ErrOIDCTokenInvalid
+	}
+
+	if !utils.StringArrayContains(provider.issuers, claims.Issuer) ||
+		!utils.StringArrayContains(provider.clientIDs, claims.Audience) ||
+		claims.Subject == "" ||
+		claims.ExpiresAt == 0 {
+		return nil, ErrOIDCTokenInvalid
+	}
+
+	if (claims.Nonce != "" || nonce != "") && claims.Nonce != nonce {
+		return nil, ErrOIDCTokenInvalid
+	}
+
+	emailVerified := false
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		emailVerified = v
+	case string:
+		emailVerified = v == "true"
+	}
+
+	return &models.OIDCIdentity{
+		Provider:      provider.name,
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: emailVerified,
+	}, nil
+}
+
+// key returns the provider's signing key, the key set is refreshed hourly or when a
+// token names an unknown key (providers rotate keys), but at most once a minute
+func (s *OIDCService) key(provider *oidcProvider, kid string) (crypto.PublicKey, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	now := s.clock.Now()
+	key, ok := provider.keys[kid]
+	stale := now.Sub(provider.fetchedAt) > jwksCacheTTL
+	if (!ok || stale) && now.Sub(provider.attemptedAt) > jwksRefreshInterval {
+		provider.attemptedAt = now
+		ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+		keys, err := utils.FetchJWKS(ctx, provider.jwksURL)
+		cancel()
+		if err != nil {
+			s.logger.Errorf("fail to fetch %s jwks: [%v]", provider.name, err)
+		} else {
+			provider.keys = keys
+			provider.fetchedAt = now
+			key, ok = keys[kid]
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
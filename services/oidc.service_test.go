@@ -0,0 +1,295 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+const (
+	testOIDCClientID = "winglets-local"
+	testOIDCIssuer   = "https://accounts.google.com"
+	testOIDCKeyID    = "winglets-fake-key"
+)
+
+func loadOIDCSigningKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	pem, err := os.ReadFile("../testdata/oidc/fake_signing_key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, kid string, claims oidcClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func testIDTokenClaims(subject, email string, emailVerified interface{}) oidcClaims {
+	// jwt-go checks exp against the wall clock
+	return oidcClaims{
+		Email:         email,
+		EmailVerified: emailVerified,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    testOIDCIssuer,
+			Audience:  testOIDCClientID,
+			Subject:   subject,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+}
+
+func newTestOIDCService(users *fakeUserRepository, identities *fakeIdentityRepository) IOIDCService {
+	env := &core.Env{
+		OIDCGoogleClientIDs: testOIDCClientID,
+		OIDCGoogleIssuers:   testOIDCIssuer,
+		OIDCGoogleJWKSURL:   "file://../testdata/oidc/fake_jwks.json",
+	}
+	return NewOIDCService(env, newTestLogger(), newFakeClock(), users, identities)
+}
+
+func TestOIDCService_SignIn(t *testing.T) {
+	key := loadOIDCSigningKey(t)
+
+	tests := []struct {
+		name string
+		// existing password account with the token's email, nil for none
+		existing     *models.User
+		claims       oidcClaims
+		wantErr      error
+		wantLinked   bool
+		wantVerified bool
+	}{
+		{
+			name:         "new email creates a verified user",
+			claims:       testIDTokenClaims("sub-1", "Ana@Example.com", true),
+			wantLinked:   true,
+			wantVerified: true,
+		},
+		{
+			name:       "new unverified email creates an unverified user",
+			claims:     testIDTokenClaims("sub-1", "ana@example.com", "false"),
+			wantLinked: true,
+		},
+		{
+			name:         "verified account is linked",
+			existing:     &models.User{Email: "ana@example.com", Password: "hash", VerificationStatus: 1},
+			claims:       testIDTokenClaims("sub-1", "ana@example.com", "true"),
+			wantLinked:   true,
+			wantVerified: true,
+		},
+		{
+			name:         "unverified account is taken over",
+			existing:     &models.User{Email: "ana@example.com", Password: "hash", VerificationStatus: 0},
+			claims:       testIDTokenClaims("sub-1", "ana@example.com", true),
+			wantLinked:   true,
+			wantVerified: true,
+		},
+		{
+			name:     "unverified account is not linked to an unverified token email",
+			existing: &models.User{Email: "ana@example.com", Password: "hash", VerificationStatus: 0},
+			claims:   testIDTokenClaims("sub-1", "ana@example.com", false),
+			wantErr:  ErrOIDCEmailTaken,
+		},
+		{
+			name:         "unverified token email is not linked",
+			existing:     &models.User{Email: "ana@example.com", Password: "hash", VerificationStatus: 1},
+			claims:       testIDTokenClaims("sub-1", "ana@example.com", false),
+			wantErr:      ErrOIDCEmailTaken,
+			wantVerified: true,
+		},
+		{
+			name:    "token without email",
+			claims:  testIDTokenClaims("sub-1", "", true),
+			wantErr: ErrOIDCEmailMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newFakeUserRepository()
+			identities := &fakeIdentityRepository{}
+			service := newTestOIDCService(users, identities)
+
+			var existingID string
+			if tt.existing != nil {
+				created, err := users.Create(*tt.existing)
+				if err != nil {
+					t.Fatal(err)
+				}
+				existingID = created.ID
+			}
+
+			user, err := service.SignIn(models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, tt.claims), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SignIn() error = %v, want %v", err, tt.wantErr)
+			}
+
+			linked, _ := identities.First(models.IdentityProviderGoogle, "sub-1")
+			if (linked != nil) != tt.wantLinked {
+				t.Fatalf("linked = %+v, want linked %v", linked, tt.wantLinked)
+			}
+
+			if tt.existing != nil {
+				stored := users.users[existingID]
+				if verified := stored.VerificationStatus == 1; verified != tt.wantVerified {
+					t.Fatalf("existing account verified = %v, want %v", verified, tt.wantVerified)
+				}
+				if tt.wantErr == nil && user.ID != existingID {
+					t.Fatalf("signed in as %s, want the existing account %s", user.ID, existingID)
+				}
+			}
+
+			if tt.wantErr == nil {
+				if user.Email != "ana@example.com" {
+					t.Fatalf("email = %s, want ana@example.com", user.Email)
+				}
+				if verified := user.VerificationStatus == 1; verified != tt.wantVerified {
+					t.Fatalf("verified = %v, want %v", verified, tt.wantVerified)
+				}
+				if linked.UserID != user.ID {
+					t.Fatalf("identity linked to %s, want %s", linked.UserID, user.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCService_SignInTakesOverSquattedAccount(t *testing.T) {
+	key := loadOIDCSigningKey(t)
+	users := newFakeUserRepository()
+	identities := &fakeIdentityRepository{}
+	env := &core.Env{
+		JWTSecret:             "test-secret",
+		AccessTokenExpiresIn:  time.Hour,
+		RefreshTokenExpiresIn: 24 * time.Hour,
+	}
+	// jwt-go checks exp against the wall clock
+	auth := NewAuthService(env, newTestLogger(), core.NewClock(), users)
+	service := newTestOIDCService(users, identities)
+
+	// someone registers the victim's email with their own password
+	squatted, err := auth.Register(models.RegisterRequest{Email: "ana@example.com", Password: "squatter-password"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	squatterToken, _, _, _, err := auth.GenerateJWTTokens(*squatted)
+	if err != nil {
+		t.Fatalf("GenerateJWTTokens() error = %v", err)
+	}
+
+	// the victim signs in with the provider that verified the email
+	user, err := service.SignIn(models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, testIDTokenClaims("sub-1", "ana@example.com", true)), "")
+	if err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+	if user.ID != squatted.ID || user.VerificationStatus != 1 {
+		t.Fatalf("signed in as %s verified %d, want the verified account %s", user.ID, user.VerificationStatus, squatted.ID)
+	}
+	if user.Password != "" || users.users[user.ID].Password != "" {
+		t.Fatal("the squatter's password still works")
+	}
+
+	if _, err = auth.Authorize(squatterToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("Authorize() of the squatter's token error = %v, want %v", err, ErrSessionRevoked)
+	}
+	victimToken, _, _, _, err := auth.GenerateJWTTokens(*user)
+	if err != nil {
+		t.Fatalf("GenerateJWTTokens() error = %v", err)
+	}
+	if _, err = auth.Authorize(victimToken); err != nil {
+		t.Fatalf("Authorize() of the victim's token error = %v", err)
+	}
+}
+
+func TestOIDCService_SignInReturnsLinkedUser(t *testing.T) {
+	key := loadOIDCSigningKey(t)
+	users := newFakeUserRepository()
+	identities := &fakeIdentityRepository{}
+	service := newTestOIDCService(users, identities)
+
+	first, err := service.SignIn(models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, testIDTokenClaims("sub-1", "ana@example.com", true)), "")
+	if err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+
+	// the provider account keeps pointing at the user after an email change on the provider side
+	second, err := service.SignIn(models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, testIDTokenClaims("sub-1", "ana@elsewhere.com", true)), "")
+	if err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("signed in as %s, want %s", second.ID, first.ID)
+	}
+	if len(users.users) != 1 {
+		t.Fatalf("%d users, want 1", len(users.users))
+	}
+}
+
+func TestOIDCService_SignInRejectsInvalidTokens(t *testing.T) {
+	key := loadOIDCSigningKey(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := testIDTokenClaims("sub-1", "ana@example.com", true)
+	withNonce := valid
+	withNonce.Nonce = "nonce-1"
+	wrongAudience := valid
+	wrongAudience.Audience = "someone-else"
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "https://evil.example.com"
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	noSubject := valid
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name     string
+		provider string
+		token    string
+		nonce    string
+		wantErr  error
+	}{
+		{"unsupported provider", models.IdentityProviderApple, signIDToken(t, key, testOIDCKeyID, valid), "", ErrOIDCProviderUnsupported},
+		{"wrong audience", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, wrongAudience), "", ErrOIDCTokenInvalid},
+		{"wrong issuer", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, wrongIssuer), "", ErrOIDCTokenInvalid},
+		{"expired", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, expired), "", ErrOIDCTokenInvalid},
+		{"no subject", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, noSubject), "", ErrOIDCTokenInvalid},
+		{"unknown key id", models.IdentityProviderGoogle, signIDToken(t, key, "rotated-key", valid), "", ErrOIDCTokenInvalid},
+		{"nonce missing from token", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, valid), "nonce-1", ErrOIDCTokenInvalid},
+		{"nonce mismatch", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, withNonce), "nonce-2", ErrOIDCTokenInvalid},
+		{"nonce match", models.IdentityProviderGoogle, signIDToken(t, key, testOIDCKeyID, withNonce), "nonce-1", nil},
+		{"signed by another key", models.IdentityProviderGoogle, signIDToken(t, otherKey, testOIDCKeyID, valid), "", ErrOIDCTokenInvalid},
+		{"not a token", models.IdentityProviderGoogle, "not-a-token", "", ErrOIDCTokenInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestOIDCService(newFakeUserRepository(), &fakeIdentityRepository{})
+			if _, err := service.SignIn(tt.provider, tt.token, tt.nonce); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SignIn() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultOnboardingMinPhotos  = 2
+	defaultOnboardingMinAnswers = 5
+)
+
+var ErrOnboardingIncomplete = errors.New("finish onboarding first")
+
+type IOnboardingService interface {
+	State(userID string) (*models.OnboardingState, error)
+}
+
+// OnboardingService works out the onboarding steps of a user from what they already stored,
+// nothing about onboarding itself is saved
+type OnboardingService struct {
+	logger         *core.Logger
+	userRepo       repositories.IUserRepository
+	profileRepo    repositories.IProfileRepository
+	answerRepo     repositories.IAnswerRepository
+	preferenceRepo repositories.IPreferenceRepository
+	minPhotos      int
+	minAnswers     int
+}
+
+// NewOnboardingService creates a new onboarding service
+func NewOnboardingService(
+	env *core.Env,
+	logger *core.Logger,
+	userRepo repositories.IUserRepository,
+	profileRepo repositories.IProfileRepository,
+	answerRepo repositories.IAnswerRepository,
+	preferenceRepo repositories.IPreferenceRepository,
+) IOnboardingService {
+	minPhotos := env.OnboardingMinPhotos
+	if minPhotos <= 0 {
+		minPhotos = defaultOnboardingMinPhotos
+	}
+	minAnswers := env.OnboardingMinAnswers
+	if minAnswers <= 0 {
+		minAnswers = defaultOnboardingMinAnswers
+	}
+
+	return &OnboardingService{
+		logger:         logger,
+		userRepo:       userRepo,
+		profileRepo:    profileRepo,
+		answerRepo:     answerRepo,
+		preferenceRepo: preferenceRepo,
+		minPhotos:      minPhotos,
+		minAnswers:     minAnswers,
+	}
+}
+
+// State walks the steps in order. Preferences are optional, recommendations fall back to
+// defaults until they are set.
+func (s *OnboardingService) State(userID string) (*models.OnboardingState, error) {
+	user, err := s.userRepo.First(models.OneUserFilter{ID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := s.profileRepo.GetProfileById(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	photos := 0
+	if profile != nil {
+		for _, photo := range profile.Photos {
+			// pending photos count, moderation shouldn't hold the user back
+			if photo.Status != models.ProfilePhotoStatusRejected {
+				photos++
+			}
+		}
+	}
+
+	answers, err := s.answerRepo.FindListAnswerByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.preferenceRepo.First(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	preferencesSet := err == nil
+
+	state := &models.OnboardingState{
+		Steps: []models.OnboardingStep{
+			{
+				Name:     models.OnboardingStepAccountVerified,
+				Required: true,
+				Done:     user.VerificationStatus == 1 || user.PhoneVerifiedAt != nil,
+			},
+			{
+				Name:     models.OnboardingStepProfileCreated,
+				Required: true,
+				Done:     profile != nil,
+			},
+			{
+				Name:     models.OnboardingStepPhotosUploaded,
+				Required: true,
+				Done:     photos >= s.minPhotos,
+				Current:  photos,
+				Target:   s.minPhotos,
+			},
+			{
+				Name:     models.OnboardingStepQuestionsAnswered,
+				Required: true,
+				Done:     len(answers) >= s.minAnswers,
+				Current:  len(answers),
+				Target:   s.minAnswers,
+			},
+			{
+				Name:     models.OnboardingStepPreferencesSet,
+				Required: false,
+				Done:     preferencesSet,
+			},
+		},
+		CurrentStep:  models.OnboardingStepDone,
+		Complete:     true,
+		Completeness: profile.Completeness(),
+	}
+
+	for _, step := range state.Steps {
+		if step.Done {
+			continue
+		}
+		if state.CurrentStep == models.OnboardingStepDone {
+			state.CurrentStep = step.Name
+		}
+		if step.Required {
+			state.Complete = false
+		}
+	}
+
+	return state, nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+type fakeAnswerRepository struct {
+	repositories.IAnswerRepository
+	answers map[string][]models.Answer
+}
+
+func (r *fakeAnswerRepository) FindListAnswerByUserId(userId string) ([]models.Answer, error) {
+	return r.answers[userId], nil
+}
+
+type fakePreferenceRepository struct {
+	repositories.IPreferenceRepository
+	preferences map[string]models.UserPreference
+	err         error
+}
+
+func (r *fakePreferenceRepository) First(userID string) (*models.UserPreference, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	preference, ok := r.preferences[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &preference, nil
+}
+
+// onboardingUser is what a user stored so far
+type onboardingUser struct {
+	emailVerified bool
+	phoneVerified bool
+	profile       bool
+	photos        []string
+	answers       int
+	preferences   bool
+}
+
+func newTestOnboardingService(env *core.Env, stored onboardingUser) (IOnboardingService, string, *fakePreferenceRepository) {
+	users := newFakeUserRepository()
+	user := models.User{Email: "ana@example.com"}
+	if stored.emailVerified {
+		user.VerificationStatus = 1
+	}
+	if stored.phoneVerified {
+		user.PhoneVerifiedAt = &testNow
+	}
+	created, _ := users.Create(user)
+
+	profiles := &fakeProfileRepository{profiles: map[string]models.Profile{}}
+	if stored.profile {
+		profile := models.Profile{ID: created.ID, Name: "Ana", Gender: "female"}
+		for _, status := range stored.photos {
+			profile.Photos = append(profile.Photos, models.ProfilePhoto{Status: status})
+		}
+		profiles.profiles[created.ID] = profile
+	}
+
+	answers := &fakeAnswerRepository{answers: map[string][]models.Answer{}}
+	for i := 0; i < stored.answers; i++ {
+		answers.answers[created.ID] = append(answers.answers[created.ID], models.Answer{})
+	}
+
+	preferences := &fakePreferenceRepository{preferences: map[string]models.UserPreference{}}
+	if stored.preferences {
+		preferences.preferences[created.ID] = models.UserPreference{}
+	}
+
+	return NewOnboardingService(env, newTestLogger(), users, profiles, answers, preferences), created.ID, preferences
+}
+
+func TestOnboardingService_State(t *testing.T) {
+	approved, pending, rejected := models.ProfilePhotoStatusApproved, models.ProfilePhotoStatusPending, models.ProfilePhotoStatusRejected
+
+	tests := []struct {
+		name         string
+		env          core.Env
+		stored       onboardingUser
+		wantCurrent  string
+		wantComplete bool
+		wantDone     []string
+	}{
+		{
+			name:        "just signed up",
+			wantCurrent: models.OnboardingStepAccountVerified,
+		},
+		{
+			name:        "email verified",
+			stored:      onboardingUser{emailVerified: true},
+			wantCurrent: models.OnboardingStepProfileCreated,
+			wantDone:    []string{models.OnboardingStepAccountVerified},
+		},
+		{
+			name:        "phone verified",
+			stored:      onboardingUser{phoneVerified: true},
+			wantCurrent: models.OnboardingStepProfileCreated,
+			wantDone:    []string{models.OnboardingStepAccountVerified},
+		},
+		{
+			name:        "profile without verification stays on the first step",
+			stored:      onboardingUser{profile: true, photos: []string{approved, approved}, answers: 5},
+			wantCurrent: models.OnboardingStepAccountVerified,
+			wantDone:    []string{models.OnboardingStepProfileCreated, models.OnboardingStepPhotosUploaded, models.OnboardingStepQuestionsAnswered},
+		},
+		{
+			name:        "rejected photos don't count",
+			stored:      onboardingUser{emailVerified: true, profile: true, photos: []string{approved, rejected, rejected}},
+			wantCurrent: models.OnboardingStepPhotosUploaded,
+			wantDone:    []string{models.OnboardingStepAccountVerified, models.OnboardingStepProfileCreated},
+		},
+		{
+			name:        "pending photos count",
+			stored:      onboardingUser{emailVerified: true, profile: true, photos: []string{approved, pending}, answers: 4},
+			wantCurrent: models.OnboardingStepQuestionsAnswered,
+			wantDone:    []string{models.OnboardingStepAccountVerified, models.OnboardingStepProfileCreated, models.OnboardingStepPhotosUploaded},
+		},
+		{
+			name:         "preferences are optional",
+			stored:       onboardingUser{emailVerified: true, profile: true, photos: []string{approved, pending}, answers: 5},
+			wantCurrent:  models.OnboardingStepPreferencesSet,
+			wantComplete: true,
+			wantDone:     []string{models.OnboardingStepAccountVerified, models.OnboardingStepProfileCreated, models.OnboardingStepPhotosUploaded, models.OnboardingStepQuestionsAnswered},
+		},
+		{
+			name:         "everything done",
+			stored:       onboardingUser{emailVerified: true, profile: true, photos: []string{approved, approved, approved}, answers: 9, preferences: true},
+			wantCurrent:  models.OnboardingStepDone,
+			wantComplete: true,
+			wantDone:     []string{models.OnboardingStepAccountVerified, models.OnboardingStepProfileCreated, models.OnboardingStepPhotosUploaded, models.OnboardingStepQuestionsAnswered, models.OnboardingStepPreferencesSet},
+		},
+		{
+			name:         "targets from the env",
+			env:          core.Env{OnboardingMinPhotos: 1, OnboardingMinAnswers: 1},
+			stored:       onboardingUser{emailVerified: true, profile: true, photos: []string{pending}, answers: 1, preferences: true},
+			wantCurrent:  models.OnboardingStepDone,
+			wantComplete: true,
+			wantDone:     []string{models.OnboardingStepAccountVerified, models.OnboardingStepProfileCreated, models.OnboardingStepPhotosUploaded, models.OnboardingStepQuestionsAnswered, models.OnboardingStepPreferencesSet},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, userID, _ := newTestOnboardingService(&tt.env, tt.stored)
+
+			state, err := service.State(userID)
+			if err != nil {
+				t.Fatalf("State() error = %v", err)
+			}
+			if state.CurrentStep != tt.wantCurrent {
+				t.Fatalf("CurrentStep = %s, want %s", state.CurrentStep, tt.wantCurrent)
+			}
+			if state.Complete != tt.wantComplete {
+				t.Fatalf("Complete = %v, want %v", state.Complete, tt.wantComplete)
+			}
+
+			var done []string
+			for _, step := range state.Steps {
+				if step.Done {
+					done = append(done, step.Name)
+				}
+			}
+			if !reflect.DeepEqual(done, tt.wantDone) {
+				t.Fatalf("done steps = %v, want %v", done, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestOnboardingService_StateCounts(t *testing.T) {
+	service, userID, _ := newTestOnboardingService(&core.Env{}, onboardingUser{
+		emailVerified: true,
+		profile:       true,
+		photos:        []string{models.ProfilePhotoStatusApproved, models.ProfilePhotoStatusRejected},
+		answers:       3,
+	})
+
+	state, err := service.State(userID)
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+
+	want := []models.OnboardingStep{
+		{Name: models.OnboardingStepAccountVerified, Required: true, Done: true},
+		{Name: models.OnboardingStepProfileCreated, Required: true, Done: true},
+		{Name: models.OnboardingStepPhotosUploaded, Required: true, Current: 1, Target: defaultOnboardingMinPhotos},
+		{Name: models.OnboardingStepQuestionsAnswered, Required: true, Current: 3, Target: defaultOnboardingMinAnswers},
+		{Name: models.OnboardingStepPreferencesSet},
+	}
+	if !reflect.DeepEqual(state.Steps, want) {
+		t.Fatalf("Steps = %+v, want %+v", state.Steps, want)
+	}
+	if state.Completeness <= 0 {
+		t.Fatalf("Completeness = %d for a created profile", state.Completeness)
+	}
+}
+
+func TestOnboardingService_StateWithoutProfile(t *testing.T) {
+	service, userID, _ := newTestOnboardingService(&core.Env{}, onboardingUser{emailVerified: true})
+
+	state, err := service.State(userID)
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if state.Completeness != 0 {
+		t.Fatalf("Completeness = %d without a profile, want 0", state.Completeness)
+	}
+}
+
+func TestOnboardingService_StateFails(t *testing.T) {
+	service, userID, preferences := newTestOnboardingService(&core.Env{}, onboardingUser{emailVerified: true})
+	preferences.err = errors.New("database is down")
+
+	if _, err := service.State(userID); !errors.Is(err, preferences.err) {
+		t.Fatalf("State() error = %v, want %v", err, preferences.err)
+	}
+	if _, err := service.State("nobody"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("State() of an unknown user error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
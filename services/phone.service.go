@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPhoneCodeExpiresIn      = 10 * time.Minute
+	defaultPhoneCodeMaxAttempts    = 5
+	defaultPhoneCodeResendCooldown = time.Minute
+	defaultPhoneCodeMaxSends       = 5
+	defaultPhoneCodeMaxUserSends   = 10
+	defaultPhoneCodeSendWindow     = 24 * time.Hour
+	phoneCodeDigits                = 6
+)
+
+var (
+	ErrPhoneAlreadyVerified       = errors.New("phone number has already been verified")
+	ErrPhoneTaken                 = errors.New("phone number is used by another account")
+	ErrPhoneCodeInvalid           = errors.New("invalid phone verification code")
+	ErrPhoneCodeExpired           = errors.New("phone verification code expired")
+	ErrPhoneCodeAttemptsExceeded  = errors.New("too many phone verification attempts")
+	ErrPhoneCodeResendTooSoon     = errors.New("phone verification code was sent recently")
+	ErrPhoneCodeSendLimitExceeded = errors.New("too many phone verification codes sent to this number")
+	ErrPhoneCodeUserLimitExceeded = errors.New("too many phone verification codes requested")
+)
+
+type IPhoneService interface {
+	SendCode(userID, phoneNumber string) (time.Duration, error)
+	VerifyCode(userID, phoneNumber, code string) error
+}
+
+// PhoneService verifies phone numbers with one-time codes sent by sms. Only the id of a send
+// goes through the job outbox, the code is issued and texted when the job runs.
+type PhoneService struct {
+	logger         *core.Logger
+	clock          core.Clock
+	smsSender      core.SmsSender
+	userRepo       repositories.IUserRepository
+	repository     repositories.IPhoneVerificationRepository
+	jobService     IJobService
+	expiresIn      time.Duration
+	maxAttempts    int
+	resendCooldown time.Duration
+	maxSends       int
+	maxUserSends   int
+	sendWindow     time.Duration
+}
+
+// NewPhoneService creates a new phone service and registers the code job
+func NewPhoneService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	smsSender core.SmsSender,
+	userRepo repositories.IUserRepository,
+	repository repositories.IPhoneVerificationRepository,
+	jobService IJobService,
+) IPhoneService {
+	s := &PhoneService{
+		logger:         logger,
+		clock:          clock,
+		smsSender:      smsSender,
+		userRepo:       userRepo,
+		repository:     repository,
+		jobService:     jobService,
+		expiresIn:      env.PhoneCodeExpiresIn,
+		maxAttempts:    env.PhoneCodeMaxAttempts,
+		resendCooldown: env.PhoneCodeResendCooldown,
+		maxSends:       env.PhoneCodeMaxSends,
+		maxUserSends:   env.PhoneCodeMaxSendsPerUser,
+		sendWindow:     env.PhoneCodeSendWindow,
+	}
+
+	if s.expiresIn <= 0 {
+		s.expiresIn = defaultPhoneCodeExpiresIn
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultPhoneCodeMaxAttempts
+	}
+	if s.resendCooldown <= 0 {
+		s.resendCooldown = defaultPhoneCodeResendCooldown
+	}
+	if s.maxSends <= 0 {
+		s.maxSends = defaultPhoneCodeMaxSends
+	}
+	if s.maxUserSends <= 0 {
+		s.maxUserSends = defaultPhoneCodeMaxUserSends
+	}
+	if s.sendWindow <= 0 {
+		s.sendWindow = defaultPhoneCodeSendWindow
+	}
+
+	jobService.Register(JobTypeSendPhoneCode, func(ctx context.Context, payload []byte) error {
+		var phoneCode models.PhoneCodePayload
+		if err := json.Unmarshal(payload, &phoneCode); err != nil {
+			return err
+		}
+		return s.deliverCode(ctx, phoneCode.SendID)
+	})
+
+	return s
+}
+
+// SendCode has a new code texted to the number, the pending one is invalidated. The sends to a
+// number are rate limited, whichever user asks, with a cool-down between codes and a cap per
+// window. The sends of a user are capped per window too, whichever numbers they try. When a
+// limit is hit it returns the time left until the next send is allowed.
+func (s *PhoneService) SendCode(userID, phoneNumber string) (time.Duration, error) {
+	if err := s.checkOwner(userID, phoneNumber); err != nil {
+		return 0, err
+	}
+
+	now := s.clock.Now()
+	verification, err := s.repository.First(phoneNumber)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		verification = &models.PhoneVerification{
+			PhoneNumber:     phoneNumber,
+			WindowStartedAt: now,
+		}
+	}
+
+	if wait := verification.SentAt.Add(s.resendCooldown).Sub(now); wait > 0 {
+		return wait, ErrPhoneCodeResendTooSoon
+	}
+
+	if now.Sub(verification.WindowStartedAt) >= s.sendWindow {
+		verification.WindowStartedAt = now
+		verification.SendCount = 0
+	}
+	if verification.SendCount >= s.maxSends {
+		s.logger.Warnw("security event",
+			"event", "phone_code_send_limit",
+			"phone_number", phoneNumber,
+			"user_id", userID,
+		)
+		return verification.WindowStartedAt.Add(s.sendWindow).Sub(now), ErrPhoneCodeSendLimitExceeded
+	}
+
+	userSends, err := s.repository.FindSendTimesByUser(userID, now.Add(-s.sendWindow))
+	if err != nil {
+		return 0, err
+	}
+	if len(userSends) >= s.maxUserSends {
+		s.logger.Warnw("security event",
+			"event", "phone_code_user_send_limit",
+			"phone_number", phoneNumber,
+			"user_id", userID,
+		)
+		// the window slides, a send is allowed again once enough of them are older than it
+		return userSends[len(userSends)-s.maxUserSends].Add(s.sendWindow).Sub(now), ErrPhoneCodeUserLimitExceeded
+	}
+
+	verification.UserID = userID
+	verification.SendID = uuid.New().String()
+	verification.CodeHash = ""
+	verification.Attempts = 0
+	verification.SentAt = now
+	verification.SendCount++
+	if err = s.repository.SaveSend(*verification); err != nil {
+		return 0, err
+	}
+
+	return 0, s.jobService.Enqueue(JobTypeSendPhoneCode, models.PhoneCodePayload{
+		UserID: userID,
+		SendID: verification.SendID,
+	})
+}
+
+// VerifyCode checks the code sent to the number and stores the number on the user. Every
+// check uses up one attempt, after the last one the user has to request a new code.
+func (s *PhoneService) VerifyCode(userID, phoneNumber, code string) error {
+	verification, err := s.repository.First(phoneNumber)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPhoneCodeInvalid
+		}
+		return err
+	}
+
+	if verification.UserID != userID || verification.CodeHash == "" {
+		return ErrPhoneCodeInvalid
+	}
+
+	if !s.clock.Now().Before(verification.SentAt.Add(s.expiresIn)) {
+		return ErrPhoneCodeExpired
+	}
+
+	consumed, err := s.repository.ConsumeAttempt(phoneNumber, s.maxAttempts)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrPhoneCodeAttemptsExceeded
+	}
+
+	if !utils.CompareTokenHash(verification.CodeHash, code) {
+		return ErrPhoneCodeInvalid
+	}
+
+	if err = s.checkOwner(userID, phoneNumber); err != nil {
+		return err
+	}
+
+	if err = s.userRepo.MarkPhoneVerified(userID, phoneNumber, s.clock.Now()); err != nil {
+		return err
+	}
+
+	return s.repository.ClearCode(phoneNumber)
+}
+
+// ----------------- private -----------------
+
+// deliverCode issues the code of the send and texts it, sends replaced by a newer one or
+// erased with the account are skipped
+func (s *PhoneService) deliverCode(ctx context.Context, sendID string) error {
+	verification, err := s.repository.FirstBySendID(sendID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code, err := newPhoneCode()
+	if err != nil {
+		return err
+	}
+
+	issued, err := s.repository.SetCode(sendID, utils.HashToken(code), s.clock.Now())
+	if err != nil || !issued {
+		return err
+	}
+
+	return s.smsSender.Send(ctx, core.Sms{
+		To:   verification.PhoneNumber,
+		Text: fmt.Sprintf("Your Winglets code is %s. It expires in %d minutes.", code, int(s.expiresIn.Minutes())),
+	})
+}
+
+// checkOwner refuses numbers that are already verified
+func (s *PhoneService) checkOwner(userID, phoneNumber string) error {
+	owner, err := s.userRepo.First(models.OneUserFilter{PhoneNumber: phoneNumber})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if owner.ID == userID {
+		return ErrPhoneAlreadyVerified
+	}
+	return ErrPhoneTaken
+}
+
+func newPhoneCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < phoneCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneCodeDigits, n), nil
+}
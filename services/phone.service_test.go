@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+)
+
+var textedCodePattern = regexp.MustCompile(`\b(\d{6})\b`)
+
+func TestPhoneService_SendCodeQueuesOnlyTheSendID(t *testing.T) {
+	clock := newFakeClock()
+	users := newFakeUserRepository()
+	verifications := newFakePhoneVerificationRepository()
+	jobs := newFakeJobService(clock)
+	sender := core.NewMemorySmsSender()
+	service := NewPhoneService(&core.Env{}, newTestLogger(), clock, sender, users, verifications, jobs)
+
+	if _, err := service.SendCode("user-1", "+84901234567"); err != nil {
+		t.Fatalf("SendCode() error = %v", err)
+	}
+
+	queued := jobs.Queued(JobTypeSendPhoneCode)
+	if len(queued) != 1 {
+		t.Fatalf("queued %d codes, want 1", len(queued))
+	}
+	if strings.Contains(queued[0].Payload, "+84901234567") {
+		t.Fatalf("payload %s holds the phone number", queued[0].Payload)
+	}
+	if verifications.verifications["+84901234567"].CodeHash != "" {
+		t.Fatal("a code was issued before the sms was sent")
+	}
+
+	if errs := jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+	sent := sender.Sent()
+	if len(sent) != 1 || sent[0].To != "+84901234567" {
+		t.Fatalf("sent = %+v, want one sms to +84901234567", sent)
+	}
+	code := textedCodePattern.FindString(sent[0].Text)
+	if err := service.VerifyCode("user-1", "+84901234567", code); err != nil {
+		t.Fatalf("VerifyCode() error = %v", err)
+	}
+}
+
+func TestPhoneService_ReplacedSendIsSkipped(t *testing.T) {
+	clock := newFakeClock()
+	users := newFakeUserRepository()
+	verifications := newFakePhoneVerificationRepository()
+	jobs := newFakeJobService(clock)
+	sender := core.NewMemorySmsSender()
+	service := NewPhoneService(&core.Env{}, newTestLogger(), clock, sender, users, verifications, jobs)
+
+	if _, err := service.SendCode("user-1", "+84901234567"); err != nil {
+		t.Fatalf("SendCode() error = %v", err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := service.SendCode("user-1", "+84901234567"); err != nil {
+		t.Fatalf("second SendCode() error = %v", err)
+	}
+
+	if errs := jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+	sent := sender.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d sms, want only the newest code", len(sent))
+	}
+
+	clock.Advance(time.Second)
+	if _, err := service.SendCode("user-1", "+84901234567"); !errors.Is(err, ErrPhoneCodeResendTooSoon) {
+		t.Fatalf("SendCode() error = %v, want %v", err, ErrPhoneCodeResendTooSoon)
+	}
+}
+
+func TestPhoneService_SendCodeCapsTheSendsOfAUser(t *testing.T) {
+	clock := newFakeClock()
+	verifications := newFakePhoneVerificationRepository()
+	env := &core.Env{PhoneCodeMaxSendsPerUser: 3, PhoneCodeSendWindow: 24 * time.Hour}
+	service := NewPhoneService(env, newTestLogger(), clock, core.NewMemorySmsSender(), newFakeUserRepository(), verifications, newFakeJobService(clock))
+
+	// a new number every hour, the per-number limits never kick in
+	for _, number := range []string{"+84901234561", "+84901234562", "+84901234563"} {
+		if _, err := service.SendCode("user-1", number); err != nil {
+			t.Fatalf("SendCode(%s) error = %v", number, err)
+		}
+		clock.Advance(time.Hour)
+	}
+
+	retryAfter, err := service.SendCode("user-1", "+84901234564")
+	if !errors.Is(err, ErrPhoneCodeUserLimitExceeded) {
+		t.Fatalf("fourth SendCode() error = %v, want %v", err, ErrPhoneCodeUserLimitExceeded)
+	}
+	if retryAfter != 21*time.Hour {
+		t.Fatalf("retry after %v, want the 21h until the first send leaves the window", retryAfter)
+	}
+	if _, ok := verifications.verifications["+84901234564"]; ok {
+		t.Fatal("the refused send was saved")
+	}
+
+	// other users are not affected
+	if _, err = service.SendCode("user-2", "+84901234564"); err != nil {
+		t.Fatalf("SendCode() of another user error = %v", err)
+	}
+
+	clock.Advance(21 * time.Hour)
+	if _, err = service.SendCode("user-1", "+84901234565"); err != nil {
+		t.Fatalf("SendCode() once the first send left the window error = %v", err)
+	}
+	if _, err = service.SendCode("user-1", "+84901234566"); !errors.Is(err, ErrPhoneCodeUserLimitExceeded) {
+		t.Fatalf("SendCode() with the window full again error = %v, want %v", err, ErrPhoneCodeUserLimitExceeded)
+	}
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	photoUploadConcurrency = 3
+	photoUploadTimeout     = 20 * time.Second
+	// urls given to the moderator and the face matcher only have to last for the call
+	blobFetchURLTTL       = 10 * time.Minute
+	photoReviewQueueLimit = 50
+)
+
+var (
+	ErrPhotoLimitReached = fmt.Errorf("a profile holds at most %d photos", models.MaxProfilePhotos)
+	ErrPhotoNotFound     = errors.New("photo not found")
+	ErrPhotoOrderInvalid = errors.New("photo order must list every photo exactly once")
+	ErrPhotoNotPending   = errors.New("photo is not waiting for review")
+)
+
+type IPhotoService interface {
+	List(userID string) ([]models.ProfilePhoto, error)
+	FreeSlots(userID string) (int, error)
+	Upload(ctx context.Context, userID string, uploads []models.ProfilePhotoUpload) ([]models.ProfilePhotoUploadResult, error)
+	Replace(ctx context.Context, userID string, id uint, upload models.ProfilePhotoUpload) (*models.ProfilePhoto, error)
+	Reorder(userID string, ids []uint) ([]models.ProfilePhoto, error)
+	SetPrimary(userID string, id uint) error
+	Delete(userID string, id uint) error
+	Moderate(ctx context.Context, moderation models.PhotoModerationPayload) error
+	ReviewQueue() ([]models.ProfilePhoto, error)
+	Approve(id uint, reviewerID string) error
+	Reject(id uint, reviewerID, reason string) error
+}
+
+// PhotoService manages the photo gallery of profiles, new photos are moderated in the background
+type PhotoService struct {
+	logger     *core.Logger
+	clock      core.Clock
+	repository repositories.IProfilePhotoRepository
+	jobService IJobService
+	blobStore  core.BlobStore
+	urlSigner  *core.BlobURLSigner
+	moderator  core.PhotoModerator
+}
+
+// NewPhotoService creates a new photo service
+func NewPhotoService(
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.IProfilePhotoRepository,
+	jobService IJobService,
+	blobStore core.BlobStore,
+	urlSigner *core.BlobURLSigner,
+	moderator core.PhotoModerator,
+) IPhotoService {
+	s := &PhotoService{
+		logger:     logger,
+		clock:      clock,
+		repository: repository,
+		jobService: jobService,
+		blobStore:  blobStore,
+		urlSigner:  urlSigner,
+		moderator:  moderator,
+	}
+
+	jobService.Register(JobTypeModeratePhoto, func(ctx context.Context, payload []byte) error {
+		var moderation models.PhotoModerationPayload
+		if err := json.Unmarshal(payload, &moderation); err != nil {
+			return err
+		}
+		return s.Moderate(ctx, moderation)
+	})
+
+	return s
+}
+
+func (s *PhotoService) List(userID string) ([]models.ProfilePhoto, error) {
+	return s.repository.FindListByUserId(userID)
+}
+
+// FreeSlots returns how many more photos fit in the gallery
+func (s *PhotoService) FreeSlots(userID string) (int, error) {
+	existing, err := s.repository.FindListByUserId(userID)
+	if err != nil {
+		return 0, err
+	}
+	if free := models.MaxProfilePhotos - len(existing); free > 0 {
+		return free, nil
+	}
+	return 0, nil
+}
+
+// Upload validates, resizes and stores the images, then appends them to the gallery in the
+// given order. Every file gets a result, one bad or failed file doesn't stop the others.
+// More files than free slots fail the whole request with ErrPhotoLimitReached before any
+// image is decoded.
+func (s *PhotoService) Upload(ctx context.Context, userID string, uploads []models.ProfilePhotoUpload) ([]models.ProfilePhotoUploadResult, error) {
+	free, err := s.FreeSlots(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(uploads) > free {
+		return nil, ErrPhotoLimitReached
+	}
+
+	results := make([]models.ProfilePhotoUploadResult, len(uploads))
+	processed := make([]*utils.ProcessedImage, len(uploads))
+	runBounded(len(uploads), photoUploadConcurrency, func(i int) {
+		results[i].Filename = uploads[i].Filename
+		image, err := utils.ProcessImage(uploads[i].Content)
+		if err != nil {
+			results[i].Status, results[i].Reason = models.ProfilePhotoUploadRejected, err.Error()
+			return
+		}
+		processed[i] = image
+	})
+
+	stored := make([]*models.ProfilePhoto, len(uploads))
+	runBounded(len(uploads), photoUploadConcurrency, func(i int) {
+		if processed[i] == nil {
+			return
+		}
+		photo, err := s.storeFiles(ctx, userID, processed[i])
+		if err != nil {
+			s.logger.Errorf("fail to store photo, user [%v], file [%v], error [%v]", userID, uploads[i].Filename, err)
+			results[i].Status, results[i].Reason = models.ProfilePhotoUploadFailed, storeFailureReason(err)
+			return
+		}
+		stored[i] = photo
+	})
+
+	// rows are added one at a time so that positions follow the request
+	for i, photo := range stored {
+		if photo == nil {
+			continue
+		}
+		created, added, err := s.repository.Create(*photo, models.MaxProfilePhotos)
+		if err != nil {
+			s.logger.Errorf("fail to add photo, user [%v], file [%v], error [%v]", userID, uploads[i].Filename, err)
+			s.deleteFiles(photo.FileIDs())
+			results[i].Status, results[i].Reason = models.ProfilePhotoUploadFailed, "server error"
+			continue
+		}
+		// another upload took the free slot in the meantime
+		if !added {
+			s.deleteFiles(photo.FileIDs())
+			results[i].Status, results[i].Reason = models.ProfilePhotoUploadRejected, ErrPhotoLimitReached.Error()
+			continue
+		}
+		results[i].Status, results[i].Photo = models.ProfilePhotoUploadUploaded, created.Serialize(s.urlSigner.Sign)
+		s.queueModeration(*created)
+	}
+
+	return results, nil
+}
+
+// Replace swaps the image of a photo, which keeps its id and place in the gallery.
+// The files of the previous image are deleted once the photo points to the new ones.
+func (s *PhotoService) Replace(ctx context.Context, userID string, id uint, upload models.ProfilePhotoUpload) (*models.ProfilePhoto, error) {
+	previous, err := s.repository.First(userID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, err
+	}
+
+	image, err := utils.ProcessImage(upload.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", upload.Filename, err)
+	}
+
+	photo, err := s.storeFiles(ctx, userID, image)
+	if err != nil {
+		return nil, err
+	}
+	photo.ID = previous.ID
+
+	if err = s.repository.ReplaceFiles(*photo); err != nil {
+		s.deleteFiles(photo.FileIDs())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, err
+	}
+	s.deleteFiles(previous.FileIDs())
+	s.queueModeration(*photo)
+
+	return s.repository.First(userID, id)
+}
+
+// Moderate asks the moderator about the photo and stores the verdict, a pending verdict
+// leaves the photo waiting for a human
+func (s *PhotoService) Moderate(ctx context.Context, moderation models.PhotoModerationPayload) error {
+	photo, err := s.repository.First(moderation.UserID, moderation.PhotoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	// replaced since, the new files have their own job
+	if photo.FileID != moderation.FileID {
+		return nil
+	}
+
+	url, err := s.blobStore.SignedURL(photo.CardOrFullPath(), blobFetchURLTTL)
+	if err != nil {
+		return err
+	}
+	verdict, err := s.moderator.Moderate(ctx, core.PhotoModerationInput{
+		URL:    url,
+		Width:  photo.Width,
+		Height: photo.Height,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch verdict.Status {
+	case core.PhotoVerdictApproved:
+		err = s.repository.SetModeration(photo.ID, photo.FileID, models.ProfilePhotoStatusApproved, "", s.clock.Now())
+	case core.PhotoVerdictRejected:
+		err = s.repository.SetModeration(photo.ID, photo.FileID, models.ProfilePhotoStatusRejected, verdict.Reason, s.clock.Now())
+		if err == nil {
+			s.logger.Infof("photo rejected, user [%v], photo [%v], reason [%v]", photo.UserID, photo.ID, verdict.Reason)
+		}
+	}
+	return err
+}
+
+// ReviewQueue lists the photos the moderator left to the admins, oldest first
+func (s *PhotoService) ReviewQueue() ([]models.ProfilePhoto, error) {
+	return s.repository.FindListByStatus(models.ProfilePhotoStatusPending, photoReviewQueueLimit)
+}
+
+func (s *PhotoService) Approve(id uint, reviewerID string) error {
+	return s.review(id, models.ProfilePhotoStatusApproved, "", reviewerID)
+}
+
+func (s *PhotoService) Reject(id uint, reviewerID, reason string) error {
+	return s.review(id, models.ProfilePhotoStatusRejected, reason, reviewerID)
+}
+
+// Reorder puts the gallery in the order of ids, which has to list every photo once
+func (s *PhotoService) Reorder(userID string, ids []uint) ([]models.ProfilePhoto, error) {
+	if err := s.repository.Reorder(userID, ids); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhotoOrderInvalid
+		}
+		return nil, err
+	}
+	return s.repository.FindListByUserId(userID)
+}
+
+func (s *PhotoService) SetPrimary(userID string, id uint) error {
+	err := s.repository.SetPrimary(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPhotoNotFound
+	}
+	return err
+}
+
+// Delete removes the photo from the gallery, the stored file is deleted in the background
+func (s *PhotoService) Delete(userID string, id uint) error {
+	photo, err := s.repository.First(userID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPhotoNotFound
+		}
+		return err
+	}
+
+	if err = s.repository.Delete(userID, id); err != nil {
+		return err
+	}
+
+	s.deleteFiles(photo.FileIDs())
+	return nil
+}
+
+// ----------------- private -----------------
+
+func (s *PhotoService) review(id uint, status, reason, reviewerID string) error {
+	reviewed, err := s.repository.Review(id, status, reason, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if !reviewed {
+		return ErrPhotoNotPending
+	}
+	s.logger.Infof("photo reviewed, photo [%v], status [%v], reviewer [%v]", id, status, reviewerID)
+	return nil
+}
+
+// storeFiles uploads every size of the image within photoUploadTimeout and returns the photo
+// to save, files already uploaded are deleted again when a later one fails
+func (s *PhotoService) storeFiles(ctx context.Context, userID string, image *utils.ProcessedImage) (*models.ProfilePhoto, error) {
+	ctx, cancel := context.WithTimeout(ctx, photoUploadTimeout)
+	defer cancel()
+
+	name := "profile_photo_" + uuid.New().String()
+	photo := models.ProfilePhoto{
+		UserID: userID,
+		Width:  image.Full.Width,
+		Height: image.Full.Height,
+		Status: models.ProfilePhotoStatusPending,
+	}
+
+	for _, variant := range []struct {
+		image  utils.ImageVariant
+		fileID *string
+		path   *string
+	}{
+		{image.Full, &photo.FileID, &photo.Path},
+		{image.Card, &photo.CardFileID, &photo.CardPath},
+		{image.Thumbnail, &photo.ThumbnailFileID, &photo.ThumbnailPath},
+	} {
+		blob, err := s.blobStore.Upload(ctx, userID, name+"_"+variant.image.Size.Name+".jpg", variant.image.Content)
+		if err != nil {
+			s.deleteFiles(photo.FileIDs())
+			return nil, err
+		}
+		*variant.fileID, *variant.path = blob.ID, blob.Path
+	}
+
+	return &photo, nil
+}
+
+// storeFailureReason tells the user whether sending the file again may help
+func storeFailureReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "upload timed out"
+	}
+	return "storage unavailable"
+}
+
+// runBounded calls fn for 0..n-1 with at most limit calls running at once
+func runBounded(n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (s *PhotoService) queueModeration(photo models.ProfilePhoto) {
+	err := s.jobService.Enqueue(JobTypeModeratePhoto, models.PhotoModerationPayload{
+		UserID:  photo.UserID,
+		PhotoID: photo.ID,
+		FileID:  photo.FileID,
+	})
+	if err != nil {
+		s.logger.Errorf("fail to queue photo moderation, photo [%v], error [%v]", photo.ID, err)
+	}
+}
+
+func (s *PhotoService) deleteFiles(fileIDs []string) {
+	for _, fileID := range fileIDs {
+		if err := s.jobService.Enqueue(JobTypeDeleteImage, models.ImageDeletePayload{FileID: fileID}); err != nil {
+			s.logger.Errorf("fail to queue image deletion, file [%v], error [%v]", fileID, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testUploads(t *testing.T, n int) []models.ProfilePhotoUpload {
+	t.Helper()
+	content := testJPEG(t, 400, 400)
+	uploads := make([]models.ProfilePhotoUpload, n)
+	for i := range uploads {
+		uploads[i] = models.ProfilePhotoUpload{Filename: "photo.jpg", Content: content}
+	}
+	return uploads
+}
+
+// racingPhotoRepository holds every gallery read until all requests have read it
+type racingPhotoRepository struct {
+	*fakeProfilePhotoRepository
+	listed sync.WaitGroup
+}
+
+func (r *racingPhotoRepository) FindListByUserId(userID string) ([]models.ProfilePhoto, error) {
+	photos, err := r.fakeProfilePhotoRepository.FindListByUserId(userID)
+	r.listed.Done()
+	r.listed.Wait()
+	return photos, err
+}
+
+func TestPhotoService_ConcurrentUploadsStayUnderTheCap(t *testing.T) {
+	clock := newFakeClock()
+	photos := &racingPhotoRepository{fakeProfilePhotoRepository: &fakeProfilePhotoRepository{}}
+	photos.listed.Add(2)
+	jobs := newFakeJobService(clock)
+	blobs := newFakeBlobStore()
+	service := NewPhotoService(newTestLogger(), clock, photos, jobs, blobs, newTestURLSigner(blobs), core.NewRulesPhotoModerator())
+
+	// both requests see an empty gallery before either adds a photo
+	var wg sync.WaitGroup
+	results := make([][]models.ProfilePhotoUploadResult, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			results[i], err = service.Upload(context.Background(), "user-1", testUploads(t, 4))
+			if err != nil {
+				t.Errorf("Upload() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	uploaded, rejected := 0, 0
+	for _, request := range results {
+		for _, result := range request {
+			switch result.Status {
+			case models.ProfilePhotoUploadUploaded:
+				uploaded++
+			case models.ProfilePhotoUploadRejected:
+				if result.Reason != ErrPhotoLimitReached.Error() {
+					t.Fatalf("rejected with %q, want the photo limit", result.Reason)
+				}
+				rejected++
+			}
+		}
+	}
+	if uploaded != models.MaxProfilePhotos || rejected != 2 {
+		t.Fatalf("uploaded %d and rejected %d, want %d and 2", uploaded, rejected, models.MaxProfilePhotos)
+	}
+
+	gallery, _ := photos.fakeProfilePhotoRepository.FindListByUserId("user-1")
+	positions := make(map[int]bool)
+	for _, photo := range gallery {
+		if positions[photo.Position] {
+			t.Fatalf("two photos at position %d", photo.Position)
+		}
+		positions[photo.Position] = true
+	}
+
+	// the files of the photos that lost the race are deleted again
+	if deletes := jobs.Queued(JobTypeDeleteImage); len(deletes) != 2*3 {
+		t.Fatalf("queued %d file deletes, want 6", len(deletes))
+	}
+}
+
+func TestPhotoService_UploadRefusesMoreFilesThanFreeSlots(t *testing.T) {
+	clock := newFakeClock()
+	photos := &fakeProfilePhotoRepository{}
+	for i := 0; i < 4; i++ {
+		_, _, _ = photos.Create(models.ProfilePhoto{UserID: "user-1"}, models.MaxProfilePhotos)
+	}
+	blobs := newFakeBlobStore()
+	service := NewPhotoService(newTestLogger(), clock, photos, newFakeJobService(clock), blobs, newTestURLSigner(blobs), core.NewRulesPhotoModerator())
+
+	free, err := service.FreeSlots("user-1")
+	if err != nil || free != 2 {
+		t.Fatalf("FreeSlots() = %d, %v, want 2", free, err)
+	}
+
+	// not an image: a decoded file would be rejected one by one instead
+	uploads := []models.ProfilePhotoUpload{{Filename: "a.jpg"}, {Filename: "b.jpg"}, {Filename: "c.jpg"}}
+	if _, err = service.Upload(context.Background(), "user-1", uploads); !errors.Is(err, ErrPhotoLimitReached) {
+		t.Fatalf("Upload() error = %v, want %v", err, ErrPhotoLimitReached)
+	}
+	if len(blobs.blobs) != 0 {
+		t.Fatalf("stored %d files", len(blobs.blobs))
+	}
+}
+
+func TestPhotoService_UploadKeepsPathsAndSignsShortLivedURLs(t *testing.T) {
+	clock := newFakeClock()
+	photos := &fakeProfilePhotoRepository{}
+	blobs := newFakeBlobStore()
+	service := NewPhotoService(newTestLogger(), clock, photos, newFakeJobService(clock), blobs, newTestURLSigner(blobs), core.NewRulesPhotoModerator())
+
+	results, err := service.Upload(context.Background(), "user-1", testUploads(t, 1))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if results[0].Status != models.ProfilePhotoUploadUploaded {
+		t.Fatalf("status = %s (%s)", results[0].Status, results[0].Reason)
+	}
+
+	gallery, _ := photos.FindListByUserId("user-1")
+	stored := gallery[0]
+	for _, path := range []string{stored.Path, stored.CardPath, stored.ThumbnailPath} {
+		if path == "" || strings.Contains(path, "://") {
+			t.Fatalf("stored %q, want a blob path", path)
+		}
+	}
+
+	photo := results[0].Photo
+	for _, url := range []string{photo.URL, photo.CardURL, photo.ThumbnailURL} {
+		if !strings.HasSuffix(url, "?ttl=1h0m0s") {
+			t.Fatalf("url %q is not signed for an hour", url)
+		}
+	}
+	if photo.CardURL != "https://blobs.test/"+stored.CardPath+"?ttl=1h0m0s" {
+		t.Fatalf("card url = %s, want the card path", photo.CardURL)
+	}
+}
+
+func TestPhotoService_ManualModerationWaitsForAnAdmin(t *testing.T) {
+	clock := newFakeClock()
+	photos := &fakeProfilePhotoRepository{}
+	jobs := newFakeJobService(clock)
+	blobs := newFakeBlobStore()
+	service := NewPhotoService(newTestLogger(), clock, photos, jobs, blobs, newTestURLSigner(blobs), core.NewManualPhotoModerator())
+
+	if _, err := service.Upload(context.Background(), "user-1", testUploads(t, 2)); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if errs := jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("moderation jobs failed: %v", errs)
+	}
+
+	queue, err := service.ReviewQueue()
+	if err != nil {
+		t.Fatalf("ReviewQueue() error = %v", err)
+	}
+	if len(queue) != 2 {
+		t.Fatalf("%d photos waiting for review, want 2", len(queue))
+	}
+
+	if err = service.Approve(queue[0].ID, "admin-1"); err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if err = service.Reject(queue[1].ID, "admin-1", "no face in the photo"); err != nil {
+		t.Fatalf("Reject() error = %v", err)
+	}
+
+	gallery, _ := photos.FindListByUserId("user-1")
+	if gallery[0].Status != models.ProfilePhotoStatusApproved {
+		t.Fatalf("first photo is %s, want approved", gallery[0].Status)
+	}
+	if gallery[1].Status != models.ProfilePhotoStatusRejected || gallery[1].ModerationReason != "no face in the photo" {
+		t.Fatalf("second photo is %s (%s), want rejected with the reason", gallery[1].Status, gallery[1].ModerationReason)
+	}
+
+	// a reviewed photo left the queue
+	if err = service.Reject(queue[0].ID, "admin-2", "changed my mind"); !errors.Is(err, ErrPhotoNotPending) {
+		t.Fatalf("Reject() of a reviewed photo error = %v, want %v", err, ErrPhotoNotPending)
+	}
+	if queue, _ = service.ReviewQueue(); len(queue) != 0 {
+		t.Fatalf("%d photos still waiting for review, want 0", len(queue))
+	}
+}
@@ -1,55 +1,106 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"github.com/hodukihugi/winglets-api/core"
 	"github.com/hodukihugi/winglets-api/models"
 	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
 	"strings"
 	"time"
 )
 
+const defaultTravelDays = 7
+
+var ErrProfileNotVisible = errors.New("profile not found")
+
+// FieldError tells which field of a request was refused and why
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
 type IProfileService interface {
 	CreateProfile(string, models.ProfileCreateRequest) error
 	GetProfileById(string) (*models.Profile, error)
+	GetVisibleProfile(viewerID, id string) (*models.Profile, error)
+	UpdateVisibility(string, models.ProfileVisibilityRequest) (*models.ProfileVisibility, error)
+	GeocodeMissingLocations() (int, error)
+	GetTravel(string) (*models.SerializableTravel, error)
+	SetTravel(string, models.TravelRequest) (*models.SerializableTravel, error)
+	ClearTravel(string) error
 	UpdateProfileById(string, models.ProfileUpdateRequest) error
-	UpdateProfileImageById(string, []int) error
+	PatchProfileById(string, models.ProfilePatchRequest) error
 	DeleteProfileById(string) error
+	GetTaxonomy() (*models.SerializableTaxonomy, error)
+	GetPreferences(string) (*models.UserPreference, error)
+	SavePreferences(string, models.UserPreferenceRequest) (*models.UserPreference, error)
 }
 
 type ProfileService struct {
-	repository repositories.IProfileRepository
-	logger     *core.Logger
+	clock              core.Clock
+	repository         repositories.IProfileRepository
+	taxonomyRepository repositories.ITaxonomyRepository
+	preferenceRepo     repositories.IPreferenceRepository
+	matchRepo          repositories.IMatchRepository
+	logger             *core.Logger
 }
 
-func NewProfileService(repository repositories.IProfileRepository, logger *core.Logger) IProfileService {
+func NewProfileService(
+	clock core.Clock,
+	repository repositories.IProfileRepository,
+	taxonomyRepository repositories.ITaxonomyRepository,
+	preferenceRepo repositories.IPreferenceRepository,
+	matchRepo repositories.IMatchRepository,
+	logger *core.Logger,
+) IProfileService {
 	return &ProfileService{
-		repository: repository,
-		logger:     logger,
+		clock:              clock,
+		repository:         repository,
+		taxonomyRepository: taxonomyRepository,
+		preferenceRepo:     preferenceRepo,
+		matchRepo:          matchRepo,
+		logger:             logger,
 	}
 }
 
 func (s *ProfileService) CreateProfile(userID string, request models.ProfileCreateRequest) error {
-	var coordinates = []string{
-		fmt.Sprintf("%.6f", request.Coordinates.Longitude),
-		fmt.Sprintf("%.6f", request.Coordinates.Latitude),
+	if err := s.validateBirthday(request.BirthdayInSeconds); err != nil {
+		return err
+	}
+	if err := validateHeight(request.HeightCm); err != nil {
+		return err
+	}
+	details, err := s.resolveDetails(request.Interests, request.Languages, request.Prompts)
+	if err != nil {
+		return err
 	}
 
-	_, err := s.repository.CreateProfile(models.Profile{
+	coordinates, location := locate(request.Coordinates.Longitude, request.Coordinates.Latitude)
+
+	_, err = s.repository.CreateProfile(models.Profile{
 		ID:          userID,
 		Name:        request.Name,
 		Gender:      request.Gender,
 		Birthday:    time.Unix(request.BirthdayInSeconds, 0).UTC(),
-		Height:      request.Height,
+		HeightCm:    request.HeightCm,
 		Horoscope:   request.Horoscope,
-		Hobby:       strings.Join(request.Hobby, ","),
-		Language:    strings.Join(request.Language, ","),
 		Education:   request.Education,
 		HomeTown:    request.HomeTown,
-		Coordinates: strings.Join(coordinates, ","),
+		Location:    location,
+		Coordinates: coordinates,
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.repository.ReplaceProfileDetails(userID, details)
 }
 
 func (s *ProfileService) GetProfileById(id string) (*models.Profile, error) {
@@ -60,43 +111,416 @@ func (s *ProfileService) GetProfileById(id string) (*models.Profile, error) {
 	return result, nil
 }
 
+// GetVisibleProfile returns the profile as viewer may see it. Paused profiles are only shown to
+// their owner and incognito ones to the people they liked, others get ErrProfileNotVisible.
+func (s *ProfileService) GetVisibleProfile(viewerID, id string) (*models.Profile, error) {
+	profile, err := s.repository.GetProfileById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProfileNotVisible
+		}
+		return nil, err
+	}
+	if viewerID == id || !profile.Hidden() {
+		return profile, nil
+	}
+	if profile.DiscoveryPaused {
+		return nil, ErrProfileNotVisible
+	}
+
+	liked, err := s.matchRepo.HasLiked(id, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !liked {
+		return nil, ErrProfileNotVisible
+	}
+	return profile, nil
+}
+
+// UpdateVisibility changes the settings present in the request
+func (s *ProfileService) UpdateVisibility(id string, request models.ProfileVisibilityRequest) (*models.ProfileVisibility, error) {
+	changes := map[string]interface{}{}
+	if request.DiscoveryPaused != nil {
+		changes["discovery_paused"] = *request.DiscoveryPaused
+	}
+	if request.Incognito != nil {
+		changes["incognito"] = *request.Incognito
+	}
+	if request.HideAge != nil {
+		changes["hide_age"] = *request.HideAge
+	}
+	if request.HideDistance != nil {
+		changes["hide_distance"] = *request.HideDistance
+	}
+
+	if err := s.repository.PatchProfileById(id, changes, models.ProfileDetails{}); err != nil {
+		return nil, err
+	}
+
+	profile, err := s.repository.GetProfileById(id)
+	if err != nil {
+		return nil, err
+	}
+	return &profile.ProfileVisibility, nil
+}
+
 func (s *ProfileService) UpdateProfileById(id string, request models.ProfileUpdateRequest) error {
-	var coordinates = []string{
-		fmt.Sprintf("%.6f", request.Coordinates.Longitude),
-		fmt.Sprintf("%.6f", request.Coordinates.Latitude),
+	// a missing birthday is left as it is instead of becoming 1970-01-01
+	var birthday time.Time
+	if request.BirthdayInSeconds != 0 {
+		if err := s.validateBirthday(request.BirthdayInSeconds); err != nil {
+			return err
+		}
+		birthday = time.Unix(request.BirthdayInSeconds, 0).UTC()
+	}
+	if err := validateHeight(request.HeightCm); err != nil {
+		return err
+	}
+	details, err := s.resolveDetails(request.Interests, request.Languages, request.Prompts)
+	if err != nil {
+		return err
 	}
 
-	_, err := s.repository.UpdateProfileById(id, models.Profile{
+	coordinates, location := locate(request.Coordinates.Longitude, request.Coordinates.Latitude)
+
+	_, err = s.repository.UpdateProfileById(id, models.Profile{
 		Name:        request.Name,
 		Gender:      request.Gender,
-		Birthday:    time.Unix(request.BirthdayInSeconds, 0).UTC(),
-		Height:      request.Height,
+		Birthday:    birthday,
+		HeightCm:    request.HeightCm,
 		Horoscope:   request.Horoscope,
-		Hobby:       strings.Join(request.Hobby, ","),
-		Language:    strings.Join(request.Language, ","),
 		Education:   request.Education,
 		HomeTown:    request.HomeTown,
-		Coordinates: strings.Join(coordinates, ","),
-		ImageId1:    request.ImageId1,
-		ImageId2:    request.ImageId2,
-		ImageId3:    request.ImageId3,
-		ImageId4:    request.ImageId4,
-		ImageId5:    request.ImageId5,
-		ImageUrl1:   request.ImageUrl1,
-		ImageUrl2:   request.ImageUrl2,
-		ImageUrl3:   request.ImageUrl3,
-		ImageUrl4:   request.ImageUrl4,
-		ImageUrl5:   request.ImageUrl5,
+		Location:    location,
+		Coordinates: coordinates,
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.repository.ReplaceProfileDetails(id, details)
 }
 
-func (s *ProfileService) UpdateProfileImageById(id string, slots []int) error {
-	_, err := s.repository.UpdateProfileImageById(id, slots)
-	return err
+// PatchProfileById changes only the fields present in the request, see ProfilePatchRequest.
+// gorm.ErrRecordNotFound is returned when the user has no profile.
+func (s *ProfileService) PatchProfileById(id string, request models.ProfilePatchRequest) error {
+	changes := map[string]interface{}{}
+
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return &FieldError{Field: "name", Message: "name can't be empty"}
+		}
+		changes["name"] = name
+	}
+	if request.Gender != nil {
+		changes["gender"] = *request.Gender
+	}
+	if request.BirthdayInSeconds != nil {
+		if err := s.validateBirthday(*request.BirthdayInSeconds); err != nil {
+			return err
+		}
+		changes["birthday"] = time.Unix(*request.BirthdayInSeconds, 0).UTC()
+	}
+	if request.HeightCm != nil {
+		if *request.HeightCm == 0 {
+			changes["height_cm"] = nil
+		} else if err := validateHeight(request.HeightCm); err != nil {
+			return err
+		} else {
+			changes["height_cm"] = *request.HeightCm
+		}
+	}
+	if request.Horoscope != nil {
+		changes["horoscope"] = *request.Horoscope
+	}
+	if request.Education != nil {
+		changes["education"] = *request.Education
+	}
+	if request.HomeTown != nil {
+		changes["home_town"] = *request.HomeTown
+	}
+	if request.Coordinates != nil {
+		changes["coordinates"], changes["location"] = locate(*request.Coordinates.Longitude, *request.Coordinates.Latitude)
+	}
+
+	details, err := s.resolveDetails(request.Interests, request.Languages, request.Prompts)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.PatchProfileById(id, changes, details)
 }
+
 func (s *ProfileService) DeleteProfileById(id string) error {
 	err := s.repository.DeleteProfileById(id)
 	return err
 }
+
+// GetTaxonomy lists the interests, languages and prompt questions a profile can pick from
+func (s *ProfileService) GetTaxonomy() (*models.SerializableTaxonomy, error) {
+	interests, err := s.taxonomyRepository.FindInterests()
+	if err != nil {
+		return nil, err
+	}
+	languages, err := s.taxonomyRepository.FindLanguages()
+	if err != nil {
+		return nil, err
+	}
+	questions, err := s.taxonomyRepository.FindPromptQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	taxonomy := &models.SerializableTaxonomy{
+		Interests:       make([]models.SerializableInterest, 0, len(interests)),
+		Languages:       make([]models.SerializableLanguage, 0, len(languages)),
+		PromptQuestions: make([]models.SerializablePromptQuestion, 0, len(questions)),
+		Limits: map[string]int{
+			"interests":            models.MaxProfileInterests,
+			"languages":            models.MaxProfileLanguages,
+			"prompts":              models.MaxProfilePrompts,
+			"prompt_answer_length": models.MaxPromptAnswerLength,
+			"min_height_cm":        models.MinProfileHeightCm,
+			"max_height_cm":        models.MaxProfileHeightCm,
+		},
+	}
+	for _, interest := range interests {
+		taxonomy.Interests = append(taxonomy.Interests, interest.Serialize())
+	}
+	for _, language := range languages {
+		taxonomy.Languages = append(taxonomy.Languages, language.Serialize())
+	}
+	for _, question := range questions {
+		taxonomy.PromptQuestions = append(taxonomy.PromptQuestions, question.Serialize())
+	}
+	return taxonomy, nil
+}
+
+// GetPreferences returns gorm.ErrRecordNotFound until the user saved preferences
+func (s *ProfileService) GetPreferences(userID string) (*models.UserPreference, error) {
+	return s.preferenceRepo.First(userID)
+}
+
+func (s *ProfileService) SavePreferences(userID string, request models.UserPreferenceRequest) (*models.UserPreference, error) {
+	return s.preferenceRepo.Save(models.UserPreference{
+		UserID:       userID,
+		InterestedIn: request.InterestedIn,
+		MinAge:       request.MinAge,
+		MaxAge:       request.MaxAge,
+		MaxDistance:  request.MaxDistance,
+	})
+}
+
+// GeocodeMissingLocations names the city of profiles saved before locations existed and
+// returns how many got one. Profiles far from every known city stay without a location.
+func (s *ProfileService) GeocodeMissingLocations() (int, error) {
+	const batchSize = 500
+
+	located, afterID := 0, ""
+	for {
+		profiles, err := s.repository.FindListWithoutLocation(afterID, batchSize)
+		if err != nil {
+			return located, err
+		}
+		for _, profile := range profiles {
+			afterID = profile.ID
+			longitude, latitude, err := utils.CoordinatesStringToPairFloat64(profile.Coordinates)
+			if err != nil {
+				s.logger.Warnf("skipping profile [%v] with invalid coordinates [%v]", profile.ID, profile.Coordinates)
+				continue
+			}
+			location := utils.ReverseGeocode(longitude, latitude)
+			if location == "" {
+				continue
+			}
+			if err = s.repository.SetLocation(profile.ID, location); err != nil {
+				return located, err
+			}
+			located++
+		}
+		if len(profiles) < batchSize {
+			return located, nil
+		}
+	}
+}
+
+// GetTravel returns the travel location in effect, or nil
+func (s *ProfileService) GetTravel(userID string) (*models.SerializableTravel, error) {
+	profile, err := s.repository.GetProfileById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.Traveling(s.clock.Now()) {
+		return nil, nil
+	}
+	return &models.SerializableTravel{City: profile.TravelLocation, ExpiresAt: *profile.TravelExpiresAt}, nil
+}
+
+// SetTravel makes the user discover from a bundled city for the given number of days, it
+// replaces the travel location in effect
+func (s *ProfileService) SetTravel(userID string, request models.TravelRequest) (*models.SerializableTravel, error) {
+	city, ok := utils.FindCity(request.City)
+	if !ok {
+		return nil, &FieldError{Field: "city", Message: fmt.Sprintf("unknown city %q", request.City)}
+	}
+	days := request.Days
+	if days <= 0 {
+		days = defaultTravelDays
+	}
+	expiresAt := s.clock.Now().AddDate(0, 0, days).UTC()
+
+	err := s.repository.PatchProfileById(userID, map[string]interface{}{
+		"travel_location":    city.Label(),
+		"travel_coordinates": fmt.Sprintf("%.6f,%.6f", city.Longitude, city.Latitude),
+		"travel_expires_at":  expiresAt,
+	}, models.ProfileDetails{})
+	if err != nil {
+		return nil, err
+	}
+	return &models.SerializableTravel{City: city.Label(), ExpiresAt: expiresAt}, nil
+}
+
+// ClearTravel brings the user back to their own coordinates
+func (s *ProfileService) ClearTravel(userID string) error {
+	return s.repository.PatchProfileById(userID, map[string]interface{}{
+		"travel_location":    "",
+		"travel_coordinates": "",
+		"travel_expires_at":  nil,
+	}, models.ProfileDetails{})
+}
+
+// ----------------- private -----------------
+
+// locate snaps the coordinates to the grid and names the city they are in, the precise
+// position is never stored
+func locate(longitude, latitude float64) (string, string) {
+	longitude, latitude = utils.SnapCoordinates(longitude, latitude)
+	return fmt.Sprintf("%.6f,%.6f", longitude, latitude), utils.ReverseGeocode(longitude, latitude)
+}
+
+// validateBirthday refuses birthdays of users younger than models.MinProfileAge
+func (s *ProfileService) validateBirthday(birthdayInSeconds int64) error {
+	latest := s.clock.Now().AddDate(-models.MinProfileAge, 0, 0)
+	if time.Unix(birthdayInSeconds, 0).After(latest) {
+		return &FieldError{
+			Field:   "birthday_in_seconds",
+			Message: fmt.Sprintf("you must be at least %d years old", models.MinProfileAge),
+		}
+	}
+	return nil
+}
+
+func validateHeight(heightCm *int) error {
+	if heightCm != nil && (*heightCm < models.MinProfileHeightCm || *heightCm > models.MaxProfileHeightCm) {
+		return &FieldError{
+			Field:   "height_cm",
+			Message: fmt.Sprintf("height must be between %d and %d cm", models.MinProfileHeightCm, models.MaxProfileHeightCm),
+		}
+	}
+	return nil
+}
+
+// resolveDetails maps the slugs and codes of a request to taxonomy ids, a nil list
+// stays nil so that the stored entries are kept
+func (s *ProfileService) resolveDetails(interestSlugs, languageCodes []string, prompts []models.ProfilePromptRequest) (models.ProfileDetails, error) {
+	var details models.ProfileDetails
+
+	if interestSlugs != nil {
+		if err := checkPicks("interests", interestSlugs, models.MaxProfileInterests); err != nil {
+			return details, err
+		}
+		interests, err := s.taxonomyRepository.FindInterestsBySlugs(interestSlugs)
+		if err != nil {
+			return details, err
+		}
+		bySlug := make(map[string]uint, len(interests))
+		for _, interest := range interests {
+			bySlug[interest.Slug] = interest.ID
+		}
+		details.InterestIDs = make([]uint, 0, len(interestSlugs))
+		for _, slug := range interestSlugs {
+			id, ok := bySlug[slug]
+			if !ok {
+				return details, &FieldError{Field: "interests", Message: fmt.Sprintf("unknown interest %q", slug)}
+			}
+			details.InterestIDs = append(details.InterestIDs, id)
+		}
+	}
+
+	if languageCodes != nil {
+		if err := checkPicks("languages", languageCodes, models.MaxProfileLanguages); err != nil {
+			return details, err
+		}
+		languages, err := s.taxonomyRepository.FindLanguagesByCodes(languageCodes)
+		if err != nil {
+			return details, err
+		}
+		byCode := make(map[string]uint, len(languages))
+		for _, language := range languages {
+			byCode[language.Code] = language.ID
+		}
+		details.LanguageIDs = make([]uint, 0, len(languageCodes))
+		for _, code := range languageCodes {
+			id, ok := byCode[code]
+			if !ok {
+				return details, &FieldError{Field: "languages", Message: fmt.Sprintf("unknown language %q", code)}
+			}
+			details.LanguageIDs = append(details.LanguageIDs, id)
+		}
+	}
+
+	if prompts != nil {
+		slugs := make([]string, 0, len(prompts))
+		for _, prompt := range prompts {
+			slugs = append(slugs, prompt.Question)
+		}
+		if err := checkPicks("prompts", slugs, models.MaxProfilePrompts); err != nil {
+			return details, err
+		}
+		questions, err := s.taxonomyRepository.FindPromptQuestionsBySlugs(slugs)
+		if err != nil {
+			return details, err
+		}
+		bySlug := make(map[string]uint, len(questions))
+		for _, question := range questions {
+			bySlug[question.Slug] = question.ID
+		}
+		details.Prompts = make([]models.ProfilePrompt, 0, len(prompts))
+		for _, prompt := range prompts {
+			id, ok := bySlug[prompt.Question]
+			if !ok {
+				return details, &FieldError{Field: "prompts", Message: fmt.Sprintf("unknown prompt question %q", prompt.Question)}
+			}
+			answer := strings.TrimSpace(prompt.Answer)
+			if answer == "" {
+				return details, &FieldError{Field: "prompts", Message: "prompt answers can't be empty"}
+			}
+			if len([]rune(answer)) > models.MaxPromptAnswerLength {
+				return details, &FieldError{
+					Field:   "prompts",
+					Message: fmt.Sprintf("prompt answers are at most %d characters", models.MaxPromptAnswerLength),
+				}
+			}
+			details.Prompts = append(details.Prompts, models.ProfilePrompt{QuestionID: id, Answer: answer})
+		}
+	}
+
+	return details, nil
+}
+
+// checkPicks refuses lists that are too long or name the same entry twice
+func checkPicks(field string, values []string, limit int) error {
+	if len(values) > limit {
+		return &FieldError{Field: field, Message: fmt.Sprintf("pick at most %d %s", limit, field)}
+	}
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if seen[value] {
+			return &FieldError{Field: field, Message: fmt.Sprintf("%q is listed twice", value)}
+		}
+		seen[value] = true
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+const homeCoordinates = "105.850000,21.030000"
+
+// fakeTaxonomyRepository knows a few interests, languages and prompt questions
+type fakeTaxonomyRepository struct {
+	repositories.ITaxonomyRepository
+}
+
+func (r *fakeTaxonomyRepository) FindInterestsBySlugs(slugs []string) ([]models.Interest, error) {
+	known := map[string]uint{"hiking": 1, "cooking": 2, "jazz": 3}
+	var interests []models.Interest
+	for _, slug := range slugs {
+		if id, ok := known[slug]; ok {
+			interests = append(interests, models.Interest{ID: id, Slug: slug})
+		}
+	}
+	return interests, nil
+}
+
+func (r *fakeTaxonomyRepository) FindLanguagesByCodes(codes []string) ([]models.Language, error) {
+	known := map[string]uint{"en": 1, "vi": 2}
+	var languages []models.Language
+	for _, code := range codes {
+		if id, ok := known[code]; ok {
+			languages = append(languages, models.Language{ID: id, Code: code})
+		}
+	}
+	return languages, nil
+}
+
+func (r *fakeTaxonomyRepository) FindPromptQuestionsBySlugs(slugs []string) ([]models.PromptQuestion, error) {
+	known := map[string]uint{"perfect-sunday": 1}
+	var questions []models.PromptQuestion
+	for _, slug := range slugs {
+		if id, ok := known[slug]; ok {
+			questions = append(questions, models.PromptQuestion{ID: id, Slug: slug})
+		}
+	}
+	return questions, nil
+}
+
+func newTestProfileService(clock *fakeClock) (IProfileService, *fakeProfileRepository) {
+	profiles := &fakeProfileRepository{profiles: map[string]models.Profile{
+		"ana": {ID: "ana", Name: "Ana", Coordinates: homeCoordinates, Location: "Hanoi, Vietnam"},
+	}}
+	return NewProfileService(clock, profiles, &fakeTaxonomyRepository{}, nil, nil, newTestLogger()), profiles
+}
+
+func TestTravel_Traveling(t *testing.T) {
+	future := testNow.Add(time.Hour)
+	past := testNow.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		travel models.Travel
+		want   bool
+	}{
+		{"never set", models.Travel{}, false},
+		{"in effect", models.Travel{TravelCoordinates: "2.350000,48.860000", TravelExpiresAt: &future}, true},
+		{"expired", models.Travel{TravelCoordinates: "2.350000,48.860000", TravelExpiresAt: &past}, false},
+		{"expires now", models.Travel{TravelCoordinates: "2.350000,48.860000", TravelExpiresAt: &testNow}, false},
+		{"no coordinates", models.Travel{TravelExpiresAt: &future}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.travel.Traveling(testNow); got != tt.want {
+				t.Fatalf("Traveling() = %v, want %v", got, tt.want)
+			}
+
+			profile := models.Profile{Coordinates: homeCoordinates, Travel: tt.travel}
+			want := homeCoordinates
+			if tt.want {
+				want = tt.travel.TravelCoordinates
+			}
+			if got := profile.DiscoveryCoordinates(testNow); got != want {
+				t.Fatalf("DiscoveryCoordinates() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestProfileService_TravelExpires(t *testing.T) {
+	clock := newFakeClock()
+	service, profiles := newTestProfileService(clock)
+
+	travel, err := service.SetTravel("ana", models.TravelRequest{City: "paris, france", Days: 3})
+	if err != nil {
+		t.Fatalf("SetTravel() error = %v", err)
+	}
+	if travel.City != "Paris, France" {
+		t.Fatalf("SetTravel() city = %s, want Paris, France", travel.City)
+	}
+	if want := testNow.AddDate(0, 0, 3); !travel.ExpiresAt.Equal(want) {
+		t.Fatalf("SetTravel() expires at %v, want %v", travel.ExpiresAt, want)
+	}
+
+	profile := profiles.profiles["ana"]
+	if got := profile.DiscoveryCoordinates(clock.Now()); got == homeCoordinates || got != profile.TravelCoordinates {
+		t.Fatalf("DiscoveryCoordinates() = %s while travelling", got)
+	}
+
+	clock.Advance(3*24*time.Hour - time.Second)
+	if current, err := service.GetTravel("ana"); err != nil || current == nil || current.City != "Paris, France" {
+		t.Fatalf("GetTravel() a second before expiry = %+v, %v", current, err)
+	}
+
+	clock.Advance(time.Second)
+	if current, err := service.GetTravel("ana"); err != nil || current != nil {
+		t.Fatalf("GetTravel() at expiry = %+v, %v, want nothing", current, err)
+	}
+	if got := profile.DiscoveryCoordinates(clock.Now()); got != homeCoordinates {
+		t.Fatalf("DiscoveryCoordinates() = %s after expiry, want %s", got, homeCoordinates)
+	}
+}
+
+func TestProfileService_SetTravel(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     models.TravelRequest
+		wantField   string
+		wantExpires time.Time
+	}{
+		{"default days", models.TravelRequest{City: "Paris, France"}, "", testNow.AddDate(0, 0, defaultTravelDays)},
+		{"two weeks", models.TravelRequest{City: "Paris, France", Days: 14}, "", testNow.AddDate(0, 0, 14)},
+		{"unknown city", models.TravelRequest{City: "Atlantis"}, "city", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, profiles := newTestProfileService(newFakeClock())
+
+			travel, err := service.SetTravel("ana", tt.request)
+			if tt.wantField != "" {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+					t.Fatalf("SetTravel() error = %v, want a %s field error", err, tt.wantField)
+				}
+				if len(profiles.patches) != 0 {
+					t.Fatalf("SetTravel() wrote %v", profiles.patches)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetTravel() error = %v", err)
+			}
+			if !travel.ExpiresAt.Equal(tt.wantExpires) {
+				t.Fatalf("SetTravel() expires at %v, want %v", travel.ExpiresAt, tt.wantExpires)
+			}
+		})
+	}
+}
+
+func TestProfileService_ClearTravel(t *testing.T) {
+	clock := newFakeClock()
+	service, profiles := newTestProfileService(clock)
+
+	if _, err := service.SetTravel("ana", models.TravelRequest{City: "Paris, France"}); err != nil {
+		t.Fatalf("SetTravel() error = %v", err)
+	}
+	if err := service.ClearTravel("ana"); err != nil {
+		t.Fatalf("ClearTravel() error = %v", err)
+	}
+
+	if current, err := service.GetTravel("ana"); err != nil || current != nil {
+		t.Fatalf("GetTravel() after ClearTravel = %+v, %v, want nothing", current, err)
+	}
+	profile := profiles.profiles["ana"]
+	if profile.Travel != (models.Travel{}) {
+		t.Fatalf("travel after ClearTravel = %+v, want it cleared", profile.Travel)
+	}
+}
+
+func TestProfileService_PatchProfileById(t *testing.T) {
+	text := func(s string) *string { return &s }
+	number := func(n int) *int { return &n }
+	seconds := func(at time.Time) *int64 { unix := at.Unix(); return &unix }
+	coordinate := func(f float64) *float64 { return &f }
+	adult := testNow.AddDate(-models.MinProfileAge, 0, 0)
+
+	tests := []struct {
+		name        string
+		request     models.ProfilePatchRequest
+		wantChanges map[string]interface{}
+		wantDetails models.ProfileDetails
+	}{
+		{
+			name:        "nothing given keeps everything",
+			wantChanges: map[string]interface{}{},
+		},
+		{
+			name:        "name is trimmed",
+			request:     models.ProfilePatchRequest{Name: text("  Ana B ")},
+			wantChanges: map[string]interface{}{"name": "Ana B"},
+		},
+		{
+			name:        "empty strings clear",
+			request:     models.ProfilePatchRequest{Education: text(""), HomeTown: text(""), Horoscope: text("")},
+			wantChanges: map[string]interface{}{"education": "", "home_town": "", "horoscope": ""},
+		},
+		{
+			name:        "birthday on the 18th birthday",
+			request:     models.ProfilePatchRequest{BirthdayInSeconds: seconds(adult)},
+			wantChanges: map[string]interface{}{"birthday": adult},
+		},
+		{
+			name:        "height",
+			request:     models.ProfilePatchRequest{HeightCm: number(172)},
+			wantChanges: map[string]interface{}{"height_cm": 172},
+		},
+		{
+			name:        "zero height clears",
+			request:     models.ProfilePatchRequest{HeightCm: number(0)},
+			wantChanges: map[string]interface{}{"height_cm": nil},
+		},
+		{
+			name:        "coordinates at zero are given",
+			request:     models.ProfilePatchRequest{Coordinates: &models.ProfileCoordinatesRequest{Longitude: coordinate(0), Latitude: coordinate(0)}},
+			wantChanges: map[string]interface{}{"coordinates": "0.000000,0.000000", "location": ""},
+		},
+		{
+			name:        "coordinates are snapped and named",
+			request:     models.ProfilePatchRequest{Coordinates: &models.ProfileCoordinatesRequest{Longitude: coordinate(105.85423), Latitude: coordinate(21.02849)}},
+			wantChanges: map[string]interface{}{"coordinates": homeCoordinates, "location": "Hanoi, Vietnam"},
+		},
+		{
+			name: "details are resolved",
+			request: models.ProfilePatchRequest{
+				Interests: []string{"jazz", "hiking"},
+				Languages: []string{"vi"},
+				Prompts:   []models.ProfilePromptRequest{{Question: "perfect-sunday", Answer: " pho and a long walk "}},
+			},
+			wantChanges: map[string]interface{}{},
+			wantDetails: models.ProfileDetails{
+				InterestIDs: []uint{3, 1},
+				LanguageIDs: []uint{2},
+				Prompts:     []models.ProfilePrompt{{QuestionID: 1, Answer: "pho and a long walk"}},
+			},
+		},
+		{
+			name:        "empty lists clear",
+			request:     models.ProfilePatchRequest{Interests: []string{}, Languages: []string{}, Prompts: []models.ProfilePromptRequest{}},
+			wantChanges: map[string]interface{}{},
+			wantDetails: models.ProfileDetails{InterestIDs: []uint{}, LanguageIDs: []uint{}, Prompts: []models.ProfilePrompt{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, profiles := newTestProfileService(newFakeClock())
+
+			if err := service.PatchProfileById("ana", tt.request); err != nil {
+				t.Fatalf("PatchProfileById() error = %v", err)
+			}
+			if len(profiles.patches) != 1 {
+				t.Fatalf("PatchProfileById() wrote %d patches, want 1", len(profiles.patches))
+			}
+			if !reflect.DeepEqual(profiles.patches[0], tt.wantChanges) {
+				t.Fatalf("changes = %#v, want %#v", profiles.patches[0], tt.wantChanges)
+			}
+			if !reflect.DeepEqual(profiles.details[0], tt.wantDetails) {
+				t.Fatalf("details = %#v, want %#v", profiles.details[0], tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestProfileService_ResolveDetails(t *testing.T) {
+	service, _ := newTestProfileService(newFakeClock())
+
+	details, err := service.(*ProfileService).resolveDetails(
+		[]string{"jazz", "hiking"},
+		[]string{"vi", "en"},
+		[]models.ProfilePromptRequest{{Question: "perfect-sunday", Answer: "  coffee, then a long walk  "}},
+	)
+	if err != nil {
+		t.Fatalf("resolveDetails() error = %v", err)
+	}
+	// the ids keep the order of the request
+	if want := []uint{3, 1}; !reflect.DeepEqual(details.InterestIDs, want) {
+		t.Fatalf("InterestIDs = %v, want %v", details.InterestIDs, want)
+	}
+	if want := []uint{2, 1}; !reflect.DeepEqual(details.LanguageIDs, want) {
+		t.Fatalf("LanguageIDs = %v, want %v", details.LanguageIDs, want)
+	}
+	if want := []models.ProfilePrompt{{QuestionID: 1, Answer: "coffee, then a long walk"}}; !reflect.DeepEqual(details.Prompts, want) {
+		t.Fatalf("Prompts = %+v, want %+v", details.Prompts, want)
+	}
+
+	// nil leaves a list alone, empty clears it
+	details, err = service.(*ProfileService).resolveDetails(nil, []string{}, nil)
+	if err != nil {
+		t.Fatalf("resolveDetails() error = %v", err)
+	}
+	if details.InterestIDs != nil || details.LanguageIDs == nil || len(details.LanguageIDs) != 0 || details.Prompts != nil {
+		t.Fatalf("resolveDetails(nil, [], nil) = %+v, want only the languages cleared", details)
+	}
+}
+
+func TestCheckPicks(t *testing.T) {
+	picks := make([]string, models.MaxProfilePrompts+1)
+	for i := range picks {
+		picks[i] = fmt.Sprintf("question-%d", i)
+	}
+
+	if err := checkPicks("prompts", picks[:models.MaxProfilePrompts], models.MaxProfilePrompts); err != nil {
+		t.Fatalf("checkPicks() at the max error = %v", err)
+	}
+	var fieldErr *FieldError
+	if err := checkPicks("prompts", picks, models.MaxProfilePrompts); !errors.As(err, &fieldErr) || fieldErr.Field != "prompts" {
+		t.Fatalf("checkPicks() over the max error = %v, want a prompts field error", err)
+	}
+	if err := checkPicks("prompts", []string{"a", "b", "a"}, models.MaxProfilePrompts); !errors.As(err, &fieldErr) || !strings.Contains(fieldErr.Message, "twice") {
+		t.Fatalf("checkPicks() with a duplicate error = %v, want it listed twice", err)
+	}
+}
+
+func TestProfileService_PatchProfileByIdRefuses(t *testing.T) {
+	text := func(s string) *string { return &s }
+	number := func(n int) *int { return &n }
+	seconds := func(at time.Time) *int64 { unix := at.Unix(); return &unix }
+	tooYoung := testNow.AddDate(-models.MinProfileAge, 0, 0).Add(time.Second)
+	tooManyInterests := make([]string, models.MaxProfileInterests+1)
+	for i := range tooManyInterests {
+		tooManyInterests[i] = fmt.Sprintf("interest-%d", i)
+	}
+	tooManyPrompts := make([]models.ProfilePromptRequest, models.MaxProfilePrompts+1)
+	for i := range tooManyPrompts {
+		tooManyPrompts[i] = models.ProfilePromptRequest{Question: fmt.Sprintf("question-%d", i), Answer: "yes"}
+	}
+
+	tests := []struct {
+		name      string
+		request   models.ProfilePatchRequest
+		wantField string
+	}{
+		{"blank name", models.ProfilePatchRequest{Name: text("   ")}, "name"},
+		{"younger than 18", models.ProfilePatchRequest{BirthdayInSeconds: seconds(tooYoung)}, "birthday_in_seconds"},
+		{"too short", models.ProfilePatchRequest{HeightCm: number(models.MinProfileHeightCm - 1)}, "height_cm"},
+		{"too tall", models.ProfilePatchRequest{HeightCm: number(models.MaxProfileHeightCm + 1)}, "height_cm"},
+		{"unknown interest", models.ProfilePatchRequest{Interests: []string{"hiking", "knitting"}}, "interests"},
+		{"interest twice", models.ProfilePatchRequest{Interests: []string{"jazz", "jazz"}}, "interests"},
+		{"too many interests", models.ProfilePatchRequest{Interests: tooManyInterests}, "interests"},
+		{"unknown language", models.ProfilePatchRequest{Languages: []string{"xx"}}, "languages"},
+		{"language twice", models.ProfilePatchRequest{Languages: []string{"vi", "en", "vi"}}, "languages"},
+		{"too many languages", models.ProfilePatchRequest{Languages: []string{"en", "vi", "a", "b", "c", "d"}}, "languages"},
+		{"unknown prompt question", models.ProfilePatchRequest{Prompts: []models.ProfilePromptRequest{{Question: "worst-monday", Answer: "rain"}}}, "prompts"},
+		{"prompt question twice", models.ProfilePatchRequest{Prompts: []models.ProfilePromptRequest{{Question: "perfect-sunday", Answer: "a"}, {Question: "perfect-sunday", Answer: "b"}}}, "prompts"},
+		{"too many prompts", models.ProfilePatchRequest{Prompts: tooManyPrompts}, "prompts"},
+		{"blank prompt answer", models.ProfilePatchRequest{Prompts: []models.ProfilePromptRequest{{Question: "perfect-sunday", Answer: " "}}}, "prompts"},
+		{"long prompt answer", models.ProfilePatchRequest{Prompts: []models.ProfilePromptRequest{{Question: "perfect-sunday", Answer: strings.Repeat("a", models.MaxPromptAnswerLength+1)}}}, "prompts"},
+		{"refused field with a valid one", models.ProfilePatchRequest{Name: text("Ana"), HeightCm: number(20)}, "height_cm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, profiles := newTestProfileService(newFakeClock())
+
+			err := service.PatchProfileById("ana", tt.request)
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+				t.Fatalf("PatchProfileById() error = %v, want a %s field error", err, tt.wantField)
+			}
+			if len(profiles.patches) != 0 {
+				t.Fatalf("PatchProfileById() wrote %v", profiles.patches)
+			}
+		})
+	}
+}
+
+func TestProfileService_GetVisibleProfile(t *testing.T) {
+	visible := func(visibility models.ProfileVisibility) models.Profile {
+		return models.Profile{ID: "ana", Name: "Ana", ProfileVisibility: visibility}
+	}
+	paused := models.ProfileVisibility{DiscoveryPaused: true}
+	incognito := models.ProfileVisibility{Incognito: true}
+
+	// ana liked ben, matched with cai and passed on dan
+	matches := map[[2]string]models.Match{
+		{"ana", "ben"}: {MatcherId: "ana", MatcheeId: "ben", MatchStatus: models.MatchStatusWaiting},
+		{"cai", "ana"}: {MatcherId: "cai", MatcheeId: "ana", MatchStatus: models.MatchStatusMatched},
+		{"ana", "dan"}: {MatcherId: "ana", MatcheeId: "dan", MatchStatus: models.MatchStatusPassed},
+		{"eve", "ana"}: {MatcherId: "eve", MatcheeId: "ana", MatchStatus: models.MatchStatusWaiting},
+	}
+
+	tests := []struct {
+		name       string
+		visibility models.ProfileVisibility
+		viewer     string
+		visible    bool
+	}{
+		{"public to a stranger", models.ProfileVisibility{}, "fay", true},
+		{"paused to the owner", paused, "ana", true},
+		{"paused to a liked user", paused, "ben", false},
+		{"paused to a match", paused, "cai", false},
+		{"paused to a stranger", paused, "fay", false},
+		{"paused and incognito to a liked user", models.ProfileVisibility{DiscoveryPaused: true, Incognito: true}, "ben", false},
+		{"incognito to the owner", incognito, "ana", true},
+		{"incognito to a liked user", incognito, "ben", true},
+		{"incognito to a match", incognito, "cai", true},
+		{"incognito to a passed user", incognito, "dan", false},
+		{"incognito to someone who liked it", incognito, "eve", false},
+		{"incognito to a stranger", incognito, "fay", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles := &fakeProfileRepository{profiles: map[string]models.Profile{"ana": visible(tt.visibility)}}
+			service := NewProfileService(newFakeClock(), profiles, &fakeTaxonomyRepository{}, nil, &fakeMatchRepository{matches: matches}, newTestLogger())
+
+			profile, err := service.GetVisibleProfile(tt.viewer, "ana")
+			if tt.visible && (err != nil || profile.ID != "ana") {
+				t.Fatalf("GetVisibleProfile() = %v, %v, want ana's profile", profile, err)
+			}
+			if !tt.visible && !errors.Is(err, ErrProfileNotVisible) {
+				t.Fatalf("GetVisibleProfile() error = %v, want %v", err, ErrProfileNotVisible)
+			}
+		})
+	}
+
+	service, _ := newTestProfileService(newFakeClock())
+	if _, err := service.GetVisibleProfile("ben", "nobody"); !errors.Is(err, ErrProfileNotVisible) {
+		t.Fatalf("GetVisibleProfile() of a missing profile error = %v, want %v", err, ErrProfileNotVisible)
+	}
+}
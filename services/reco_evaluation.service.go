@@ -0,0 +1,468 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+)
+
+const (
+	defaultRecoEvaluationUsers = 200
+	defaultRecoEvaluationK     = 10
+	recoEvaluationBuckets      = 10
+
+	// shape of the synthetic population
+	syntheticQuestions      = 20
+	syntheticAnswerRate     = 0.8
+	syntheticSeenRate       = 0.3
+	syntheticMaxInactivity  = 20 * 24 * time.Hour
+	syntheticMinComplete    = 20
+	syntheticLikeBase       = 0.05
+	syntheticLikeMatch      = 0.55
+	syntheticLikeAppeal     = 0.3
+	syntheticAnswerChoices  = 4
+	syntheticImportanceLast = 5
+)
+
+type IRecoEvaluationService interface {
+	Evaluate(options models.RecoEvaluationOptions) (*models.RecoEvaluationReport, error)
+	CompareExperiment(experiment string) (*models.ExperimentReport, error)
+}
+
+// RecoEvaluationService replays swipes through a scorer and a ranker offline, to tell how
+// high each viewer's eventual mutual matches would have been in their feed. It also compares
+// the variants of a live experiment from their recorded events.
+type RecoEvaluationService struct {
+	logger               *core.Logger
+	clock                core.Clock
+	repository           repositories.IRecoEvaluationRepository
+	experimentRepository repositories.IExperimentRepository
+}
+
+// NewRecoEvaluationService creates a new recommendation evaluation service
+func NewRecoEvaluationService(
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.IRecoEvaluationRepository,
+	experimentRepository repositories.IExperimentRepository,
+) IRecoEvaluationService {
+	return &RecoEvaluationService{
+		logger:               logger,
+		clock:                clock,
+		repository:           repository,
+		experimentRepository: experimentRepository,
+	}
+}
+
+// evaluationUser is what the evaluation knows of a user, whatever the source
+type evaluationUser struct {
+	id           string
+	gender       string
+	interestedIn string
+	answers      map[int]*models.Answer
+	completeness int
+	lastActiveAt time.Time
+}
+
+// evaluationDataset is a population with the swipes it made
+type evaluationDataset struct {
+	users  []evaluationUser
+	swipes []models.Match
+}
+
+func (s *RecoEvaluationService) Evaluate(options models.RecoEvaluationOptions) (*models.RecoEvaluationReport, error) {
+	if options.K <= 0 {
+		options.K = defaultRecoEvaluationK
+	}
+	if options.Users <= 0 {
+		options.Users = defaultRecoEvaluationUsers
+	}
+	if options.Scorer == "" {
+		options.Scorer = models.RecoEvaluationScorerAnswers
+	}
+	if options.Ranker == "" {
+		options.Ranker = "weighted"
+	}
+
+	ranker, err := core.NewRankerByName(options.Ranker)
+	if err != nil {
+		return nil, err
+	}
+	if options.Scorer != models.RecoEvaluationScorerAnswers && options.Scorer != models.RecoEvaluationScorerRandom {
+		return nil, fmt.Errorf("unknown scorer %q", options.Scorer)
+	}
+
+	now := s.clock.Now()
+	var dataset *evaluationDataset
+	switch options.Source {
+	case "", models.RecoEvaluationSourceHistorical:
+		options.Source = models.RecoEvaluationSourceHistorical
+		dataset, err = s.historical()
+	case models.RecoEvaluationSourceSynthetic:
+		dataset = synthetic(options.Users, options.Seed, now)
+	default:
+		err = fmt.Errorf("unknown source %q", options.Source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := s.evaluate(dataset, ranker, options, now)
+	s.logger.Infof("recommendations evaluated, source [%v], scorer [%v], ranker [%v], viewers [%v], precision@%d [%.4f]",
+		report.Source, report.Scorer, report.Ranker, report.Viewers, report.K, report.PrecisionAtK)
+	return report, nil
+}
+
+// CompareExperiment sums up the exposures and swipes of each variant of the experiment
+func (s *RecoEvaluationService) CompareExperiment(experiment string) (*models.ExperimentReport, error) {
+	counts, err := s.experimentRepository.CountEvents(experiment)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ExperimentReport{
+		Experiment:  experiment,
+		Variants:    []models.ExperimentVariantReport{},
+		GeneratedAt: s.clock.Now(),
+	}
+	byVariant := make(map[string]int)
+	for _, count := range counts {
+		index, ok := byVariant[count.Variant]
+		if !ok {
+			index = len(report.Variants)
+			byVariant[count.Variant] = index
+			report.Variants = append(report.Variants, models.ExperimentVariantReport{Variant: count.Variant})
+		}
+		variant := &report.Variants[index]
+		switch count.Event {
+		case models.ExperimentEventExposure:
+			// users are counted on exposures, every swipe follows one
+			variant.Users = count.Users
+			variant.Exposures = count.Events
+		case models.ExperimentEventLike:
+			variant.Likes = count.Events
+		case models.ExperimentEventPass:
+			variant.Passes = count.Events
+		case models.ExperimentEventMatch:
+			variant.Matches = count.Events
+		}
+	}
+
+	for i := range report.Variants {
+		variant := &report.Variants[i]
+		if variant.Exposures > 0 {
+			variant.LikeRate = float64(variant.Likes) / float64(variant.Exposures)
+			variant.MatchRate = float64(variant.Matches) / float64(variant.Exposures)
+		}
+	}
+	return report, nil
+}
+
+// ----------------- private -----------------
+
+// historical loads the real population, as the recommendations would see it today
+func (s *RecoEvaluationService) historical() (*evaluationDataset, error) {
+	profiles, err := s.repository.FindProfiles()
+	if err != nil {
+		return nil, err
+	}
+	answers, err := s.repository.FindAnswers()
+	if err != nil {
+		return nil, err
+	}
+	swipes, err := s.repository.FindSwipes()
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := s.repository.FindPreferences()
+	if err != nil {
+		return nil, err
+	}
+
+	answersByUser := make(map[string]map[int]*models.Answer)
+	for i := range answers {
+		answer := &answers[i]
+		if answersByUser[answer.UserID] == nil {
+			answersByUser[answer.UserID] = make(map[int]*models.Answer)
+		}
+		answersByUser[answer.UserID][answer.QuestionID] = answer
+	}
+	interestedIn := make(map[string]string, len(preferences))
+	for _, preference := range preferences {
+		interestedIn[preference.UserID] = preference.InterestedIn
+	}
+
+	dataset := &evaluationDataset{swipes: swipes}
+	for i := range profiles {
+		profile := &profiles[i]
+		user := evaluationUser{
+			id:           profile.ID,
+			gender:       profile.Gender,
+			interestedIn: oppositeGender(profile.Gender),
+			answers:      answersByUser[profile.ID],
+			completeness: profile.Completeness(),
+			lastActiveAt: profile.LastActive(),
+		}
+		if saved, ok := interestedIn[profile.ID]; ok {
+			user.interestedIn = saved
+		}
+		dataset.users = append(dataset.users, user)
+	}
+	return dataset, nil
+}
+
+// synthetic generates a seeded population. Users like each other more the better their
+// answers match and the more appealing the other one is, a like back makes a mutual match.
+func synthetic(size int, seed int64, now time.Time) *evaluationDataset {
+	random := rand.New(rand.NewSource(seed))
+
+	dataset := &evaluationDataset{}
+	appeal := make([]float64, size)
+	for i := 0; i < size; i++ {
+		gender := "male"
+		if random.Intn(2) == 0 {
+			gender = "female"
+		}
+		answers := make(map[int]*models.Answer)
+		for question := 1; question <= syntheticQuestions; question++ {
+			if random.Float64() >= syntheticAnswerRate {
+				continue
+			}
+			answers[question] = &models.Answer{
+				QuestionID:   question,
+				UserAnswer:   1 + random.Intn(syntheticAnswerChoices),
+				PreferAnswer: 1 + random.Intn(syntheticAnswerChoices),
+				Importance:   1 + random.Intn(syntheticImportanceLast),
+			}
+		}
+		appeal[i] = random.Float64()
+		dataset.users = append(dataset.users, evaluationUser{
+			id:           fmt.Sprintf("synthetic-%04d", i),
+			gender:       gender,
+			interestedIn: oppositeGender(gender),
+			answers:      answers,
+			completeness: syntheticMinComplete + random.Intn(101-syntheticMinComplete),
+			lastActiveAt: now.Add(-time.Duration(random.Int63n(int64(syntheticMaxInactivity)))),
+		})
+	}
+
+	liked := make(map[[2]int]bool)
+	var seen [][2]int
+	for i, viewer := range dataset.users {
+		for j, candidate := range dataset.users {
+			if i == j || candidate.gender != viewer.interestedIn || random.Float64() >= syntheticSeenRate {
+				continue
+			}
+			match := scoreAnswers(viewer.answers, candidate.answers)
+			if math.IsNaN(match) {
+				match = 0
+			}
+			like := syntheticLikeBase + syntheticLikeMatch*match + syntheticLikeAppeal*appeal[j]
+			liked[[2]int{i, j}] = random.Float64() < like
+			seen = append(seen, [2]int{i, j})
+		}
+	}
+
+	for _, pair := range seen {
+		status := models.MatchStatusPassed
+		if liked[pair] {
+			status = models.MatchStatusWaiting
+			if liked[[2]int{pair[1], pair[0]}] {
+				status = models.MatchStatusMatched
+			}
+		}
+		dataset.swipes = append(dataset.swipes, models.Match{
+			MatcherId:   dataset.users[pair[0]].id,
+			MatcheeId:   dataset.users[pair[1]].id,
+			MatchStatus: status,
+		})
+	}
+	return dataset
+}
+
+func (s *RecoEvaluationService) evaluate(
+	dataset *evaluationDataset,
+	ranker core.Ranker,
+	options models.RecoEvaluationOptions,
+	now time.Time,
+) *models.RecoEvaluationReport {
+	// a mutual match can be stored on either side
+	mutual := make(map[string]map[string]bool)
+	addMutual := func(a, b string) {
+		if mutual[a] == nil {
+			mutual[a] = make(map[string]bool)
+		}
+		mutual[a][b] = true
+	}
+	for _, swipe := range dataset.swipes {
+		if swipe.MatchStatus == models.MatchStatusMatched {
+			addMutual(swipe.MatcherId, swipe.MatcheeId)
+			addMutual(swipe.MatcheeId, swipe.MatcherId)
+		}
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	report := &models.RecoEvaluationReport{
+		Source:      options.Source,
+		Scorer:      options.Scorer,
+		Ranker:      options.Ranker,
+		Seed:        options.Seed,
+		K:           options.K,
+		Users:       len(dataset.users),
+		GeneratedAt: now,
+	}
+
+	var scores []float64
+	unscorable := 0
+	eligible := make(map[string]bool)
+	recommended := make(map[string]bool)
+	for _, viewer := range dataset.users {
+		matches := mutual[viewer.id]
+		if len(matches) == 0 {
+			continue
+		}
+
+		// the viewer's own swipes are what we try to predict, they don't count as signals
+		received := make(map[string]models.SwipeCounts)
+		for _, swipe := range dataset.swipes {
+			if swipe.MatcherId == viewer.id {
+				continue
+			}
+			counts := received[swipe.MatcheeId]
+			if swipe.MatchStatus == models.MatchStatusPassed {
+				counts.Passes++
+			} else {
+				counts.Likes++
+			}
+			received[swipe.MatcheeId] = counts
+		}
+
+		var candidates []core.RankCandidate
+		for _, candidate := range dataset.users {
+			if candidate.id == viewer.id || candidate.gender != viewer.interestedIn {
+				continue
+			}
+			var score float64
+			if options.Scorer == models.RecoEvaluationScorerRandom {
+				score = random.Float64()
+			} else {
+				score = scoreAnswers(viewer.answers, candidate.answers)
+			}
+			if math.IsNaN(score) {
+				unscorable++
+				score = 0
+			}
+			scores = append(scores, score)
+			eligible[candidate.id] = true
+
+			candidates = append(candidates, core.RankCandidate{
+				ID:              candidate.id,
+				MatchPercentage: score,
+				LikesReceived:   received[candidate.id].Likes,
+				PassesReceived:  received[candidate.id].Passes,
+				Completeness:    candidate.completeness,
+				LastActiveAt:    candidate.lastActiveAt,
+			})
+		}
+
+		ranked := ranker.Rank(core.RankRequest{
+			ViewerID:   viewer.id,
+			Now:        now,
+			Seed:       options.Seed ^ core.RankSeed(viewer.id, now),
+			Candidates: candidates,
+		})
+		if len(ranked) > options.K {
+			ranked = ranked[:options.K]
+		}
+
+		hits := 0
+		for _, candidate := range ranked {
+			recommended[candidate.ID] = true
+			if matches[candidate.ID] {
+				hits++
+			}
+		}
+		report.Viewers++
+		report.PrecisionAtK += float64(hits) / float64(options.K)
+		report.RecallAtK += float64(hits) / float64(len(matches))
+	}
+
+	if report.Viewers > 0 {
+		report.PrecisionAtK /= float64(report.Viewers)
+		report.RecallAtK /= float64(report.Viewers)
+	}
+	if len(eligible) > 0 {
+		report.Coverage = float64(len(recommended)) / float64(len(eligible))
+	}
+	report.ScoreDistribution = distribution(scores, unscorable)
+	return report
+}
+
+// distribution buckets scores between 0 and 1
+func distribution(scores []float64, unscorable int) models.ScoreDistribution {
+	result := models.ScoreDistribution{
+		Scored:     len(scores),
+		Unscorable: unscorable,
+		Buckets:    make([]models.ScoreBucket, recoEvaluationBuckets),
+	}
+	for i := range result.Buckets {
+		result.Buckets[i].From = float64(i) / recoEvaluationBuckets
+		result.Buckets[i].To = float64(i+1) / recoEvaluationBuckets
+	}
+	if len(scores) == 0 {
+		return result
+	}
+
+	sorted := append([]float64(nil), scores...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, score := range sorted {
+		sum += score
+		bucket := int(score * recoEvaluationBuckets)
+		if bucket >= recoEvaluationBuckets {
+			bucket = recoEvaluationBuckets - 1
+		}
+		if bucket < 0 {
+			bucket = 0
+		}
+		result.Buckets[bucket].Count++
+	}
+	result.Mean = sum / float64(len(sorted))
+	result.P50 = percentile(sorted, 0.5)
+	result.P90 = percentile(sorted, 0.9)
+	return result
+}
+
+// percentile takes the nearest rank of sorted scores
+func percentile(sorted []float64, p float64) float64 {
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+// scoreAnswers gives the match percentage the recommendations use, NaN when the users have
+// no answer in common
+func scoreAnswers(userAnswers, otherAnswers map[int]*models.Answer) float64 {
+	var wg sync.WaitGroup
+	results := make(chan models.MatchCalculationResult, 1)
+	wg.Add(1)
+	utils.CalculateMatchPercentage(&wg, results, userAnswers, otherAnswers, models.Profile{})
+	return (<-results).MatchPercentage
+}
+
+func oppositeGender(gender string) string {
+	if gender == "male" {
+		return "female"
+	}
+	return "male"
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+)
+
+type fakeRecoEvaluationRepository struct {
+	repositories.IRecoEvaluationRepository
+	profiles    []models.Profile
+	answers     []models.Answer
+	swipes      []models.Match
+	preferences []models.UserPreference
+}
+
+func (r *fakeRecoEvaluationRepository) FindProfiles() ([]models.Profile, error) {
+	return r.profiles, nil
+}
+
+func (r *fakeRecoEvaluationRepository) FindAnswers() ([]models.Answer, error) {
+	return r.answers, nil
+}
+
+func (r *fakeRecoEvaluationRepository) FindSwipes() ([]models.Match, error) {
+	return r.swipes, nil
+}
+
+func (r *fakeRecoEvaluationRepository) FindPreferences() ([]models.UserPreference, error) {
+	return r.preferences, nil
+}
+
+type fakeExperimentRepository struct {
+	repositories.IExperimentRepository
+	counts []models.ExperimentEventCount
+}
+
+func (r *fakeExperimentRepository) CountEvents(experiment string) ([]models.ExperimentEventCount, error) {
+	return r.counts, nil
+}
+
+// evaluationPopulation has two men and four women. Only vic and fey answered a question and
+// they answered it for each other, every other pair has no answer in common and scores 0.
+func evaluationPopulation() *fakeRecoEvaluationRepository {
+	return &fakeRecoEvaluationRepository{
+		profiles: []models.Profile{
+			{ID: "vic", Gender: "male"},
+			{ID: "wes", Gender: "male"},
+			{ID: "ada", Gender: "female"},
+			{ID: "bia", Gender: "female"},
+			{ID: "cleo", Gender: "female"},
+			{ID: "fey", Gender: "female"},
+		},
+		answers: []models.Answer{
+			{UserID: "vic", QuestionID: 1, UserAnswer: 1, PreferAnswer: 2, Importance: 3},
+			{UserID: "fey", QuestionID: 1, UserAnswer: 2, PreferAnswer: 1, Importance: 3},
+		},
+		swipes: []models.Match{
+			{MatcherId: "vic", MatcheeId: "fey", MatchStatus: models.MatchStatusMatched},
+			{MatcherId: "ada", MatcheeId: "wes", MatchStatus: models.MatchStatusMatched},
+			{MatcherId: "cleo", MatcheeId: "vic", MatchStatus: models.MatchStatusWaiting},
+			{MatcherId: "vic", MatcheeId: "bia", MatchStatus: models.MatchStatusPassed},
+		},
+	}
+}
+
+func TestRecoEvaluationService_Evaluate(t *testing.T) {
+	service := NewRecoEvaluationService(newTestLogger(), newFakeClock(), evaluationPopulation(), nil)
+
+	// the match ranker keeps the scores and breaks ties by id, so with k = 2:
+	// vic gets fey and ada, 1 of his 1 match
+	// wes gets ada and bia, 1 of his 1 match
+	// ada, fey get vic and wes, 1 of their 1 match each
+	report, err := service.Evaluate(models.RecoEvaluationOptions{Ranker: "match", K: 2})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if report.Source != models.RecoEvaluationSourceHistorical || report.Scorer != models.RecoEvaluationScorerAnswers {
+		t.Fatalf("Evaluate() source %s, scorer %s, want the defaults", report.Source, report.Scorer)
+	}
+	if report.Users != 6 || report.Viewers != 4 {
+		t.Fatalf("Evaluate() users %d, viewers %d, want 6 and 4", report.Users, report.Viewers)
+	}
+	if !closeTo(report.PrecisionAtK, 0.5) {
+		t.Fatalf("PrecisionAtK = %v, want 0.5", report.PrecisionAtK)
+	}
+	if !closeTo(report.RecallAtK, 1) {
+		t.Fatalf("RecallAtK = %v, want 1", report.RecallAtK)
+	}
+	// cleo never makes a top 2
+	if !closeTo(report.Coverage, 5.0/6) {
+		t.Fatalf("Coverage = %v, want 5/6", report.Coverage)
+	}
+
+	distribution := report.ScoreDistribution
+	if distribution.Scored != 12 || distribution.Unscorable != 10 {
+		t.Fatalf("scored %d, unscorable %d, want 12 and 10", distribution.Scored, distribution.Unscorable)
+	}
+	if distribution.Buckets[0].Count != 10 || distribution.Buckets[9].Count != 2 {
+		t.Fatalf("buckets = %+v, want 10 in the first and 2 in the last", distribution.Buckets)
+	}
+	if !closeTo(distribution.Mean, 2.0/12) || distribution.P50 != 0 || distribution.P90 != 1 {
+		t.Fatalf("mean %v, p50 %v, p90 %v, want 1/6, 0 and 1", distribution.Mean, distribution.P50, distribution.P90)
+	}
+}
+
+func TestRecoEvaluationService_EvaluateRecallMissesMatches(t *testing.T) {
+	repository := evaluationPopulation()
+	// wes's second match ranks after ada and bia
+	repository.swipes = append(repository.swipes, models.Match{MatcherId: "wes", MatcheeId: "cleo", MatchStatus: models.MatchStatusMatched})
+	service := NewRecoEvaluationService(newTestLogger(), newFakeClock(), repository, nil)
+
+	report, err := service.Evaluate(models.RecoEvaluationOptions{Ranker: "match", K: 2})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	// cleo joins the viewers and finds wes, wes finds 1 of his 2 matches
+	if report.Viewers != 5 {
+		t.Fatalf("Viewers = %d, want 5", report.Viewers)
+	}
+	if !closeTo(report.RecallAtK, (1+0.5+1+1+1)/5.0) {
+		t.Fatalf("RecallAtK = %v, want 0.9", report.RecallAtK)
+	}
+	if !closeTo(report.PrecisionAtK, 0.5) {
+		t.Fatalf("PrecisionAtK = %v, want 0.5", report.PrecisionAtK)
+	}
+}
+
+func TestRecoEvaluationService_EvaluateSynthetic(t *testing.T) {
+	service := NewRecoEvaluationService(newTestLogger(), newFakeClock(), nil, nil)
+	options := models.RecoEvaluationOptions{Source: models.RecoEvaluationSourceSynthetic, Users: 120, Seed: 7}
+
+	first, err := service.Evaluate(options)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	again, err := service.Evaluate(options)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Fatalf("the same seed gave two reports:\n%+v\n%+v", first, again)
+	}
+	if first.Users != 120 || first.K != defaultRecoEvaluationK || first.Viewers == 0 {
+		t.Fatalf("Evaluate() users %d, k %d, viewers %d", first.Users, first.K, first.Viewers)
+	}
+
+	bucketed := 0
+	for _, bucket := range first.ScoreDistribution.Buckets {
+		bucketed += bucket.Count
+	}
+	if bucketed != first.ScoreDistribution.Scored {
+		t.Fatalf("buckets hold %d scores, want %d", bucketed, first.ScoreDistribution.Scored)
+	}
+	for name, value := range map[string]float64{"precision": first.PrecisionAtK, "recall": first.RecallAtK, "coverage": first.Coverage} {
+		if value < 0 || value > 1 {
+			t.Fatalf("%s = %v, want it in [0, 1]", name, value)
+		}
+	}
+
+	options.Scorer = models.RecoEvaluationScorerRandom
+	baseline, err := service.Evaluate(options)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	// the synthetic users like better matches, the answers should beat chance
+	if first.RecallAtK <= baseline.RecallAtK {
+		t.Fatalf("answers recall %v, random recall %v", first.RecallAtK, baseline.RecallAtK)
+	}
+}
+
+func TestRecoEvaluationService_EvaluateRefuses(t *testing.T) {
+	service := NewRecoEvaluationService(newTestLogger(), newFakeClock(), evaluationPopulation(), nil)
+
+	for _, options := range []models.RecoEvaluationOptions{
+		{Ranker: "popular"},
+		{Scorer: "vibes"},
+		{Source: "csv"},
+	} {
+		if _, err := service.Evaluate(options); err == nil {
+			t.Fatalf("Evaluate(%+v) gave a report", options)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	result := distribution([]float64{1, 0.05, 0.55, 0, 0.1}, 1)
+
+	counts := make([]int, 0, len(result.Buckets))
+	for _, bucket := range result.Buckets {
+		counts = append(counts, bucket.Count)
+	}
+	if want := []int{2, 1, 0, 0, 0, 1, 0, 0, 0, 1}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("bucket counts = %v, want %v", counts, want)
+	}
+	if result.Buckets[3].From != 0.3 || result.Buckets[3].To != 0.4 {
+		t.Fatalf("bucket 3 = [%v, %v), want [0.3, 0.4)", result.Buckets[3].From, result.Buckets[3].To)
+	}
+	if result.Scored != 5 || result.Unscorable != 1 {
+		t.Fatalf("scored %d, unscorable %d, want 5 and 1", result.Scored, result.Unscorable)
+	}
+	if !closeTo(result.Mean, 0.34) || result.P50 != 0.1 || result.P90 != 1 {
+		t.Fatalf("mean %v, p50 %v, p90 %v, want 0.34, 0.1 and 1", result.Mean, result.P50, result.P90)
+	}
+
+	empty := distribution(nil, 0)
+	if len(empty.Buckets) != recoEvaluationBuckets || empty.Mean != 0 || empty.P50 != 0 {
+		t.Fatalf("distribution(nil) = %+v", empty)
+	}
+}
+
+func TestRecoEvaluationService_CompareExperiment(t *testing.T) {
+	experiments := &fakeExperimentRepository{counts: []models.ExperimentEventCount{
+		{Variant: "control", Event: models.ExperimentEventExposure, Events: 200, Users: 40},
+		{Variant: "control", Event: models.ExperimentEventLike, Events: 50, Users: 30},
+		{Variant: "control", Event: models.ExperimentEventPass, Events: 150, Users: 38},
+		{Variant: "control", Event: models.ExperimentEventMatch, Events: 10, Users: 8},
+		{Variant: "weighted", Event: models.ExperimentEventLike, Events: 3, Users: 2},
+		{Variant: "weighted", Event: models.ExperimentEventExposure, Events: 0, Users: 0},
+	}}
+	service := NewRecoEvaluationService(newTestLogger(), newFakeClock(), nil, experiments)
+
+	report, err := service.CompareExperiment("ranker")
+	if err != nil {
+		t.Fatalf("CompareExperiment() error = %v", err)
+	}
+	want := []models.ExperimentVariantReport{
+		{Variant: "control", Users: 40, Exposures: 200, Likes: 50, Passes: 150, Matches: 10, LikeRate: 0.25, MatchRate: 0.05},
+		// no exposure, no rate
+		{Variant: "weighted", Likes: 3},
+	}
+	if !reflect.DeepEqual(report.Variants, want) {
+		t.Fatalf("Variants = %+v, want %+v", report.Variants, want)
+	}
+	if report.Experiment != "ranker" || !report.GeneratedAt.Equal(testNow) {
+		t.Fatalf("CompareExperiment() experiment %s at %v", report.Experiment, report.GeneratedAt)
+	}
+}
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/hodukihugi/winglets-api/core"
@@ -8,45 +9,78 @@ import (
 	"github.com/hodukihugi/winglets-api/repositories"
 	"github.com/hodukihugi/winglets-api/utils"
 	"gorm.io/gorm"
-	"sort"
 	"sync"
+	"time"
 )
 
+// defaultRecommendMaxInactiveDays leaves dormant profiles out of recommendations
+const defaultRecommendMaxInactiveDays = 30
+
 type IRecommendService interface {
 	CreateUserAnswer(models.SerializableAnswer) error
 	GetMatchesByUserId(string) error
 	GetAnswersByUserId(string) ([]models.SerializableAnswer, error)
 	GetListQuestions() ([]models.SerializableQuestion, error)
-	GetRecommendationByUserId(string, int, int, float64, float64) ([]models.MatchProfile, error)
-	SmashById(string, string) (string, *models.Profile, error)
-	PassById(string, string) error
+	GetRecommendationByUserId(context.Context, string, int, int, float64, float64, bool) ([]models.MatchProfile, error)
+	SmashById(context.Context, string, string) (string, *models.Profile, error)
+	PassById(context.Context, string, string) error
 }
 
 type RecommendService struct {
+	maxInactiveDays             int
+	clock                       core.Clock
 	profileRepository           repositories.IProfileRepository
 	answerRepository            repositories.IAnswerRepository
 	matchRepository             repositories.IMatchRepository
 	questionRepository          repositories.IQuestionRepository
 	recommendationBinRepository repositories.IRecommendationBinRepository
+	preferenceRepository        repositories.IPreferenceRepository
+	jobService                  IJobService
+	experimentService           IExperimentService
+	notificationService         INotificationService
+	ranker                      core.Ranker
+	urlSigner                   *core.BlobURLSigner
 	logger                      *core.Logger
 }
 
 func NewRecommendService(
+	env *core.Env,
+	clock core.Clock,
 	profileRepository repositories.IProfileRepository,
 	answerRepository repositories.IAnswerRepository,
 	matchRepository repositories.IMatchRepository,
 	questionRepository repositories.IQuestionRepository,
 	recommendationBinRepository repositories.IRecommendationBinRepository,
+	preferenceRepository repositories.IPreferenceRepository,
+	jobService IJobService,
+	experimentService IExperimentService,
+	notificationService INotificationService,
+	ranker core.Ranker,
+	urlSigner *core.BlobURLSigner,
 	logger *core.Logger,
 ) IRecommendService {
-	return &RecommendService{
+	s := &RecommendService{
+		maxInactiveDays:             env.RecommendMaxInactiveDays,
+		clock:                       clock,
 		profileRepository:           profileRepository,
 		answerRepository:            answerRepository,
 		matchRepository:             matchRepository,
 		questionRepository:          questionRepository,
 		recommendationBinRepository: recommendationBinRepository,
+		preferenceRepository:        preferenceRepository,
+		jobService:                  jobService,
+		experimentService:           experimentService,
+		notificationService:         notificationService,
+		ranker:                      ranker,
+		urlSigner:                   urlSigner,
 		logger:                      logger,
 	}
+
+	if s.maxInactiveDays <= 0 {
+		s.maxInactiveDays = defaultRecommendMaxInactiveDays
+	}
+
+	return s
 }
 
 func (s *RecommendService) CreateUserAnswer(answer models.SerializableAnswer) error {
@@ -120,11 +154,13 @@ func (s *RecommendService) GetListQuestions() ([]models.SerializableQuestion, er
 }
 
 func (s *RecommendService) GetRecommendationByUserId(
+	ctx context.Context,
 	userId string,
 	minAge int,
 	maxAge int,
 	minDistance float64,
 	maxDistance float64,
+	verifiedOnly bool,
 ) ([]models.MatchProfile, error) {
 	var recommendedProfiles []models.MatchProfile
 
@@ -134,7 +170,9 @@ func (s *RecommendService) GetRecommendationByUserId(
 		return nil, err
 	}
 
-	longitude, latitude, err := utils.CoordinatesStringToPairFloat64(userProfile.Coordinates)
+	// travellers browse the city they travel to
+	now := s.clock.Now()
+	longitude, latitude, err := utils.CoordinatesStringToPairFloat64(userProfile.DiscoveryCoordinates(now))
 
 	if err != nil {
 		s.logger.Error(err)
@@ -148,15 +186,33 @@ func (s *RecommendService) GetRecommendationByUserId(
 		interestedIn = "male"
 	}
 
+	// saved preferences fill in what the request leaves out
+	preference, err := s.preferenceRepository.First(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error(err)
+		return nil, err
+	}
+	if preference != nil {
+		interestedIn = preference.InterestedIn
+		if minAge == 0 && maxAge == 0 {
+			minAge, maxAge = preference.MinAge, preference.MaxAge
+		}
+		if maxDistance == 0 {
+			maxDistance = preference.MaxDistance
+		}
+	}
+
 	satisfiedProfiles, err := s.profileRepository.GetListProfile(models.ProfileFilter{
-		ExcludedUserId: userId,
-		Gender:         interestedIn,
-		MinAge:         minAge,
-		MaxAge:         maxAge,
-		MinDistance:    minDistance,
-		MaxDistance:    maxDistance,
-		Longitude:      longitude,
-		Latitude:       latitude,
+		ExcludedUserId:  userId,
+		Gender:          interestedIn,
+		MinAge:          minAge,
+		MaxAge:          maxAge,
+		MinDistance:     minDistance,
+		MaxDistance:     maxDistance,
+		Longitude:       longitude,
+		Latitude:        latitude,
+		VerifiedOnly:    verifiedOnly,
+		MaxInactiveDays: s.maxInactiveDays,
 	})
 
 	if err != nil {
@@ -199,33 +255,90 @@ func (s *RecommendService) GetRecommendationByUserId(
 		matchResults = append(matchResults, result)
 	}
 
-	sort.Slice(matchResults, func(i, j int) bool {
-		return matchResults[i].MatchPercentage > matchResults[j].MatchPercentage
-	})
+	matchResults, err = s.rank(ctx, userId, now, matchResults)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
 
 	for _, result := range matchResults {
-		matchProfile := result.MatchedProfile.ConvertToMatchProfile()
-		lon, lat, err := utils.CoordinatesStringToPairFloat64(result.MatchedProfile.Coordinates)
+		matchProfile := result.MatchedProfile.ConvertToMatchProfile(s.urlSigner.Sign)
+		lon, lat, err := utils.CoordinatesStringToPairFloat64(result.MatchedProfile.DiscoveryCoordinates(now))
 		if err != nil {
 			s.logger.Error(err)
 			return nil, err
 		}
-		matchProfile.Distance = utils.CalculateDistance(longitude, latitude, lon, lat)
+		matchProfile.Distance = utils.RoundDistance(
+			utils.CalculateDistance(longitude, latitude, lon, lat),
+			result.MatchedProfile.HideDistance,
+		)
 		matchProfile.MatchPercentage = result.MatchPercentage
 		recommendedProfiles = append(recommendedProfiles, *matchProfile)
 	}
 
-	for _, recommendedProfile := range recommendedProfiles {
-		s.recommendationBinRepository.Create(models.RecommendationBin{
-			UserID:            userId,
-			RecommendedUserID: recommendedProfile.ID,
-		})
+	if len(recommendedProfiles) > 0 {
+		binPayload := models.RecommendationBinPayload{UserID: userId}
+		for _, recommendedProfile := range recommendedProfiles {
+			binPayload.RecommendedUserIDs = append(binPayload.RecommendedUserIDs, recommendedProfile.ID)
+		}
+		if err = s.jobService.Enqueue(JobTypeCreateRecommendationBin, binPayload); err != nil {
+			s.logger.Error(err)
+			return nil, err
+		}
+		s.experimentService.RecordExposures(ctx, userId, binPayload.RecommendedUserIDs)
 	}
 
 	return recommendedProfiles, nil
 }
 
-func (s *RecommendService) SmashById(matcherId string, matcheeId string) (string, *models.Profile, error) {
+// rank orders the scored profiles with the ranker, which also weighs how others swiped on them.
+// An experiment variant of the user can replace the ranker.
+func (s *RecommendService) rank(ctx context.Context, userId string, now time.Time, results []models.MatchCalculationResult) ([]models.MatchCalculationResult, error) {
+	ids := make([]string, 0, len(results))
+	byID := make(map[string]models.MatchCalculationResult, len(results))
+	for _, result := range results {
+		ids = append(ids, result.MatchedProfile.ID)
+		byID[result.MatchedProfile.ID] = result
+	}
+
+	swipes, err := s.matchRepository.CountReceivedSwipes(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]core.RankCandidate, 0, len(results))
+	for _, result := range results {
+		profile := result.MatchedProfile
+		candidates = append(candidates, core.RankCandidate{
+			ID:              profile.ID,
+			MatchPercentage: result.MatchPercentage,
+			LikesReceived:   swipes[profile.ID].Likes,
+			PassesReceived:  swipes[profile.ID].Passes,
+			Completeness:    profile.Completeness(),
+			LastActiveAt:    profile.LastActive(),
+		})
+	}
+
+	ranker := s.ranker
+	if variantRanker, ok := core.ExperimentAssignmentsFrom(ctx).Ranker(); ok {
+		ranker = variantRanker
+	}
+
+	ranked := ranker.Rank(core.RankRequest{
+		ViewerID:   userId,
+		Now:        now,
+		Seed:       core.RankSeed(userId, now),
+		Candidates: candidates,
+	})
+
+	ordered := make([]models.MatchCalculationResult, 0, len(ranked))
+	for _, candidate := range ranked {
+		ordered = append(ordered, byID[candidate.ID])
+	}
+	return ordered, nil
+}
+
+func (s *RecommendService) SmashById(ctx context.Context, matcherId string, matcheeId string) (string, *models.Profile, error) {
 	// Kiểm tra xem người mình quẹt phải đã quẹt phải mình chưa
 	existedMatch, err := s.matchRepository.First(matcheeId, matcherId)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -259,10 +372,31 @@ func (s *RecommendService) SmashById(matcherId string, matcheeId string) (string
 		message = "match finish"
 	}
 
+	s.experimentService.RecordSwipe(ctx, matcherId, matcheeId, models.ExperimentEventLike)
+	if message == "match finish" {
+		s.experimentService.RecordSwipe(ctx, matcherId, matcheeId, models.ExperimentEventMatch)
+	}
+
+	// the swipe went through, a failed notification is only logged
+	if message == "match wait" {
+		s.logNotifyError(s.notificationService.NotifyLike(matcheeId, matcherId), matcherId, matcheeId)
+	} else if existedMatch.MatchStatus == models.MatchStatusWaiting {
+		// the other one liked first, a pass of theirs is no match to announce. Both sides hear
+		// of the match, the swiper's other devices included.
+		s.logNotifyError(s.notificationService.NotifyMatch(matcheeId, matcherId), matcherId, matcheeId)
+		s.logNotifyError(s.notificationService.NotifyMatch(matcherId, matcheeId), matcherId, matcheeId)
+	}
+
 	return message, matcheeProfile, nil
 }
 
-func (s *RecommendService) PassById(passerId string, passeeId string) error {
+func (s *RecommendService) logNotifyError(err error, matcherId, matcheeId string) {
+	if err != nil {
+		s.logger.Errorf("fail to notify swipe, matcher [%v], matchee [%v], error [%v]", matcherId, matcheeId, err)
+	}
+}
+
+func (s *RecommendService) PassById(ctx context.Context, passerId string, passeeId string) error {
 	// Kiểm tra xem người mình quẹt phải đã quẹt phải mình chưa
 	existedMatch, err := s.matchRepository.First(passeeId, passerId)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -286,5 +420,6 @@ func (s *RecommendService) PassById(passerId string, passeeId string) error {
 		})
 	}
 
+	s.experimentService.RecordSwipe(ctx, passerId, passeeId, models.ExperimentEventPass)
 	return nil
 }
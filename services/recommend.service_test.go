@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"gorm.io/gorm"
+)
+
+// fakeMatchRepository keeps matches by matcher and matchee
+type fakeMatchRepository struct {
+	repositories.IMatchRepository
+	matches map[[2]string]models.Match
+}
+
+func (r *fakeMatchRepository) First(matcherId, matcheeId string) (*models.Match, error) {
+	match, ok := r.matches[[2]string{matcherId, matcheeId}]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &match, nil
+}
+
+func (r *fakeMatchRepository) Create(match models.Match) error {
+	r.matches[[2]string{match.MatcherId, match.MatcheeId}] = match
+	return nil
+}
+
+func (r *fakeMatchRepository) Update(match models.Match) error {
+	key := [2]string{match.MatcherId, match.MatcheeId}
+	existing, ok := r.matches[key]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	existing.MatchStatus = match.MatchStatus
+	r.matches[key] = existing
+	return nil
+}
+
+func (r *fakeMatchRepository) HasLiked(likerID, likedID string) (bool, error) {
+	if match, ok := r.matches[[2]string{likerID, likedID}]; ok && match.MatchStatus != models.MatchStatusPassed {
+		return true, nil
+	}
+	match, ok := r.matches[[2]string{likedID, likerID}]
+	return ok && match.MatchStatus == models.MatchStatusMatched, nil
+}
+
+type fakeExperimentService struct {
+	IExperimentService
+}
+
+func (s *fakeExperimentService) RecordSwipe(ctx context.Context, userID, subjectID, event string) {}
+
+// recordingNotificationService keeps who was told what
+type recordingNotificationService struct {
+	INotificationService
+	sent []string
+}
+
+func (s *recordingNotificationService) NotifyMatch(userID, matchedID string) error {
+	s.sent = append(s.sent, "match "+userID+" with "+matchedID)
+	return nil
+}
+
+func (s *recordingNotificationService) NotifyLike(userID, likerID string) error {
+	s.sent = append(s.sent, "like "+userID+" from "+likerID)
+	return nil
+}
+
+func TestRecommendService_SmashByIdNotifies(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    []models.Match
+		wantMessage string
+		wantSent    []string
+	}{
+		{
+			name:        "first like",
+			wantMessage: "match wait",
+			wantSent:    []string{"like ben from ana"},
+		},
+		{
+			name:        "liked back tells both sides",
+			existing:    []models.Match{{MatcherId: "ben", MatcheeId: "ana", MatchStatus: models.MatchStatusWaiting}},
+			wantMessage: "match finish",
+			wantSent:    []string{"match ben with ana", "match ana with ben"},
+		},
+		{
+			name:        "already matched",
+			existing:    []models.Match{{MatcherId: "ben", MatcheeId: "ana", MatchStatus: models.MatchStatusMatched}},
+			wantMessage: "match finish",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			matches := &fakeMatchRepository{matches: make(map[[2]string]models.Match)}
+			for _, match := range tt.existing {
+				matches.matches[[2]string{match.MatcherId, match.MatcheeId}] = match
+			}
+			profiles := &fakeProfileRepository{profiles: map[string]models.Profile{
+				"ana": {ID: "ana", Name: "Ana"},
+				"ben": {ID: "ben", Name: "Ben"},
+			}}
+			notifications := &recordingNotificationService{}
+			service := NewRecommendService(&core.Env{}, clock, profiles, nil, matches, nil, nil, nil,
+				newFakeJobService(clock), &fakeExperimentService{}, notifications, core.NewMatchRanker(),
+				newTestURLSigner(newFakeBlobStore()), newTestLogger())
+
+			message, profile, err := service.SmashById(context.Background(), "ana", "ben")
+			if err != nil {
+				t.Fatalf("SmashById() error = %v", err)
+			}
+			if message != tt.wantMessage {
+				t.Fatalf("message = %q, want %q", message, tt.wantMessage)
+			}
+			if profile.ID != "ben" {
+				t.Fatalf("profile = %s, want ben", profile.ID)
+			}
+			if !reflect.DeepEqual(notifications.sent, tt.wantSent) {
+				t.Fatalf("notified %v, want %v", notifications.sent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -8,4 +8,18 @@ var Module = fx.Options(
 	fx.Provide(NewAuthService),
 	fx.Provide(NewProfileService),
 	fx.Provide(NewRecommendService),
+	fx.Provide(NewLoginThrottleService),
+	fx.Provide(NewMailService),
+	fx.Provide(NewJobService),
+	fx.Provide(NewOIDCService),
+	fx.Provide(NewMFAService),
+	fx.Provide(NewPhoneService),
+	fx.Provide(NewAccountService),
+	fx.Provide(NewPhotoService),
+	fx.Provide(NewVerificationService),
+	fx.Provide(NewOnboardingService),
+	fx.Provide(NewRecoEvaluationService),
+	fx.Provide(NewExperimentService),
+	fx.Provide(NewActivityService),
+	fx.Provide(NewNotificationService),
 )
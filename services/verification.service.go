@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"github.com/hodukihugi/winglets-api/repositories"
+	"github.com/hodukihugi/winglets-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultVerificationChallengeTTL = 10 * time.Minute
+	verificationReviewQueueLimit    = 50
+)
+
+var (
+	ErrVerificationAlreadyVerified   = errors.New("profile is already verified")
+	ErrVerificationProfileMissing    = errors.New("create a profile before verifying it")
+	ErrVerificationNotFound          = errors.New("verification not found")
+	ErrVerificationChallengeNotFound = errors.New("request a pose challenge first")
+	ErrVerificationChallengeExpired  = errors.New("pose challenge expired, request a new one")
+	ErrVerificationNotPending        = errors.New("verification is not waiting for review")
+	ErrVerificationInReview          = errors.New("a selfie is already waiting for review")
+)
+
+type IVerificationService interface {
+	Latest(userID string) (*models.ProfileVerification, error)
+	RequestChallenge(userID string) (*models.ProfileVerification, error)
+	SubmitSelfie(ctx context.Context, userID string, upload models.ProfilePhotoUpload) (*models.ProfileVerification, error)
+	ReviewQueue() ([]models.ProfileVerification, error)
+	Approve(id uint, reviewerID string) error
+	Reject(id uint, reviewerID, reason string) error
+	Match(ctx context.Context, id uint) error
+}
+
+// VerificationService runs selfie verification: the user mimics a random pose, the selfie is
+// compared with the profile photos by the face matcher or, when it can't decide, by an admin
+type VerificationService struct {
+	logger       *core.Logger
+	clock        core.Clock
+	repository   repositories.IProfileVerificationRepository
+	profileRepo  repositories.IProfileRepository
+	photoRepo    repositories.IProfilePhotoRepository
+	jobService   IJobService
+	blobStore    core.BlobStore
+	faceMatcher  core.FaceMatcher
+	challengeTTL time.Duration
+}
+
+// NewVerificationService creates a new verification service
+func NewVerificationService(
+	env *core.Env,
+	logger *core.Logger,
+	clock core.Clock,
+	repository repositories.IProfileVerificationRepository,
+	profileRepo repositories.IProfileRepository,
+	photoRepo repositories.IProfilePhotoRepository,
+	jobService IJobService,
+	blobStore core.BlobStore,
+	faceMatcher core.FaceMatcher,
+) IVerificationService {
+	s := &VerificationService{
+		logger:       logger,
+		clock:        clock,
+		repository:   repository,
+		profileRepo:  profileRepo,
+		photoRepo:    photoRepo,
+		jobService:   jobService,
+		blobStore:    blobStore,
+		faceMatcher:  faceMatcher,
+		challengeTTL: env.VerificationChallengeTTL,
+	}
+
+	if s.challengeTTL <= 0 {
+		s.challengeTTL = defaultVerificationChallengeTTL
+	}
+
+	jobService.Register(JobTypeMatchVerification, func(ctx context.Context, payload []byte) error {
+		var match models.ProfileVerificationMatchPayload
+		if err := json.Unmarshal(payload, &match); err != nil {
+			return err
+		}
+		return s.Match(ctx, match.VerificationID)
+	})
+
+	return s
+}
+
+// Latest returns the current state of the user's verification
+func (s *VerificationService) Latest(userID string) (*models.ProfileVerification, error) {
+	verification, err := s.repository.Latest(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrVerificationNotFound
+	}
+	return verification, err
+}
+
+// RequestChallenge picks a random pose the selfie has to show, a new challenge replaces
+// one still waiting for its selfie
+func (s *VerificationService) RequestChallenge(userID string) (*models.ProfileVerification, error) {
+	profile, err := s.profileRepo.GetProfileById(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVerificationProfileMissing
+		}
+		return nil, err
+	}
+	if profile.VerifiedAt != nil {
+		return nil, ErrVerificationAlreadyVerified
+	}
+
+	latest, err := s.repository.Latest(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if latest != nil && latest.Status == models.ProfileVerificationStatusPending {
+		return nil, ErrVerificationInReview
+	}
+
+	pose, err := randomPose()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.Create(models.ProfileVerification{
+		UserID:             userID,
+		Pose:               pose,
+		Status:             models.ProfileVerificationStatusChallenged,
+		ChallengeExpiresAt: s.clock.Now().Add(s.challengeTTL),
+	})
+}
+
+// SubmitSelfie answers the latest challenge. The selfie goes through the photo pipeline
+// and is queued for the face matcher.
+func (s *VerificationService) SubmitSelfie(ctx context.Context, userID string, upload models.ProfilePhotoUpload) (*models.ProfileVerification, error) {
+	verification, err := s.repository.Latest(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVerificationChallengeNotFound
+		}
+		return nil, err
+	}
+	if verification.Status != models.ProfileVerificationStatusChallenged {
+		return nil, ErrVerificationChallengeNotFound
+	}
+	if s.clock.Now().After(verification.ChallengeExpiresAt) {
+		return nil, ErrVerificationChallengeExpired
+	}
+
+	image, err := utils.ProcessImage(upload.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", upload.Filename, err)
+	}
+
+	uploadCtx, cancel := context.WithTimeout(ctx, photoUploadTimeout)
+	defer cancel()
+	blob, err := s.blobStore.Upload(uploadCtx, userID, "verification_selfie_"+uuid.New().String()+".jpg", image.Full.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	submitted, err := s.repository.Submit(verification.ID, blob.ID, blob.Path, s.clock.Now())
+	if err == nil && !submitted {
+		err = ErrVerificationChallengeNotFound
+	}
+	if err != nil {
+		s.deleteSelfie(blob.ID)
+		return nil, err
+	}
+
+	if err = s.jobService.Enqueue(JobTypeMatchVerification, models.ProfileVerificationMatchPayload{
+		VerificationID: verification.ID,
+	}); err != nil {
+		// still in the review queue, an admin will get to it
+		s.logger.Errorf("fail to queue face match, verification [%v], error [%v]", verification.ID, err)
+	}
+
+	return s.repository.First(verification.ID)
+}
+
+// ReviewQueue lists the selfies waiting for an admin, oldest first
+func (s *VerificationService) ReviewQueue() ([]models.ProfileVerification, error) {
+	return s.repository.FindListByStatus(models.ProfileVerificationStatusPending, verificationReviewQueueLimit)
+}
+
+func (s *VerificationService) Approve(id uint, reviewerID string) error {
+	return s.review(id, models.ProfileVerificationStatusApproved, "", reviewerID)
+}
+
+func (s *VerificationService) Reject(id uint, reviewerID, reason string) error {
+	return s.review(id, models.ProfileVerificationStatusRejected, reason, reviewerID)
+}
+
+// Match asks the face matcher about a pending selfie, an undecided verdict leaves it in
+// the review queue
+func (s *VerificationService) Match(ctx context.Context, id uint) error {
+	verification, err := s.repository.First(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if verification.Status != models.ProfileVerificationStatusPending {
+		return nil
+	}
+
+	photos, err := s.photoRepo.FindListByUserId(verification.UserID)
+	if err != nil {
+		return err
+	}
+	var photoURLs []string
+	for _, photo := range photos {
+		if !photo.IsApproved() {
+			continue
+		}
+		url, err := s.blobStore.SignedURL(photo.CardOrFullPath(), blobFetchURLTTL)
+		if err != nil {
+			return err
+		}
+		photoURLs = append(photoURLs, url)
+	}
+	selfieURL, err := s.blobStore.SignedURL(verification.SelfiePath, blobFetchURLTTL)
+	if err != nil {
+		return err
+	}
+
+	verdict, err := s.faceMatcher.Match(ctx, core.FaceMatchInput{
+		SelfieURL: selfieURL,
+		PhotoURLs: photoURLs,
+		Pose:      verification.Pose,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch verdict.Status {
+	case core.FaceMatchApproved:
+		err = s.review(id, models.ProfileVerificationStatusApproved, "", models.ProfileVerificationReviewerMatcher)
+	case core.FaceMatchRejected:
+		err = s.review(id, models.ProfileVerificationStatusRejected, verdict.Reason, models.ProfileVerificationReviewerMatcher)
+	}
+	if errors.Is(err, ErrVerificationNotPending) {
+		// an admin was faster
+		return nil
+	}
+	return err
+}
+
+// ----------------- private -----------------
+
+func (s *VerificationService) review(id uint, status, reason, reviewer string) error {
+	reviewed, err := s.repository.Review(id, status, reason, reviewer, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVerificationNotFound
+		}
+		return err
+	}
+	if !reviewed {
+		return ErrVerificationNotPending
+	}
+
+	s.logger.Infof("profile verification reviewed, verification [%v], status [%v], by [%v]", id, status, reviewer)
+	return nil
+}
+
+func (s *VerificationService) deleteSelfie(fileID string) {
+	if err := s.jobService.Enqueue(JobTypeDeleteImage, models.ImageDeletePayload{FileID: fileID}); err != nil {
+		s.logger.Errorf("fail to queue image deletion, file [%v], error [%v]", fileID, err)
+	}
+}
+
+func randomPose() (string, error) {
+	poses := make([]string, 0, len(models.VerificationPoses))
+	for pose := range models.VerificationPoses {
+		poses = append(poses, pose)
+	}
+	sort.Strings(poses)
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(poses))))
+	if err != nil {
+		return "", err
+	}
+	return poses[n.Int64()], nil
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hodukihugi/winglets-api/core"
+	"github.com/hodukihugi/winglets-api/models"
+	"gorm.io/gorm"
+)
+
+// fakeProfileVerificationRepository keeps verifications in memory, an approval verifies the
+// profile like the Review transaction does
+type fakeProfileVerificationRepository struct {
+	profiles      *fakeProfileRepository
+	verifications []models.ProfileVerification
+}
+
+func (r *fakeProfileVerificationRepository) Create(verification models.ProfileVerification) (*models.ProfileVerification, error) {
+	verification.ID = uint(len(r.verifications) + 1)
+	r.verifications = append(r.verifications, verification)
+	return &verification, nil
+}
+
+func (r *fakeProfileVerificationRepository) First(id uint) (*models.ProfileVerification, error) {
+	for _, verification := range r.verifications {
+		if verification.ID == id {
+			return &verification, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeProfileVerificationRepository) Latest(userID string) (*models.ProfileVerification, error) {
+	for i := len(r.verifications) - 1; i >= 0; i-- {
+		if verification := r.verifications[i]; verification.UserID == userID {
+			return &verification, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeProfileVerificationRepository) FindListByStatus(status string, limit int) ([]models.ProfileVerification, error) {
+	var verifications []models.ProfileVerification
+	for _, verification := range r.verifications {
+		if verification.Status == status && len(verifications) < limit {
+			verifications = append(verifications, verification)
+		}
+	}
+	return verifications, nil
+}
+
+func (r *fakeProfileVerificationRepository) Submit(id uint, fileID, path string, at time.Time) (bool, error) {
+	for i := range r.verifications {
+		verification := &r.verifications[i]
+		if verification.ID == id && verification.Status == models.ProfileVerificationStatusChallenged {
+			verification.Status = models.ProfileVerificationStatusPending
+			verification.SelfieFileID, verification.SelfiePath, verification.SubmittedAt = fileID, path, &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeProfileVerificationRepository) Review(id uint, status, reason, reviewer string, at time.Time) (bool, error) {
+	for i := range r.verifications {
+		verification := &r.verifications[i]
+		if verification.ID != id {
+			continue
+		}
+		if verification.Status != models.ProfileVerificationStatusPending {
+			return false, nil
+		}
+		verification.Status, verification.Reason, verification.ReviewedBy, verification.ReviewedAt = status, reason, reviewer, &at
+		if status == models.ProfileVerificationStatusApproved {
+			profile := r.profiles.profiles[verification.UserID]
+			profile.VerifiedAt = &at
+			r.profiles.profiles[verification.UserID] = profile
+		}
+		return true, nil
+	}
+	return false, gorm.ErrRecordNotFound
+}
+
+type verificationTest struct {
+	clock         *fakeClock
+	jobs          *fakeJobService
+	profiles      *fakeProfileRepository
+	verifications *fakeProfileVerificationRepository
+	service       IVerificationService
+}
+
+// newTestVerificationService gives ana a profile with an approved photo
+func newTestVerificationService(t *testing.T, matcher core.FaceMatcher) *verificationTest {
+	t.Helper()
+	clock := newFakeClock()
+	jobs := newFakeJobService(clock)
+	profiles := &fakeProfileRepository{profiles: map[string]models.Profile{"ana": {ID: "ana", Name: "Ana"}}}
+	photos := &fakeProfilePhotoRepository{photos: []models.ProfilePhoto{
+		{ID: 1, UserID: "ana", FileID: "full-1", Path: "ana/full-1.jpg", Status: models.ProfilePhotoStatusApproved},
+	}}
+	verifications := &fakeProfileVerificationRepository{profiles: profiles}
+	env := &core.Env{VerificationChallengeTTL: 10 * time.Minute}
+	service := NewVerificationService(env, newTestLogger(), clock, verifications, profiles, photos, jobs, newFakeBlobStore(), matcher)
+	return &verificationTest{clock: clock, jobs: jobs, profiles: profiles, verifications: verifications, service: service}
+}
+
+func (v *verificationTest) submitSelfie(t *testing.T) *models.ProfileVerification {
+	t.Helper()
+	if _, err := v.service.RequestChallenge("ana"); err != nil {
+		t.Fatalf("RequestChallenge() error = %v", err)
+	}
+	verification, err := v.service.SubmitSelfie(context.Background(), "ana", models.ProfilePhotoUpload{Filename: "selfie.jpg", Content: testJPEG(t, 400, 400)})
+	if err != nil {
+		t.Fatalf("SubmitSelfie() error = %v", err)
+	}
+	if verification.Status != models.ProfileVerificationStatusPending {
+		t.Fatalf("status = %s after the selfie, want pending", verification.Status)
+	}
+	return verification
+}
+
+func TestVerificationService_ChallengeExpires(t *testing.T) {
+	v := newTestVerificationService(t, core.NewManualFaceMatcher())
+	upload := models.ProfilePhotoUpload{Filename: "selfie.jpg", Content: testJPEG(t, 400, 400)}
+
+	if _, err := v.service.SubmitSelfie(context.Background(), "ana", upload); !errors.Is(err, ErrVerificationChallengeNotFound) {
+		t.Fatalf("SubmitSelfie() without a challenge error = %v, want %v", err, ErrVerificationChallengeNotFound)
+	}
+
+	challenge, err := v.service.RequestChallenge("ana")
+	if err != nil {
+		t.Fatalf("RequestChallenge() error = %v", err)
+	}
+	if _, ok := models.VerificationPoses[challenge.Pose]; !ok {
+		t.Fatalf("pose = %q, want one of the verification poses", challenge.Pose)
+	}
+	if !challenge.ChallengeExpiresAt.Equal(testNow.Add(10 * time.Minute)) {
+		t.Fatalf("challenge expires at %v, want in 10m", challenge.ChallengeExpiresAt)
+	}
+
+	v.clock.Advance(10*time.Minute + time.Second)
+	if _, err = v.service.SubmitSelfie(context.Background(), "ana", upload); !errors.Is(err, ErrVerificationChallengeExpired) {
+		t.Fatalf("SubmitSelfie() after the challenge expired error = %v, want %v", err, ErrVerificationChallengeExpired)
+	}
+
+	// a new challenge replaces the expired one
+	if _, err = v.service.RequestChallenge("ana"); err != nil {
+		t.Fatalf("RequestChallenge() error = %v", err)
+	}
+	if _, err = v.service.SubmitSelfie(context.Background(), "ana", upload); err != nil {
+		t.Fatalf("SubmitSelfie() error = %v", err)
+	}
+}
+
+func TestVerificationService_OneSelfieInReview(t *testing.T) {
+	v := newTestVerificationService(t, core.NewManualFaceMatcher())
+	v.submitSelfie(t)
+
+	if _, err := v.service.RequestChallenge("ana"); !errors.Is(err, ErrVerificationInReview) {
+		t.Fatalf("RequestChallenge() while a selfie is pending error = %v, want %v", err, ErrVerificationInReview)
+	}
+
+	// the manual matcher leaves it to the admins
+	if errs := v.jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+	queue, err := v.service.ReviewQueue()
+	if err != nil {
+		t.Fatalf("ReviewQueue() error = %v", err)
+	}
+	if len(queue) != 1 || queue[0].UserID != "ana" {
+		t.Fatalf("review queue = %+v, want ana's selfie", queue)
+	}
+}
+
+func TestVerificationService_MatcherApproves(t *testing.T) {
+	v := newTestVerificationService(t, core.NewFakeFaceMatcher())
+	submitted := v.submitSelfie(t)
+
+	v.clock.Advance(time.Minute)
+	if errs := v.jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+
+	verification, _ := v.verifications.First(submitted.ID)
+	if verification.Status != models.ProfileVerificationStatusApproved || verification.ReviewedBy != models.ProfileVerificationReviewerMatcher {
+		t.Fatalf("verification %s by %s, want approved by the matcher", verification.Status, verification.ReviewedBy)
+	}
+	verifiedAt := v.profiles.profiles["ana"].VerifiedAt
+	if verifiedAt == nil || !verifiedAt.Equal(testNow.Add(time.Minute)) {
+		t.Fatalf("profile verified at %v, want at the match", verifiedAt)
+	}
+	if _, err := v.service.RequestChallenge("ana"); !errors.Is(err, ErrVerificationAlreadyVerified) {
+		t.Fatalf("RequestChallenge() of a verified profile error = %v, want %v", err, ErrVerificationAlreadyVerified)
+	}
+}
+
+func TestVerificationService_MatcherRejectionKeepsTheReason(t *testing.T) {
+	matcher := &core.FakeFaceMatcher{Verdict: core.FaceMatchVerdict{Status: core.FaceMatchRejected, Reason: "no face in the selfie"}}
+	v := newTestVerificationService(t, matcher)
+	submitted := v.submitSelfie(t)
+
+	if errs := v.jobs.RunDue(t); len(errs) > 0 {
+		t.Fatalf("RunDue() errors = %v", errs)
+	}
+
+	latest, err := v.service.Latest("ana")
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if latest.ID != submitted.ID || latest.Status != models.ProfileVerificationStatusRejected || latest.Reason != "no face in the selfie" {
+		t.Fatalf("latest verification = %+v, want rejected with the matcher's reason", latest)
+	}
+	if v.profiles.profiles["ana"].VerifiedAt != nil {
+		t.Fatal("a rejected selfie verified the profile")
+	}
+	// a rejection doesn't block another try
+	if _, err = v.service.RequestChallenge("ana"); err != nil {
+		t.Fatalf("RequestChallenge() after a rejection error = %v", err)
+	}
+}
+
+func TestVerificationService_MatchAfterAdminReview(t *testing.T) {
+	v := newTestVerificationService(t, core.NewFakeFaceMatcher())
+	submitted := v.submitSelfie(t)
+
+	if err := v.service.Reject(submitted.ID, "admin-1", "the pose doesn't match"); err != nil {
+		t.Fatalf("Reject() error = %v", err)
+	}
+	// the matcher would approve, but the admin got there first
+	if err := v.service.Match(context.Background(), submitted.ID); err != nil {
+		t.Fatalf("Match() after the review error = %v", err)
+	}
+
+	verification, _ := v.verifications.First(submitted.ID)
+	if verification.Status != models.ProfileVerificationStatusRejected || verification.ReviewedBy != "admin-1" || verification.Reason != "the pose doesn't match" {
+		t.Fatalf("verification = %+v, want the admin's rejection", verification)
+	}
+	if v.profiles.profiles["ana"].VerifiedAt != nil {
+		t.Fatal("the matcher verified a profile the admin rejected")
+	}
+	if err := v.service.Approve(submitted.ID, "admin-2"); !errors.Is(err, ErrVerificationNotPending) {
+		t.Fatalf("Approve() of a reviewed selfie error = %v, want %v", err, ErrVerificationNotPending)
+	}
+}
@@ -16,3 +16,22 @@ func CalculateDistance(lon1, lat1, lon2, lat2 float64) float64 {
 
 	return R * c // Distance in kilometers
 }
+
+// RoundDistance is the distance shown to other users, rounded up into buckets that grow with
+// the distance: 2 km at least, then steps of 1, 5 and 10 km past 10 and 50 km. Profiles hiding
+// their exact distance get steps of 10 km at least.
+func RoundDistance(distance float64, coarse bool) float64 {
+	step := 1.0
+	switch {
+	case distance >= 50:
+		step = 10
+	case distance >= 10:
+		step = 5
+	}
+	if coarse && step < 10 {
+		step = 10
+	}
+
+	rounded := math.Ceil(distance/step) * step
+	return math.Max(rounded, math.Max(step, 2))
+}
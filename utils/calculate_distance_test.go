@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRoundDistance(t *testing.T) {
+	tests := []struct {
+		distance float64
+		coarse   bool
+		want     float64
+	}{
+		{0, false, 2},
+		{0.3, false, 2},
+		{2, false, 2},
+		{2.1, false, 3},
+		{9.99, false, 10},
+		// 5 km steps from 10 km
+		{10, false, 10},
+		{10.1, false, 15},
+		{49.9, false, 50},
+		// 10 km steps from 50 km
+		{50, false, 50},
+		{50.1, false, 60},
+		{123, false, 130},
+		// hide_distance always uses 10 km steps
+		{0, true, 10},
+		{2.1, true, 10},
+		{9.99, true, 10},
+		{10, true, 10},
+		{10.1, true, 20},
+		{49.9, true, 50},
+		{50.1, true, 60},
+		{123, true, 130},
+	}
+
+	for _, tt := range tests {
+		if got := RoundDistance(tt.distance, tt.coarse); got != tt.want {
+			t.Errorf("RoundDistance(%v, %v) = %v, want %v", tt.distance, tt.coarse, got, tt.want)
+		}
+	}
+}
+
+func TestRoundDistanceNeverShowsLess(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		distance := random.Float64() * 200
+		for _, coarse := range []bool{false, true} {
+			if got := RoundDistance(distance, coarse); got < distance {
+				t.Fatalf("RoundDistance(%v, %v) = %v, less than the distance", distance, coarse, got)
+			}
+		}
+	}
+}
+
+func TestSnapCoordinates(t *testing.T) {
+	tests := []struct {
+		longitude, latitude         float64
+		wantLongitude, wantLatitude float64
+	}{
+		{105.85423, 21.02849, 105.85, 21.03},
+		{105.85, 21.03, 105.85, 21.03},
+		{-0.1249, -33.8681, -0.12, -33.87},
+		{179.998, -89.996, 180, -90},
+		{0.004, -0.004, 0, 0},
+	}
+
+	for _, tt := range tests {
+		longitude, latitude := SnapCoordinates(tt.longitude, tt.latitude)
+		if math.Abs(longitude-tt.wantLongitude) > 1e-9 || math.Abs(latitude-tt.wantLatitude) > 1e-9 {
+			t.Errorf("SnapCoordinates(%v, %v) = %v, %v, want %v, %v",
+				tt.longitude, tt.latitude, longitude, latitude, tt.wantLongitude, tt.wantLatitude)
+		}
+	}
+}
+
+func TestSnapCoordinatesStaysInTheCell(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		longitude, latitude := random.Float64()*360-180, random.Float64()*180-90
+		snappedLongitude, snappedLatitude := SnapCoordinates(longitude, latitude)
+		if math.Abs(snappedLongitude-longitude) > CoordinateGridStep/2+1e-9 || math.Abs(snappedLatitude-latitude) > CoordinateGridStep/2+1e-9 {
+			t.Fatalf("SnapCoordinates(%v, %v) = %v, %v, further than half a step", longitude, latitude, snappedLongitude, snappedLatitude)
+		}
+		// snapping is idempotent, stored coordinates don't drift when saved again
+		if again, _ := SnapCoordinates(snappedLongitude, snappedLatitude); again != snappedLongitude {
+			t.Fatalf("snapping %v again gave %v", snappedLongitude, again)
+		}
+	}
+}
+
+func TestReverseGeocode(t *testing.T) {
+	tests := []struct {
+		name                string
+		longitude, latitude float64
+		want                string
+	}{
+		{"city centre", 105.8542, 21.0285, "Hanoi, Vietnam"},
+		{"snapped point near the centre", 105.85, 21.03, "Hanoi, Vietnam"},
+		{"Paris", 2.35, 48.86, "Paris, France"},
+		{"middle of the Pacific", -150, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReverseGeocode(tt.longitude, tt.latitude); got != tt.want {
+				t.Fatalf("ReverseGeocode(%v, %v) = %q, want %q", tt.longitude, tt.latitude, got, tt.want)
+			}
+		})
+	}
+}
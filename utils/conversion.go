@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
@@ -23,6 +26,18 @@ func VerifyPassword(hashedPassword string, candidatePassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(candidatePassword))
 }
 
+// HashToken digests a short-lived secret (verification code, one time password, ...) before
+// it is stored. Use CompareTokenHash to check a candidate against the stored value.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareTokenHash reports whether candidate hashes to hashedToken, in constant time
+func CompareTokenHash(hashedToken string, candidate string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashedToken), []byte(HashToken(candidate))) == 1
+}
+
 // PascalToSnake eg: given input as "UserID", output will be "user_id"
 func PascalToSnake(input string) string {
 	// Use a regular expression to match capital letters and insert an underscore before them
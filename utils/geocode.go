@@ -0,0 +1,103 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// CoordinateGridStep is the size in degrees of the grid stored coordinates snap to, about 1 km
+	CoordinateGridStep = 0.01
+	// maxGeocodeDistance is how far in km the nearest city may be before the place is unknown
+	maxGeocodeDistance = 75
+)
+
+// cities.csv lists city centres as name,country,latitude,longitude, it is curated by hand
+//
+//go:embed data/cities.csv
+var citiesCSV string
+
+// City is an entry of the bundled city dataset
+type City struct {
+	Name      string  `json:"name"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Label names the city as "City, Country"
+func (c City) Label() string {
+	return c.Name + ", " + c.Country
+}
+
+var (
+	citiesOnce sync.Once
+	cities     []City
+)
+
+// SnapCoordinates moves coordinates to the nearest point of the CoordinateGridStep grid, which
+// is all the service keeps of where a user is
+func SnapCoordinates(longitude, latitude float64) (float64, float64) {
+	snap := func(value float64) float64 {
+		return math.Round(value/CoordinateGridStep) * CoordinateGridStep
+	}
+	return snap(longitude), snap(latitude)
+}
+
+// ReverseGeocode names the nearest bundled city as "City, Country", or returns "" when no city
+// is within maxGeocodeDistance
+func ReverseGeocode(longitude, latitude float64) string {
+	citiesOnce.Do(loadCities)
+
+	best, bestDistance := -1, math.MaxFloat64
+	for i, c := range cities {
+		distance := CalculateDistance(longitude, latitude, c.Longitude, c.Latitude)
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	if best < 0 || bestDistance > maxGeocodeDistance {
+		return ""
+	}
+	return cities[best].Label()
+}
+
+// Cities lists the bundled cities in the order of the dataset
+func Cities() []City {
+	citiesOnce.Do(loadCities)
+	return append([]City(nil), cities...)
+}
+
+// FindCity looks a city up by its label, ignoring case
+func FindCity(label string) (City, bool) {
+	citiesOnce.Do(loadCities)
+	for _, c := range cities {
+		if strings.EqualFold(c.Label(), strings.TrimSpace(label)) {
+			return c, true
+		}
+	}
+	return City{}, false
+}
+
+// loadCities panics on a broken dataset, it is part of the binary
+func loadCities() {
+	records, err := csv.NewReader(strings.NewReader(citiesCSV)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, record := range records[1:] {
+		latitude, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			panic(err)
+		}
+		longitude, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			panic(err)
+		}
+		cities = append(cities, City{Name: record[0], Country: record[1], Latitude: latitude, Longitude: longitude})
+	}
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	MinImageDimension = 200
+	MaxImageDimension = 10000
+	// a decoded png takes 4 bytes a pixel, 100 MB at the cap
+	MaxImagePixels   = 25_000_000
+	imageJPEGQuality = 85
+)
+
+var (
+	ErrImageUnsupported = errors.New("file is not a jpeg or png image")
+	ErrImageDimensions  = errors.New("image dimensions are out of range")
+)
+
+// ImageSize is the bounding box of a standard size, images are scaled down to fit it
+// and never scaled up
+type ImageSize struct {
+	Name      string
+	MaxWidth  uint
+	MaxHeight uint
+}
+
+var (
+	ImageSizeThumbnail = ImageSize{Name: "thumbnail", MaxWidth: 240, MaxHeight: 240}
+	ImageSizeCard      = ImageSize{Name: "card", MaxWidth: 720, MaxHeight: 960}
+	ImageSizeFull      = ImageSize{Name: "full", MaxWidth: 1600, MaxHeight: 1600}
+)
+
+// ImageVariant is one encoded size of a processed image
+type ImageVariant struct {
+	Size        ImageSize
+	Content     []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// ProcessedImage holds the standard sizes of an upload
+type ProcessedImage struct {
+	Thumbnail ImageVariant
+	Card      ImageVariant
+	Full      ImageVariant
+}
+
+// ProcessImage validates an uploaded image and renders its standard sizes. The content type
+// is sniffed from the bytes, the dimensions are checked before the pixels are decoded, the
+// EXIF orientation is applied and, as every size is re-encoded from pixels, all metadata
+// (camera, GPS position, ...) is dropped.
+func ProcessImage(data []byte) (*ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, fmt.Errorf("%w, got %s", ErrImageUnsupported, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageUnsupported, err)
+	}
+	if config.Width < MinImageDimension || config.Height < MinImageDimension ||
+		config.Width > MaxImageDimension || config.Height > MaxImageDimension ||
+		config.Width*config.Height > MaxImagePixels {
+		return nil, fmt.Errorf("%w: %dx%d, each side must be between %d and %d pixels and the image at most %d pixels",
+			ErrImageDimensions, config.Width, config.Height, MinImageDimension, MaxImageDimension, MaxImagePixels)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageUnsupported, err)
+	}
+
+	// every standard size fits in the full one, scaling down to it first keeps the flattened
+	// and turned copies small
+	decoded = resize.Thumbnail(ImageSizeFull.MaxWidth, ImageSizeFull.MaxHeight, decoded, resize.Lanczos3)
+
+	orientation := OrientationNormal
+	if contentType == "image/jpeg" {
+		orientation = JPEGOrientation(data)
+	}
+	img := orient(flatten(decoded), orientation)
+
+	var processed ProcessedImage
+	for _, variant := range []struct {
+		size   ImageSize
+		target *ImageVariant
+	}{
+		{ImageSizeThumbnail, &processed.Thumbnail},
+		{ImageSizeCard, &processed.Card},
+		{ImageSizeFull, &processed.Full},
+	} {
+		if *variant.target, err = encodeVariant(img, variant.size); err != nil {
+			return nil, err
+		}
+	}
+	return &processed, nil
+}
+
+// ----------------- private -----------------
+
+func encodeVariant(img image.Image, size ImageSize) (ImageVariant, error) {
+	scaled := resize.Thumbnail(size.MaxWidth, size.MaxHeight, img, resize.Lanczos3)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: imageJPEGQuality}); err != nil {
+		return ImageVariant{}, err
+	}
+
+	bounds := scaled.Bounds()
+	return ImageVariant{
+		Size:        size,
+		Content:     buf.Bytes(),
+		ContentType: "image/jpeg",
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}, nil
+}
+
+// flatten draws the image on white, jpeg has no transparency
+func flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// orient turns the pixels so that the image displays upright without its EXIF orientation
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= OrientationNormal || orientation > OrientationRotate270 {
+		return src
+	}
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= OrientationTranspose {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case OrientationFlipH:
+				dx, dy = w-1-x, y
+			case OrientationRotate180:
+				dx, dy = w-1-x, h-1-y
+			case OrientationFlipV:
+				dx, dy = x, h-1-y
+			case OrientationTranspose:
+				dx, dy = y, x
+			case OrientationRotate90:
+				dx, dy = h-1-y, x
+			case OrientationTransverse:
+				dx, dy = h-1-y, w-1-x
+			case OrientationRotate270:
+				dx, dy = y, w-1-x
+			}
+			s := src.PixOffset(x, y)
+			d := dst.PixOffset(dx, dy)
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
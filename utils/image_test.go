@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the image tests")
+
+const imageFixtures = "../testdata/images"
+
+// imageSummary is what the golden file keeps of a variant: its size and the colors seen
+// near the corners, which tell the orientation apart
+type imageSummary struct {
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Corners [4]string `json:"corners"`
+}
+
+var imagePalette = map[string]color.RGBA{
+	"red":    {220, 30, 30, 255},
+	"green":  {30, 200, 60, 255},
+	"blue":   {30, 60, 220, 255},
+	"yellow": {240, 220, 30, 255},
+	"white":  {255, 255, 255, 255},
+}
+
+func nearestColorName(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	best, bestDistance := "", -1
+	for name, p := range imagePalette {
+		dr, dg, db := int(r>>8)-int(p.R), int(g>>8)-int(p.G), int(b>>8)-int(p.B)
+		if distance := dr*dr + dg*dg + db*db; bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+func summarize(t *testing.T, variant ImageVariant) imageSummary {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(variant.Content))
+	if err != nil {
+		t.Fatalf("%s is not a jpeg: %v", variant.Size.Name, err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() != variant.Width || bounds.Dy() != variant.Height {
+		t.Fatalf("%s is %dx%d, the variant says %dx%d", variant.Size.Name, bounds.Dx(), bounds.Dy(), variant.Width, variant.Height)
+	}
+
+	// sample a quarter of the way in, away from the blurred borders between quadrants
+	left, right := bounds.Dx()/4, bounds.Dx()*3/4
+	top, bottom := bounds.Dy()/4, bounds.Dy()*3/4
+	return imageSummary{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Corners: [4]string{
+			nearestColorName(img.At(left, top)),
+			nearestColorName(img.At(right, top)),
+			nearestColorName(img.At(left, bottom)),
+			nearestColorName(img.At(right, bottom)),
+		},
+	}
+}
+
+// jpegMarkers lists the segment markers in front of the image data
+func jpegMarkers(data []byte) []byte {
+	var markers []byte
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF {
+		marker := data[offset+1]
+		markers = append(markers, marker)
+		if marker == 0xDA {
+			break
+		}
+		offset += 2 + int(data[offset+2])<<8 + int(data[offset+3])
+	}
+	return markers
+}
+
+func TestProcessImageGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join(imageFixtures, "*.[jp][pn]g"))
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("no image fixtures in %s: %v", imageFixtures, err)
+	}
+
+	got := make(map[string]map[string]imageSummary)
+	for _, fixture := range fixtures {
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		processed, err := ProcessImage(data)
+		if err != nil {
+			t.Fatalf("ProcessImage(%s) error = %v", fixture, err)
+		}
+
+		summaries := make(map[string]imageSummary)
+		for _, variant := range []ImageVariant{processed.Thumbnail, processed.Card, processed.Full} {
+			if variant.ContentType != "image/jpeg" {
+				t.Fatalf("%s %s content type = %s", fixture, variant.Size.Name, variant.ContentType)
+			}
+			if variant.Width > int(variant.Size.MaxWidth) || variant.Height > int(variant.Size.MaxHeight) {
+				t.Fatalf("%s %s is %dx%d, larger than its size", fixture, variant.Size.Name, variant.Width, variant.Height)
+			}
+			summaries[variant.Size.Name] = summarize(t, variant)
+		}
+		got[filepath.Base(fixture)] = summaries
+	}
+
+	golden := filepath.Join(imageFixtures, "golden.json")
+	if *updateGolden {
+		data, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(golden, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	var want map[string]map[string]imageSummary
+	if err = json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, summaries := range want {
+		if _, ok := got[name]; !ok {
+			t.Errorf("%s is in the golden file but not in %s", name, imageFixtures)
+		}
+		for size, summary := range summaries {
+			if got[name][size] != summary {
+				t.Errorf("%s %s = %+v, want %+v", name, size, got[name][size], summary)
+			}
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s has no golden entry, run the tests with -update", name)
+		}
+	}
+}
+
+func TestProcessImageStripsMetadata(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(imageFixtures, "large_gps.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fixture has to carry what we expect to be gone
+	if !bytes.Contains(data, []byte("WingletsTestCam")) || bytes.IndexByte(jpegMarkers(data), 0xE1) < 0 {
+		t.Fatal("large_gps.jpg lost its EXIF segment")
+	}
+
+	processed, err := ProcessImage(data)
+	if err != nil {
+		t.Fatalf("ProcessImage() error = %v", err)
+	}
+
+	for _, variant := range []ImageVariant{processed.Thumbnail, processed.Card, processed.Full} {
+		for _, marker := range jpegMarkers(variant.Content) {
+			// APP1 holds EXIF and XMP, APP13 IPTC
+			if marker == 0xE1 || marker == 0xED {
+				t.Errorf("%s keeps metadata segment 0x%X", variant.Size.Name, marker)
+			}
+		}
+		if bytes.Contains(variant.Content, []byte("WingletsTestCam")) || bytes.Contains(variant.Content, []byte("Exif")) {
+			t.Errorf("%s keeps the camera metadata", variant.Size.Name)
+		}
+		if JPEGOrientation(variant.Content) != OrientationNormal {
+			t.Errorf("%s keeps an orientation tag", variant.Size.Name)
+		}
+	}
+}
+
+func TestJPEGOrientationOfFixtures(t *testing.T) {
+	for orientation := OrientationNormal; orientation <= OrientationRotate270; orientation++ {
+		data, err := os.ReadFile(filepath.Join(imageFixtures, "orientation_"+string(rune('0'+orientation))+".jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := JPEGOrientation(data); got != orientation {
+			t.Errorf("orientation_%d.jpg: JPEGOrientation() = %d", orientation, got)
+		}
+	}
+}
+
+// pngHeader is the start of a png of the given size, enough for image.DecodeConfig
+func pngHeader(width, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	// 8 bit rgba, no interlace
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	small := image.NewRGBA(image.Rect(0, 0, MinImageDimension-1, 400))
+	var smallJPEG bytes.Buffer
+	if err := jpeg.Encode(&smallJPEG, small, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not an image", []byte("GIF89a, or anything else"), ErrImageUnsupported},
+		{"truncated jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00}, ErrImageUnsupported},
+		{"too narrow", smallJPEG.Bytes(), ErrImageDimensions},
+		{"too many pixels", pngHeader(6000, 5000), ErrImageDimensions},
+		// passes the size checks, then has no pixels to decode
+		{"at the pixel cap", pngHeader(5000, 5000), ErrImageUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ProcessImage(tt.data); !errors.Is(err, tt.want) {
+				t.Fatalf("ProcessImage() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
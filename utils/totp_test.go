@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 Appendix B, "12345678901234567890" in ascii
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// the 8 digit codes of RFC 6238 Appendix B, mod 10^6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() at %d error = %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCode_AcceptsPaddingAndLowerCase(t *testing.T) {
+	secret := "gezdgnbvgy3tqojqgezdgnbvgy3tqojq===="
+	got, err := TOTPCode(secret, TOTPStep(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Fatalf("TOTPCode() = %s, %v, want 287082", got, err)
+	}
+	if _, err = TOTPCode("not base32!", 1); err == nil {
+		t.Fatal("TOTPCode() of an invalid secret error = nil")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		skew   int64
+		want   bool
+	}{
+		{name: "current step", want: true},
+		{name: "previous step within the skew", offset: -1, skew: 1, want: true},
+		{name: "next step within the skew", offset: 1, skew: 1, want: true},
+		{name: "previous step without skew", offset: -1},
+		{name: "two steps back", offset: -2, skew: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfc6238Secret, step+tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matched, ok := ValidateTOTP(rfc6238Secret, code, now, tt.skew)
+			if ok != tt.want {
+				t.Fatalf("ValidateTOTP() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && matched != step+tt.offset {
+				t.Fatalf("ValidateTOTP() step = %d, want %d", matched, step+tt.offset)
+			}
+		})
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, "50471", now, 1); ok {
+		t.Fatal("ValidateTOTP() accepted a 5 digit code")
+	}
+}